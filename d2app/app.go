@@ -19,6 +19,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2loader/asset/types"
 
@@ -41,6 +42,7 @@ import (
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
 	"github.com/OpenDiablo2/OpenDiablo2/d2script"
 )
 
@@ -86,7 +88,10 @@ type Options struct {
 	Debug    *bool
 	profiler *string
 	Server   *d2networking.ServerOptions
+	Bots     *BotOptions
 	LogLevel *d2util.LogLevel
+	Record   *string // Demo file the packets received in a game are recorded into
+	PlayDemo *string // Demo file to play back instead of showing the main menu
 }
 
 const (
@@ -112,6 +117,7 @@ func Create(gitBranch, gitCommit string) *App {
 		gitCommit: gitCommit,
 		Options: &Options{
 			Server: &d2networking.ServerOptions{},
+			Bots:   &BotOptions{},
 		},
 	}
 	app.Infof("OpenDiablo2 - Open source Diablo 2 engine")
@@ -136,9 +142,9 @@ func (a *App) startDedicatedServer() error {
 	srvChanIn := make(chan int)
 	srvChanLog := make(chan string)
 
-	srvErr := d2networking.StartDedicatedServer(a.asset, srvChanIn, srvChanLog, *a.Options.LogLevel, maxPlayers)
-	if srvErr != nil {
-		return srvErr
+	conditions, err := d2netsim.ParseConditions(*a.Options.Server.NetSim)
+	if err != nil {
+		return err
 	}
 
 	c := make(chan os.Signal)
@@ -149,11 +155,26 @@ func (a *App) startDedicatedServer() error {
 		srvChanIn <- d2networking.ServerEventStop
 	}()
 
-	for {
+	// the server logs while it runs, which is until it is stopped
+	go func() {
 		for data := range srvChanLog {
 			a.Info(data)
 		}
-	}
+	}()
+
+	return d2networking.StartDedicatedServer(a.asset, srvChanIn, srvChanLog, *a.Options.LogLevel,
+		d2networking.DedicatedServerConfig{
+			MaxPlayers:  maxPlayers,
+			Conditions:  conditions,
+			BanFile:     *a.Options.Server.BanFile,
+			AdminSocket: *a.Options.Server.AdminSocket,
+			TLS:         *a.Options.Server.TLS,
+			CertFile:    *a.Options.Server.CertFile,
+			KeyFile:     *a.Options.Server.KeyFile,
+			Password:    *a.Options.Server.Password,
+			SaveDir:     *a.Options.Server.SaveDir,
+			Metrics:     *a.Options.Server.Metrics,
+		})
 }
 
 func (a *App) loadEngine() error {
@@ -196,6 +217,18 @@ func (a *App) parseArguments() {
 	const (
 		descProfile = "Profiles the program,\none of (cpu, mem, block, goroutine, trace, thread, mutex)"
 		descPlayers = "Sets the number of max players for the dedicated server"
+		descBanFile = "File the dedicated server keeps banned IP addresses in"
+		descAdmin   = "Unix socket the dedicated server accepts admin console commands on"
+		descTLS     = "Makes the clients of the dedicated server connect over TLS,\n" +
+			"they join it with tls://host"
+		descCert     = "PEM certificate of the dedicated server, a self-signed one is generated if it does not exist"
+		descPassword = "Makes the dedicated server private, players join it with password@host"
+		descMetrics  = "HTTP address the dedicated server serves Prometheus metrics on at /metrics,\n" +
+			"and its health at /health, for example :9669"
+		descBots   = "Runs this many headless bots against a server, to load test it"
+		descBotSrv = "Server the bots join, host[:port][/game[/password]]"
+		descNetSim = "Simulates network conditions between client and server,\n" +
+			"for example latency=100ms,jitter=20ms,loss=5%,dup=1%,reorder=2%"
 		descLogging = "Enables verbose logging. Log levels will include those below it.\n" +
 			" 0 disables log messages\n" +
 			" 1 shows fatal\n" +
@@ -208,7 +241,24 @@ func (a *App) parseArguments() {
 	a.Options.profiler = flag.String("profile", "", descProfile)
 	a.Options.Server.Dedicated = flag.Bool("dedicated", false, "Starts a dedicated server")
 	a.Options.Server.MaxPlayers = flag.Int("players", 0, descPlayers)
+	a.Options.Server.NetSim = flag.String("netsim", "", descNetSim)
+	a.Options.Server.BanFile = flag.String("banfile", "bans.txt", descBanFile)
+	a.Options.Server.AdminSocket = flag.String("adminsocket", "", descAdmin)
+	a.Options.Server.TLS = flag.Bool("tls", false, descTLS)
+	a.Options.Server.CertFile = flag.String("tlscert", "server.crt", descCert)
+	a.Options.Server.KeyFile = flag.String("tlskey", "server.key", "PEM private key of the certificate of the dedicated server")
+	a.Options.Server.Password = flag.String("serverpassword", "", descPassword)
+	a.Options.Server.Metrics = flag.String("metrics", "", descMetrics)
+	a.Options.Server.SaveDir = flag.String("savedir", "characters", "Directory the dedicated server keeps the characters of the players in")
+	a.Options.Bots.Count = flag.Int("bots", 0, descBots)
+	a.Options.Bots.Server = flag.String("botserver", "127.0.0.1", descBotSrv)
+	a.Options.Bots.Ramp = flag.Duration("botramp", 100*time.Millisecond, "Time between starting two bots")
+	a.Options.Bots.Interval = flag.Duration("botinterval", 2*time.Second, "Mean time between two actions of a bot")
+	a.Options.Bots.Duration = flag.Duration("botduration", 0, "How long the bots stay, until interrupted if zero")
+	a.Options.Bots.ReportEvery = flag.Duration("botreport", 10*time.Second, "Time between two reports of the bots")
 	a.Options.LogLevel = flag.Int("l", d2util.LogLevelDefault, descLogging)
+	a.Options.Record = flag.String("record", "", "Records the packets received in the game into a demo file")
+	a.Options.PlayDemo = flag.String("playdemo", "", "Plays back a demo file recorded with -record")
 	showVersion := flag.Bool("v", false, "Show version")
 	showHelp := flag.Bool("h", false, "Show help")
 
@@ -284,6 +334,11 @@ func (a *App) Run() (err error) {
 		}
 	}
 
+	// the bots run without a window
+	if *a.Options.Bots.Count > 0 {
+		return a.runBots()
+	}
+
 	// start the server if `--listen` option was supplied
 	if *a.Options.Server.Dedicated {
 		if err := a.startDedicatedServer(); err != nil {
@@ -312,7 +367,11 @@ func (a *App) Run() (err error) {
 		return err
 	}
 
-	a.ToMainMenu()
+	if *a.Options.PlayDemo != "" {
+		a.ToCreateGame("", d2clientconnectiontype.Demo, *a.Options.PlayDemo)
+	} else {
+		a.ToMainMenu()
+	}
 
 	if err := a.renderer.Run(a.update, a.advance, 800, 600, windowTitle); err != nil {
 		return err
@@ -629,8 +688,12 @@ func (a *App) ToCreateGame(filePath string, connType d2clientconnectiontype.Clie
 		return
 	}
 
+	if connType != d2clientconnectiontype.Demo {
+		a.prepareGameClient(gameClient)
+	}
+
 	if err = gameClient.Open(host, filePath); err != nil {
-		errorMessage := fmt.Sprintf("can not connect to the host: %s", host)
+		errorMessage := fmt.Sprintf("can not connect to the host: %s", err)
 		a.Error(errorMessage)
 		a.ToMainMenu(errorMessage)
 	} else {
@@ -645,6 +708,24 @@ func (a *App) ToCreateGame(filePath string, connType d2clientconnectiontype.Clie
 	}
 }
 
+// prepareGameClient sets up the network simulation and demo recording asked for on the command line.
+func (a *App) prepareGameClient(gameClient *d2client.GameClient) {
+	if conditions, err := d2netsim.ParseConditions(*a.Options.Server.NetSim); err != nil {
+		a.Error(err.Error())
+	} else if conditions.Enabled() {
+		a.Infof("simulating network conditions %s", conditions)
+		gameClient.SimulateNetwork(conditions)
+	}
+
+	if *a.Options.Record != "" {
+		if err := gameClient.RecordDemo(*a.Options.Record); err != nil {
+			a.Errorf("can not record the demo: %s", err)
+		} else {
+			a.Infof("recording the demo %s", *a.Options.Record)
+		}
+	}
+}
+
 // ToCharacterSelect forces the game to transition to the Character Select (load character) screen
 func (a *App) ToCharacterSelect(connType d2clientconnectiontype.ClientConnectionType, connHost string) {
 	characterSelect, err := d2gamescreen.CreateCharacterSelect(a, a.asset, a.renderer, a.inputManager,
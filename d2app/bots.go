@@ -0,0 +1,66 @@
+package d2app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2bot"
+)
+
+// BotOptions are the options of the headless bots, which load test a dedicated server.
+type BotOptions struct {
+	Count       *int
+	Server      *string        // Connection string of the server, host[:port][/game[/password]]
+	Ramp        *time.Duration // Time between starting two bots
+	Interval    *time.Duration // Mean time between two actions of a bot
+	Duration    *time.Duration // How long the bots stay, zero until interrupted
+	ReportEvery *time.Duration
+}
+
+// runBots loads the game data, without a window, and runs the bots against the server
+// until the duration passed or the process is interrupted.
+func (a *App) runBots() error {
+	if err := a.initConfig(a.config); err != nil {
+		return err
+	}
+
+	a.initLanguage()
+
+	if err := a.initDataDictionaries(); err != nil {
+		return err
+	}
+
+	swarm, err := d2bot.NewSwarm(d2bot.Config{
+		Connection:  *a.Options.Bots.Server,
+		Bots:        *a.Options.Bots.Count,
+		Ramp:        *a.Options.Bots.Ramp,
+		Interval:    *a.Options.Bots.Interval,
+		Duration:    *a.Options.Bots.Duration,
+		ReportEvery: *a.Options.Bots.ReportEvery,
+	}, a.asset, *a.Options.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM) // This traps Control-c to disconnect the bots
+
+	go func() {
+		<-c
+		cancel()
+	}()
+
+	a.Infof("Starting %d bots against %s", *a.Options.Bots.Count, *a.Options.Bots.Server)
+
+	swarm.Run(ctx, func(report d2bot.Report) {
+		a.Info(report.String())
+	})
+
+	return nil
+}
@@ -1,11 +1,15 @@
 package d2app
 
 import (
+	"fmt"
 	"os"
 	"runtime/pprof"
 	"strconv"
+	"strings"
 
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
 	"github.com/OpenDiablo2/OpenDiablo2/d2game/d2gamescreen"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2remoteclient"
 )
 
 func (a *App) initTerminalCommands() {
@@ -26,6 +30,10 @@ func (a *App) initTerminalCommands() {
 		{"quit", "exits the game", nil, a.quitGame},
 		{"screen-gui", "enters the gui playground screen", nil, a.enterGuiPlayground},
 		{"js", "eval JS scripts", []string{"code"}, a.evalJS},
+		{"games", "lists the games of a server", []string{"host"}, a.listGames},
+		{"creategame", "creates a game on a server", []string{"host", "name", "difficulty"}, a.createGame},
+		{"createprivategame", "creates a game with a password on a server",
+			[]string{"host", "name", "difficulty", "password"}, a.createGame},
 	}
 
 	for _, cmd := range terminalCommands {
@@ -137,3 +145,66 @@ func (a *App) enterGuiPlayground([]string) error {
 	a.screen.SetNextScreen(d2gamescreen.CreateGuiTestMain(a.renderer, a.guiManager, *a.Options.LogLevel, a.asset))
 	return nil
 }
+
+func (a *App) listGames(args []string) error {
+	games, err := d2remoteclient.ListGames(args[0])
+	if err != nil {
+		a.terminal.Errorf(err.Error())
+		return nil
+	}
+
+	if len(games) == 0 {
+		a.terminal.Infof("no games, create one with creategame")
+		return nil
+	}
+
+	for _, game := range games {
+		line := fmt.Sprintf("%s  %s  %d/%d  %s", game.ID, game.Name, game.Players, game.MaxPlayers, game.Difficulty)
+		if game.HasPassword {
+			line += "  password"
+		}
+
+		a.terminal.Infof(line)
+	}
+
+	return nil
+}
+
+// createGame creates a game with the name and difficulty, and the password if one is given.
+func (a *App) createGame(args []string) error {
+	host, name, password := args[0], args[1], ""
+	if len(args) > 3 { // nolint:gomnd // host, name, difficulty and password
+		password = args[3]
+	}
+
+	difficulty, err := parseDifficulty(args[2])
+	if err != nil {
+		a.terminal.Errorf(err.Error())
+		return nil
+	}
+
+	id, err := d2remoteclient.CreateGame(host, name, difficulty, password, 0)
+	if err != nil {
+		a.terminal.Errorf(err.Error())
+		return nil
+	}
+
+	address := host + "/" + id
+	if password != "" {
+		address += "/" + password
+	}
+
+	a.terminal.Infof("created game %s, join it at %s", id, address)
+
+	return nil
+}
+
+func parseDifficulty(name string) (d2enum.DifficultyType, error) {
+	for d := d2enum.DifficultyNormal; d <= d2enum.DifficultyHell; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d, nil
+		}
+	}
+
+	return d2enum.DifficultyNormal, fmt.Errorf("unknown difficulty %q, expected normal, nightmare or hell", name)
+}
@@ -41,6 +41,9 @@ func (c *Cache) SetVerbose(verbose bool) {
 
 // GetWeight gets the "weight" of a cache
 func (c *Cache) GetWeight() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	return c.weight
 }
 
@@ -11,3 +11,16 @@ const (
 	// DifficultyHell is the hell difficulty
 	DifficultyHell
 )
+
+func (d DifficultyType) String() string {
+	switch d {
+	case DifficultyNormal:
+		return "Normal"
+	case DifficultyNightmare:
+		return "Nightmare"
+	case DifficultyHell:
+		return "Hell"
+	}
+
+	return "Unknown"
+}
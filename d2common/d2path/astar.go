@@ -0,0 +1,277 @@
+package d2path
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+)
+
+const (
+	// DefaultMaxNodes is the default number of nodes the path finder may expand before it gives up
+	// and returns a path to the closest point it has found.
+	DefaultMaxNodes = 10000
+
+	straightCost  = 1.0
+	diagonalCost  = math.Sqrt2
+	subTileCenter = 0.5
+)
+
+// Walkable reports whether the sub tile at the given sub tile coordinates can be walked on.
+type Walkable func(x, y int) bool
+
+type point struct {
+	x, y int
+}
+
+// neighbours lists the eight directions a path can move in, orthogonal directions first.
+// nolint:gochecknoglobals // constant lookup table
+var neighbours = [...]point{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+type node struct {
+	point
+	g, f   float64
+	parent *node
+	index  int
+	closed bool
+}
+
+// FindPath finds a path over the sub tile grid from start to dest using A*. Diagonal moves are
+// allowed as long as they do not cut a blocked corner. The resulting path is smoothed using line
+// of sight, so that only the points where the path changes direction are returned. The start
+// position is not included.
+//
+// At most maxNodes nodes are expanded. If dest cannot be reached, the path leads to the
+// reachable sub tile closest to it. A nil path is returned if no movement is possible.
+func FindPath(start, dest d2vector.Position, walkable Walkable, maxNodes int) []d2vector.Position {
+	from := toPoint(start)
+	to := toPoint(dest)
+
+	if from == to {
+		if walkable(to.x, to.y) {
+			return []d2vector.Position{dest}
+		}
+
+		return nil
+	}
+
+	goal, reached := search(from, to, walkable, maxNodes)
+	if goal.parent == nil {
+		return nil
+	}
+
+	cells := make([]d2vector.Position, 0)
+	for n := goal; n.parent != nil; n = n.parent {
+		cells = append(cells, cellCenter(n.point))
+	}
+
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+
+	if reached {
+		cells[len(cells)-1] = dest
+	}
+
+	return smooth(start, cells, walkable)
+}
+
+// search runs A* from start to goal. It returns the goal node and true if it was reached, or
+// otherwise the expanded node closest to the goal and false.
+func search(start, goal point, walkable Walkable, maxNodes int) (result *node, reached bool) {
+	nodes := make(map[point]*node)
+	open := &nodeQueue{}
+
+	first := &node{point: start, f: heuristic(start, goal)}
+	nodes[start] = first
+	heap.Push(open, first)
+
+	best := first
+	bestH := first.f
+
+	for expanded := 0; open.Len() > 0 && expanded < maxNodes; expanded++ {
+		current := heap.Pop(open).(*node)
+		current.closed = true
+
+		if current.point == goal {
+			return current, true
+		}
+
+		if h := current.f - current.g; h < bestH {
+			best, bestH = current, h
+		}
+
+		for _, dir := range neighbours {
+			next := point{current.x + dir.x, current.y + dir.y}
+			if !walkable(next.x, next.y) {
+				continue
+			}
+
+			cost := straightCost
+
+			if dir.x != 0 && dir.y != 0 {
+				// Do not cut corners around blocked sub tiles
+				if !walkable(current.x+dir.x, current.y) || !walkable(current.x, current.y+dir.y) {
+					continue
+				}
+
+				cost = diagonalCost
+			}
+
+			g := current.g + cost
+			n, found := nodes[next]
+
+			switch {
+			case !found:
+				n = &node{point: next, g: g, f: g + heuristic(next, goal), parent: current}
+				nodes[next] = n
+				heap.Push(open, n)
+			case !n.closed && g < n.g:
+				n.f += g - n.g
+				n.g = g
+				n.parent = current
+				heap.Fix(open, n.index)
+			}
+		}
+	}
+
+	return best, false
+}
+
+// smooth removes every point of the path which can be skipped by walking in a straight line.
+func smooth(start d2vector.Position, path []d2vector.Position, walkable Walkable) []d2vector.Position {
+	result := make([]d2vector.Position, 0)
+	anchor := start
+
+	for i := 0; i < len(path); {
+		last := i
+
+		for j := i + 1; j < len(path); j++ {
+			if !LineOfSight(anchor, path[j], walkable) {
+				break
+			}
+
+			last = j
+		}
+
+		result = append(result, path[last])
+		anchor = path[last]
+		i = last + 1
+	}
+
+	return result
+}
+
+// LineOfSight reports whether a straight line between the two positions only crosses walkable
+// sub tiles. Lines passing exactly through the corner of a sub tile require both sub tiles
+// beside the corner to be walkable.
+func LineOfSight(from, to d2vector.Position, walkable Walkable) bool {
+	x0, y0 := from.X(), from.Y()
+	x1, y1 := to.X(), to.Y()
+
+	cell := toPoint(from)
+	end := toPoint(to)
+
+	if !walkable(cell.x, cell.y) {
+		return false
+	}
+
+	stepX, maxX, deltaX := traversal(x0, x1-x0)
+	stepY, maxY, deltaY := traversal(y0, y1-y0)
+
+	steps := abs(end.x-cell.x) + abs(end.y-cell.y)
+
+	for i := 0; i < steps && cell != end; i++ {
+		switch {
+		case maxX < maxY:
+			cell.x += stepX
+			maxX += deltaX
+		case maxY < maxX:
+			cell.y += stepY
+			maxY += deltaY
+		default:
+			if !walkable(cell.x+stepX, cell.y) || !walkable(cell.x, cell.y+stepY) {
+				return false
+			}
+
+			cell.x += stepX
+			cell.y += stepY
+			maxX += deltaX
+			maxY += deltaY
+		}
+
+		if !walkable(cell.x, cell.y) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// traversal returns the grid step direction, the line parameter at which the first grid line is
+// crossed and the parameter distance between grid lines for one axis of a line.
+func traversal(origin, delta float64) (step int, first, interval float64) {
+	switch {
+	case delta > 0:
+		return 1, (math.Floor(origin) + 1 - origin) / delta, 1 / delta
+	case delta < 0:
+		return -1, (origin - math.Floor(origin)) / -delta, 1 / -delta
+	default:
+		return 0, math.Inf(1), math.Inf(1)
+	}
+}
+
+// heuristic is the octile distance between two points.
+func heuristic(a, b point) float64 {
+	dx := float64(abs(a.x - b.x))
+	dy := float64(abs(a.y - b.y))
+
+	return straightCost*(dx+dy) + (diagonalCost-2*straightCost)*math.Min(dx, dy)
+}
+
+func toPoint(p d2vector.Position) point {
+	return point{int(math.Floor(p.X())), int(math.Floor(p.Y()))}
+}
+
+func cellCenter(p point) d2vector.Position {
+	return d2vector.NewPosition(float64(p.x)+subTileCenter, float64(p.y)+subTileCenter)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+// nodeQueue is a priority queue of nodes ordered by their estimated total cost.
+type nodeQueue []*node
+
+func (q nodeQueue) Len() int { return len(q) }
+
+func (q nodeQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+
+func (q nodeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *nodeQueue) Push(x interface{}) {
+	n := x.(*node)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+
+	return n
+}
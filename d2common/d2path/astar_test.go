@@ -0,0 +1,154 @@
+package d2path
+
+import (
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+)
+
+// gridWalkable builds a Walkable from rows of text where '#' marks a blocked sub tile.
+func gridWalkable(rows ...string) Walkable {
+	return func(x, y int) bool {
+		if y < 0 || y >= len(rows) || x < 0 || x >= len(rows[y]) {
+			return false
+		}
+
+		return rows[y][x] != '#'
+	}
+}
+
+func checkPath(t *testing.T, start d2vector.Position, path []d2vector.Position, walkable Walkable) {
+	t.Helper()
+
+	from := start
+
+	for _, p := range path {
+		if !LineOfSight(from, p, walkable) {
+			t.Fatalf("path segment %s -> %s crosses a blocked sub tile", from.Vector, p.Vector)
+		}
+
+		from = p
+	}
+}
+
+func TestFindPathStraight(t *testing.T) {
+	walkable := gridWalkable(
+		"..........",
+		"..........",
+	)
+	start := d2vector.NewPosition(0.5, 0.5)
+	dest := d2vector.NewPosition(9.5, 0.5)
+
+	path := FindPath(start, dest, walkable, DefaultMaxNodes)
+
+	if len(path) != 1 {
+		t.Fatalf("expected a single point for an unobstructed path, got %d", len(path))
+	}
+
+	if !path[0].Equals(&dest.Vector) {
+		t.Errorf("expected path to end at %s, got %s", dest.Vector, path[0].Vector)
+	}
+}
+
+func TestFindPathAroundWall(t *testing.T) {
+	walkable := gridWalkable(
+		"....#.....",
+		"....#.....",
+		"....#.....",
+		"....#.....",
+		"..........",
+	)
+	start := d2vector.NewPosition(1.5, 0.5)
+	dest := d2vector.NewPosition(8.5, 0.5)
+
+	path := FindPath(start, dest, walkable, DefaultMaxNodes)
+
+	if len(path) < 2 {
+		t.Fatalf("expected the path to turn around the wall, got %d points", len(path))
+	}
+
+	if last := path[len(path)-1]; !last.Equals(&dest.Vector) {
+		t.Errorf("expected path to end at %s, got %s", dest.Vector, last.Vector)
+	}
+
+	checkPath(t, start, path, walkable)
+}
+
+func TestFindPathNoCornerCutting(t *testing.T) {
+	walkable := gridWalkable(
+		".#",
+		"#.",
+	)
+	start := d2vector.NewPosition(0.5, 0.5)
+	dest := d2vector.NewPosition(1.5, 1.5)
+
+	if path := FindPath(start, dest, walkable, DefaultMaxNodes); path != nil {
+		t.Errorf("expected no path through a blocked corner, got %v", path)
+	}
+}
+
+func TestFindPathUnreachable(t *testing.T) {
+	walkable := gridWalkable(
+		"...#..",
+		"...#..",
+		"...#..",
+	)
+	start := d2vector.NewPosition(0.5, 1.5)
+	dest := d2vector.NewPosition(5.5, 1.5)
+
+	path := FindPath(start, dest, walkable, DefaultMaxNodes)
+
+	if len(path) == 0 {
+		t.Fatal("expected a path to the closest reachable sub tile")
+	}
+
+	last := path[len(path)-1]
+	if want := d2vector.NewPosition(2.5, 1.5); !last.Equals(&want.Vector) {
+		t.Errorf("expected path to end next to the wall at %s, got %s", want.Vector, last.Vector)
+	}
+
+	checkPath(t, start, path, walkable)
+}
+
+func TestFindPathMaxNodes(t *testing.T) {
+	walkable := gridWalkable(
+		"....................",
+		"....................",
+	)
+	start := d2vector.NewPosition(0.5, 0.5)
+	dest := d2vector.NewPosition(19.5, 0.5)
+
+	path := FindPath(start, dest, walkable, 5)
+
+	if len(path) == 0 {
+		t.Fatal("expected a partial path when the node limit is reached")
+	}
+
+	if last := path[len(path)-1]; last.Equals(&dest.Vector) {
+		t.Error("expected the search to stop before reaching the destination")
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	walkable := gridWalkable(
+		".....",
+		"..#..",
+		".....",
+	)
+
+	tests := []struct {
+		from, to d2vector.Position
+		want     bool
+	}{
+		{d2vector.NewPosition(0.5, 0.5), d2vector.NewPosition(4.5, 0.5), true},
+		{d2vector.NewPosition(0.5, 1.5), d2vector.NewPosition(4.5, 1.5), false},
+		{d2vector.NewPosition(0.5, 0.5), d2vector.NewPosition(4.5, 2.5), false},
+		{d2vector.NewPosition(0.5, 2.5), d2vector.NewPosition(4.5, 2.5), true},
+	}
+
+	for _, test := range tests {
+		if got := LineOfSight(test.from, test.to, walkable); got != test.want {
+			t.Errorf("LineOfSight(%s, %s) = %v, want %v", test.from.Vector, test.to.Vector, got, test.want)
+		}
+	}
+}
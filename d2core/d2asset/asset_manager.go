@@ -86,6 +86,20 @@ func (am *AssetManager) SetLogLevel(level d2util.LogLevel) {
 	am.Loader.Logger.SetLevel(level)
 }
 
+// Caches returns the caches of the asset manager by the kind of asset they hold.
+func (am *AssetManager) Caches() map[string]d2interface.Cache {
+	return map[string]d2interface.Cache{
+		"animation":         am.animations,
+		"font":              am.fonts,
+		"palette":           am.palettes,
+		"palette_transform": am.transforms,
+		"dt1":               am.dt1s,
+		"ds1":               am.ds1s,
+		"cof":               am.cofs,
+		"dcc":               am.dccs,
+	}
+}
+
 // LoadAsset loads an asset
 func (am *AssetManager) LoadAsset(filePath string) (io.ReadSeeker, error) {
 	data, err := am.Loader.Load(filePath)
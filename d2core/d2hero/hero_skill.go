@@ -21,6 +21,15 @@ type shallowHeroSkill struct {
 	SkillPoints int `json:"skillPoints"`
 }
 
+// NewShallowHeroSkill returns a HeroSkill which only holds the skill ID and skill points, the
+// same as a HeroSkill which has been deserialized. Use HydrateSkills to load its records.
+func NewShallowHeroSkill(skillID, skillPoints int) *HeroSkill {
+	return &HeroSkill{
+		SkillPoints: skillPoints,
+		Shallow:     &shallowHeroSkill{SkillID: skillID, SkillPoints: skillPoints},
+	}
+}
+
 // MarshalJSON overrides the default logic used when the HeroSkill is serialized to a byte array.
 func (hs *HeroSkill) MarshalJSON() ([]byte, error) {
 	// only serialize the Shallow object instead of the SkillRecord & SkillDescriptionRecord
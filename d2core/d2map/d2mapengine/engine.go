@@ -34,6 +34,7 @@ type MapEngine struct {
 	startSubTileX int                       // Starting X position
 	startSubTileY int                       // Starting Y position
 	dt1Files      []string                  // List of DS1 strings
+	subLevel      int                       // Level whose substitutions apply to shared stamps
 
 	// https://github.com/OpenDiablo2/OpenDiablo2/issues/789
 	IsLoading bool // (temp) Whether we have processed the GenerateMapPacket(only for remote client)
@@ -78,6 +79,7 @@ func (m *MapEngine) ResetMap(levelType d2enum.RegionIdType, width, height int) {
 	m.tiles = make([]MapTile, width*height)
 	m.dt1TileData = make([]d2dt1.Tile, 0)
 	m.dt1Files = make([]string, 0)
+	m.subLevel = 0
 
 	for idx := range m.levelType.Files {
 		m.addDT1(m.levelType.Files[idx])
@@ -139,6 +141,13 @@ func (m *MapEngine) SetSeed(seed int64) {
 	m.seed = seed
 }
 
+// SetSubstitutionLevel sets the level whose substitutions, see lvlsub.txt, apply to the
+// stamps placed next which are not a level of their own, like the fill presets of a
+// wilderness. ResetMap clears it.
+func (m *MapEngine) SetSubstitutionLevel(levelID int) {
+	m.subLevel = levelID
+}
+
 // Size returns the size of the map in sub-tiles.
 func (m *MapEngine) Size() d2geom.Size {
 	return m.size
@@ -182,7 +191,14 @@ func (m *MapEngine) PlaceStamp(stamp *d2mapstamp.Stamp, tileOffsetX, tileOffsetY
 			stampTile := *stamp.Tile(x, y)
 			m.tiles[targetTileIndex].RegionType = stamp.RegionID()
 			m.tiles[targetTileIndex].Components = stampTile
-			m.tiles[targetTileIndex].PrepareTile(x, y, m)
+		}
+	}
+
+	m.substitute(stamp, tileOffsetX, tileOffsetY)
+
+	for y := 0; y < stampH; y++ {
+		for x := 0; x < stampW; x++ {
+			m.tiles[m.tileCoordinateToIndex(x+xMin, y+yMin)].PrepareTile(x, y, m)
 		}
 	}
 
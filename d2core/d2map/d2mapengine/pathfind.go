@@ -1,48 +1,25 @@
 package d2mapengine
 
 import (
-	"math"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2path"
 )
 
 // PathFind finds a path between given start and dest positions and returns the positions of the path
 func (m *MapEngine) PathFind(start, dest d2vector.Position) []d2vector.Position {
-	points := make([]d2vector.Position, 0)
-	_, point := m.checkLos(start, dest)
-	points = append(points, point)
-
-	return points
+	return d2path.FindPath(start, dest, m.IsWalkable, d2path.DefaultMaxNodes)
 }
 
-// checkLos finds out if there is a clear line of sight between two points
-func (m *MapEngine) checkLos(start, end d2vector.Position) (bool, d2vector.Position) {
-	dv := d2vector.Position{Vector: *end.Clone()}
-	dv.Subtract(&start.Vector)
-	dx := dv.X()
-	dy := dv.Y()
-	N := math.Max(math.Abs(dx), math.Abs(dy))
-
-	var divN float64
-	if N == 0 {
-		divN = 0.0
-	} else {
-		divN = 1.0 / N // nolint:gomnd // we're just taking inverse...
+// IsWalkable returns true if the given sub tile is inside the map and does not block walking
+func (m *MapEngine) IsWalkable(subX, subY int) bool {
+	if subX < 0 || subY < 0 || subX >= m.size.Width*subtilesPerTile || subY >= m.size.Height*subtilesPerTile {
+		return false
 	}
 
-	xstep := dx * divN
-	ystep := dy * divN
-	x := start.X()
-	y := start.Y()
-
-	for i := 0; i <= int(N); i++ {
-		x += xstep
-		y += ystep
-
-		if m.SubTileAt(int(math.Floor(x)), int(math.Floor(y))).BlockWalk {
-			return false, d2vector.NewPosition(x-xstep, y-ystep)
-		}
+	tile := m.TileAt(subX/subtilesPerTile, subY/subtilesPerTile)
+	if tile == nil {
+		return false
 	}
 
-	return true, end
+	return !tile.GetSubTileFlags(subX%subtilesPerTile, subY%subtilesPerTile).BlockWalk
 }
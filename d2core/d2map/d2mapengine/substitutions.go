@@ -0,0 +1,309 @@
+package d2mapengine
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2fileformats/d2ds1"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2geom"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapstamp"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+// substitutionPercent is what the chances of lvlsub.txt are out of.
+const substitutionPercent = 100
+
+// Special values of the Trials, Max and BordType columns of lvlsub.txt.
+const (
+	trialsEveryCell = -1 // One trial in every cell of the grid of the group
+	noMost          = -1 // As many substitutions as the group has room for
+	borderNone      = -1 // The substitution replaces floors, away from walls
+)
+
+// substitution is a block of tiles of a lvlsub.txt DS1 file which may replace tiles of
+// the map.
+type substitution struct {
+	ds1  *d2ds1.DS1
+	rect d2geom.Rectangle // in tiles of the DS1 file
+}
+
+// substitute replaces tiles in the substitution groups of a stamp placed at the given
+// tile with the substitutions of its level. Each lvlsub.txt record of the sub type of
+// the level has a number of trials in every group, or one in every cell of its grid,
+// each of which spawns one of the blocks of its DS1 file with a chance, up to a most
+// per group. The trials use the columns of the subtheme of the level, and are seeded
+// by the map seed and the tile, so every copy of a preset varies differently, but the
+// same way on every machine.
+func (m *MapEngine) substitute(stamp *d2mapstamp.Stamp, tileOffsetX, tileOffsetY int) {
+	groups := stamp.SubstitutionGroups()
+	if len(groups) == 0 {
+		return
+	}
+
+	levelID := stamp.LevelPreset().LevelID
+	if levelID == 0 {
+		levelID = m.subLevel
+	}
+
+	details := m.asset.Records.GetLevelDetails(levelID)
+	if details == nil || details.SubType < 0 || details.SubTheme < 0 {
+		return
+	}
+
+	records := m.asset.Records.Level.Sub[details.SubType]
+	rng := m.substitutionRNG(tileOffsetX, tileOffsetY)
+
+	for _, group := range groups {
+		area := d2geom.Rectangle{
+			Left:   tileOffsetX + int(group.TileX),
+			Top:    tileOffsetY + int(group.TileY),
+			Width:  int(group.WidthInTiles),
+			Height: int(group.HeightInTiles),
+		}
+
+		var placed []d2geom.Rectangle
+
+		for _, record := range records {
+			placed = m.substituteGroup(rng, area, record, details.SubTheme, placed)
+		}
+	}
+}
+
+// substitutionRNG returns the random number generator of the substitutions of a stamp
+// placed at the given tile.
+func (m *MapEngine) substitutionRNG(tileOffsetX, tileOffsetY int) *rand.Rand {
+	seed := m.seed + int64(m.tileCoordinateToIndex(tileOffsetX, tileOffsetY))
+	return rand.New(rand.NewSource(seed)) //nolint:gosec // must be reproducible
+}
+
+// substituteGroup runs the trials of a lvlsub.txt record in a substitution group of the
+// map, and returns the blocks placed in the group so far. Records whose Dt1Mask picks
+// DT1 files the level type does not have are skipped, as their tiles could not be drawn.
+func (m *MapEngine) substituteGroup(rng *rand.Rand, area d2geom.Rectangle, record *d2records.LevelSubstitutionRecord,
+	theme int, placed []d2geom.Rectangle) []d2geom.Rectangle {
+	rule := substitutionRule{grid: record.GridSize}
+
+	rule.chance, rule.trials, rule.most = record.Theme(theme)
+	if rule.chance <= 0 || rule.trials == 0 || rule.most == 0 || !m.hasDT1s(record.Mask) {
+		return placed
+	}
+
+	blocks := m.substitutionBlocks(record)
+	if len(blocks) == 0 {
+		return placed
+	}
+
+	sizes := make([]d2geom.Size, len(blocks))
+	for idx := range blocks {
+		sizes[idx] = d2geom.Size{Width: blocks[idx].rect.Width, Height: blocks[idx].rect.Height}
+	}
+
+	fits := func(target d2geom.Rectangle) bool {
+		return m.inBounds(target) && m.hasWalls(target) == (record.BorderType != borderNone)
+	}
+
+	placements, placed := rule.place(rng, area, sizes, fits, placed)
+
+	for _, p := range placements {
+		m.copySubstitution(blocks[p.block], p.target.Left, p.target.Top)
+	}
+
+	return placed
+}
+
+// substitutionRule is how a lvlsub.txt record places its blocks in a group, for the
+// subtheme of a level.
+type substitutionRule struct {
+	chance int // Percent chance of a trial placing a block
+	trials int // Trials in a group, or trialsEveryCell
+	most   int // Most blocks placed in a group, or noMost
+	grid   int // Blocks are placed on a grid of this many tiles
+}
+
+// substitutionPlacement is where a block of a substitution is placed.
+type substitutionPlacement struct {
+	block  int
+	target d2geom.Rectangle
+}
+
+// place runs the trials of the rule in a group of the map, and returns the blocks it
+// placed and the blocks placed in the group so far. Each trial picks a block of the
+// given sizes and a cell of the grid, at random unless the rule tries every cell.
+// Blocks are only placed where they fit, and do not overlap.
+func (r substitutionRule) place(rng *rand.Rand, area d2geom.Rectangle, sizes []d2geom.Size,
+	fits func(d2geom.Rectangle) bool, placed []d2geom.Rectangle) ([]substitutionPlacement, []d2geom.Rectangle) {
+	grid := r.grid
+	if grid < 1 {
+		grid = 1
+	}
+
+	var cells []d2geom.Point
+
+	trials := r.trials
+
+	if trials == trialsEveryCell {
+		for y := 0; y < area.Height; y += grid {
+			for x := 0; x < area.Width; x += grid {
+				cells = append(cells, d2geom.Point{X: area.Left + x, Y: area.Top + y})
+			}
+		}
+
+		trials = len(cells)
+	}
+
+	var placements []substitutionPlacement
+
+	for trial := 0; trial < trials && (r.most == noMost || len(placements) < r.most); trial++ {
+		if rng.Intn(substitutionPercent) >= r.chance {
+			continue
+		}
+
+		block := rng.Intn(len(sizes))
+		target := d2geom.Rectangle{Width: sizes[block].Width, Height: sizes[block].Height}
+
+		if cells != nil {
+			target.Left, target.Top = cells[trial].X, cells[trial].Y
+		} else {
+			if target.Width > area.Width || target.Height > area.Height {
+				continue
+			}
+
+			target.Left = area.Left + rng.Intn((area.Width-target.Width)/grid+1)*grid
+			target.Top = area.Top + rng.Intn((area.Height-target.Height)/grid+1)*grid
+		}
+
+		if target.Right() > area.Right() || target.Bottom() > area.Bottom() ||
+			overlapsAny(target, placed) || !fits(target) {
+			continue
+		}
+
+		placements = append(placements, substitutionPlacement{block: block, target: target})
+		placed = append(placed, target)
+	}
+
+	return placements, placed
+}
+
+// hasDT1s returns true if the level type of the map has every DT1 file the Dt1Mask of a
+// lvlsub.txt record picks: bit n of the mask picks the nth file of the level type.
+func (m *MapEngine) hasDT1s(mask int) bool {
+	for idx := range m.levelType.Files {
+		file := m.levelType.Files[idx]
+		if mask&(1<<idx) != 0 && (file == "" || file == "0") {
+			return false
+		}
+	}
+
+	return mask>>len(m.levelType.Files) == 0
+}
+
+// hasWalls returns true if any tile of the map in the rectangle has a wall. Substitutions
+// with a BordType replace the walls on the border of a group, the others only floors.
+func (m *MapEngine) hasWalls(rect d2geom.Rectangle) bool {
+	for y := rect.Top; y < rect.Bottom(); y++ {
+		for x := rect.Left; x < rect.Right(); x++ {
+			walls := m.tiles[m.tileCoordinateToIndex(x, y)].Components.Walls
+			for idx := range walls {
+				if walls[idx].Prop1 != 0 {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// substitutionBlocks loads the DS1 file of a lvlsub.txt record and returns its blocks:
+// its substitution groups, or the whole file if it has none.
+func (m *MapEngine) substitutionBlocks(record *d2records.LevelSubstitutionRecord) []substitution {
+	if record.File == "" || record.File == "0" {
+		return nil
+	}
+
+	fileName := strings.ReplaceAll(record.File, "\\", "/")
+
+	ds1, err := m.asset.LoadDS1(fileName)
+	if err != nil {
+		m.Warningf("Loading the substitutions of %s: %v", record.Name, err)
+		return nil
+	}
+
+	m.AddDS1(fileName)
+
+	bounds := d2geom.Rectangle{Width: ds1.Width(), Height: ds1.Height()}
+
+	if len(ds1.SubstitutionGroups) == 0 {
+		return []substitution{{ds1: ds1, rect: bounds}}
+	}
+
+	blocks := make([]substitution, 0, len(ds1.SubstitutionGroups))
+
+	for _, group := range ds1.SubstitutionGroups {
+		rect := d2geom.Rectangle{
+			Left:   int(group.TileX),
+			Top:    int(group.TileY),
+			Width:  int(group.WidthInTiles),
+			Height: int(group.HeightInTiles),
+		}
+
+		if rect.Width < 1 || rect.Height < 1 || rect.Left < 0 || rect.Top < 0 ||
+			rect.Right() > bounds.Width || rect.Bottom() > bounds.Height {
+			continue
+		}
+
+		blocks = append(blocks, substitution{ds1: ds1, rect: rect})
+	}
+
+	return blocks
+}
+
+// copySubstitution replaces the tiles of the map from the given tile with the tiles of
+// a block. Only the layers the block has a tile in are replaced.
+func (m *MapEngine) copySubstitution(block substitution, tileX, tileY int) {
+	for y := 0; y < block.rect.Height; y++ {
+		for x := 0; x < block.rect.Width; x++ {
+			source := d2mapstamp.DS1Tile(block.ds1, block.rect.Left+x, block.rect.Top+y)
+			target := &m.tiles[m.tileCoordinateToIndex(tileX+x, tileY+y)].Components
+
+			target.Floors = overlayLayers(target.Floors, source.Floors)
+			target.Walls = overlayLayers(target.Walls, source.Walls)
+			target.Shadows = overlayLayers(target.Shadows, source.Shadows)
+		}
+	}
+}
+
+// inBounds returns true if the rectangle, in tiles, is on the map.
+func (m *MapEngine) inBounds(rect d2geom.Rectangle) bool {
+	return rect.Left >= 0 && rect.Top >= 0 && rect.Right() <= m.size.Width && rect.Bottom() <= m.size.Height
+}
+
+// overlayLayers replaces the tiles of the layers with the tiles of the substitution
+// layers which are not empty.
+func overlayLayers(layers, substitution []d2ds1.Tile) []d2ds1.Tile {
+	for idx := range substitution {
+		if substitution[idx].Prop1 == 0 {
+			continue
+		}
+
+		for len(layers) <= idx {
+			layers = append(layers, d2ds1.Tile{})
+		}
+
+		layers[idx] = substitution[idx]
+	}
+
+	return layers
+}
+
+func overlapsAny(rect d2geom.Rectangle, others []d2geom.Rectangle) bool {
+	for idx := range others {
+		other := &others[idx]
+		if rect.Left < other.Right() && other.Left < rect.Right() &&
+			rect.Top < other.Bottom() && other.Top < rect.Bottom() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,180 @@
+package d2mapengine
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2fileformats/d2ds1"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2geom"
+)
+
+func anywhere(d2geom.Rectangle) bool { return true }
+
+func TestSubstitutionRule_EveryCell(t *testing.T) {
+	rule := substitutionRule{chance: substitutionPercent, trials: trialsEveryCell, most: noMost, grid: 2}
+	area := d2geom.Rectangle{Left: 10, Top: 10, Width: 4, Height: 5}
+	sizes := []d2geom.Size{{Width: 2, Height: 2}}
+
+	placements, placed := rule.place(rand.New(rand.NewSource(1)), area, sizes, anywhere, nil)
+
+	// the cells of the last row are cut off by the group
+	if len(placements) != 4 || len(placed) != 4 {
+		t.Fatalf("expected a block in each of the 4 cells, got %v", placements)
+	}
+
+	for idx, p := range placements {
+		left, top := 10+idx%2*2, 10+idx/2*2
+		if p.target.Left != left || p.target.Top != top {
+			t.Errorf("expected block %d at %d, %d, got %v", idx, left, top, p.target)
+		}
+	}
+}
+
+func TestSubstitutionRule_Most(t *testing.T) {
+	rule := substitutionRule{chance: substitutionPercent, trials: trialsEveryCell, most: 3, grid: 1}
+	area := d2geom.Rectangle{Width: 8, Height: 8}
+	sizes := []d2geom.Size{{Width: 1, Height: 1}}
+
+	if placements, _ := rule.place(rand.New(rand.NewSource(1)), area, sizes, anywhere, nil); len(placements) != 3 {
+		t.Fatalf("expected at most 3 blocks, got %d", len(placements))
+	}
+
+	rule.trials = 20
+
+	placements, _ := rule.place(rand.New(rand.NewSource(1)), area, sizes, anywhere, nil)
+	if len(placements) != 3 {
+		t.Fatalf("expected at most 3 blocks out of the trials, got %d", len(placements))
+	}
+}
+
+func TestSubstitutionRule_DoesNotOverlap(t *testing.T) {
+	rule := substitutionRule{chance: substitutionPercent, trials: 50, most: noMost, grid: 1}
+	area := d2geom.Rectangle{Width: 6, Height: 6}
+	sizes := []d2geom.Size{{Width: 2, Height: 2}, {Width: 3, Height: 1}}
+	taken := []d2geom.Rectangle{{Left: 2, Top: 2, Width: 2, Height: 2}}
+
+	placements, placed := rule.place(rand.New(rand.NewSource(1)), area, sizes, anywhere, taken)
+
+	if len(placed) != len(taken)+len(placements) {
+		t.Fatalf("expected the placed blocks to follow the taken ones, got %v", placed)
+	}
+
+	for idx := range placed {
+		rect := placed[idx]
+
+		if rect.Left < area.Left || rect.Top < area.Top || rect.Right() > area.Right() || rect.Bottom() > area.Bottom() {
+			t.Errorf("expected %v to be in the group", rect)
+		}
+
+		if overlapsAny(rect, placed[:idx]) {
+			t.Errorf("expected %v not to overlap another block", rect)
+		}
+	}
+}
+
+func TestSubstitutionRule_Fits(t *testing.T) {
+	rule := substitutionRule{chance: substitutionPercent, trials: trialsEveryCell, most: noMost, grid: 1}
+	area := d2geom.Rectangle{Width: 4, Height: 4}
+	sizes := []d2geom.Size{{Width: 1, Height: 1}}
+	leftHalf := func(rect d2geom.Rectangle) bool { return rect.Right() <= 2 }
+
+	placements, _ := rule.place(rand.New(rand.NewSource(1)), area, sizes, leftHalf, nil)
+
+	if len(placements) != 8 {
+		t.Fatalf("expected a block in each cell of the left half, got %v", placements)
+	}
+}
+
+// stampSubstitutions runs a rule in a group of a preset stamped at the given tile, and
+// returns where the blocks went relative to the stamp.
+func stampSubstitutions(m *MapEngine, tileX, tileY int) []d2geom.Rectangle {
+	rule := substitutionRule{chance: substitutionPercent / 2, trials: trialsEveryCell, most: noMost, grid: 1}
+	area := d2geom.Rectangle{Left: tileX + 2, Top: tileY + 2, Width: 8, Height: 8}
+	sizes := []d2geom.Size{{Width: 1, Height: 1}, {Width: 2, Height: 1}}
+
+	placements, _ := rule.place(m.substitutionRNG(tileX, tileY), area, sizes, anywhere, nil)
+
+	relative := make([]d2geom.Rectangle, len(placements))
+
+	for idx, p := range placements {
+		relative[idx] = p.target
+		relative[idx].Left -= tileX
+		relative[idx].Top -= tileY
+	}
+
+	return relative
+}
+
+func sameRectangles(a, b []d2geom.Rectangle) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSubstitutionsVaryByStamp(t *testing.T) {
+	m := &MapEngine{seed: 42, size: d2geom.Size{Width: 100, Height: 100}}
+
+	first := stampSubstitutions(m, 0, 0)
+
+	if !sameRectangles(first, stampSubstitutions(m, 0, 0)) {
+		t.Fatal("expected a preset stamped at the same tile to vary the same way")
+	}
+
+	if sameRectangles(first, stampSubstitutions(m, 30, 0)) {
+		t.Error("expected a preset stamped at another tile to vary differently")
+	}
+
+	other := &MapEngine{seed: 43, size: m.size}
+
+	if sameRectangles(first, stampSubstitutions(other, 0, 0)) {
+		t.Error("expected a preset stamped with another seed to vary differently")
+	}
+}
+
+func TestHasDT1s(t *testing.T) {
+	m := &MapEngine{}
+	m.levelType.Files[0] = "floor.dt1"
+	m.levelType.Files[1] = "0"
+	m.levelType.Files[2] = "wall.dt1"
+
+	tests := []struct {
+		mask int
+		has  bool
+	}{
+		{0, true},
+		{0b001, true},
+		{0b101, true},
+		{0b010, false},
+		{0b110, false},
+		{1 << len(m.levelType.Files), false},
+	}
+
+	for _, test := range tests {
+		if has := m.hasDT1s(test.mask); has != test.has {
+			t.Errorf("mask %b: expected %v, got %v", test.mask, test.has, has)
+		}
+	}
+}
+
+func TestHasWalls(t *testing.T) {
+	m := &MapEngine{size: d2geom.Size{Width: 4, Height: 4}, tiles: make([]MapTile, 16)}
+	m.tiles[m.tileCoordinateToIndex(2, 1)].Components.Walls = []d2ds1.Tile{{}, {}}
+	m.tiles[m.tileCoordinateToIndex(3, 3)].Components.Walls = []d2ds1.Tile{{}}
+	m.tiles[m.tileCoordinateToIndex(3, 3)].Components.Walls[0].Prop1 = 1
+
+	if m.hasWalls(d2geom.Rectangle{Left: 1, Top: 0, Width: 2, Height: 3}) {
+		t.Error("expected empty wall layers not to count")
+	}
+
+	if !m.hasWalls(d2geom.Rectangle{Left: 2, Top: 2, Width: 2, Height: 2}) {
+		t.Error("expected the wall at 3, 3 to count")
+	}
+}
@@ -106,7 +106,7 @@ func (f *MapEntityFactory) NewPlayer(id, name string, x, y, direction int, heroT
 	}
 
 	result.mapEntity.uuid = id
-	result.SetSpeed(baseWalkSpeed)
+	result.SetSpeed(BaseWalkSpeed)
 	result.mapEntity.directioner = result.rotate
 
 	err = composite.SetMode(d2enum.PlayerAnimationModeTownNeutral, equipment.RightHand.GetWeaponClass())
@@ -63,6 +63,13 @@ func (m *mapEntity) StopMoving() {
 	m.setTarget(m.Position, nil)
 }
 
+// SetPosition stops the entity and places it at the given position.
+func (m *mapEntity) SetPosition(p d2vector.Position) {
+	m.ClearPath()
+	m.Position.Copy(&p.Vector)
+	m.setTarget(m.Position, nil)
+}
+
 // SetSpeed sets the entity movement speed.
 func (m *mapEntity) SetSpeed(speed float64) {
 	m.Speed = speed
@@ -47,3 +47,8 @@ func (m *Missile) Advance(tickTime float64) {
 	m.Step(tickTime)
 	m.AnimatedEntity.Advance(tickTime)
 }
+
+// Record returns the missiles.txt record of the missile.
+func (m *Missile) Record() *d2records.MissileRecord {
+	return m.record
+}
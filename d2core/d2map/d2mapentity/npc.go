@@ -26,8 +26,23 @@ type NPC struct {
 	monstatEx     *d2records.MonStat2Record
 	HasPaths      bool
 	isDone        bool
+	Rank          MonsterRank // Whether the monster leads a pack, see d2mapgen.Populate
+	Level         int         // Monster level, 0 for the NPCs placed by the map
+	Hitpoints     int         // Scaled by the monster level and the difficulty
+	Experience    int         // Given for killing the monster
 }
 
+// MonsterRank tells the champions and the uniques leading packs apart from the other
+// monsters.
+type MonsterRank int
+
+// Monster ranks
+const (
+	RankNormal MonsterRank = iota
+	RankChampion
+	RankUnique
+)
+
 const (
 	magicOffsetX            = 5
 	magicOffsetScalarX      = 8
@@ -189,3 +204,8 @@ func (v *NPC) GetVelocity() d2vector.Vector {
 func (v *NPC) GetSize() (width, height int) {
 	return v.composite.GetSize()
 }
+
+// MonStat returns the monstats.txt record of the NPC.
+func (v *NPC) MonStat() *d2records.MonStatRecord {
+	return v.monstatRecord
+}
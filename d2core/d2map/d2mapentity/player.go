@@ -31,12 +31,21 @@ type Player struct {
 	isCasting         bool
 	onFinishedCasting func()
 	Act               int
+	predictedMoves    []PredictedMove
+}
+
+// PredictedMove is a move of the local player which has been applied on the
+// client before the server acknowledged it.
+type PredictedMove struct {
+	Sequence uint32
+	Dest     d2vector.Position
 }
 
 // run speed should be walkspeed * 1.5, since in the original game it is 6 yards walk and 9 yards run.
+// Speeds are in sub tiles per second.
 const (
-	baseWalkSpeed = 9.0
-	baseRunSpeed  = 13.0
+	BaseWalkSpeed = 9.0
+	BaseRunSpeed  = 13.0
 )
 
 // ID returns the Player uuid
@@ -71,9 +80,9 @@ func (p *Player) SetIsRunning(isRunning bool) {
 	p.isRunning = isRunning
 
 	if isRunning {
-		p.SetSpeed(baseRunSpeed)
+		p.SetSpeed(BaseRunSpeed)
 	} else {
-		p.SetSpeed(baseWalkSpeed)
+		p.SetSpeed(BaseWalkSpeed)
 	}
 }
 
@@ -135,13 +144,13 @@ func (p *Player) Advance(tickTime float64) {
 	if p.IsRunning() && !p.atTarget() && !p.IsInTown() {
 		p.Stats.Stamina -= staminaDrain * tickTime / magicStaminaDrainDivisor
 		if p.Stats.Stamina <= 0 {
-			p.SetSpeed(baseWalkSpeed)
+			p.SetSpeed(BaseWalkSpeed)
 			p.Stats.Stamina = 0
 		}
 	} else if p.Stats.Stamina < float64(p.Stats.MaxStamina) {
 		p.Stats.Stamina += staminaDrain * tickTime / magicStaminaDrainDivisor
 		if p.IsRunning() {
-			p.SetSpeed(baseRunSpeed)
+			p.SetSpeed(BaseRunSpeed)
 		}
 	}
 }
@@ -265,3 +274,28 @@ func (p *Player) GetSize() (width, height int) {
 
 	return width, height
 }
+
+// PredictMove records a move which has been applied ahead of the server. It
+// stays pending until the server acknowledges or corrects it.
+func (p *Player) PredictMove(sequence uint32, dest d2vector.Position) {
+	p.predictedMoves = append(p.predictedMoves, PredictedMove{Sequence: sequence, Dest: dest})
+}
+
+// AcknowledgeMove drops the given move and every move predicted before it.
+// It returns true if the move was predicted by this player.
+func (p *Player) AcknowledgeMove(sequence uint32) bool {
+	predicted := false
+
+	for len(p.predictedMoves) > 0 && p.predictedMoves[0].Sequence <= sequence {
+		predicted = predicted || p.predictedMoves[0].Sequence == sequence
+		p.predictedMoves = p.predictedMoves[1:]
+	}
+
+	return predicted
+}
+
+// PendingMoves returns the predicted moves which the server has not
+// acknowledged yet, oldest first.
+func (p *Player) PendingMoves() []PredictedMove {
+	return p.predictedMoves
+}
@@ -0,0 +1,93 @@
+package d2mapentity
+
+import (
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+)
+
+func predictedPlayer(sequences ...uint32) *Player {
+	p := &Player{}
+
+	for _, sequence := range sequences {
+		p.PredictMove(sequence, d2vector.NewPositionTile(float64(sequence), 0))
+	}
+
+	return p
+}
+
+func pendingSequences(p *Player) []uint32 {
+	sequences := make([]uint32, 0, len(p.PendingMoves()))
+
+	for _, move := range p.PendingMoves() {
+		sequences = append(sequences, move.Sequence)
+	}
+
+	return sequences
+}
+
+func equalSequences(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestPlayer_AcknowledgeMove(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicted []uint32
+		ack       uint32
+		found     bool
+		pending   []uint32
+	}{
+		{"oldest", []uint32{1, 2, 3}, 1, true, []uint32{2, 3}},
+		{"skips the older moves", []uint32{1, 2, 3}, 2, true, []uint32{3}},
+		{"newest", []uint32{1, 2, 3}, 3, true, []uint32{}},
+		{"already acknowledged", []uint32{2, 3}, 1, false, []uint32{2, 3}},
+		{"never predicted", []uint32{1, 3}, 2, false, []uint32{3}},
+		{"nothing predicted", nil, 1, false, []uint32{}},
+	}
+
+	for _, test := range tests {
+		p := predictedPlayer(test.predicted...)
+
+		if found := p.AcknowledgeMove(test.ack); found != test.found {
+			t.Errorf("%s: expected AcknowledgeMove to return %v, got %v", test.name, test.found, found)
+		}
+
+		if pending := pendingSequences(p); !equalSequences(pending, test.pending) {
+			t.Errorf("%s: expected %v to be pending, got %v", test.name, test.pending, pending)
+		}
+	}
+}
+
+func TestPlayer_ReplayAfterCorrection(t *testing.T) {
+	p := predictedPlayer(1, 2, 3)
+
+	// the server corrected move 2, move 3 is replayed from the corrected position
+	p.AcknowledgeMove(2)
+
+	pending := p.PendingMoves()
+	if len(pending) != 1 {
+		t.Fatalf("expected one move to replay, got %d", len(pending))
+	}
+
+	dest := d2vector.NewPositionTile(3, 0)
+	if !pending[0].Dest.Equals(&dest.Vector) {
+		t.Errorf("expected the move to %v to replay, got one to %v", dest, pending[0].Dest)
+	}
+
+	p.PredictMove(4, d2vector.NewPositionTile(4, 0))
+
+	if pending := pendingSequences(p); !equalSequences(pending, []uint32{3, 4}) {
+		t.Errorf("expected the new move after the replayed one, got %v", pending)
+	}
+}
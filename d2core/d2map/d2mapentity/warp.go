@@ -0,0 +1,120 @@
+package d2mapentity
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2interface"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+// warpMargin is how far, in tiles, a player may stand from the selection box of a
+// warp to enter it. The tiles of most warps block walking.
+const warpMargin = 1
+
+// Warp is an entrance to another level, like the mouth of a cave or a staircase. Its
+// graphics are tiles of the map, the warp is where players walk to to enter the level.
+type Warp struct {
+	uuid        string
+	Position    d2vector.Position
+	Level       int // ID of the level the warp is in
+	Destination int // ID of the level the warp leads to
+	record      *d2records.LevelWarpRecord
+	name        string
+	highlight   bool
+}
+
+// NewWarp creates a warp at the given tile, leading from one level to another. The
+// record places the selection box and the exit of the warp around the tile.
+func NewWarp(tileX, tileY, level, destination int, record *d2records.LevelWarpRecord, name string) *Warp {
+	return &Warp{
+		uuid:        uuid.New().String(),
+		Position:    d2vector.NewPositionTile(float64(tileX), float64(tileY)),
+		Level:       level,
+		Destination: destination,
+		record:      record,
+		name:        name,
+	}
+}
+
+// Contains returns true if the position, in tiles, is on the warp.
+func (w *Warp) Contains(x, y float64) bool {
+	tile := w.Position.Tile()
+	width, height := w.record.SelectDX, w.record.SelectDY
+
+	if width < 1 {
+		width = 1
+	}
+
+	if height < 1 {
+		height = 1
+	}
+
+	left := tile.X() + float64(w.record.SelectX) - warpMargin
+	top := tile.Y() + float64(w.record.SelectY) - warpMargin
+	right := left + float64(width) + 2*warpMargin
+	bottom := top + float64(height) + 2*warpMargin
+
+	return x >= left && x < right && y >= top && y < bottom
+}
+
+// Exit returns the tile players coming out of the warp are placed on.
+func (w *Warp) Exit() (tileX, tileY float64) {
+	tile := w.Position.Tile()
+
+	return tile.X() + float64(w.record.ExitWalkX), tile.Y() + float64(w.record.ExitWalkY)
+}
+
+// ID returns the warp uuid
+func (w *Warp) ID() string {
+	return w.uuid
+}
+
+// Render draws nothing, the graphics of the warp are tiles of the map
+func (w *Warp) Render(_ d2interface.Surface) {
+	w.highlight = false
+}
+
+// Advance does nothing, warps do not change
+func (w *Warp) Advance(_ float64) {}
+
+// GetPosition returns the warp's position
+func (w *Warp) GetPosition() d2vector.Position {
+	return w.Position
+}
+
+// GetVelocity returns the warp's velocity vector
+func (w *Warp) GetVelocity() d2vector.Vector {
+	return *d2vector.VectorZero()
+}
+
+// GetSize returns the size of the selection box of the warp
+func (w *Warp) GetSize() (width, height int) {
+	return w.record.SelectDX * subtileWidth * subtilesPerTile, w.record.SelectDY * subtileHeight * subtilesPerTile
+}
+
+// GetLayer returns which layer of the map the warp is drawn
+func (w *Warp) GetLayer() int {
+	return 0
+}
+
+// GetPositionF returns the warp's position in tiles
+func (w *Warp) GetPositionF() (x, y float64) {
+	world := w.Position.World()
+	return world.X(), world.Y()
+}
+
+// Label returns the name of the level the warp leads to
+func (w *Warp) Label() string {
+	return w.name
+}
+
+// Selectable returns true, the players click warps to enter them
+func (w *Warp) Selectable() bool {
+	return true
+}
+
+// Highlight sets the entity highlighted flag to true.
+func (w *Warp) Highlight() {
+	w.highlight = true
+}
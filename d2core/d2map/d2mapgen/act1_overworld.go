@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
-	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2fileformats/d2ds1"
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2geom"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapgen/d2wilderness"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapstamp"
@@ -28,23 +27,24 @@ const (
 )
 
 const (
-	mapWidth      = 150
-	mapHeight     = mapWidth
-	mapMargin     = 9
-	autoFileIndex = -1
+	mapWidth  = 150
+	mapHeight = mapWidth
+	mapMargin = 9
 )
 
 // GenerateAct1Overworld generates the map and entities for the first town and surrounding area.
 func (g *MapGenerator) GenerateAct1Overworld() {
-	rand.Seed(g.engine.Seed())
+	rng := rand.New(rand.NewSource(g.engine.Seed())) //nolint:gosec // must be reproducible
 
 	wilderness1Details := g.asset.Records.GetLevelDetails(wildernessDetailsRecordID)
 
 	g.engine.ResetMap(d2enum.RegionAct1Town, mapWidth, mapHeight)
+	g.engine.SetSubstitutionLevel(wildernessDetailsRecordID)
 	mapWidth := g.engine.Size().Width
 	mapHeight := g.engine.Size().Height
 
-	townStamp := g.engine.LoadStamp(d2enum.RegionAct1Town, presetB, autoFileIndex)
+	townFileIndex := presetFileIndex(rng, g.asset.Records.Level.Presets[presetB])
+	townStamp := g.engine.LoadStamp(d2enum.RegionAct1Town, presetB, townFileIndex)
 	townStamp.RegionPath()
 	townSize := townStamp.Size()
 
@@ -53,7 +53,7 @@ func (g *MapGenerator) GenerateAct1Overworld() {
 	switch {
 	case strings.Contains(townStamp.RegionPath(), "E1"):
 		g.engine.PlaceStamp(townStamp, 0, 0)
-		g.generateWilderness1TownEast(townSize.Width, 0)
+		g.generateWilderness1TownEast(rng, townSize.Width, 0)
 	case strings.Contains(townStamp.RegionPath(), "S1"):
 		g.engine.PlaceStamp(townStamp, mapWidth-townSize.Width, 0)
 		rightWaterBorderStamp := g.loadPreset(d2wilderness.WaterBorderEast, 0)
@@ -71,19 +71,19 @@ func (g *MapGenerator) GenerateAct1Overworld() {
 		startX := mapWidth - wilderness1Details.SizeXNormal - edgeOffset
 		startY := townSize.Height
 
-		g.generateWilderness1TownSouth(startX, startY)
+		g.generateWilderness1TownSouth(rng, startX, startY)
 	case strings.Contains(townStamp.RegionPath(), "W1"):
 		g.engine.PlaceStamp(townStamp, mapWidth-townSize.Width, mapHeight-townSize.Height)
 		startX := mapWidth - townSize.Width - wilderness1Details.SizeXNormal
 		startY := mapHeight - wilderness1Details.SizeYNormal
-		g.generateWilderness1TownWest(startX, startY)
+		g.generateWilderness1TownWest(rng, startX, startY)
 	default:
 		g.engine.PlaceStamp(townStamp, mapWidth-townSize.Width, mapHeight-townSize.Height)
 	}
 }
 
 // nolint:gosec,gomnd // we dont need crypto-strong randomness, mapgen will get a refactor soon
-func (g *MapGenerator) generateWilderness1TownEast(startX, startY int) {
+func (g *MapGenerator) generateWilderness1TownEast(rng *rand.Rand, startX, startY int) {
 	levelDetails := g.asset.Records.GetLevelDetails(wildernessDetailsRecordID)
 
 	fenceNorthStamp := []*d2mapstamp.Stamp{
@@ -122,24 +122,24 @@ func (g *MapGenerator) generateWilderness1TownEast(startX, startY int) {
 		Height: levelDetails.SizeYNormal - 3,
 	}
 
-	g.generateWilderness1Contents(areaRect)
+	g.generateWilderness1Contents(rng, areaRect)
 
 	// Draw the north and south fence
 	for i := 0; i < 9; i++ {
-		g.engine.PlaceStamp(fenceNorthStamp[rand.Intn(3)], startX+(i*9), startY)
-		g.engine.PlaceStamp(fenceSouthStamp[rand.Intn(3)], startX+(i*9),
+		g.engine.PlaceStamp(fenceNorthStamp[rng.Intn(3)], startX+(i*9), startY)
+		g.engine.PlaceStamp(fenceSouthStamp[rng.Intn(3)], startX+(i*9),
 			startY+(levelDetails.SizeYNormal+6))
 	}
 
 	// West fence
 	for i := 1; i < 6; i++ {
-		g.engine.PlaceStamp(fenceWestStamp[rand.Intn(3)], startX,
+		g.engine.PlaceStamp(fenceWestStamp[rng.Intn(3)], startX,
 			startY+(levelDetails.SizeYNormal+6)-(i*9))
 	}
 
 	// East Fence
 	for i := 1; i < 10; i++ {
-		g.engine.PlaceStamp(fenceEastStamp[rand.Intn(3)], startX+levelDetails.SizeXNormal, startY+(i*9))
+		g.engine.PlaceStamp(fenceEastStamp[rng.Intn(3)], startX+levelDetails.SizeXNormal, startY+(i*9))
 	}
 
 	g.engine.PlaceStamp(fenceSouthWestStamp, startX, startY+levelDetails.SizeYNormal+6)
@@ -149,7 +149,7 @@ func (g *MapGenerator) generateWilderness1TownEast(startX, startY int) {
 }
 
 // nolint:gosec,gomnd // we dont need crypto-strong randomness, mapgen will get a refactor soon
-func (g *MapGenerator) generateWilderness1TownSouth(startX, startY int) {
+func (g *MapGenerator) generateWilderness1TownSouth(rng *rand.Rand, startX, startY int) {
 	levelDetails := g.asset.Records.GetLevelDetails(wildernessDetailsRecordID)
 
 	fenceNorthStamp := []*d2mapstamp.Stamp{
@@ -180,21 +180,21 @@ func (g *MapGenerator) generateWilderness1TownSouth(startX, startY int) {
 		Width:  levelDetails.SizeXNormal - 2,
 		Height: levelDetails.SizeYNormal - 3,
 	}
-	g.generateWilderness1Contents(areaRect)
+	g.generateWilderness1Contents(rng, areaRect)
 
 	// Draw the north fence
 	for i := 0; i < 4; i++ {
-		g.engine.PlaceStamp(fenceNorthStamp[rand.Intn(3)], startX+(i*9)+5, startY-6)
+		g.engine.PlaceStamp(fenceNorthStamp[rng.Intn(3)], startX+(i*9)+5, startY-6)
 	}
 
 	// Draw the west fence
 	for i := 0; i < 8; i++ {
-		g.engine.PlaceStamp(fenceWestStamp[rand.Intn(3)], startX, startY+(i*9)+3)
+		g.engine.PlaceStamp(fenceWestStamp[rng.Intn(3)], startX, startY+(i*9)+3)
 	}
 
 	// Draw the south fence
 	for i := 1; i < 9; i++ {
-		g.engine.PlaceStamp(fenceSouthStamp[rand.Intn(3)], startX+(i*9), startY+(8*9)+3)
+		g.engine.PlaceStamp(fenceSouthStamp[rng.Intn(3)], startX+(i*9), startY+(8*9)+3)
 	}
 
 	g.engine.PlaceStamp(fenceNorthWestStamp, startX, startY-6)
@@ -203,7 +203,7 @@ func (g *MapGenerator) generateWilderness1TownSouth(startX, startY int) {
 }
 
 // nolint:gosec,gomnd // we dont need crypto-strong randomness, mapgen will get a refactor soon
-func (g *MapGenerator) generateWilderness1TownWest(startX, startY int) {
+func (g *MapGenerator) generateWilderness1TownWest(rng *rand.Rand, startX, startY int) {
 	levelDetails := g.asset.Records.GetLevelDetails(wildernessDetailsRecordID)
 
 	fenceEastEdge := g.loadPreset(d2wilderness.TreeBoxSouthWest, presetA)
@@ -238,20 +238,20 @@ func (g *MapGenerator) generateWilderness1TownWest(startX, startY int) {
 	// Draw the north and south fences
 	for i := 0; i < 9; i++ {
 		if i > 0 && i < 8 {
-			g.engine.PlaceStamp(fenceNorthStamp[rand.Intn(3)], startX+(i*9)-1, startY-15)
+			g.engine.PlaceStamp(fenceNorthStamp[rng.Intn(3)], startX+(i*9)-1, startY-15)
 		}
 
-		g.engine.PlaceStamp(fenceSouthStamp[rand.Intn(3)], startX+(i*9)-1, startY+levelDetails.SizeYNormal-12)
+		g.engine.PlaceStamp(fenceSouthStamp[rng.Intn(3)], startX+(i*9)-1, startY+levelDetails.SizeYNormal-12)
 	}
 
 	// Draw the east fence
 	for i := 0; i < 6; i++ {
-		g.engine.PlaceStamp(fenceEastStamp[rand.Intn(3)], startX+levelDetails.SizeXNormal-9, startY+(i*9)-6)
+		g.engine.PlaceStamp(fenceEastStamp[rng.Intn(3)], startX+levelDetails.SizeXNormal-9, startY+(i*9)-6)
 	}
 
 	// Draw the west fence
 	for i := 0; i < 9; i++ {
-		g.engine.PlaceStamp(fenceWestStamp[rand.Intn(3)], startX, startY+(i*9)-6)
+		g.engine.PlaceStamp(fenceWestStamp[rng.Intn(3)], startX, startY+(i*9)-6)
 	}
 
 	// Draw the west fence
@@ -266,30 +266,21 @@ func (g *MapGenerator) generateWilderness1TownWest(startX, startY int) {
 		Width:  levelDetails.SizeXNormal - 9,
 		Height: levelDetails.SizeYNormal - 2,
 	}
-	g.generateWilderness1Contents(areaRect)
+	g.generateWilderness1Contents(rng, areaRect)
 }
 
 // nolint:gosec,gomnd // we dont need crypto-strong randomness, mapgen will get a refactor soon
-func (g *MapGenerator) generateWilderness1Contents(rect d2geom.Rectangle) {
+func (g *MapGenerator) generateWilderness1Contents(rng *rand.Rand, rect d2geom.Rectangle) {
 	levelDetails := g.asset.Records.GetLevelDetails(wildernessDetailsRecordID)
 
 	denOfEvil := g.loadPreset(d2wilderness.DenOfEvilEntrance, 0)
 	denOfEvilLoc := d2geom.Point{
-		X: rect.Left + (rect.Width / 2) + rand.Intn(10),
-		Y: rect.Top + (rect.Height / 2) + rand.Intn(10),
+		X: rect.Left + (rect.Width / 2) + rng.Intn(10),
+		Y: rect.Top + (rect.Height / 2) + rng.Intn(10),
 	}
 
 	// Fill in the grass
-	for y := 0; y < rect.Height; y++ {
-		for x := 0; x < rect.Width; x++ {
-			tile := g.engine.Tile(rect.Left+x, rect.Top+y)
-			tile.RegionType = d2enum.RegionIdType(levelDetails.LevelType)
-			floorTile := d2ds1.Tile{}
-			floorTile.Prop1 = 1
-			tile.Components.Floors = []d2ds1.Tile{floorTile} // wildernessGrass
-			tile.PrepareTile(x, y, g.engine)
-		}
-	}
+	g.fillWilderness(rect, d2enum.RegionIdType(levelDetails.LevelType))
 
 	stuff := []*d2mapstamp.Stamp{
 		g.loadPreset(d2wilderness.StoneFill1, presetA),
@@ -317,11 +308,11 @@ func (g *MapGenerator) generateWilderness1Contents(rect d2geom.Rectangle) {
 
 	numPlaced := 0
 	for numPlaced < 25 {
-		stamp := stuff[rand.Intn(len(stuff))]
+		stamp := stuff[rng.Intn(len(stuff))]
 
 		stampRect := d2geom.Rectangle{
-			Left:   rect.Left + rand.Intn(rect.Width) - stamp.Size().Width,
-			Top:    rect.Top + rand.Intn(rect.Height) - stamp.Size().Height,
+			Left:   rect.Left + rng.Intn(rect.Width) - stamp.Size().Width,
+			Top:    rect.Top + rng.Intn(rect.Height) - stamp.Size().Height,
 			Width:  stamp.Size().Width,
 			Height: stamp.Size().Height,
 		}
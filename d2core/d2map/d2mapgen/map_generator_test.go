@@ -0,0 +1,94 @@
+package d2mapgen
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2loader/asset/types"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2resource"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2config"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
+)
+
+// testAssets loads the level records from the MPQs at the path of the default
+// configuration. The test is skipped if they are not there.
+func testAssets(t *testing.T) *d2asset.AssetManager {
+	t.Helper()
+
+	asset, err := d2asset.NewAssetManager(d2util.LogLevelNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := d2config.DefaultConfig()
+
+	for _, mpqName := range config.MpqLoadOrder {
+		if err := asset.AddSource(filepath.Join(config.MpqPath, mpqName), types.AssetSourceMPQ); err != nil {
+			t.Skipf("no game data at %s: %v", config.MpqPath, err)
+		}
+	}
+
+	records := []string{
+		d2resource.LevelType, d2resource.LevelPreset, d2resource.LevelWarp,
+		d2resource.LevelDetails, d2resource.LevelMaze, d2resource.LevelSubstitutions,
+	}
+
+	for _, path := range records {
+		if err := asset.LoadRecords(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return asset
+}
+
+// generateTestMap generates a level with the seed, and returns its tiles.
+func generateTestMap(t *testing.T, asset *d2asset.AssetManager, seed int64, act, levelID int) []d2mapengine.MapTile {
+	t.Helper()
+
+	engine := d2mapengine.CreateMapEngine(d2util.LogLevelNone, asset)
+	engine.SetSeed(seed)
+
+	generator, err := NewMapGenerator(asset, d2util.LogLevelNone, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generator.Generate(act, levelID, d2enum.DifficultyNormal); err != nil {
+		t.Fatalf("act %d, level %d: %v", act, levelID, err)
+	}
+
+	return *engine.Tiles()
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	asset := testAssets(t)
+
+	const seed = 1
+
+	tests := []struct {
+		name         string
+		act, levelID int
+	}{
+		{"act 1 overworld", 1, 0},
+		{"act 2 overworld", 2, 0},
+		{"act 3 overworld", 3, 0},
+		{"act 4 overworld", 4, 0},
+		{"act 5 overworld", 5, 0},
+		{"maze", 1, 8},   // The Den of Evil
+		{"preset", 1, 1}, // The Rogue Encampment
+	}
+
+	for _, test := range tests {
+		first := generateTestMap(t, asset, seed, test.act, test.levelID)
+		second := generateTestMap(t, asset, seed, test.act, test.levelID)
+
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: expected the same map from one seed", test.name)
+		}
+	}
+}
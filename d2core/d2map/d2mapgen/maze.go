@@ -0,0 +1,361 @@
+package d2mapgen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapstamp"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+const minMazeRooms = 2 // The entrance and the exit
+
+var (
+	errNoMaze       = errors.New("level is not a maze")
+	errNoMazePreset = errors.New("no room preset for the maze")
+)
+
+// mazeExits is a set of the edges of a maze room which open into a neighbouring room.
+type mazeExits int
+
+// Maze room edges
+const (
+	exitNorth mazeExits = 1 << iota
+	exitSouth
+	exitEast
+	exitWest
+)
+
+// mazeDirections are the edges of a room, in the order they appear in preset names,
+// with the grid offset of the neighbour on that edge. North is up the grid.
+var mazeDirections = []struct {
+	exit, opposite mazeExits
+	letter         rune
+	dx, dy         int
+}{
+	{exitNorth, exitSouth, 'N', 0, -1},
+	{exitSouth, exitNorth, 'S', 0, 1},
+	{exitEast, exitWest, 'E', 1, 0},
+	{exitWest, exitEast, 'W', -1, 0},
+}
+
+// mazeRoomKind tells the rooms leading out of the maze apart from the others.
+type mazeRoomKind int
+
+// Maze room kinds
+const (
+	roomPlain mazeRoomKind = iota
+	roomPrev               // Leads to the previous level
+	roomNext               // Leads to the next level
+)
+
+var mazeRoomKinds = map[string]mazeRoomKind{
+	"":     roomPlain,
+	"Prev": roomPrev,
+	"Next": roomNext,
+}
+
+type mazeCell struct {
+	x, y int
+}
+
+// mazeRoom is a room of a maze, at a cell of the grid of the maze.
+type mazeRoom struct {
+	mazeCell
+	exits mazeExits
+	kind  mazeRoomKind
+	depth int // Rooms from the entrance
+}
+
+// mazeRoomAllowed returns true if there is a preset for a room of the kind with the exits.
+type mazeRoomAllowed func(kind mazeRoomKind, exits mazeExits) bool
+
+// buildMazeGraph grows a tree of numRooms rooms on a grid from the entrance room, which
+// leads to the previous level. Each new room opens into the room it grows from, and the
+// room furthest from the entrance leads to the next level. Rooms only grow where
+// allowed says there is a preset for the rooms of both sides; growth stops early if no
+// room can grow. The first room is the entrance, and all cells are at least 0.
+func buildMazeGraph(rng *rand.Rand, numRooms int, allowed mazeRoomAllowed) []*mazeRoom {
+	rooms := []*mazeRoom{{kind: roomPrev}}
+	cells := map[mazeCell]*mazeRoom{{}: rooms[0]}
+
+	type growth struct {
+		from *mazeRoom
+		dir  int
+	}
+
+	for len(rooms) < numRooms {
+		var candidates []growth
+
+		for _, room := range rooms {
+			// The entrance only opens into one room, like the presets leading out of a maze
+			if room.kind == roomPrev && room.exits != 0 {
+				continue
+			}
+
+			for dir, d := range mazeDirections {
+				if _, taken := cells[mazeCell{room.x + d.dx, room.y + d.dy}]; taken {
+					continue
+				}
+
+				if allowed(room.kind, room.exits|d.exit) && allowed(roomPlain, d.opposite) {
+					candidates = append(candidates, growth{room, dir})
+				}
+			}
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+
+		c := candidates[rng.Intn(len(candidates))]
+		d := mazeDirections[c.dir]
+		room := &mazeRoom{
+			mazeCell: mazeCell{c.from.x + d.dx, c.from.y + d.dy},
+			exits:    d.opposite,
+			depth:    c.from.depth + 1,
+		}
+
+		c.from.exits |= d.exit
+		cells[room.mazeCell] = room
+		rooms = append(rooms, room)
+	}
+
+	markMazeExit(rooms, allowed)
+	normalizeMazeCells(rooms)
+
+	return rooms
+}
+
+// markMazeExit makes the deepest room with a preset leading to the next level the exit.
+func markMazeExit(rooms []*mazeRoom, allowed mazeRoomAllowed) {
+	var exit *mazeRoom
+
+	for _, room := range rooms[1:] {
+		if !allowed(roomNext, room.exits) {
+			continue
+		}
+
+		if exit == nil || room.depth > exit.depth {
+			exit = room
+		}
+	}
+
+	if exit != nil {
+		exit.kind = roomNext
+	}
+}
+
+// normalizeMazeCells moves the rooms so the top left cell is at 0, 0.
+func normalizeMazeCells(rooms []*mazeRoom) {
+	minX, minY := 0, 0
+
+	for _, room := range rooms {
+		if room.x < minX {
+			minX = room.x
+		}
+
+		if room.y < minY {
+			minY = room.y
+		}
+	}
+
+	for _, room := range rooms {
+		room.x -= minX
+		room.y -= minY
+	}
+}
+
+// parseMazePreset returns the kind and exits of a room preset of a level type. Their
+// names are the name of the level type, the kind if the room leads out of the maze, and
+// the letters of the exits, as in "Act 1 - Cave Prev W" or "Act 1 - Cave NSE".
+func parseMazePreset(levelTypeName, presetName string) (kind mazeRoomKind, exits mazeExits, ok bool) {
+	if !strings.HasPrefix(presetName, levelTypeName+" ") {
+		return 0, 0, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(presetName, levelTypeName))
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, false
+	}
+
+	if kind, ok = mazeRoomKinds[strings.Join(fields[:len(fields)-1], "")]; !ok {
+		return 0, 0, false
+	}
+
+	for _, letter := range fields[len(fields)-1] {
+		found := false
+
+		for _, d := range mazeDirections {
+			if letter == d.letter && exits&d.exit == 0 {
+				exits |= d.exit
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, 0, false
+		}
+	}
+
+	return kind, exits, true
+}
+
+type mazePresetKey struct {
+	kind  mazeRoomKind
+	exits mazeExits
+}
+
+// mazePresets returns the IDs of the room presets of a level type, by kind and exits.
+// The IDs are sorted so every machine picks the same presets from one seed.
+func (g *MapGenerator) mazePresets(levelType *d2records.LevelTypeRecord) map[mazePresetKey][]int {
+	presets := make(map[mazePresetKey][]int)
+
+	for id := range g.asset.Records.Level.Presets {
+		preset := g.asset.Records.Level.Presets[id]
+
+		kind, exits, ok := parseMazePreset(levelType.Name, preset.Name)
+		if !ok {
+			continue
+		}
+
+		key := mazePresetKey{kind, exits}
+		presets[key] = append(presets[key], id)
+	}
+
+	for key := range presets {
+		sort.Ints(presets[key])
+	}
+
+	return presets
+}
+
+// GenerateMaze generates a random maze level, like a cave or a crypt, from the room
+// presets of its level type. The number of rooms and the size of each room come from
+// its LevelMaze record. The layout only depends on the seed of the map engine, so the
+// server and its clients generate the same maze.
+func (g *MapGenerator) GenerateMaze(levelID int, difficulty d2enum.DifficultyType) error {
+	rng := rand.New(rand.NewSource(g.engine.Seed())) //nolint:gosec // must be reproducible
+
+	maze, found := g.asset.Records.Level.Maze[levelID]
+	if !found {
+		return fmt.Errorf("%w: %d", errNoMaze, levelID)
+	}
+
+	details := g.asset.Records.GetLevelDetails(levelID)
+	if details == nil || details.LevelType <= 0 || details.LevelType >= len(g.asset.Records.Level.Types) {
+		return fmt.Errorf("%w: %d has no level type", errNoMaze, levelID)
+	}
+
+	levelType := g.asset.Records.Level.Types[details.LevelType]
+	presets := g.mazePresets(levelType)
+
+	allowed := func(kind mazeRoomKind, exits mazeExits) bool {
+		return len(presets[mazePresetKey{kind, exits}]) > 0
+	}
+
+	numRooms := mazeRoomCount(maze, difficulty)
+
+	rooms := buildMazeGraph(rng, numRooms, allowed)
+	if len(rooms) < numRooms {
+		g.Warningf("Only %d of the %d rooms of %s fit the presets of %s", len(rooms), numRooms, maze.Name,
+			levelType.Name)
+	}
+
+	region := d2enum.RegionIdType(details.LevelType)
+	stamps := make([]*d2mapstamp.Stamp, len(rooms))
+	cellWidth, cellHeight := maze.SizeX, maze.SizeY
+	gridWidth, gridHeight := 0, 0
+
+	for idx, room := range rooms {
+		ids := presets[mazePresetKey{room.kind, room.exits}]
+		if len(ids) == 0 {
+			return fmt.Errorf("%w: %s of %s", errNoMazePreset, room.describe(), levelType.Name)
+		}
+
+		preset := g.asset.Records.Level.Presets[ids[rng.Intn(len(ids))]]
+		fileIndex := 0
+
+		if preset.FileCount > 1 {
+			fileIndex = rng.Intn(preset.FileCount)
+		}
+
+		if stamps[idx] = g.engine.LoadStamp(region, preset.DefinitionID, fileIndex); stamps[idx] == nil {
+			return fmt.Errorf("%w: %s could not be loaded", errNoMazePreset, preset.Name)
+		}
+
+		// cells fit the largest room, should a preset be larger than the maze says
+		size := stamps[idx].Size()
+		if size.Width > cellWidth {
+			cellWidth = size.Width
+		}
+
+		if size.Height > cellHeight {
+			cellHeight = size.Height
+		}
+
+		if room.x >= gridWidth {
+			gridWidth = room.x + 1
+		}
+
+		if room.y >= gridHeight {
+			gridHeight = room.y + 1
+		}
+	}
+
+	g.engine.ResetMap(region, gridWidth*cellWidth, gridHeight*cellHeight)
+	g.engine.SetSubstitutionLevel(levelID)
+
+	for idx, room := range rooms {
+		for _, file := range stamps[idx].LevelPreset().Files {
+			g.engine.AddDS1(file)
+		}
+
+		g.engine.PlaceStamp(stamps[idx], room.x*cellWidth, room.y*cellHeight)
+	}
+
+	g.Infof("Generated %s with %d rooms on a %dx%d grid", maze.Name, len(rooms), gridWidth, gridHeight)
+
+	return nil
+}
+
+// mazeRoomCount returns the number of rooms of a maze in a difficulty.
+func mazeRoomCount(maze *d2records.LevelMazeDetailRecord, difficulty d2enum.DifficultyType) int {
+	count := maze.NumRoomsNormal
+
+	switch difficulty {
+	case d2enum.DifficultyNightmare:
+		count = maze.NumRoomsNightmare
+	case d2enum.DifficultyHell:
+		count = maze.NumRoomsHell
+	}
+
+	if count < minMazeRooms {
+		return minMazeRooms
+	}
+
+	return count
+}
+
+// describe returns the kind and exits of the room, as they appear in preset names.
+func (r *mazeRoom) describe() string {
+	var b strings.Builder
+
+	for name, kind := range mazeRoomKinds {
+		if kind == r.kind && name != "" {
+			b.WriteString(name + " ")
+		}
+	}
+
+	for _, d := range mazeDirections {
+		if r.exits&d.exit != 0 {
+			b.WriteRune(d.letter)
+		}
+	}
+
+	return b.String()
+}
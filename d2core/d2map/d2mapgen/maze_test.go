@@ -0,0 +1,181 @@
+package d2mapgen
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+// anyMazeRoom is a level type with a preset for every room.
+func anyMazeRoom(mazeRoomKind, mazeExits) bool {
+	return true
+}
+
+// corridorMazeRoom is a level type whose rooms have at most two exits, and whose rooms
+// leading out of the maze have one.
+func corridorMazeRoom(kind mazeRoomKind, exits mazeExits) bool {
+	count := 0
+
+	for _, d := range mazeDirections {
+		if exits&d.exit != 0 {
+			count++
+		}
+	}
+
+	if kind != roomPlain {
+		return count == 1
+	}
+
+	return count <= 2
+}
+
+func buildTestMaze(seed int64, numRooms int, allowed mazeRoomAllowed) []*mazeRoom {
+	return buildMazeGraph(rand.New(rand.NewSource(seed)), numRooms, allowed)
+}
+
+func TestBuildMazeGraph_Deterministic(t *testing.T) {
+	first := buildTestMaze(1, 12, anyMazeRoom)
+	second := buildTestMaze(1, 12, anyMazeRoom)
+
+	if len(first) != len(second) {
+		t.Fatalf("expected the same rooms from one seed, got %d and %d", len(first), len(second))
+	}
+
+	for idx := range first {
+		if *first[idx] != *second[idx] {
+			t.Fatalf("expected room %d to be the same from one seed, got %v and %v", idx, *first[idx], *second[idx])
+		}
+	}
+}
+
+func TestBuildMazeGraph_RoomCount(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		if rooms := buildTestMaze(seed, 12, anyMazeRoom); len(rooms) != 12 {
+			t.Fatalf("seed %d: expected 12 rooms, got %d", seed, len(rooms))
+		}
+
+		// a corridor can only grow from its far end, which may be walled in
+		if rooms := buildTestMaze(seed, 12, corridorMazeRoom); len(rooms) < minMazeRooms || len(rooms) > 12 {
+			t.Fatalf("seed %d: expected at most 12 rooms, got %d", seed, len(rooms))
+		}
+	}
+
+	// nothing but the entrance has a preset
+	if rooms := buildTestMaze(1, 12, func(kind mazeRoomKind, _ mazeExits) bool {
+		return kind == roomPrev
+	}); len(rooms) != 1 {
+		t.Fatalf("expected the maze to stop growing without presets, got %d rooms", len(rooms))
+	}
+}
+
+func TestBuildMazeGraph_Shape(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		for _, allowed := range []mazeRoomAllowed{anyMazeRoom, corridorMazeRoom} {
+			checkMazeShape(t, seed, buildTestMaze(seed, 10, allowed), allowed)
+		}
+	}
+}
+
+// checkMazeShape checks a maze has exactly one entrance, which is the first room, and
+// exactly one exit, that every room has a preset and that the rooms open into each other.
+func checkMazeShape(t *testing.T, seed int64, rooms []*mazeRoom, allowed mazeRoomAllowed) {
+	t.Helper()
+
+	prev, next := 0, 0
+	cells := make(map[mazeCell]*mazeRoom)
+
+	for _, room := range rooms {
+		switch room.kind {
+		case roomPrev:
+			prev++
+		case roomNext:
+			next++
+		}
+
+		if room.x < 0 || room.y < 0 {
+			t.Errorf("seed %d: expected the cells to be normalized, got %v", seed, room.mazeCell)
+		}
+
+		if !allowed(room.kind, room.exits) {
+			t.Errorf("seed %d: expected room %s to have a preset", seed, room.describe())
+		}
+
+		cells[room.mazeCell] = room
+	}
+
+	if prev != 1 || rooms[0].kind != roomPrev {
+		t.Errorf("seed %d: expected the first room to be the only entrance, got %d entrances", seed, prev)
+	}
+
+	if next != 1 {
+		t.Errorf("seed %d: expected exactly one exit, got %d", seed, next)
+	}
+
+	for _, room := range rooms {
+		for _, d := range mazeDirections {
+			if room.exits&d.exit == 0 {
+				continue
+			}
+
+			neighbour, found := cells[mazeCell{room.x + d.dx, room.y + d.dy}]
+			if !found || neighbour.exits&d.opposite == 0 {
+				t.Errorf("seed %d: expected the exit %c of %v to open into a room", seed, d.letter, room.mazeCell)
+			}
+		}
+	}
+}
+
+func TestParseMazePreset(t *testing.T) {
+	const levelType = "Act 1 - Cave"
+
+	tests := []struct {
+		preset string
+		kind   mazeRoomKind
+		exits  mazeExits
+		ok     bool
+	}{
+		{"Act 1 - Cave Prev W", roomPrev, exitWest, true},
+		{"Act 1 - Cave Next S", roomNext, exitSouth, true},
+		{"Act 1 - Cave NSE", roomPlain, exitNorth | exitSouth | exitEast, true},
+		{"Act 1 - Cave EW", roomPlain, exitEast | exitWest, true},
+		{"Act 1 - Cave", 0, 0, false},
+		{"Act 1 - Cave Den Of Evil", 0, 0, false},
+		{"Act 1 - Cave Other W", 0, 0, false},
+		{"Act 1 - Cave NN", 0, 0, false},
+		{"Act 1 - Cave NX", 0, 0, false},
+		{"Act 1 - Caves NSE", 0, 0, false},
+		{"Act 2 - Cave NSE", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		kind, exits, ok := parseMazePreset(levelType, test.preset)
+		if ok != test.ok || kind != test.kind || exits != test.exits {
+			t.Errorf("%q: expected %v %v %v, got %v %v %v", test.preset, test.kind, test.exits, test.ok, kind, exits, ok)
+		}
+	}
+}
+
+func TestMazeRoomCount(t *testing.T) {
+	maze := &d2records.LevelMazeDetailRecord{NumRoomsNormal: 6, NumRoomsNightmare: 8, NumRoomsHell: 10}
+
+	tests := []struct {
+		difficulty d2enum.DifficultyType
+		count      int
+	}{
+		{d2enum.DifficultyNormal, 6},
+		{d2enum.DifficultyNightmare, 8},
+		{d2enum.DifficultyHell, 10},
+	}
+
+	for _, test := range tests {
+		if count := mazeRoomCount(maze, test.difficulty); count != test.count {
+			t.Errorf("difficulty %v: expected %d rooms, got %d", test.difficulty, test.count, count)
+		}
+	}
+
+	if count := mazeRoomCount(&d2records.LevelMazeDetailRecord{}, d2enum.DifficultyNormal); count != minMazeRooms {
+		t.Errorf("expected at least %d rooms, got %d", minMazeRooms, count)
+	}
+}
@@ -0,0 +1,412 @@
+package d2mapgen
+
+import (
+	"math/rand"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+const (
+	subtilesPerTile = 5
+
+	monsterDensityScale = 100000 // MonDen is a chance in 100000ths that a pack spawns on a tile
+	maxMonsterDensity   = 10000  // The game allows at most 10%
+
+	championLevelBonus = 2
+	uniqueLevelBonus   = 3
+	championPackMin    = 2
+	championPackMax    = 4
+
+	// packSpread is how far, in sub tiles, the members of a pack may stand from its center.
+	packSpread = 2 * subtilesPerTile
+
+	// startClearance is how far, in tiles, monsters stay from the start of a map.
+	startClearance = 10
+)
+
+// monsterPopulation is what the level details say about the monsters of a level in a
+// difficulty.
+type monsterPopulation struct {
+	types      []string // monstats.txt keys of the monsters which may spawn
+	uniques    []string // Of the champions and uniques, the types in nightmare and hell
+	numTypes   int      // Number of types picked from types
+	level      int      // Area level
+	density    int
+	uniqueMin  int
+	uniqueMax  int
+	clearance  int // Distance, in tiles, monsters keep from the warps
+	difficulty d2enum.DifficultyType
+}
+
+// spawnArea are the tiles of a level monsters may spawn on, and the sub tiles they took.
+type spawnArea struct {
+	tiles    []d2vector.Position // Centers of the tiles, in sub tiles
+	occupied map[[2]int]bool
+}
+
+// Populate spawns the monsters of the levels of a map from their level details: packs
+// of the monster types of the level, as dense as the level says, and the champion and
+// unique packs. The monsters only depend on the seed of the map engine, not on the
+// order the levels are populated in.
+func (g *MapGenerator) Populate(act, levelID int, difficulty d2enum.DifficultyType) {
+	for _, id := range mapLevels(act, levelID) {
+		details := g.asset.Records.GetLevelDetails(id)
+		if details == nil {
+			continue
+		}
+
+		population := g.monsterPopulation(details, difficulty)
+		if population.density <= 0 || population.numTypes <= 0 {
+			continue
+		}
+
+		rng := rand.New(rand.NewSource(g.engine.Seed() + int64(id))) //nolint:gosec // must be reproducible
+
+		spawned := g.populateLevel(rng, details, &population)
+
+		g.Debugf("Spawned %d monsters in %s", spawned, details.Name)
+	}
+}
+
+// monsterPopulation returns the monsters of a level in a difficulty. The expansion
+// columns are used where the level has them.
+func (g *MapGenerator) monsterPopulation(details *d2records.LevelDetailRecord,
+	difficulty d2enum.DifficultyType) monsterPopulation {
+	population := monsterPopulation{
+		numTypes:   details.NumMonsterTypes,
+		clearance:  details.WarpClearanceDistance,
+		difficulty: difficulty,
+	}
+
+	switch difficulty {
+	case d2enum.DifficultyNightmare:
+		population.types = []string{
+			details.MonsterID1Nightmare, details.MonsterID2Nightmare, details.MonsterID3Nightmare,
+			details.MonsterID4Nightmare, details.MonsterID5Nightmare, details.MonsterID6Nightmare,
+			details.MonsterID7Nightmare, details.MonsterID8Nightmare, details.MonsterID9Nightmare,
+			details.MonsterID10Nightmare,
+		}
+		population.level = firstPositive(details.MonsterLevelNightmareEx, details.MonsterLevelNightmare)
+		population.density = details.MonsterDensityNightmare
+		population.uniqueMin, population.uniqueMax = details.MonsterUniqueMinNightmare, details.MonsterUniqueMaxNightmare
+	case d2enum.DifficultyHell:
+		population.types = []string{
+			details.MonsterID1Hell, details.MonsterID2Hell, details.MonsterID3Hell, details.MonsterID4Hell,
+			details.MonsterID5Hell, details.MonsterID6Hell, details.MonsterID7Hell, details.MonsterID8Hell,
+			details.MonsterID9Hell, details.MonsterID10Hell,
+		}
+		population.level = firstPositive(details.MonsterLevelHellEx, details.MonsterLevelHell)
+		population.density = details.MonsterDensityHell
+		population.uniqueMin, population.uniqueMax = details.MonsterUniqueMinHell, details.MonsterUniqueMaxHell
+	default:
+		population.types = []string{
+			details.MonsterID1Normal, details.MonsterID2Normal, details.MonsterID3Normal, details.MonsterID4Normal,
+			details.MonsterID5Normal, details.MonsterID6Normal, details.MonsterID7Normal, details.MonsterID8Normal,
+			details.MonsterID9Normal, details.MonsterID10Normal,
+		}
+		population.uniques = []string{
+			details.MonsterUniqueID1, details.MonsterUniqueID2, details.MonsterUniqueID3, details.MonsterUniqueID4,
+			details.MonsterUniqueID5, details.MonsterUniqueID6, details.MonsterUniqueID7, details.MonsterUniqueID8,
+			details.MonsterUniqueID9, details.MonsterUniqueID10,
+		}
+		population.level = firstPositive(details.MonsterLevelNormalEx, details.MonsterLevelNormal)
+		population.density = details.MonsterDensityNormal
+		population.uniqueMin, population.uniqueMax = details.MonsterUniqueMinNormal, details.MonsterUniqueMaxNormal
+	}
+
+	// the umon columns only work in normal
+	if population.uniques == nil {
+		population.uniques = population.types
+	}
+
+	if population.density > maxMonsterDensity {
+		population.density = maxMonsterDensity
+	}
+
+	return population
+}
+
+// populateLevel spawns the packs of a level, and returns the number of monsters spawned.
+func (g *MapGenerator) populateLevel(rng *rand.Rand, details *d2records.LevelDetailRecord,
+	population *monsterPopulation) int {
+	stats := g.asset.Records.Monster.Stats
+
+	types := pickMonsterTypes(rng, stats, population.types, population.numTypes)
+	if len(types) == 0 {
+		g.Warningf("None of the monsters of %s can spawn", details.Name)
+		return 0
+	}
+
+	uniques := pickMonsterTypes(rng, stats, population.uniques, population.numTypes)
+
+	area := g.spawnArea(d2enum.RegionIdType(details.LevelType), population.clearance)
+	if len(area.tiles) == 0 {
+		return 0
+	}
+
+	spawned := 0
+
+	for _, center := range area.tiles {
+		if rng.Intn(monsterDensityScale) >= population.density {
+			continue
+		}
+
+		monStat := pickByRarity(rng, types)
+		size := randomBetween(rng, monStat.MinionGroupMin, monStat.MinionGroupMax)
+
+		spawned += g.spawnPack(rng, area, center, monStat, d2mapentity.RankNormal, size, population)
+	}
+
+	if len(uniques) == 0 {
+		return spawned
+	}
+
+	numUniques := randomBetween(rng, population.uniqueMin, population.uniqueMax)
+
+	for i := 0; i < numUniques; i++ {
+		center := area.tiles[rng.Intn(len(area.tiles))]
+		monStat := pickByRarity(rng, uniques)
+
+		// champions come in packs, uniques lead a pack of minions of their type
+		if rng.Intn(2) == 0 {
+			size := randomBetween(rng, championPackMin, championPackMax)
+			spawned += g.spawnPack(rng, area, center, monStat, d2mapentity.RankChampion, size, population)
+
+			continue
+		}
+
+		spawned += g.spawnPack(rng, area, center, monStat, d2mapentity.RankUnique, 1, population)
+
+		minions := randomBetween(rng, monStat.MinionPartyMin, monStat.MinionPartyMax)
+		spawned += g.spawnPack(rng, area, center, monStat, d2mapentity.RankNormal, minions, population)
+	}
+
+	return spawned
+}
+
+// pickMonsterTypes picks up to count of the monster types, weighted by their rarity. Types
+// which are disabled, never picked, or missing from monstats.txt are left out.
+func pickMonsterTypes(rng *rand.Rand, stats d2records.MonStats, keys []string, count int) []*d2records.MonStatRecord {
+	candidates := make([]*d2records.MonStatRecord, 0, len(keys))
+
+	for _, key := range keys {
+		monStat := stats[key]
+		if key == "" || monStat == nil || !monStat.Enabled || monStat.Rarity <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, monStat)
+	}
+
+	picked := make([]*d2records.MonStatRecord, 0, count)
+
+	for len(picked) < count && len(candidates) > 0 {
+		monStat := pickByRarity(rng, candidates)
+		picked = append(picked, monStat)
+
+		for idx := range candidates {
+			if candidates[idx] == monStat {
+				candidates = append(candidates[:idx], candidates[idx+1:]...)
+				break
+			}
+		}
+	}
+
+	return picked
+}
+
+// spawnArea returns the tiles of the region monsters may spawn on, which are away from
+// the start of the map and from its warps.
+func (g *MapGenerator) spawnArea(region d2enum.RegionIdType, clearance int) *spawnArea {
+	area := &spawnArea{occupied: make(map[[2]int]bool)}
+	size := g.engine.Size()
+
+	startX, startY := g.engine.GetStartPosition()
+	start := d2vector.NewPositionTile(startX, startY)
+
+	var warps []d2vector.Position
+
+	for _, entity := range g.engine.Entities() {
+		if warp, ok := entity.(*d2mapentity.Warp); ok {
+			warps = append(warps, warp.Position)
+		}
+	}
+
+	for y := 0; y < size.Height; y++ {
+		for x := 0; x < size.Width; x++ {
+			if g.engine.Tile(x, y).RegionType != region {
+				continue
+			}
+
+			center := d2vector.NewPosition(float64(x*subtilesPerTile+subtilesPerTile/2),
+				float64(y*subtilesPerTile+subtilesPerTile/2))
+
+			if center.Distance(&start.Vector) < startClearance*subtilesPerTile {
+				continue
+			}
+
+			if isNearAny(center, warps, float64(clearance*subtilesPerTile)) {
+				continue
+			}
+
+			area.tiles = append(area.tiles, center)
+		}
+	}
+
+	return area
+}
+
+// spawnPack spawns up to size monsters on free sub tiles around the center, which do not
+// block walking, and returns the number spawned.
+func (g *MapGenerator) spawnPack(rng *rand.Rand, area *spawnArea, center d2vector.Position,
+	monStat *d2records.MonStatRecord, rank d2mapentity.MonsterRank, size int, population *monsterPopulation) int {
+	spawned := 0
+
+	for i := 0; i < size; i++ {
+		x, y, found := freeSubtile(rng, area, int(center.X()), int(center.Y()), g.engine.IsWalkable)
+		if !found {
+			break
+		}
+
+		npc, err := g.engine.NewNPC(x, y, monStat, 0)
+		if err != nil {
+			g.Errorf("failed to spawn %s: %s", monStat.Key, err)
+			return spawned
+		}
+
+		g.setMonsterLevel(rng, npc, rank, population)
+
+		area.occupied[[2]int{x, y}] = true
+		g.engine.AddEntity(npc)
+
+		spawned++
+	}
+
+	return spawned
+}
+
+// freeSubtile returns a sub tile near the given one which no monster took, and which
+// is walkable.
+func freeSubtile(rng *rand.Rand, area *spawnArea, centerX, centerY int,
+	walkable func(x, y int) bool) (x, y int, found bool) {
+	const attempts = 8
+
+	for i := 0; i < attempts; i++ {
+		x = centerX + rng.Intn(2*packSpread+1) - packSpread
+		y = centerY + rng.Intn(2*packSpread+1) - packSpread
+
+		if !area.occupied[[2]int{x, y}] && walkable(x, y) {
+			return x, y, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// setMonsterLevel sets the level of a monster, and its hit points and experience, from
+// monlvl.txt. In normal, monsters have the level of their monstats.txt record, and in
+// nightmare and hell the level of their area. Champions and uniques are a few levels
+// higher.
+func (g *MapGenerator) setMonsterLevel(rng *rand.Rand, npc *d2mapentity.NPC, rank d2mapentity.MonsterRank,
+	population *monsterPopulation) {
+	monStat := npc.MonStat()
+	level := population.level
+	minHP, maxHP, experience := monStat.MinHPNormal, monStat.MaxHPNormal, monStat.ExperienceNormal
+
+	switch population.difficulty {
+	case d2enum.DifficultyNightmare:
+		minHP, maxHP, experience = monStat.MinHPNightmare, monStat.MaxHPNightmare, monStat.ExperienceNightmare
+	case d2enum.DifficultyHell:
+		minHP, maxHP, experience = monStat.MinHPHell, monStat.MaxHPHell, monStat.ExperienceHell
+	default:
+		level = firstPositive(monStat.LevelNormal, level)
+	}
+
+	switch rank {
+	case d2mapentity.RankChampion:
+		level += championLevelBonus
+	case d2mapentity.RankUnique:
+		level += uniqueLevelBonus
+	}
+
+	npc.Rank = rank
+	npc.Level = level
+
+	record, found := g.asset.Records.Monster.Levels[level]
+	if !found {
+		return
+	}
+
+	// LAN and single player games use the ladder values
+	values := record.Ladder.Normal
+
+	switch population.difficulty {
+	case d2enum.DifficultyNightmare:
+		values = record.Ladder.Nightmare
+	case d2enum.DifficultyHell:
+		values = record.Ladder.Hell
+	}
+
+	// nolint:gomnd // monlvl.txt values are percentages
+	npc.Hitpoints = randomBetween(rng, minHP, maxHP) * values.Hitpoints / 100
+	// nolint:gomnd // monlvl.txt values are percentages
+	npc.Experience = experience * values.Experience / 100
+}
+
+// pickByRarity picks one of the monster types, weighted by their rarity.
+func pickByRarity(rng *rand.Rand, monStats []*d2records.MonStatRecord) *d2records.MonStatRecord {
+	total := 0
+
+	for _, monStat := range monStats {
+		total += monStat.Rarity
+	}
+
+	if total <= 0 {
+		return monStats[rng.Intn(len(monStats))]
+	}
+
+	roll := rng.Intn(total)
+
+	for _, monStat := range monStats {
+		if roll < monStat.Rarity {
+			return monStat
+		}
+
+		roll -= monStat.Rarity
+	}
+
+	return monStats[len(monStats)-1]
+}
+
+// randomBetween returns a random number between min and max, both included. It returns
+// min if max is not larger.
+func randomBetween(rng *rand.Rand, min, max int) int {
+	if max <= min {
+		return min
+	}
+
+	return min + rng.Intn(max-min+1)
+}
+
+func firstPositive(values ...int) int {
+	for _, value := range values {
+		if value > 0 {
+			return value
+		}
+	}
+
+	return 0
+}
+
+func isNearAny(position d2vector.Position, others []d2vector.Position, distance float64) bool {
+	for idx := range others {
+		if position.Distance(&others[idx].Vector) < distance {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,210 @@
+package d2mapgen
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+func testRNG(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed)) //nolint:gosec // must be reproducible
+}
+
+func testMonStat(key string, rarity int) *d2records.MonStatRecord {
+	return &d2records.MonStatRecord{Key: key, Rarity: rarity, Enabled: true}
+}
+
+func TestRandomBetween(t *testing.T) {
+	tests := []struct {
+		min, max int
+		values   int // Number of values between min and max
+	}{
+		{2, 4, 3},
+		{0, 0, 1},
+		{5, 5, 1},
+		{5, 3, 1}, // max is not larger, min is returned
+	}
+
+	for _, test := range tests {
+		rng := testRNG(1)
+		seen := make(map[int]bool)
+
+		for i := 0; i < 100; i++ {
+			value := randomBetween(rng, test.min, test.max)
+			if value < test.min || (test.max > test.min && value > test.max) {
+				t.Fatalf("%d-%d: expected a value between them, got %d", test.min, test.max, value)
+			}
+
+			seen[value] = true
+		}
+
+		if len(seen) != test.values {
+			t.Errorf("%d-%d: expected %d values, got %v", test.min, test.max, test.values, seen)
+		}
+	}
+}
+
+func TestPickByRarity(t *testing.T) {
+	common, rare, never := testMonStat("common", 3), testMonStat("rare", 1), testMonStat("never", 0)
+	monStats := []*d2records.MonStatRecord{never, common, rare}
+
+	const rolls = 4000
+
+	rng := testRNG(1)
+	picked := make(map[*d2records.MonStatRecord]int)
+
+	for i := 0; i < rolls; i++ {
+		picked[pickByRarity(rng, monStats)]++
+	}
+
+	if picked[never] != 0 {
+		t.Errorf("expected a type without rarity never to be picked, got %d", picked[never])
+	}
+
+	// common is three times as likely as rare
+	if picked[common] < 2*picked[rare] || picked[common] > 4*picked[rare] {
+		t.Errorf("expected the picks to follow the rarities, got %d common and %d rare", picked[common], picked[rare])
+	}
+
+	first, second := testRNG(2), testRNG(2)
+
+	for i := 0; i < 100; i++ {
+		if pickByRarity(first, monStats) != pickByRarity(second, monStats) {
+			t.Fatal("expected the same picks from one seed")
+		}
+	}
+
+	// without any rarity, any of them is picked
+	if monStat := pickByRarity(rng, []*d2records.MonStatRecord{never}); monStat != never {
+		t.Errorf("expected %s, got %s", never.Key, monStat.Key)
+	}
+}
+
+func TestPickMonsterTypes(t *testing.T) {
+	disabled := testMonStat("disabled", 1)
+	disabled.Enabled = false
+
+	stats := d2records.MonStats{
+		"a":        testMonStat("a", 1),
+		"b":        testMonStat("b", 2),
+		"c":        testMonStat("c", 3),
+		"never":    testMonStat("never", 0),
+		"disabled": disabled,
+	}
+
+	keys := []string{"", "a", "missing", "b", "never", "c", "disabled"}
+
+	tests := []struct {
+		count    int
+		expected int
+	}{
+		{0, 0},
+		{2, 2},
+		{3, 3},
+		{10, 3}, // only a, b and c can spawn
+	}
+
+	for _, test := range tests {
+		picked := pickMonsterTypes(testRNG(1), stats, keys, test.count)
+		if len(picked) != test.expected {
+			t.Errorf("count %d: expected %d types, got %d", test.count, test.expected, len(picked))
+		}
+
+		seen := make(map[string]bool)
+
+		for _, monStat := range picked {
+			switch monStat.Key {
+			case "a", "b", "c":
+			default:
+				t.Errorf("count %d: expected %s not to be picked", test.count, monStat.Key)
+			}
+
+			if seen[monStat.Key] {
+				t.Errorf("count %d: expected %s to be picked once", test.count, monStat.Key)
+			}
+
+			seen[monStat.Key] = true
+		}
+	}
+
+	first := pickMonsterTypes(testRNG(3), stats, keys, 2)
+	second := pickMonsterTypes(testRNG(3), stats, keys, 2)
+
+	for idx := range first {
+		if first[idx] != second[idx] {
+			t.Fatalf("expected the same types from one seed, got %s and %s", first[idx].Key, second[idx].Key)
+		}
+	}
+}
+
+// testBlockWalk is a map whose sub tiles block walking on every other column, and
+// everywhere outside of 0-39.
+func testBlockWalk(x, y int) bool {
+	return x < 0 || y < 0 || x >= 40 || y >= 40 || x%2 == 1
+}
+
+// spawnTestPack places a pack of size around the center the way spawnPack does, and
+// returns the sub tiles of its monsters.
+func spawnTestPack(seed int64, size int, walkable func(x, y int) bool) [][2]int {
+	rng := testRNG(seed)
+	area := &spawnArea{occupied: make(map[[2]int]bool)}
+
+	var placed [][2]int
+
+	for i := 0; i < size; i++ {
+		x, y, found := freeSubtile(rng, area, 20, 20, walkable)
+		if !found {
+			break
+		}
+
+		area.occupied[[2]int{x, y}] = true
+		placed = append(placed, [2]int{x, y})
+	}
+
+	return placed
+}
+
+func TestFreeSubtile(t *testing.T) {
+	walkable := func(x, y int) bool { return !testBlockWalk(x, y) }
+
+	for seed := int64(0); seed < 20; seed++ {
+		placed := spawnTestPack(seed, 20, walkable)
+		if len(placed) == 0 {
+			t.Fatalf("seed %d: expected monsters to be placed", seed)
+		}
+
+		seen := make(map[[2]int]bool)
+
+		for _, at := range placed {
+			if testBlockWalk(at[0], at[1]) {
+				t.Errorf("seed %d: expected no monster on a sub tile blocking walking, got %v", seed, at)
+			}
+
+			if at[0] < 20-packSpread || at[0] > 20+packSpread || at[1] < 20-packSpread || at[1] > 20+packSpread {
+				t.Errorf("seed %d: expected the monsters near the center, got %v", seed, at)
+			}
+
+			if seen[at] {
+				t.Errorf("seed %d: expected one monster per sub tile, got two on %v", seed, at)
+			}
+
+			seen[at] = true
+		}
+
+		again := spawnTestPack(seed, 20, walkable)
+		if len(again) != len(placed) {
+			t.Fatalf("seed %d: expected the same pack from one seed, got %d and %d monsters", seed, len(placed), len(again))
+		}
+
+		for idx := range placed {
+			if placed[idx] != again[idx] {
+				t.Fatalf("seed %d: expected the same pack from one seed, got %v and %v", seed, placed[idx], again[idx])
+			}
+		}
+	}
+
+	if placed := spawnTestPack(1, 5, func(x, y int) bool { return false }); len(placed) != 0 {
+		t.Errorf("expected no monsters where everything blocks walking, got %v", placed)
+	}
+}
@@ -0,0 +1,419 @@
+package d2mapgen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2fileformats/d2ds1"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2geom"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapstamp"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+const (
+	wildernessFillStamps   = 25 // As many as in the Act 1 wilderness
+	wildernessFillAttempts = 40 // Per stamp, before the wilderness is left emptier
+)
+
+var (
+	errUnknownAct   = errors.New("unknown act")
+	errUnknownLevel = errors.New("unknown level")
+	errNoPreset     = errors.New("no preset for the level")
+)
+
+// overworld is the town of an act and the wilderness next to it, by level ID.
+type overworld struct {
+	town, wilderness int
+}
+
+// overworlds are the towns and wildernesses of the acts. All but the first act are
+// generated from their records, the first act has its own generator.
+var overworlds = map[int]overworld{
+	1: {town: 1, wilderness: 2},     // The Rogue Encampment and the Blood Moor
+	2: {town: 40, wilderness: 41},   // Lut Gholein and the Rocky Waste
+	3: {town: 75, wilderness: 76},   // Kurast Docks and the Spider Forest
+	4: {town: 103, wilderness: 104}, // The Pandemonium Fortress and the Outer Steppes
+	5: {town: 109, wilderness: 110}, // Harrogath and the Bloody Foothills
+}
+
+// mapKind is how the map of a level is generated.
+type mapKind int
+
+// Map kinds
+const (
+	overworldMap mapKind = iota // The town and the wilderness of an act
+	mazeMap                     // Rooms of a maze, see GenerateMaze
+	presetMap                   // One preset, see GeneratePreset
+)
+
+// levelMapKind returns how the map of a level is generated. Level 0 is the overworld of
+// an act, and levels with a LevelMaze record are mazes.
+func levelMapKind(levelID int, mazes d2records.LevelMazeDetails) mapKind {
+	if levelID == 0 {
+		return overworldMap
+	}
+
+	if _, isMaze := mazes[levelID]; isMaze {
+		return mazeMap
+	}
+
+	return presetMap
+}
+
+// Generate generates a level, or the overworld of the act if the level ID is 0. Mazes
+// are generated from their rooms, and the other levels from their preset. Warps are
+// placed where the map leads to other levels.
+func (g *MapGenerator) Generate(act, levelID int, difficulty d2enum.DifficultyType) error {
+	var err error
+
+	switch levelMapKind(levelID, g.asset.Records.Level.Maze) {
+	case overworldMap:
+		err = g.GenerateOverworld(act, difficulty)
+	case mazeMap:
+		err = g.GenerateMaze(levelID, difficulty)
+	default:
+		err = g.GeneratePreset(levelID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	g.placeWarps(mapLevels(act, levelID))
+
+	return nil
+}
+
+// GenerateOverworld generates the town of an act and the wilderness next to it.
+func (g *MapGenerator) GenerateOverworld(act int, difficulty d2enum.DifficultyType) error {
+	if act == 1 {
+		g.GenerateAct1Overworld()
+		return nil
+	}
+
+	levels, found := overworlds[act]
+	if !found {
+		return fmt.Errorf("%w: %d", errUnknownAct, act)
+	}
+
+	return g.generateOverworld(levels, difficulty)
+}
+
+// GeneratePreset generates a level made of one preset, like a town or a tomb.
+func (g *MapGenerator) GeneratePreset(levelID int) error {
+	rng := rand.New(rand.NewSource(g.engine.Seed())) //nolint:gosec // must be reproducible
+
+	region, err := g.levelRegion(levelID)
+	if err != nil {
+		return err
+	}
+
+	stamp, err := g.loadLevelStamp(rng, region, levelID)
+	if err != nil {
+		return err
+	}
+
+	size := stamp.Size()
+
+	g.engine.ResetMap(region, size.Width, size.Height)
+	g.addPresetDS1s(stamp)
+	g.engine.PlaceStamp(stamp, 0, 0)
+
+	return nil
+}
+
+// generateOverworld places the town where its exit leads into the wilderness, and
+// fills the wilderness with the fill presets of its level type.
+func (g *MapGenerator) generateOverworld(levels overworld, difficulty d2enum.DifficultyType) error {
+	rng := rand.New(rand.NewSource(g.engine.Seed())) //nolint:gosec // must be reproducible
+
+	townRegion, err := g.levelRegion(levels.town)
+	if err != nil {
+		return err
+	}
+
+	wildRegion, err := g.levelRegion(levels.wilderness)
+	if err != nil {
+		return err
+	}
+
+	townStamp, err := g.loadLevelStamp(rng, townRegion, levels.town)
+	if err != nil {
+		return err
+	}
+
+	wildDetails := g.asset.Records.GetLevelDetails(levels.wilderness)
+	wildWidth, wildHeight := levelSize(wildDetails, difficulty)
+	townSize := townStamp.Size()
+	side := townExit(townStamp.RegionPath())
+
+	layout := layoutOverworld(townSize, d2geom.Size{Width: wildWidth, Height: wildHeight}, side)
+
+	g.Infof("Region Path: %s, the wilderness is to the %c", townStamp.RegionPath(), side)
+
+	g.engine.ResetMap(townRegion, layout.size.Width, layout.size.Height)
+	g.engine.SetSubstitutionLevel(levels.wilderness)
+	g.addPresetDS1s(townStamp)
+	g.engine.PlaceStamp(townStamp, layout.town.X, layout.town.Y)
+
+	g.fillWilderness(layout.wilderness, wildRegion)
+
+	stamps := g.wildernessFillStamps(wildRegion, layout.wilderness)
+	placed := g.scatterStamps(rng, layout.wilderness, stamps, wildernessFillStamps)
+
+	g.Debugf("Placed %d fill stamps in %s", placed, wildDetails.Name)
+
+	return nil
+}
+
+// levelRegion returns the region of the level type of a level.
+func (g *MapGenerator) levelRegion(levelID int) (d2enum.RegionIdType, error) {
+	details := g.asset.Records.GetLevelDetails(levelID)
+	if details == nil {
+		return d2enum.RegionNone, fmt.Errorf("%w: %d", errUnknownLevel, levelID)
+	}
+
+	if details.LevelType <= 0 || details.LevelType >= len(g.asset.Records.Level.Types) {
+		return d2enum.RegionNone, fmt.Errorf("%w: %s has no level type", errUnknownLevel, details.Name)
+	}
+
+	return d2enum.RegionIdType(details.LevelType), nil
+}
+
+// loadLevelStamp loads the preset of a level, with one of its files picked at random.
+func (g *MapGenerator) loadLevelStamp(rng *rand.Rand, region d2enum.RegionIdType, levelID int) (*d2mapstamp.Stamp,
+	error) {
+	ids := g.presetIDs(func(preset *d2records.LevelPresetRecord) bool {
+		return preset.LevelID == levelID
+	})
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %d", errNoPreset, levelID)
+	}
+
+	stamp := g.engine.LoadStamp(region, ids[0], presetFileIndex(rng, g.asset.Records.Level.Presets[ids[0]]))
+	if stamp == nil {
+		return nil, fmt.Errorf("%w: %d could not be loaded", errNoPreset, levelID)
+	}
+
+	return stamp, nil
+}
+
+// presetIDs returns the sorted IDs of the presets which match, so every machine picks
+// the same presets from one seed.
+func (g *MapGenerator) presetIDs(match func(preset *d2records.LevelPresetRecord) bool) []int {
+	var ids []int
+
+	for id := range g.asset.Records.Level.Presets {
+		preset := g.asset.Records.Level.Presets[id]
+		if match(&preset) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
+
+// presetFileIndex picks one of the files of a preset.
+func presetFileIndex(rng *rand.Rand, preset d2records.LevelPresetRecord) int {
+	if preset.FileCount > 1 {
+		return rng.Intn(preset.FileCount)
+	}
+
+	return 0
+}
+
+// addPresetDS1s loads the tiles of the files of the preset of a stamp.
+func (g *MapGenerator) addPresetDS1s(stamp *d2mapstamp.Stamp) {
+	for _, file := range stamp.LevelPreset().Files {
+		g.engine.AddDS1(file)
+	}
+}
+
+// levelSize returns the size of a level in a difficulty, in tiles.
+func levelSize(details *d2records.LevelDetailRecord, difficulty d2enum.DifficultyType) (width, height int) {
+	switch difficulty {
+	case d2enum.DifficultyNightmare:
+		return details.SizeXNightmare, details.SizeYNightmare
+	case d2enum.DifficultyHell:
+		return details.SizeXHell, details.SizeYHell
+	}
+
+	return details.SizeXNormal, details.SizeYNormal
+}
+
+// townExit returns the side of the town which leads into the wilderness. The town
+// files are named after it, like townE1.ds1 in the first act. It is the east when the
+// file does not tell.
+func townExit(regionPath string) rune {
+	name := strings.ToUpper(path.Base(strings.ReplaceAll(regionPath, "\\", "/")))
+	name = strings.TrimRight(strings.TrimSuffix(name, ".DS1"), "0123456789")
+
+	if len(name) == len("townE") && strings.HasPrefix(name, "TOWN") {
+		switch side := rune(name[len(name)-1]); side {
+		case 'N', 'S', 'E', 'W':
+			return side
+		}
+	}
+
+	return 'E'
+}
+
+// overworldLayout is where the town and the wilderness of an overworld are, in tiles.
+type overworldLayout struct {
+	size       d2geom.Size
+	town       d2geom.Point
+	wilderness d2geom.Rectangle
+}
+
+// layoutOverworld puts the wilderness on the side of the town its exit is on, centered
+// on the town.
+// nolint:gomnd // halves of sizes
+func layoutOverworld(town, wilderness d2geom.Size, side rune) overworldLayout {
+	var l overworldLayout
+
+	switch side {
+	case 'N', 'S':
+		l.size = d2geom.Size{Width: maxInt(town.Width, wilderness.Width), Height: town.Height + wilderness.Height}
+		l.town.X = (l.size.Width - town.Width) / 2
+		l.wilderness.Left = (l.size.Width - wilderness.Width) / 2
+	default:
+		l.size = d2geom.Size{Width: town.Width + wilderness.Width, Height: maxInt(town.Height, wilderness.Height)}
+		l.town.Y = (l.size.Height - town.Height) / 2
+		l.wilderness.Top = (l.size.Height - wilderness.Height) / 2
+	}
+
+	switch side {
+	case 'N':
+		l.town.Y = wilderness.Height
+	case 'S':
+		l.wilderness.Top = town.Height
+	case 'W':
+		l.town.X = wilderness.Width
+	default:
+		l.wilderness.Left = town.Width
+	}
+
+	l.wilderness.Width, l.wilderness.Height = wilderness.Width, wilderness.Height
+
+	return l
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// fillWilderness covers an area with the plain floor of a wilderness, which fill stamps
+// may then be placed on.
+func (g *MapGenerator) fillWilderness(rect d2geom.Rectangle, region d2enum.RegionIdType) {
+	for y := 0; y < rect.Height; y++ {
+		for x := 0; x < rect.Width; x++ {
+			tile := g.engine.Tile(rect.Left+x, rect.Top+y)
+			tile.RegionType = region
+			floorTile := d2ds1.Tile{}
+			floorTile.Prop1 = 1
+			tile.Components.Floors = []d2ds1.Tile{floorTile}
+			tile.PrepareTile(x, y, g.engine)
+		}
+	}
+}
+
+// wildernessFillStamps loads the fill presets of the level type of a wilderness which
+// fit in it. They are the presets named after the level type which are not a level of
+// their own, a border or a maze room.
+func (g *MapGenerator) wildernessFillStamps(region d2enum.RegionIdType, rect d2geom.Rectangle) []*d2mapstamp.Stamp {
+	levelType := g.asset.Records.Level.Types[region]
+
+	ids := g.presetIDs(func(preset *d2records.LevelPresetRecord) bool {
+		if preset.LevelID != 0 || !strings.HasPrefix(preset.Name, levelType.Name+" ") {
+			return false
+		}
+
+		if _, _, room := parseMazePreset(levelType.Name, preset.Name); room {
+			return false
+		}
+
+		return !strings.Contains(preset.Name, "Border") && preset.SizeX < rect.Width && preset.SizeY < rect.Height
+	})
+
+	stamps := make([]*d2mapstamp.Stamp, 0, len(ids))
+
+	for _, id := range ids {
+		stamp := g.engine.LoadStamp(region, id, 0)
+		if stamp == nil {
+			continue
+		}
+
+		g.addPresetDS1s(stamp)
+
+		stamps = append(stamps, stamp)
+	}
+
+	return stamps
+}
+
+// scatterStamps places up to count stamps at random where the wilderness is still
+// empty, and returns how many were placed.
+func (g *MapGenerator) scatterStamps(rng *rand.Rand, rect d2geom.Rectangle, stamps []*d2mapstamp.Stamp,
+	count int) int {
+	sizes := make([]d2geom.Size, len(stamps))
+	for idx := range stamps {
+		sizes[idx] = stamps[idx].Size()
+	}
+
+	return scatter(rng, rect, sizes, count, func(idx int, stampRect d2geom.Rectangle) bool {
+		if !areaEmpty(g.engine, stampRect) {
+			return false
+		}
+
+		g.engine.PlaceStamp(stamps[idx], stampRect.Left, stampRect.Top)
+
+		return true
+	})
+}
+
+// scatter picks up to count rectangles of the given sizes at random in rect, and
+// returns how many place accepted. Each attempt picks a size and a position for it;
+// place is given the index of the size and the rectangle. The attempts are bounded, so
+// a crowded rect is left emptier.
+func scatter(rng *rand.Rand, rect d2geom.Rectangle, sizes []d2geom.Size, count int,
+	place func(idx int, at d2geom.Rectangle) bool) int {
+	if len(sizes) == 0 {
+		return 0
+	}
+
+	placed := 0
+
+	for attempt := 0; placed < count && attempt < count*wildernessFillAttempts; attempt++ {
+		idx := rng.Intn(len(sizes))
+		size := sizes[idx]
+
+		if size.Width >= rect.Width || size.Height >= rect.Height {
+			continue
+		}
+
+		at := d2geom.Rectangle{
+			Left:   rect.Left + rng.Intn(rect.Width-size.Width),
+			Top:    rect.Top + rng.Intn(rect.Height-size.Height),
+			Width:  size.Width,
+			Height: size.Height,
+		}
+
+		if place(idx, at) {
+			placed++
+		}
+	}
+
+	return placed
+}
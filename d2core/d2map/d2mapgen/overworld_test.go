@@ -0,0 +1,132 @@
+package d2mapgen
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2geom"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+func TestLevelMapKind(t *testing.T) {
+	mazes := d2records.LevelMazeDetails{8: &d2records.LevelMazeDetailRecord{}}
+
+	tests := []struct {
+		levelID int
+		kind    mapKind
+	}{
+		{0, overworldMap},
+		{8, mazeMap},
+		{1, presetMap},
+	}
+
+	for _, test := range tests {
+		if kind := levelMapKind(test.levelID, mazes); kind != test.kind {
+			t.Errorf("level %d: expected kind %d, got %d", test.levelID, test.kind, kind)
+		}
+	}
+}
+
+func TestPresetFileIndex(t *testing.T) {
+	for _, fileCount := range []int{0, 1} {
+		rng := rand.New(rand.NewSource(1))
+
+		if idx := presetFileIndex(rng, d2records.LevelPresetRecord{FileCount: fileCount}); idx != 0 {
+			t.Errorf("expected the only file of a preset with %d files, got %d", fileCount, idx)
+		}
+
+		// a preset with one file does not use up a number, so the map is the same either way
+		if rng.Int63() != rand.New(rand.NewSource(1)).Int63() {
+			t.Errorf("expected a preset with %d files not to draw a number", fileCount)
+		}
+	}
+
+	preset := d2records.LevelPresetRecord{FileCount: 4}
+	seen := make(map[int]bool)
+
+	for seed := int64(0); seed < 50; seed++ {
+		idx := presetFileIndex(rand.New(rand.NewSource(seed)), preset)
+		if idx < 0 || idx >= preset.FileCount {
+			t.Fatalf("seed %d: expected a file of the preset, got %d", seed, idx)
+		}
+
+		if again := presetFileIndex(rand.New(rand.NewSource(seed)), preset); again != idx {
+			t.Fatalf("seed %d: expected the same file from one seed, got %d and %d", seed, idx, again)
+		}
+
+		seen[idx] = true
+	}
+
+	if len(seen) != preset.FileCount {
+		t.Errorf("expected every file to be picked, got %v", seen)
+	}
+}
+
+type scatterPlacement struct {
+	idx int
+	at  d2geom.Rectangle
+}
+
+// scatterTest scatters the sizes in rect without overlaps, and returns where they went.
+func scatterTest(seed int64, rect d2geom.Rectangle, sizes []d2geom.Size, count int) []scatterPlacement {
+	var placements []scatterPlacement
+
+	scatter(rand.New(rand.NewSource(seed)), rect, sizes, count, func(idx int, at d2geom.Rectangle) bool {
+		for _, p := range placements {
+			if at.Left < p.at.Right() && p.at.Left < at.Right() && at.Top < p.at.Bottom() && p.at.Top < at.Bottom() {
+				return false
+			}
+		}
+
+		placements = append(placements, scatterPlacement{idx, at})
+
+		return true
+	})
+
+	return placements
+}
+
+func TestScatter(t *testing.T) {
+	rect := d2geom.Rectangle{Left: 10, Top: 20, Width: 60, Height: 40}
+	sizes := []d2geom.Size{{Width: 8, Height: 8}, {Width: 12, Height: 6}, {Width: 70, Height: 2}}
+
+	placements := scatterTest(1, rect, sizes, 10)
+	if len(placements) != 10 {
+		t.Fatalf("expected 10 stamps, got %d", len(placements))
+	}
+
+	for _, p := range placements {
+		if p.idx == 2 {
+			t.Errorf("expected a stamp wider than the wilderness not to be placed, got %v", p.at)
+		}
+
+		if p.at.Left < rect.Left || p.at.Top < rect.Top || p.at.Right() > rect.Right() || p.at.Bottom() > rect.Bottom() {
+			t.Errorf("expected %v to be in %v", p.at, rect)
+		}
+
+		if p.at.Width != sizes[p.idx].Width || p.at.Height != sizes[p.idx].Height {
+			t.Errorf("expected stamp %d to keep its size, got %v", p.idx, p.at)
+		}
+	}
+
+	again := scatterTest(1, rect, sizes, 10)
+	for idx := range placements {
+		if placements[idx] != again[idx] {
+			t.Fatalf("expected the same stamps from one seed, got %v and %v", placements[idx], again[idx])
+		}
+	}
+}
+
+func TestScatterGivesUp(t *testing.T) {
+	rect := d2geom.Rectangle{Width: 20, Height: 20}
+	sizes := []d2geom.Size{{Width: 15, Height: 15}}
+
+	// only one stamp fits, the others are given up on
+	if placements := scatterTest(1, rect, sizes, 5); len(placements) != 1 {
+		t.Fatalf("expected one stamp to fit, got %d", len(placements))
+	}
+
+	if placed := scatter(rand.New(rand.NewSource(1)), rect, nil, 5, nil); placed != 0 {
+		t.Fatalf("expected no stamps without sizes, got %d", placed)
+	}
+}
@@ -0,0 +1,139 @@
+package d2mapgen
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+)
+
+const (
+	// visTileStyleLeft and visTileStyleRight are the styles of the special tiles which
+	// mark where the Vis of a level are. The sequence of the tile is the Vis number.
+	visTileStyleLeft  = 8
+	visTileStyleRight = 9
+
+	// fallbackWarpSpacing is how far apart, in tiles, the warps of a map whose tiles do not
+	// mark them are placed next to its start position.
+	fallbackWarpSpacing = 3
+)
+
+// levelLink is a Vis of a level: the level it leads to, and the lvlwarp.txt record of
+// its warp, if it has one. Vis without a warp are open borders between two areas.
+type levelLink struct {
+	level, warp int
+}
+
+// levelLinks returns the Vis of a level.
+func levelLinks(details *d2records.LevelDetailRecord) []levelLink {
+	return []levelLink{
+		{details.LevelLinkID0, details.WarpGraphicsID0},
+		{details.LevelLinkID1, details.WarpGraphicsID1},
+		{details.LevelLinkID2, details.WarpGraphicsID2},
+		{details.LevelLinkID3, details.WarpGraphicsID3},
+		{details.LevelLinkID4, details.WarpGraphicsID4},
+		{details.LevelLinkID5, details.WarpGraphicsID5},
+		{details.LevelLinkID6, details.WarpGraphicsID6},
+		{details.LevelLinkID7, details.WarpGraphicsID7},
+	}
+}
+
+// OverworldAct returns the act whose overworld has the level, if it is a town or the
+// wilderness next to it. These are generated together, see GenerateOverworld.
+func OverworldAct(levelID int) (act int, ok bool) {
+	for act, levels := range overworlds {
+		if levels.town == levelID || levels.wilderness == levelID {
+			return act, true
+		}
+	}
+
+	return 0, false
+}
+
+// mapLevels returns the levels which make up the map of the act or level, see Generate.
+func mapLevels(act, levelID int) []int {
+	if levelID != 0 {
+		return []int{levelID}
+	}
+
+	levels := overworlds[act]
+
+	return []int{levels.town, levels.wilderness}
+}
+
+// placeWarps adds a warp to the map for each Vis of its levels which leads to another
+// map. The warps are placed on the special tiles which mark the Vis, or next to the
+// start position if the tiles of the map do not mark them.
+func (g *MapGenerator) placeWarps(levels []int) {
+	fallback := 0
+
+	for _, levelID := range levels {
+		details := g.asset.Records.GetLevelDetails(levelID)
+		if details == nil {
+			continue
+		}
+
+		for vis, link := range levelLinks(details) {
+			if link.level <= 0 || link.warp < 0 || containsLevel(levels, link.level) {
+				continue
+			}
+
+			record, found := g.asset.Records.Level.Warp[link.warp]
+			if !found {
+				continue
+			}
+
+			destination := g.asset.Records.GetLevelDetails(link.level)
+			if destination == nil {
+				continue
+			}
+
+			x, y, found := g.findVisTile(d2enum.RegionIdType(details.LevelType), vis)
+			if !found {
+				startX, startY := g.engine.GetStartPosition()
+				fallback++
+				x, y = int(startX)+fallback*fallbackWarpSpacing, int(startY)
+
+				g.Debugf("No tile marks Vis %d of %s, its warp is placed next to the start", vis, details.Name)
+			}
+
+			name := g.asset.TranslateString(destination.LevelWarpName)
+
+			g.engine.AddEntity(d2mapentity.NewWarp(x, y, levelID, link.level, record, name))
+		}
+	}
+}
+
+// findVisTile returns the tile marking a Vis, among the tiles of the region.
+func (g *MapGenerator) findVisTile(region d2enum.RegionIdType, vis int) (x, y int, found bool) {
+	size := g.engine.Size()
+
+	for y = 0; y < size.Height; y++ {
+		for x = 0; x < size.Width; x++ {
+			tile := g.engine.Tile(x, y)
+			if tile.RegionType != region {
+				continue
+			}
+
+			for idx := range tile.Components.Walls {
+				wall := &tile.Components.Walls[idx]
+
+				isVis := wall.Style == visTileStyleLeft || wall.Style == visTileStyleRight
+				if wall.Type.Special() && isVis && int(wall.Sequence) == vis {
+					return x, y, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+func containsLevel(levels []int, levelID int) bool {
+	for _, id := range levels {
+		if id == levelID {
+			return true
+		}
+	}
+
+	return false
+}
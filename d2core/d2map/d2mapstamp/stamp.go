@@ -66,32 +66,43 @@ type Tile struct {
 
 // Tile returns the tile at the given x and y tile coordinates.
 func (mr *Stamp) Tile(x, y int) *Tile {
+	return DS1Tile(mr.ds1, x, y)
+}
+
+// DS1Tile returns the tile of a DS1 file at the given x and y tile coordinates.
+func DS1Tile(ds1 *d2ds1.DS1, x, y int) *Tile {
 	t := &Tile{
-		Walls:         make([]d2ds1.Tile, len(mr.ds1.Walls)),
-		Floors:        make([]d2ds1.Tile, len(mr.ds1.Floors)),
-		Shadows:       make([]d2ds1.Tile, len(mr.ds1.Shadows)),
-		Substitutions: make([]d2ds1.Tile, len(mr.ds1.Substitutions)),
+		Walls:         make([]d2ds1.Tile, len(ds1.Walls)),
+		Floors:        make([]d2ds1.Tile, len(ds1.Floors)),
+		Shadows:       make([]d2ds1.Tile, len(ds1.Shadows)),
+		Substitutions: make([]d2ds1.Tile, len(ds1.Substitutions)),
 	}
 
-	for idx := range mr.ds1.Walls {
-		t.Walls[idx] = *mr.ds1.Walls[idx].Tile(x, y)
+	for idx := range ds1.Walls {
+		t.Walls[idx] = *ds1.Walls[idx].Tile(x, y)
 	}
 
-	for idx := range mr.ds1.Floors {
-		t.Floors[idx] = *mr.ds1.Floors[idx].Tile(x, y)
+	for idx := range ds1.Floors {
+		t.Floors[idx] = *ds1.Floors[idx].Tile(x, y)
 	}
 
-	for idx := range mr.ds1.Shadows {
-		t.Shadows[idx] = *mr.ds1.Shadows[idx].Tile(x, y)
+	for idx := range ds1.Shadows {
+		t.Shadows[idx] = *ds1.Shadows[idx].Tile(x, y)
 	}
 
-	for idx := range mr.ds1.Substitutions {
-		t.Substitutions[idx] = *mr.ds1.Substitutions[idx].Tile(x, y)
+	for idx := range ds1.Substitutions {
+		t.Substitutions[idx] = *ds1.Substitutions[idx].Tile(x, y)
 	}
 
 	return t
 }
 
+// SubstitutionGroups returns the areas of the stamp whose tiles may be replaced by the
+// substitutions of the level it is placed in, see lvlsub.txt.
+func (mr *Stamp) SubstitutionGroups() []d2ds1.SubstitutionGroup {
+	return mr.ds1.SubstitutionGroups
+}
+
 // TileData returns the tile data for the tile with given style, sequence and type.
 func (mr *Stamp) TileData(style, sequence int32, tileType d2enum.TileType) *d2dt1.Tile {
 	for idx := range mr.tiles {
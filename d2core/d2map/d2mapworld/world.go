@@ -0,0 +1,255 @@
+// Package d2mapworld holds the maps of a game, which are generated when a player first
+// goes to them, and tells where the warps between them lead.
+package d2mapworld
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapgen"
+)
+
+const (
+	logPrefix = "Map World"
+
+	// actSeedShift separates the seeds of the maps of each act, see Seed.
+	actSeedShift = 16
+
+	// initialMapSize is the size the maps are reset to before they are generated.
+	initialMapSize = 100
+)
+
+var errUnknownLevel = errors.New("unknown level")
+
+// Area is a map of the world: the overworld of an act when LevelID is 0, the town and
+// the wilderness next to it, or else a level.
+type Area struct {
+	Act, LevelID int
+}
+
+// World holds the maps of a game. A map is generated from the seed of the game the
+// first time it is asked for, so the players only wait for the maps they go to.
+type World struct {
+	sync.Mutex
+	asset      *d2asset.AssetManager
+	logLevel   d2util.LogLevel
+	seed       int64
+	difficulty d2enum.DifficultyType
+	maps       map[Area]*d2mapengine.MapEngine
+	generating map[Area]chan struct{} // Closed once the map of the area is generated
+
+	*d2util.Logger
+}
+
+// NewWorld creates a world whose maps are generated from the given seed.
+func NewWorld(asset *d2asset.AssetManager, l d2util.LogLevel, seed int64) *World {
+	world := &World{
+		asset:      asset,
+		logLevel:   l,
+		seed:       seed,
+		maps:       make(map[Area]*d2mapengine.MapEngine),
+		generating: make(map[Area]chan struct{}),
+	}
+
+	world.Logger = d2util.NewLogger()
+	world.Logger.SetPrefix(logPrefix)
+	world.Logger.SetLevel(l)
+
+	return world
+}
+
+// SetDifficulty changes the difficulty the maps are generated for. The maps generated
+// so far are dropped.
+func (w *World) SetDifficulty(difficulty d2enum.DifficultyType) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.difficulty = difficulty
+	w.maps = make(map[Area]*d2mapengine.MapEngine)
+}
+
+// Difficulty returns the difficulty the maps are generated for.
+func (w *World) Difficulty() d2enum.DifficultyType {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.difficulty
+}
+
+// AreaOf returns the area of a level. The towns and the wildernesses next to them are
+// in the overworld of their act.
+func (w *World) AreaOf(levelID int) (Area, error) {
+	if act, ok := d2mapgen.OverworldAct(levelID); ok {
+		return Area{Act: act}, nil
+	}
+
+	details := w.asset.Records.GetLevelDetails(levelID)
+	if details == nil {
+		return Area{}, fmt.Errorf("%w: %d", errUnknownLevel, levelID)
+	}
+
+	// acts are numbered from 0 in the records
+	return Area{Act: details.Act + 1, LevelID: levelID}, nil
+}
+
+// Seed returns the seed the map of an area is generated from. The overworld of the
+// first act is generated from the seed of the game, as it was before there were more
+// maps, and each other map has a seed of its own.
+func (w *World) Seed(area Area) int64 {
+	return w.seed + int64((area.Act-1)<<actSeedShift+area.LevelID)
+}
+
+// Map returns the map of an area, generating it and spawning its monsters if no player
+// went there yet. The world is not locked while a map is generated, so the maps which
+// were generated stay available; callers asking for a map being generated wait for it.
+func (w *World) Map(area Area) (*d2mapengine.MapEngine, error) {
+	w.Lock()
+
+	for {
+		if engine, found := w.maps[area]; found {
+			w.Unlock()
+			return engine, nil
+		}
+
+		done, found := w.generating[area]
+		if !found {
+			break
+		}
+
+		w.Unlock()
+		<-done
+		w.Lock()
+	}
+
+	done := make(chan struct{})
+	w.generating[area] = done
+	difficulty := w.difficulty
+	w.Unlock()
+
+	engine, err := w.generate(area, difficulty)
+
+	w.Lock()
+	defer w.Unlock()
+
+	delete(w.generating, area)
+	close(done)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the maps were dropped if the difficulty changed in the meantime
+	if difficulty == w.difficulty {
+		w.maps[area] = engine
+	}
+
+	return engine, nil
+}
+
+// generate generates the map of an area and spawns its monsters.
+func (w *World) generate(area Area, difficulty d2enum.DifficultyType) (*d2mapengine.MapEngine, error) {
+	engine := d2mapengine.CreateMapEngine(w.logLevel, w.asset)
+	engine.SetSeed(w.Seed(area))
+	engine.ResetMap(d2enum.RegionAct1Town, initialMapSize, initialMapSize)
+
+	generator, err := d2mapgen.NewMapGenerator(w.asset, w.logLevel, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := generator.Generate(area.Act, area.LevelID, difficulty); err != nil {
+		return nil, err
+	}
+
+	// the clients are sent the monsters near their player, they only generate the map
+	generator.Populate(area.Act, area.LevelID, difficulty)
+
+	w.Infof("Generated the map of act %d, level %d", area.Act, area.LevelID)
+
+	return engine, nil
+}
+
+// Loaded returns the areas whose map was generated, by act and level.
+func (w *World) Loaded() []Area {
+	w.Lock()
+	defer w.Unlock()
+
+	areas := make([]Area, 0, len(w.maps))
+
+	for area := range w.maps {
+		areas = append(areas, area)
+	}
+
+	sort.Slice(areas, func(i, j int) bool {
+		if areas[i].Act != areas[j].Act {
+			return areas[i].Act < areas[j].Act
+		}
+
+		return areas[i].LevelID < areas[j].LevelID
+	})
+
+	return areas
+}
+
+// Maps returns the maps which were generated, in the order of Loaded.
+func (w *World) Maps() []*d2mapengine.MapEngine {
+	areas := w.Loaded()
+	maps := make([]*d2mapengine.MapEngine, len(areas))
+
+	w.Lock()
+	defer w.Unlock()
+
+	for idx, area := range areas {
+		maps[idx] = w.maps[area]
+	}
+
+	return maps
+}
+
+// Destination returns the area a warp leads to and its map, generating it if no player
+// went there yet. See Arrival for where players come out of the warp.
+func (w *World) Destination(warp *d2mapentity.Warp) (Area, *d2mapengine.MapEngine, error) {
+	area, err := w.AreaOf(warp.Destination)
+	if err != nil {
+		return Area{}, nil, err
+	}
+
+	engine, err := w.Map(area)
+	if err != nil {
+		return Area{}, nil, err
+	}
+
+	return area, engine, nil
+}
+
+// Arrival returns the tile of the map a warp leads to which players coming out of it are
+// placed on: the exit of the warp back, or the start of the map if it has none. It reads
+// the entities of the map, so the caller must keep the game from changing them.
+func Arrival(engine *d2mapengine.MapEngine, warp *d2mapentity.Warp) (tileX, tileY float64) {
+	for _, entity := range engine.Entities() {
+		back, ok := entity.(*d2mapentity.Warp)
+		if ok && back.Level == warp.Destination && back.Destination == warp.Level {
+			return back.Exit()
+		}
+	}
+
+	return engine.GetStartPosition()
+}
+
+// WarpAt returns the warp of a map at the given tile, if there is one.
+func WarpAt(engine *d2mapengine.MapEngine, tileX, tileY float64) *d2mapentity.Warp {
+	for _, entity := range engine.Entities() {
+		if warp, ok := entity.(*d2mapentity.Warp); ok && warp.Contains(tileX, tileY) {
+			return warp
+		}
+	}
+
+	return nil
+}
@@ -33,14 +33,14 @@ func levelSubstitutionsLoader(r *RecordManager, d *d2txt.DataDictionary) error {
 			GridMax4:     d.Number("Max4"),
 		}
 
-		records[record.ID] = record
+		records[record.ID] = append(records[record.ID], record)
 	}
 
 	if d.Err != nil {
 		return d.Err
 	}
 
-	r.Debugf("Loaded %d LevelSubstitution records", len(records))
+	r.Debugf("Loaded %d LevelSubstitution types", len(records))
 
 	r.Level.Sub = records
 
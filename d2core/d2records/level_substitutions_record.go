@@ -1,7 +1,8 @@
 package d2records
 
-// LevelSubstitutions stores all of the LevelSubstitutionRecords
-type LevelSubstitutions map[int]*LevelSubstitutionRecord
+// LevelSubstitutions stores all of the LevelSubstitutionRecords, grouped by their type
+// in the order of lvlsub.txt
+type LevelSubstitutions map[int][]*LevelSubstitutionRecord
 
 // LevelSubstitutionRecord is a representation of a row from lvlsub.txt
 // these records are parameters for levels and describe substitution rules
@@ -60,3 +61,18 @@ type LevelSubstitutionRecord struct {
 
 	// Beta
 }
+
+// Theme returns the chance, in percent, of each trial spawning a substitution, the
+// number of trials, and the most substitutions which spawn in a grid, for the
+// subtheme of a level. The subtheme ranges from 0 to 4.
+func (r *LevelSubstitutionRecord) Theme(theme int) (chance, trials, max int) {
+	chances := [...]int{r.ChanceSpawn0, r.ChanceSpawn1, r.ChanceSpawn2, r.ChanceSpawn3, r.ChanceSpawn4}
+	if theme < 0 || theme >= len(chances) {
+		return 0, 0, 0
+	}
+
+	allTrials := [...]int{r.ChanceFloor0, r.ChanceFloor1, r.ChanceFloor2, r.ChanceFloor3, r.ChanceFloor4}
+	maxes := [...]int{r.GridMax0, r.GridMax1, r.GridMax2, r.GridMax3, r.GridMax4}
+
+	return chances[theme], allTrials[theme], maxes[theme]
+}
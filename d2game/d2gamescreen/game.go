@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image/color"
 	"strconv"
+	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2gui"
@@ -31,10 +32,13 @@ const (
 	bindControlsErrStr = "failed to add gameControls as input handler for player: %s\n"
 	castErrStr         = "failed to send CastSkill packet to the server, playerId: %s, skillId: %d, x: %g, x: %g\n"
 	spawnItemErrStr    = "failed to send SpawnItem packet to the server: (%d, %d) %+v"
+	chatErrStr         = "failed to send Chat packet to the server: %s"
 )
 
 const (
 	black50alpha = 0x0000007f // rgba
+	chatWhisper  = 0x18ff00ff
+	chatSystem   = 0xffa800ff
 )
 
 // CreateGame creates the Gameplay screen and returns a pointer to it
@@ -67,6 +71,7 @@ func CreateGame(
 	keyMap := d2player.GetDefaultKeyMap(asset)
 
 	game := &Game{
+		navigator:            navigator,
 		asset:                asset,
 		gameClient:           gameClient,
 		gameControls:         nil,
@@ -104,6 +109,7 @@ func CreateGame(
 // Game represents the Gameplay screen
 type Game struct {
 	*d2mapentity.MapEntityFactory
+	navigator            d2interface.Navigator
 	asset                *d2asset.AssetManager
 	gameClient           *d2client.GameClient
 	mapRenderer          *d2maprenderer.MapRenderer
@@ -117,6 +123,7 @@ type Game struct {
 	soundEnv             d2audio.SoundEnvironment
 	guiManager           *d2gui.GuiManager
 	keyMap               *d2player.KeyMap
+	rejected             bool // The server refused to let the player join
 
 	renderer      d2interface.Renderer
 	inputManager  d2interface.InputManager
@@ -127,16 +134,18 @@ type Game struct {
 	logLevel d2util.LogLevel
 }
 
+type terminalCommand struct {
+	name string
+	desc string
+	args []string
+	fn   func([]string) error
+}
+
 // OnLoad loads the resources for the Gameplay screen
 func (v *Game) OnLoad(_ d2screen.LoadingState) {
 	v.audioProvider.PlayBGM("")
 
-	commands := []struct {
-		name string
-		desc string
-		args []string
-		fn   func([]string) error
-	}{
+	commands := []terminalCommand{
 		{"spawnitem", "spawns an item at the local player position",
 			[]string{"code1", "code2", "code3", "code4", "code5"}, v.commandSpawnItem},
 		{"spawnitemat", "spawns an item at the x,y coordinates",
@@ -144,6 +153,16 @@ func (v *Game) OnLoad(_ d2screen.LoadingState) {
 		{"spawnmon", "spawn monster at the local player position", []string{"name"}, v.commandSpawnMon},
 	}
 
+	if v.gameClient.Demo() != nil {
+		commands = append(commands, []terminalCommand{
+			{"demopause", "pauses or resumes the demo", nil, v.commandDemoPause},
+			{"demospeed", "sets the playback speed of the demo, 4 plays it 4 times faster", []string{"speed"},
+				v.commandDemoSpeed},
+			{"demoseek", "moves the demo to a time, such as 1m30s", []string{"time"}, v.commandDemoSeek},
+			{"demostatus", "shows the playback position of the demo", nil, v.commandDemoStatus},
+		}...)
+	}
+
 	for _, cmd := range commands {
 		if err := v.terminal.Bind(cmd.name, cmd.desc, cmd.args, cmd.fn); err != nil {
 			v.Errorf(err.Error())
@@ -162,8 +181,10 @@ func (v *Game) OnUnload() error {
 	}
 
 	// https://github.com/OpenDiablo2/OpenDiablo2/issues/792
-	if err := v.inputManager.UnbindHandler(v.gameControls); err != nil {
-		return err
+	if v.gameControls != nil {
+		if err := v.inputManager.UnbindHandler(v.gameControls); err != nil {
+			return err
+		}
 	}
 
 	// https://github.com/OpenDiablo2/OpenDiablo2/issues/792
@@ -175,8 +196,16 @@ func (v *Game) OnUnload() error {
 		return err
 	}
 
-	if err := v.OnPlayerSave(); err != nil {
-		return err
+	if v.gameClient.Demo() != nil {
+		if err := v.terminal.Unbind("demopause", "demospeed", "demoseek", "demostatus"); err != nil {
+			return err
+		}
+	}
+
+	if !v.rejected {
+		if err := v.OnPlayerSave(); err != nil {
+			return err
+		}
 	}
 
 	if err := v.gameClient.Close(); err != nil {
@@ -225,7 +254,18 @@ func (v *Game) Render(screen d2interface.Surface) {
 // Advance runs the update logic on the Gameplay screen
 // nolint:gocyclo // not need to change
 func (v *Game) Advance(elapsed float64) error {
+	// the player never joined, so there is nothing to show but why
+	if reason, rejected := v.gameClient.Rejection(); rejected {
+		if !v.rejected {
+			v.rejected = true
+			v.navigator.ToMainMenu("can not join the game: " + reason)
+		}
+
+		return nil
+	}
+
 	v.soundEngine.Advance(elapsed)
+	v.gameClient.Advance(time.Now())
 
 	if (v.escapeMenu != nil && !v.escapeMenu.IsOpen()) || len(v.gameClient.Players) != 1 {
 		v.gameClient.MapEngine.Advance(elapsed)
@@ -283,11 +323,28 @@ func (v *Game) Advance(elapsed float64) error {
 		if v.gameControls.PartyPanel != nil {
 			v.gameControls.PartyPanel.UpdatePlayersList(v.gameClient.Players)
 		}
+
+		for _, message := range v.gameClient.ChatMessages() {
+			v.gameControls.AddChatMessage(formatChatMessage(message))
+		}
 	}
 
 	return nil
 }
 
+func formatChatMessage(message d2client.ChatMessage) (string, color.Color) {
+	switch message.Kind {
+	case d2client.ChatWhisperReceived:
+		return fmt.Sprintf("%s whispers: %s", message.Name, message.Text), d2util.Color(chatWhisper)
+	case d2client.ChatWhisperSent:
+		return fmt.Sprintf("You whisper to %s: %s", message.Name, message.Text), d2util.Color(chatWhisper)
+	case d2client.ChatSystem:
+		return message.Text, d2util.Color(chatSystem)
+	}
+
+	return fmt.Sprintf("%s: %s", message.Name, message.Text), color.White
+}
+
 func (v *Game) bindGameControls() error {
 	for _, player := range v.gameClient.Players {
 		if player.ID() != v.gameClient.PlayerID {
@@ -317,20 +374,9 @@ func (v *Game) bindGameControls() error {
 	return nil
 }
 
-// OnPlayerMove sends the player move action to the server
+// OnPlayerMove moves the local player and sends the move action to the server
 func (v *Game) OnPlayerMove(targetX, targetY float64) {
-	worldPosition := v.localPlayer.Position.World()
-
-	playerID, worldX, worldY := v.gameClient.PlayerID, worldPosition.X(), worldPosition.Y()
-
-	createMovePlayerPacket, err := d2netpacket.CreateMovePlayerPacket(playerID, worldX, worldY, targetX, targetY)
-	if err != nil {
-		v.Errorf("MovePlayerPacket: %v", err)
-	}
-
-	err = v.gameClient.SendPacketToServer(createMovePlayerPacket)
-
-	if err != nil {
+	if err := v.gameClient.MovePlayer(targetX, targetY); err != nil {
 		v.Errorf(moveErrStr, v.gameClient.PlayerID, targetX, targetY)
 	}
 }
@@ -366,6 +412,13 @@ func (v *Game) OnPlayerCast(skillID int, targetX, targetY float64) {
 	}
 }
 
+// OnPlayerChat sends the message the player entered in the chat box to the server
+func (v *Game) OnPlayerChat(message string) {
+	if err := v.gameClient.Chat(message); err != nil {
+		v.Errorf(chatErrStr, err)
+	}
+}
+
 func (v *Game) debugSpawnItemAtPlayer(codes ...string) {
 	if v.localPlayer == nil {
 		return
@@ -379,7 +432,7 @@ func (v *Game) debugSpawnItemAtPlayer(codes ...string) {
 }
 
 func (v *Game) debugSpawnItemAtLocation(x, y int, codes ...string) {
-	packet, err := d2netpacket.CreateSpawnItemPacket(x, y, codes...)
+	packet, err := d2netpacket.CreateSpawnItemPacket(0, x, y, codes...)
 	if err != nil {
 		v.Errorf("SpawnItemPacket: %v", err)
 	}
@@ -433,3 +486,42 @@ func (v *Game) commandSpawnMon(args []string) error {
 
 	return nil
 }
+
+func (v *Game) commandDemoPause([]string) error {
+	paused, err := v.gameClient.Demo().TogglePause()
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		v.terminal.Infof("demo paused")
+	} else {
+		v.terminal.Infof("demo resumed")
+	}
+
+	return nil
+}
+
+func (v *Game) commandDemoSpeed(args []string) error {
+	speed, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		return fmt.Errorf("invalid argument")
+	}
+
+	return v.gameClient.Demo().SetSpeed(speed)
+}
+
+func (v *Game) commandDemoSeek(args []string) error {
+	to, err := time.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid argument")
+	}
+
+	return v.gameClient.Demo().Seek(to)
+}
+
+func (v *Game) commandDemoStatus([]string) error {
+	v.terminal.Infof("demo %s", v.gameClient.Demo().Status())
+
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 
@@ -19,6 +21,8 @@ import (
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2screen"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2ui"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2discovery"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
 	"github.com/OpenDiablo2/OpenDiablo2/d2script"
 )
 
@@ -63,6 +67,13 @@ const (
 	errorLabelX, errorLabelY                 = 400, 250
 	machineIPX, machineIPY                   = 400, 90
 	tipX, tipY                               = 400, 300
+	lanGamesX, lanGamesY                     = 400, 360
+	lanGameLineHeight                        = 20
+)
+
+const (
+	maxListedLANGames = 5
+	discoveryTimeout  = time.Second
 )
 
 const (
@@ -73,7 +84,7 @@ const (
 )
 
 const (
-	joinGameCharacterFilter = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890._:"
+	joinGameCharacterFilter = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890._:/"
 )
 
 const (
@@ -103,16 +114,17 @@ func CreateMainMenu(
 	}
 
 	mainMenu := &MainMenu{
-		asset:          asset,
-		screenMode:     ScreenModeUnknown,
-		leftButtonHeld: true,
-		renderer:       renderer,
-		inputManager:   inputManager,
-		audioProvider:  audioProvider,
-		navigator:      navigator,
-		buildInfo:      buildInfo,
-		uiManager:      ui,
-		heroState:      heroStateFactory,
+		asset:           asset,
+		screenMode:      ScreenModeUnknown,
+		leftButtonHeld:  true,
+		renderer:        renderer,
+		inputManager:    inputManager,
+		audioProvider:   audioProvider,
+		navigator:       navigator,
+		buildInfo:       buildInfo,
+		uiManager:       ui,
+		heroState:       heroStateFactory,
+		discoveredGames: make(chan []d2discovery.ServerInfo, 1),
 	}
 
 	mainMenu.Logger = d2util.NewLogger()
@@ -163,6 +175,9 @@ type MainMenu struct {
 	joinTipLabel        *d2ui.Label
 	hostTipLabel        *d2ui.Label
 	tcpJoinGameEntry    *d2ui.TextBox
+	lanGamesLabel       *d2ui.Label
+	lanGameButtons      []*d2ui.LabelButton
+	discoveredGames     chan []d2discovery.ServerInfo
 	screenMode          mainMenuScreenMode
 	leftButtonHeld      bool
 
@@ -317,6 +332,11 @@ func (v *MainMenu) createMultiplayerLabels() {
 		"\n"), d2ui.ColorTokenGold))
 	v.joinTipLabel.SetPosition(tipX, tipY)
 	v.joinTipLabel.SetVisible(false)
+
+	v.lanGamesLabel = v.uiManager.NewLabel(d2resource.Font16, d2resource.PaletteUnits)
+	v.lanGamesLabel.Alignment = d2ui.HorizontalAlignCenter
+	v.lanGamesLabel.Color[0] = d2util.Color(gold)
+	v.lanGamesLabel.SetPosition(lanGamesX, lanGamesY)
 }
 
 func (v *MainMenu) createLogos(loading d2screen.LoadingState) {
@@ -423,6 +443,17 @@ func (v *MainMenu) createMultiplayerMenuButtons() {
 	v.btnTCPIPJoinGame.OnActivated(func() { v.onTCPIPJoinGameClicked() })
 	v.btnTCPIPJoinGame.OnHoverStart(func() { v.joinTipLabel.SetVisible(true) })
 	v.btnTCPIPJoinGame.OnHoverEnd(func() { v.joinTipLabel.SetVisible(false) })
+
+	v.lanGameButtons = make([]*d2ui.LabelButton, maxListedLANGames)
+
+	for i := range v.lanGameButtons {
+		button := v.uiManager.NewLabelButton(d2resource.FontFormal12, d2resource.PaletteUnits)
+		button.SetColors(d2util.Color(white), d2util.Color(lightYellow))
+		button.SetPosition(lanGamesX, lanGamesY+(i+1)*lanGameLineHeight)
+		button.SetVisible(false)
+
+		v.lanGameButtons[i] = button
+	}
 }
 
 func (v *MainMenu) onMapTestClicked() {
@@ -510,6 +541,7 @@ func (v *MainMenu) renderLabels(screen d2interface.Surface) {
 		v.tcpIPOptionsLabel.Render(screen)
 		v.tcpJoinGameLabel.Render(screen)
 		v.machineIP.Render(screen)
+		v.lanGamesLabel.Render(screen)
 	case ScreenModeTCPIP:
 		v.tcpIPOptionsLabel.Render(screen)
 		v.machineIP.Render(screen)
@@ -529,6 +561,12 @@ func (v *MainMenu) renderLabels(screen d2interface.Surface) {
 
 // Advance runs the update logic on the main menu
 func (v *MainMenu) Advance(tickTime float64) error {
+	select {
+	case games := <-v.discoveredGames:
+		v.showLANGames(games)
+	default:
+	}
+
 	switch v.screenMode {
 	case ScreenModeMainMenu, ScreenModeTrademark, ScreenModeMultiplayer:
 		if err := v.diabloLogoLeftBack.Advance(tickTime); err != nil {
@@ -628,6 +666,14 @@ func (v *MainMenu) SetScreenMode(screenMode mainMenuScreenMode) {
 
 	v.btnServerIPOk.SetVisible(isServerIP)
 	v.btnServerIPCancel.SetVisible(isServerIP)
+
+	for _, button := range v.lanGameButtons {
+		button.SetVisible(false)
+	}
+
+	if isServerIP {
+		v.discoverLANGames()
+	}
 }
 
 func (v *MainMenu) onNetworkCancelClicked() {
@@ -662,6 +708,57 @@ func (v *MainMenu) onBtnTCPIPOkClicked() {
 	v.navigator.ToCharacterSelect(d2clientconnectiontype.LANClient, v.tcpJoinGameEntry.GetText())
 }
 
+// discoverLANGames looks for game servers on the local network in the background.
+// The games found are shown by Advance.
+func (v *MainMenu) discoverLANGames() {
+	v.lanGamesLabel.SetText("Searching for LAN games...")
+
+	go func() {
+		loopback := net.JoinHostPort("127.0.0.1", strconv.Itoa(d2discovery.Port))
+
+		games, err := d2discovery.Discover(discoveryTimeout, loopback)
+		if err != nil {
+			v.Warningf("LAN game discovery failed: %s", err)
+		}
+
+		v.discoveredGames <- games
+	}()
+}
+
+// showLANGames lists the games found on the local network. Selecting one fills in its address.
+func (v *MainMenu) showLANGames(games []d2discovery.ServerInfo) {
+	if v.screenMode != ScreenModeServerIP {
+		return
+	}
+
+	listed := 0
+
+	for _, game := range games {
+		if game.ProtocolVersion != d2netpacket.ProtocolVersion || listed == len(v.lanGameButtons) {
+			continue
+		}
+
+		address := game.Address
+		if game.TLS {
+			address = "tls://" + address
+		}
+
+		button := v.lanGameButtons[listed]
+		button.SetText(fmt.Sprintf("%s  %d/%d  %s", game.Name, game.Players, game.MaxPlayers, game.Difficulty))
+		button.OnActivated(func() { v.tcpJoinGameEntry.SetText(address) })
+		button.SetVisible(true)
+
+		listed++
+	}
+
+	if listed == 0 {
+		v.lanGamesLabel.SetText("No LAN games found")
+		return
+	}
+
+	v.lanGamesLabel.SetText("LAN games")
+}
+
 // getLocalIP returns local machine IP address
 func (v *MainMenu) getLocalIP() string {
 	// https://stackoverflow.com/a/28862477
@@ -0,0 +1,172 @@
+package d2player
+
+import (
+	"image/color"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2interface"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2resource"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2ui"
+)
+
+const (
+	chatBoxX, chatBoxInputY = 20, 525
+	chatLineHeight          = 16
+	chatScrollback          = 100 // lines kept in the scrollback
+	chatVisibleLines        = 10  // lines shown while the chat box is open
+	chatRecentLines         = 5   // lines shown while the chat box is closed
+	chatRecentSeconds       = 10  // how long a line is shown while the chat box is closed
+	chatMaxInputLength      = 200 // the server refuses longer messages
+	chatBackspaceDelay      = 30  // frames a held backspace waits before it repeats
+	chatBackspaceInterval   = 3
+)
+
+type chatLine struct {
+	text  string
+	color color.Color
+	age   float64
+}
+
+// chatBox shows the chat messages above the HUD, and takes the input of the local
+// player while it is open.
+type chatBox struct {
+	lines      []*chatLine
+	lineLabels []*d2ui.Label
+	inputLabel *d2ui.Label
+	input      string
+	scroll     int // lines scrolled back from the newest line
+	isOpen     bool
+	onSend     func(message string)
+}
+
+func newChatBox(ui *d2ui.UIManager, onSend func(message string)) *chatBox {
+	c := &chatBox{
+		lineLabels: make([]*d2ui.Label, chatVisibleLines),
+		inputLabel: ui.NewLabel(d2resource.Font16, d2resource.PaletteStatic),
+		onSend:     onSend,
+	}
+
+	for i := range c.lineLabels {
+		c.lineLabels[i] = ui.NewLabel(d2resource.Font16, d2resource.PaletteStatic)
+		c.lineLabels[i].SetPosition(chatBoxX, chatBoxInputY-(i+1)*chatLineHeight)
+	}
+
+	c.inputLabel.SetPosition(chatBoxX, chatBoxInputY)
+
+	return c
+}
+
+// IsOpen returns true if the chat box takes input
+func (c *chatBox) IsOpen() bool {
+	return c.isOpen
+}
+
+// Open opens the chat box for input
+func (c *chatBox) Open() {
+	c.isOpen = true
+	c.scroll = 0
+	c.updateInput()
+}
+
+// Close closes the chat box, discarding the input
+func (c *chatBox) Close() {
+	c.isOpen = false
+	c.input = ""
+}
+
+func (c *chatBox) addLine(text string, textColor color.Color) {
+	c.lines = append(c.lines, &chatLine{text: text, color: textColor})
+
+	if len(c.lines) > chatScrollback {
+		c.lines = c.lines[len(c.lines)-chatScrollback:]
+	}
+}
+
+func (c *chatBox) send() {
+	message := strings.TrimSpace(c.input)
+	c.Close()
+
+	if message != "" {
+		c.onSend(message)
+	}
+}
+
+func (c *chatBox) onKeyDown(event d2interface.KeyEvent) {
+	switch event.Key() {
+	case d2enum.KeyEnter:
+		c.send()
+	case d2enum.KeyEscape:
+		c.Close()
+	case d2enum.KeyPageUp:
+		if c.scroll+chatVisibleLines < len(c.lines) {
+			c.scroll++
+		}
+	case d2enum.KeyPageDown:
+		if c.scroll > 0 {
+			c.scroll--
+		}
+	}
+}
+
+func (c *chatBox) onKeyRepeat(event d2interface.KeyEvent) {
+	if event.Key() != d2enum.KeyBackspace || c.input == "" {
+		return
+	}
+
+	frames := event.Duration()
+	if frames != 1 && (frames < chatBackspaceDelay || (frames-chatBackspaceDelay)%chatBackspaceInterval != 0) {
+		return
+	}
+
+	_, size := utf8.DecodeLastRuneInString(c.input)
+	c.input = c.input[:len(c.input)-size]
+	c.updateInput()
+}
+
+func (c *chatBox) onKeyChars(event d2interface.KeyCharsEvent) {
+	for _, char := range event.Chars() {
+		if utf8.RuneCountInString(c.input) >= chatMaxInputLength {
+			break
+		}
+
+		c.input += string(char)
+	}
+
+	c.updateInput()
+}
+
+func (c *chatBox) updateInput() {
+	c.inputLabel.SetText("> " + c.input + "_")
+}
+
+func (c *chatBox) advance(elapsed float64) {
+	for _, line := range c.lines {
+		line.age += elapsed
+	}
+}
+
+func (c *chatBox) render(target d2interface.Surface) {
+	if c.isOpen {
+		c.inputLabel.Render(target)
+	}
+
+	for i, label := range c.lineLabels {
+		index := len(c.lines) - 1 - c.scroll - i
+		if index < 0 {
+			break
+		}
+
+		line := c.lines[index]
+
+		// while the chat box is closed only the recent lines are shown
+		if !c.isOpen && (i >= chatRecentLines || line.age > chatRecentSeconds) {
+			break
+		}
+
+		label.SetText(line.text)
+		label.Color[0] = line.color
+		label.Render(target)
+	}
+}
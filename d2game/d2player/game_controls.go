@@ -2,6 +2,7 @@ package d2player
 
 import (
 	"fmt"
+	"image/color"
 	"strconv"
 	"strings"
 	"time"
@@ -240,6 +241,7 @@ func NewGameControls(
 
 	hud := NewHUD(asset, ui, hero, miniPanel, actionableRegions, mapEngine, l, gc, mapRenderer)
 	gc.hud = hud
+	gc.chatBox = newChatBox(ui, inputListener.OnPlayerChat)
 
 	hoverLabel := hud.nameLabel
 	hoverLabel.SetBackgroundColor(d2util.Color(blackAlpha50percent))
@@ -278,6 +280,7 @@ type GameControls struct {
 	ui                     *d2ui.UIManager
 	inventory              *Inventory
 	hud                    *HUD
+	chatBox                *chatBox
 	skilltree              *skillTree
 	heroStatsPanel         *HeroStatsPanel
 	PartyPanel             *PartyPanel
@@ -314,6 +317,11 @@ type SkillResource struct {
 
 // OnKeyRepeat is called to handle repeated key presses
 func (g *GameControls) OnKeyRepeat(event d2interface.KeyEvent) bool {
+	if g.chatBox.IsOpen() {
+		g.chatBox.onKeyRepeat(event)
+		return true
+	}
+
 	if g.FreeCam {
 		var moveSpeed float64 = 8
 		if event.KeyMod() == d2enum.KeyModShift {
@@ -354,6 +362,12 @@ func (g *GameControls) OnKeyRepeat(event d2interface.KeyEvent) bool {
 
 // OnKeyDown handles key presses
 func (g *GameControls) OnKeyDown(event d2interface.KeyEvent) bool {
+	// while the chat box is open, keys are typed into it instead of controlling the game
+	if g.chatBox.IsOpen() {
+		g.chatBox.onKeyDown(event)
+		return true
+	}
+
 	if event.Key() == d2enum.KeyEscape {
 		g.onEscKey()
 		return true
@@ -383,6 +397,10 @@ func (g *GameControls) OnKeyDown(event d2interface.KeyEvent) bool {
 		g.hud.onToggleRunButton(true)
 	case d2enum.ToggleHelpScreen:
 		g.toggleHelpOverlay()
+	case d2enum.ToggleChatBox:
+		if !g.escapeMenu.IsOpen() {
+			g.chatBox.Open()
+		}
 	default:
 		return false
 	}
@@ -390,6 +408,22 @@ func (g *GameControls) OnKeyDown(event d2interface.KeyEvent) bool {
 	return false
 }
 
+// OnKeyChars handles the characters typed into the chat box
+func (g *GameControls) OnKeyChars(event d2interface.KeyCharsEvent) bool {
+	if !g.chatBox.IsOpen() {
+		return false
+	}
+
+	g.chatBox.onKeyChars(event)
+
+	return true
+}
+
+// AddChatMessage adds a line to the chat box
+func (g *GameControls) AddChatMessage(text string, textColor color.Color) {
+	g.chatBox.addLine(text, textColor)
+}
+
 // OnKeyUp handles key release
 func (g *GameControls) OnKeyUp(event d2interface.KeyEvent) bool {
 	gameEvent := g.keyMap.getGameEvent(event.Key())
@@ -716,6 +750,7 @@ func (g *GameControls) Load() {
 func (g *GameControls) Advance(elapsed float64) error {
 	g.mapRenderer.Advance(elapsed)
 	g.hud.Advance(elapsed)
+	g.chatBox.advance(elapsed)
 	g.inventory.Advance(elapsed)
 	g.questLog.Advance(elapsed)
 
@@ -809,6 +844,8 @@ func (g *GameControls) Render(target d2interface.Surface) error {
 		return err
 	}
 
+	g.chatBox.render(target)
+
 	if err := g.renderPanels(target); err != nil {
 		return err
 	}
@@ -3,4 +3,5 @@ package d2player
 type inputCallbackListener interface {
 	OnPlayerMove(x, y float64)
 	OnPlayerCast(skillID int, x, y float64)
+	OnPlayerChat(message string)
 }
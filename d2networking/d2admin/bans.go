@@ -0,0 +1,116 @@
+package d2admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const banFileMode = 0o600
+
+// BanList is a set of banned IP addresses, persisted to a file with one address per line.
+type BanList struct {
+	path string
+	ips  map[string]bool
+	sync.RWMutex
+}
+
+// LoadBanList reads the ban list from the file at path. A missing file is an empty
+// ban list. An empty path gives a ban list which is not persisted.
+func LoadBanList(path string) (*BanList, error) {
+	b := &BanList{path: path, ips: make(map[string]bool)}
+
+	if path == "" {
+		return b, nil
+	}
+
+	f, err := os.Open(path) //nolint:gosec // the operator chooses the ban file
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck // read only
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ip := net.ParseIP(line)
+		if ip == nil {
+			return nil, fmt.Errorf("%s: invalid IP address %q", path, line)
+		}
+
+		b.ips[ip.String()] = true
+	}
+
+	return b, scanner.Err()
+}
+
+// Add bans the IP address and saves the ban list.
+func (b *BanList) Add(address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", address)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.ips[ip.String()] = true
+
+	return b.save()
+}
+
+// Contains returns true if the IP address is banned.
+func (b *BanList) Contains(ip net.IP) bool {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.ips[ip.String()]
+}
+
+// List returns the banned IP addresses in order.
+func (b *BanList) List() []string {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.sorted()
+}
+
+func (b *BanList) sorted() []string {
+	ips := make([]string, 0, len(b.ips))
+	for ip := range b.ips {
+		ips = append(ips, ip)
+	}
+
+	sort.Strings(ips)
+
+	return ips
+}
+
+func (b *BanList) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data := strings.Join(b.sorted(), "\n") + "\n"
+
+	// write a new file first, so a failed write does not lose the existing bans
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), banFileMode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, b.path)
+}
@@ -0,0 +1,305 @@
+// Package d2admin provides the operator console of a dedicated server, which
+// takes commands on stdin or on a local Unix socket, and the ban list.
+package d2admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const socketFileMode = 0o600
+
+var (
+	errUnknownCommand = errors.New("unknown command, try help")
+	errMissingArgs    = errors.New("missing arguments")
+)
+
+// Status describes the state of a server.
+type Status struct {
+	Uptime    time.Duration
+	Games     int
+	Players   int
+	Suspended int // Players whose connection dropped, and who may still resume
+	Bans      int
+	Dropped   map[string]uint64 // Packets dropped by the rate limits, by class
+	Oversized uint64            // Packets larger than the maximum frame size
+	Flooders  uint64            // Clients disconnected for flooding
+}
+
+// Game describes a game hosted by a server.
+type Game struct {
+	ID         string
+	Name       string
+	Players    int
+	MaxPlayers int
+	Difficulty string
+	Password   bool // The game can only be joined with a password
+	Seed       int64
+}
+
+// Player describes a player connected to a server.
+type Player struct {
+	ID      string
+	Name    string
+	Class   string
+	Level   int
+	Game    string // ID of the game the player is in
+	Address string
+}
+
+// Server is the server the console acts on.
+type Server interface {
+	Status() Status
+	Games() []Game
+	Players() []Player
+	Kick(id, reason string) error
+	Ban(ip string) (kicked int, err error)
+	Say(message string) error
+	SaveAll() (saved int, err error)
+	ScheduleShutdown(delay time.Duration)
+}
+
+// Console executes operator commands.
+type Console struct {
+	server Server
+}
+
+// NewConsole creates a console for the server.
+func NewConsole(server Server) *Console {
+	return &Console{server: server}
+}
+
+const usage = `status               shows the state of the server
+games                lists the games
+players              lists the connected players
+kick <id> [reason]   disconnects a player
+ban <ip>             disconnects and bans every player from an IP address
+say <message>        sends a message to every player
+save-all             saves every player
+shutdown [delay]     stops the server, after the delay if one is given (30s, 5m)`
+
+// Execute runs one command line and returns its output.
+func (c *Console) Execute(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	command, args := fields[0], fields[1:]
+
+	switch command {
+	case "help":
+		return usage, nil
+	case "status":
+		return c.status(), nil
+	case "games":
+		return c.games(), nil
+	case "players":
+		return c.players(), nil
+	case "kick":
+		return c.kick(args)
+	case "ban":
+		return c.ban(args)
+	case "say":
+		return c.say(args)
+	case "save-all":
+		saved, err := c.server.SaveAll()
+		return fmt.Sprintf("saved %d players", saved), err
+	case "shutdown":
+		return c.shutdown(args)
+	}
+
+	return "", errUnknownCommand
+}
+
+func (c *Console) status() string {
+	s := c.server.Status()
+
+	return fmt.Sprintf("games %d, players %d, %d suspended\nuptime %s\nbans %d\n%s",
+		s.Games, s.Players, s.Suspended, s.Uptime.Round(time.Second), s.Bans, limits(s))
+}
+
+// limits describes the packets dropped by the limits of the server.
+func limits(s Status) string {
+	var dropped uint64
+
+	classes := make([]string, 0, len(s.Dropped))
+
+	for class, count := range s.Dropped {
+		dropped += count
+
+		classes = append(classes, fmt.Sprintf("%s %d", class, count))
+	}
+
+	sort.Strings(classes)
+
+	out := fmt.Sprintf("dropped %d packets, %d oversized, %d flooders disconnected", dropped, s.Oversized, s.Flooders)
+	if len(classes) > 0 {
+		out += " (" + strings.Join(classes, ", ") + ")"
+	}
+
+	return out
+}
+
+func (c *Console) games() string {
+	games := c.server.Games()
+	if len(games) == 0 {
+		return "no games"
+	}
+
+	lines := make([]string, len(games))
+	for i, g := range games {
+		lines[i] = fmt.Sprintf("%s  %s  %d/%d  %s  seed %d", g.ID, g.Name, g.Players, g.MaxPlayers, g.Difficulty, g.Seed)
+
+		if g.Password {
+			lines[i] += "  password"
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c *Console) players() string {
+	players := c.server.Players()
+	if len(players) == 0 {
+		return "no players"
+	}
+
+	lines := make([]string, len(players))
+	for i, p := range players {
+		lines[i] = fmt.Sprintf("%s  %s  level %d %s  game %s  %s", p.ID, p.Name, p.Level, p.Class, p.Game, p.Address)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c *Console) kick(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errMissingArgs
+	}
+
+	id, reason := args[0], strings.Join(args[1:], " ")
+	if err := c.server.Kick(id, reason); err != nil {
+		return "", err
+	}
+
+	return "kicked " + id, nil
+}
+
+func (c *Console) ban(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errMissingArgs
+	}
+
+	kicked, err := c.server.Ban(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("banned %s, kicked %d players", args[0], kicked), nil
+}
+
+func (c *Console) say(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errMissingArgs
+	}
+
+	return "", c.server.Say(strings.Join(args, " "))
+}
+
+func (c *Console) shutdown(args []string) (string, error) {
+	var delay time.Duration
+
+	if len(args) > 0 {
+		d, err := parseDelay(args[0])
+		if err != nil {
+			return "", err
+		}
+
+		delay = d
+	}
+
+	c.server.ScheduleShutdown(delay)
+
+	if delay == 0 {
+		return "shutting down", nil
+	}
+
+	return "shutting down in " + delay.String(), nil
+}
+
+// parseDelay parses a duration, where a plain number is a number of seconds.
+func parseDelay(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+// Serve executes the commands read from r, one per line, and writes their output to w.
+// It returns when r is exhausted.
+func (c *Console) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		out, err := c.Execute(scanner.Text())
+		if err != nil {
+			out = "error: " + err.Error()
+		}
+
+		if out == "" {
+			continue
+		}
+
+		if _, err := fmt.Fprintln(w, out); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ListenUnix serves the console on a Unix socket at path, which only the current user
+// can connect to. Closing the returned listener stops serving and removes the socket.
+func (c *Console) ListenUnix(path string) (net.Listener, error) {
+	// a socket left behind by a server which did not shut down cleanly
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, socketFileMode); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return // the listener was closed
+			}
+
+			go func() {
+				defer conn.Close() //nolint:errcheck // the operator disconnected
+
+				_ = c.Serve(conn, conn)
+			}()
+		}
+	}()
+
+	return l, nil
+}
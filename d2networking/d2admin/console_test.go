@@ -0,0 +1,158 @@
+package d2admin
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	kicked   []string
+	said     []string
+	shutdown time.Duration
+}
+
+func (s *testServer) Status() Status {
+	return Status{Uptime: time.Minute, Games: 1, Players: 1}
+}
+
+func (s *testServer) Games() []Game {
+	return []Game{{ID: "1", Name: "Cows", Players: 1, MaxPlayers: 8, Difficulty: "Hell", Password: true, Seed: 42}}
+}
+
+func (s *testServer) Players() []Player {
+	return []Player{{ID: "abc", Name: "Rogue", Class: "Amazon", Level: 3, Game: "1", Address: "10.0.0.2:5000"}}
+}
+
+func (s *testServer) Kick(id, reason string) error {
+	if id != "abc" {
+		return fmt.Errorf("no player %s", id)
+	}
+
+	s.kicked = append(s.kicked, id+":"+reason)
+
+	return nil
+}
+
+func (s *testServer) Ban(ip string) (int, error) {
+	return 1, nil
+}
+
+func (s *testServer) Say(message string) error {
+	s.said = append(s.said, message)
+	return nil
+}
+
+func (s *testServer) SaveAll() (int, error) {
+	return 1, nil
+}
+
+func (s *testServer) ScheduleShutdown(delay time.Duration) {
+	s.shutdown = delay
+}
+
+func TestConsoleCommands(t *testing.T) {
+	server := &testServer{}
+	console := NewConsole(server)
+
+	tests := []struct {
+		line    string
+		output  string
+		wantErr bool
+	}{
+		{line: "status", output: "games 1, players 1, 0 suspended\nuptime 1m0s\nbans 0\n" +
+			"dropped 0 packets, 0 oversized, 0 flooders disconnected"},
+		{line: "games", output: "1  Cows  1/8  Hell  seed 42  password"},
+		{line: "players", output: "abc  Rogue  level 3 Amazon  game 1  10.0.0.2:5000"},
+		{line: "kick abc too much lag", output: "kicked abc"},
+		{line: "kick nobody", wantErr: true},
+		{line: "kick", wantErr: true},
+		{line: "say  hello   world"},
+		{line: "shutdown 30", output: "shutting down in 30s"},
+		{line: "launch", wantErr: true},
+	}
+
+	for _, test := range tests {
+		output, err := console.Execute(test.line)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: unexpected error %v", test.line, err)
+		}
+
+		if output != test.output {
+			t.Errorf("%q: expected %q, got %q", test.line, test.output, output)
+		}
+	}
+
+	if len(server.kicked) != 1 || server.kicked[0] != "abc:too much lag" {
+		t.Errorf("unexpected kicks %v", server.kicked)
+	}
+
+	if len(server.said) != 1 || server.said[0] != "hello world" {
+		t.Errorf("unexpected messages %v", server.said)
+	}
+
+	if server.shutdown != 30*time.Second {
+		t.Errorf("expected a shutdown in 30s, got %s", server.shutdown)
+	}
+}
+
+func TestConsoleUnixSocket(t *testing.T) {
+	console := NewConsole(&testServer{})
+
+	l, err := console.ListenUnix(filepath.Join(t.TempDir(), "admin.sock"))
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+
+	defer l.Close()
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, "save-all"); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(line) != "saved 1 players" {
+		t.Errorf("unexpected output %q", line)
+	}
+}
+
+func TestBanListPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.txt")
+
+	bans, err := LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bans.Add("10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bans.Add("not an ip"); err == nil {
+		t.Error("expected an error banning an invalid address")
+	}
+
+	loaded, err := LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Contains(net.ParseIP("10.0.0.2")) || loaded.Contains(net.ParseIP("10.0.0.3")) {
+		t.Errorf("unexpected bans %v", loaded.List())
+	}
+}
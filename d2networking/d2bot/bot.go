@@ -0,0 +1,429 @@
+package d2bot
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2remoteclient"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+const (
+	subtilesPerTile = 5
+
+	walkRadius   = 10 * subtilesPerTile // How far, in sub tiles, a bot walks at most
+	castRadius   = 5 * subtilesPerTile  // How far, in sub tiles, from itself a bot casts at most
+	walkAttempts = 10                   // Destinations a bot tries before it does something else
+
+	// The chance of each action, in percent. The rest of the actions are chat messages.
+	walkChance = 60
+	castChance = 25
+)
+
+// Bot is a headless client which joins a game and walks, casts skills and chats at random.
+type Bot struct {
+	swarm  *Swarm
+	name   string
+	client *d2remoteclient.RemoteClientConnection
+	hero   *d2hero.HeroState
+	rand   *rand.Rand
+
+	mutex        sync.Mutex
+	playerID     string
+	mapEngine    *d2mapengine.MapEngine
+	position     d2vector.Position // In sub tiles
+	joined       bool              // The server added the player of the bot to the game
+	walkingUntil time.Time
+	moveSequence uint32
+	moves        map[uint32]time.Time // Send times of the moves the server did not answer yet
+	chats        map[string]time.Time // Send times of the chat messages not received back yet
+	chatCount    int
+	dialed       time.Time
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newBot(swarm *Swarm, index int, hero *d2hero.HeroState) (*Bot, error) {
+	client, err := d2remoteclient.Create(swarm.logLevel, swarm.asset)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bot{
+		swarm:  swarm,
+		name:   hero.HeroName,
+		client: client,
+		hero:   hero,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano() + int64(index))), //nolint:gosec // not security related
+		moves:  make(map[uint32]time.Time),
+		chats:  make(map[string]time.Time),
+		done:   make(chan struct{}),
+	}
+
+	client.SetClientListener(b)
+
+	return b, nil
+}
+
+// start connects the bot to the server, and starts its actions.
+func (b *Bot) start(connectionString string, interval time.Duration) error {
+	b.mutex.Lock()
+	b.dialed = time.Now()
+	b.mutex.Unlock()
+
+	if err := b.client.OpenAs(connectionString, b.hero); err != nil {
+		return err
+	}
+
+	go b.act(interval)
+
+	return nil
+}
+
+// stop disconnects the bot from the server.
+func (b *Bot) stop() {
+	b.stopOnce.Do(func() {
+		close(b.done)
+
+		b.mutex.Lock()
+		joined := b.joined
+		b.joined = false
+		b.mutex.Unlock()
+
+		if joined {
+			b.swarm.stats.left()
+		}
+
+		if err := b.client.Close(); err != nil {
+			b.swarm.Debugf("%s: %s", b.name, err)
+		}
+	})
+}
+
+// act does an action every interval on average, until the bot stops.
+func (b *Bot) act(interval time.Duration) {
+	for {
+		// half to one and a half of the interval, so the bots do not act in lockstep
+		wait := interval/2 + time.Duration(b.rand.Int63n(int64(interval)+1))
+
+		select {
+		case <-b.done:
+			return
+		case <-time.After(wait):
+		}
+
+		if err := b.nextAction(); err != nil {
+			b.swarm.Warningf("%s: %s", b.name, err)
+			b.swarm.stats.failed()
+			b.stop()
+
+			return
+		}
+	}
+}
+
+func (b *Bot) nextAction() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.joined || b.mapEngine == nil || time.Now().Before(b.walkingUntil) {
+		return nil
+	}
+
+	switch chance := b.rand.Intn(100); {
+	case chance < walkChance:
+		return b.walk()
+	case chance < walkChance+castChance:
+		return b.cast()
+	default:
+		return b.chat()
+	}
+}
+
+// walk moves the player to a random walkable destination near it, along a path.
+// The caller must hold the mutex of the bot.
+func (b *Bot) walk() error {
+	for attempt := 0; attempt < walkAttempts; attempt++ {
+		dest := d2vector.NewPosition(
+			b.position.X()+float64(b.rand.Intn(2*walkRadius+1)-walkRadius),
+			b.position.Y()+float64(b.rand.Intn(2*walkRadius+1)-walkRadius))
+
+		if !b.mapEngine.IsWalkable(int(dest.X()), int(dest.Y())) {
+			continue
+		}
+
+		path := b.mapEngine.PathFind(b.position, dest)
+		if len(path) == 0 {
+			continue
+		}
+
+		length, from := 0.0, b.position
+
+		for _, step := range path {
+			length += from.Distance(&step.Vector)
+			from = step
+		}
+
+		dest = path[len(path)-1]
+		b.moveSequence++
+
+		start, end := b.position.World(), dest.World()
+
+		packet, err := d2netpacket.CreateMovePlayerPacket(b.playerID, start.X(), start.Y(), end.X(), end.Y(),
+			b.moveSequence)
+		if err != nil {
+			return err
+		}
+
+		b.moves[b.moveSequence] = time.Now()
+		b.position = dest
+		b.walkingUntil = time.Now().Add(time.Duration(length / d2mapentity.BaseWalkSpeed * float64(time.Second)))
+
+		return b.send(packet)
+	}
+
+	return nil
+}
+
+// cast casts a random skill of the hero at a point near the player.
+// The caller must hold the mutex of the bot.
+func (b *Bot) cast() error {
+	if len(b.hero.Skills) == 0 {
+		return nil
+	}
+
+	skillIDs := make([]int, 0, len(b.hero.Skills))
+
+	for skillID := range b.hero.Skills {
+		skillIDs = append(skillIDs, skillID)
+	}
+
+	sort.Ints(skillIDs)
+
+	target := d2vector.NewPosition(
+		b.position.X()+float64(b.rand.Intn(2*castRadius+1)-castRadius),
+		b.position.Y()+float64(b.rand.Intn(2*castRadius+1)-castRadius))
+	world := target.World()
+
+	packet, err := d2netpacket.CreateCastPacket(b.playerID, skillIDs[b.rand.Intn(len(skillIDs))], world.X(), world.Y())
+	if err != nil {
+		return err
+	}
+
+	return b.send(packet)
+}
+
+// chat sends a chat message, which the server relays to every player of the game.
+// The caller must hold the mutex of the bot.
+func (b *Bot) chat() error {
+	b.chatCount++
+	message := fmt.Sprintf("%s says hello #%d", b.name, b.chatCount)
+
+	packet, err := d2netpacket.CreateChatPacket(b.playerID, b.name, message)
+	if err != nil {
+		return err
+	}
+
+	b.chats[message] = time.Now()
+
+	return b.send(packet)
+}
+
+func (b *Bot) send(packet d2netpacket.NetPacket) error {
+	if err := b.client.SendPacketToServer(packet); err != nil {
+		return err
+	}
+
+	b.swarm.stats.sent(packetSize(packet))
+
+	return nil
+}
+
+// OnPacketReceived is called by the connection for every packet the server sends.
+//
+//nolint:gocyclo // switch statement on packet type makes sense, no need to change
+func (b *Bot) OnPacketReceived(packet d2netpacket.NetPacket) error {
+	b.swarm.stats.received(packetSize(packet))
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch packet.PacketType {
+	case d2netpackettype.UpdateServerInfo:
+		return b.handleUpdateServerInfo(packet)
+	case d2netpackettype.GenerateMap:
+		return b.handleGenerateMap(packet)
+	case d2netpackettype.AddPlayer:
+		return b.handleAddPlayer(packet)
+	case d2netpackettype.MovePlayer:
+		return b.handleMovePlayer(packet)
+	case d2netpackettype.MovePlayerCorrection:
+		return b.handleMovePlayerCorrection(packet)
+	case d2netpackettype.Chat:
+		return b.handleChat(packet)
+	case d2netpackettype.Ping:
+		pong, err := d2netpacket.CreatePongPacket(b.playerID)
+		if err != nil {
+			return err
+		}
+
+		return b.send(pong)
+	case d2netpackettype.EntityStates:
+		// acknowledged, so the server sends deltas like it does to a real client
+		entityStates, err := d2netpacket.UnmarshalEntityStates(packet)
+		if err != nil {
+			return err
+		}
+
+		ack, err := d2netpacket.CreateEntityStatesAckPacket(entityStates.Sequence)
+		if err != nil {
+			return err
+		}
+
+		return b.send(ack)
+	case d2netpackettype.ConnectionRejected:
+		connectionRejected, err := d2netpacket.UnmarshalConnectionRejected(packet)
+		if err != nil {
+			return err
+		}
+
+		b.swarm.Warningf("%s: the server refused the connection: %s", b.name, connectionRejected.Message())
+		b.swarm.stats.failed()
+
+		// stop takes the mutex
+		go b.stop()
+	case d2netpackettype.ServerClosed:
+		// stop takes the mutex
+		go b.stop()
+	}
+
+	return nil
+}
+
+func (b *Bot) handleUpdateServerInfo(packet d2netpacket.NetPacket) error {
+	serverInfo, err := d2netpacket.UnmarshalUpdateServerInfo(packet)
+	if err != nil {
+		return err
+	}
+
+	b.playerID = serverInfo.PlayerID
+
+	return nil
+}
+
+func (b *Bot) handleGenerateMap(packet d2netpacket.NetPacket) error {
+	generate, err := d2netpacket.UnmarshalGenerateMap(packet)
+	if err != nil {
+		return err
+	}
+
+	// the map is shared by the bots which are on the same map of a game
+	b.mapEngine = b.swarm.mapEngine(generate)
+
+	return nil
+}
+
+func (b *Bot) handleAddPlayer(packet d2netpacket.NetPacket) error {
+	player, err := d2netpacket.UnmarshalAddPlayer(packet)
+	if err != nil {
+		return err
+	}
+
+	if player.ID != b.playerID {
+		return nil
+	}
+
+	b.position = d2vector.NewPosition(float64(player.X), float64(player.Y))
+	b.walkingUntil = time.Time{}
+
+	// the server adds the player again when the session is resumed
+	if !b.joined {
+		b.joined = true
+		b.swarm.stats.joined()
+		b.swarm.stats.Latency(LatencyConnect, time.Since(b.dialed))
+	}
+
+	return nil
+}
+
+func (b *Bot) handleMovePlayer(packet d2netpacket.NetPacket) error {
+	move, err := d2netpacket.UnmarshalMovePlayer(packet)
+	if err != nil {
+		return err
+	}
+
+	if move.PlayerID == b.playerID {
+		b.moveAnswered(move.Sequence)
+	}
+
+	return nil
+}
+
+func (b *Bot) handleMovePlayerCorrection(packet d2netpacket.NetPacket) error {
+	correction, err := d2netpacket.UnmarshalMovePlayerCorrection(packet)
+	if err != nil {
+		return err
+	}
+
+	if correction.PlayerID != b.playerID {
+		return nil
+	}
+
+	b.moveAnswered(correction.Sequence)
+	b.swarm.stats.corrected()
+
+	b.position = d2vector.NewPositionTile(correction.X, correction.Y)
+	b.walkingUntil = time.Time{}
+
+	return nil
+}
+
+// moveAnswered records the latency of the move the server accepted or corrected.
+// The caller must hold the mutex of the bot.
+func (b *Bot) moveAnswered(sequence uint32) {
+	sent, found := b.moves[sequence]
+	if !found {
+		return
+	}
+
+	delete(b.moves, sequence)
+	b.swarm.stats.Latency(LatencyMove, time.Since(sent))
+}
+
+func (b *Bot) handleChat(packet d2netpacket.NetPacket) error {
+	chat, err := d2netpacket.UnmarshalChat(packet)
+	if err != nil {
+		return err
+	}
+
+	if chat.PlayerID != b.playerID {
+		return nil
+	}
+
+	if sent, found := b.chats[chat.Message]; found {
+		delete(b.chats, chat.Message)
+		b.swarm.stats.Latency(LatencyChat, time.Since(sent))
+	}
+
+	return nil
+}
+
+// packetSize returns the size of the JSON data of the packet, the measure of the stats
+// whichever codec the connection uses.
+func packetSize(packet d2netpacket.NetPacket) int {
+	data, err := packet.Data()
+	if err != nil {
+		return 0
+	}
+
+	return len(data)
+}
@@ -0,0 +1,215 @@
+package d2bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The round trips the bots measure.
+const (
+	LatencyConnect = "connect" // From dialing the server to being added to the game
+	LatencyMove    = "move"    // From sending a move to the server accepting or correcting it
+	LatencyChat    = "chat"    // From sending a chat message to receiving it back
+)
+
+const (
+	percentile50 = 50
+	percentile95 = 95
+	percentile99 = 99
+	percent      = 100
+)
+
+// Stats collects the measurements of the bots of a swarm. It is safe for concurrent use.
+type Stats struct {
+	mutex       sync.Mutex
+	since       time.Time
+	bots        int // Bots in a game
+	connects    int
+	disconnects int
+	corrections int
+	errors      int
+	packetsIn   int
+	packetsOut  int
+	bytesIn     int
+	bytesOut    int
+	latencies   map[string][]time.Duration
+}
+
+// NewStats creates empty Stats.
+func NewStats() *Stats {
+	return &Stats{since: time.Now(), latencies: make(map[string][]time.Duration)}
+}
+
+func (s *Stats) joined() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.bots++
+	s.connects++
+}
+
+func (s *Stats) left() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.bots--
+	s.disconnects++
+}
+
+func (s *Stats) corrected() {
+	s.mutex.Lock()
+	s.corrections++
+	s.mutex.Unlock()
+}
+
+func (s *Stats) failed() {
+	s.mutex.Lock()
+	s.errors++
+	s.mutex.Unlock()
+}
+
+func (s *Stats) received(size int) {
+	s.mutex.Lock()
+	s.packetsIn++
+	s.bytesIn += size
+	s.mutex.Unlock()
+}
+
+func (s *Stats) sent(size int) {
+	s.mutex.Lock()
+	s.packetsOut++
+	s.bytesOut += size
+	s.mutex.Unlock()
+}
+
+// Latency records a round trip of the given kind.
+func (s *Stats) Latency(kind string, latency time.Duration) {
+	s.mutex.Lock()
+	s.latencies[kind] = append(s.latencies[kind], latency)
+	s.mutex.Unlock()
+}
+
+// Report returns the measurements since the last report, and starts a new interval.
+// The number of bots in a game carries over.
+func (s *Stats) Report() Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	report := Report{
+		Interval:    now.Sub(s.since),
+		Bots:        s.bots,
+		Connects:    s.connects,
+		Disconnects: s.disconnects,
+		Corrections: s.corrections,
+		Errors:      s.errors,
+		PacketsIn:   s.packetsIn,
+		PacketsOut:  s.packetsOut,
+		BytesIn:     s.bytesIn,
+		BytesOut:    s.bytesOut,
+		Latencies:   make(map[string]LatencySummary, len(s.latencies)),
+	}
+
+	for kind, latencies := range s.latencies {
+		report.Latencies[kind] = summarize(latencies)
+	}
+
+	s.since = now
+	s.connects, s.disconnects, s.corrections, s.errors = 0, 0, 0, 0
+	s.packetsIn, s.packetsOut, s.bytesIn, s.bytesOut = 0, 0, 0, 0
+	s.latencies = make(map[string][]time.Duration)
+
+	return report
+}
+
+// LatencySummary describes the round trips of one kind measured in an interval.
+type LatencySummary struct {
+	Count          int
+	Min, Mean, Max time.Duration
+	P50, P95, P99  time.Duration
+}
+
+func summarize(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+
+	for _, latency := range sorted {
+		total += latency
+	}
+
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/percent]
+	}
+
+	return LatencySummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Mean:  total / time.Duration(len(sorted)),
+		Max:   sorted[len(sorted)-1],
+		P50:   percentile(percentile50),
+		P95:   percentile(percentile95),
+		P99:   percentile(percentile99),
+	}
+}
+
+// Report holds the measurements of a swarm over an interval.
+type Report struct {
+	Interval    time.Duration
+	Bots        int // Bots in a game at the end of the interval
+	Connects    int
+	Disconnects int
+	Corrections int // Moves the server corrected
+	Errors      int
+	PacketsIn   int // Packets received by all bots
+	PacketsOut  int // Packets sent by all bots
+	BytesIn     int // Bytes of packet data received by all bots, before encoding
+	BytesOut    int // Bytes of packet data sent by all bots, before encoding
+	Latencies   map[string]LatencySummary
+}
+
+func (r Report) perSecond(count int) float64 {
+	if r.Interval <= 0 {
+		return 0
+	}
+
+	return float64(count) / r.Interval.Seconds()
+}
+
+// String formats the report as a few lines of text.
+func (r Report) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d bots, %d joined, %d left, %d corrections, %d errors in %s\n",
+		r.Bots, r.Connects, r.Disconnects, r.Corrections, r.Errors, r.Interval.Round(time.Millisecond))
+	fmt.Fprintf(&b, "in %.1f packets/s %.1f KiB/s, out %.1f packets/s %.1f KiB/s",
+		r.perSecond(r.PacketsIn), r.perSecond(r.BytesIn)/bytesPerKiB,
+		r.perSecond(r.PacketsOut), r.perSecond(r.BytesOut)/bytesPerKiB)
+
+	kinds := make([]string, 0, len(r.Latencies))
+
+	for kind := range r.Latencies {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		l := r.Latencies[kind]
+		fmt.Fprintf(&b, "\n%-7s n=%d min=%s mean=%s p50=%s p95=%s p99=%s max=%s", kind, l.Count,
+			l.Min.Round(time.Microsecond), l.Mean.Round(time.Microsecond), l.P50.Round(time.Microsecond),
+			l.P95.Round(time.Microsecond), l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond))
+	}
+
+	return b.String()
+}
+
+const bytesPerKiB = 1024
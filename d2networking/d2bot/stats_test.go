@@ -0,0 +1,43 @@
+package d2bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsReport(t *testing.T) {
+	stats := NewStats()
+
+	stats.joined()
+	stats.joined()
+	stats.sent(10)
+	stats.received(30)
+	stats.received(20)
+
+	for i := 1; i <= 100; i++ {
+		stats.Latency(LatencyMove, time.Duration(i)*time.Millisecond)
+	}
+
+	report := stats.Report()
+
+	if report.Bots != 2 || report.PacketsOut != 1 || report.PacketsIn != 2 || report.BytesIn != 50 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	move := report.Latencies[LatencyMove]
+	if move.Count != 100 || move.Min != time.Millisecond || move.Max != 100*time.Millisecond {
+		t.Errorf("unexpected move latencies %+v", move)
+	}
+
+	if move.P50 != 50*time.Millisecond || move.P99 != 99*time.Millisecond {
+		t.Errorf("unexpected move percentiles %+v", move)
+	}
+
+	stats.left()
+
+	report = stats.Report()
+
+	if report.Bots != 1 || report.Disconnects != 1 || report.PacketsIn != 0 || len(report.Latencies) != 0 {
+		t.Errorf("expected only the bots to carry over, got %+v", report)
+	}
+}
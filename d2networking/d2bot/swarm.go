@@ -0,0 +1,196 @@
+// Package d2bot provides headless clients, which join a server and walk, cast skills and
+// chat at random, to load test a dedicated server. A Swarm runs many of them and reports
+// the latency and throughput they measure.
+package d2bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapgen"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	logPrefix = "Bots"
+
+	mapWidth, mapHeight = 100, 100 // Size the server resets its map to
+)
+
+// heroes are the classes of the bots, in turn.
+var heroes = []d2enum.Hero{ //nolint:gochecknoglobals // constant list
+	d2enum.HeroBarbarian,
+	d2enum.HeroNecromancer,
+	d2enum.HeroPaladin,
+	d2enum.HeroAssassin,
+	d2enum.HeroSorceress,
+	d2enum.HeroAmazon,
+	d2enum.HeroDruid,
+}
+
+// Config configures a Swarm.
+type Config struct {
+	Connection  string        // Server to join, host[:port][/game[/password]]
+	Bots        int           // Number of bots
+	Ramp        time.Duration // Time between starting two bots
+	Interval    time.Duration // Mean time between two actions of a bot
+	Duration    time.Duration // How long the bots stay, zero until the context is done
+	ReportEvery time.Duration // Time between two reports
+}
+
+// Swarm runs bots against a server.
+type Swarm struct {
+	config   Config
+	asset    *d2asset.AssetManager
+	logLevel d2util.LogLevel
+	heroes   *d2hero.HeroStateFactory
+	stats    *Stats
+
+	mapMutex sync.Mutex
+	maps     map[d2netpacket.GenerateMapPacket]*d2mapengine.MapEngine
+
+	*d2util.Logger
+}
+
+// NewSwarm creates a Swarm. The game data must be loaded into the asset manager.
+func NewSwarm(config Config, asset *d2asset.AssetManager, l d2util.LogLevel) (*Swarm, error) {
+	heroStateFactory, err := d2hero.NewHeroStateFactory(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Swarm{
+		config:   config,
+		asset:    asset,
+		logLevel: l,
+		heroes:   heroStateFactory,
+		stats:    NewStats(),
+		maps:     make(map[d2netpacket.GenerateMapPacket]*d2mapengine.MapEngine),
+	}
+
+	s.Logger = d2util.NewLogger()
+	s.Logger.SetPrefix(logPrefix)
+	s.Logger.SetLevel(l)
+
+	return s, nil
+}
+
+// Run starts the bots, one every Ramp, and calls report every ReportEvery until the
+// Duration passed or the context is done. The bots then leave, and report is called
+// one last time.
+func (s *Swarm) Run(ctx context.Context, report func(Report)) {
+	if s.config.Duration > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.config.Duration)
+		defer cancel()
+	}
+
+	var (
+		bots      []*Bot
+		botsMutex sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < s.config.Bots; i++ {
+			bot, err := s.startBot(i)
+			if err != nil {
+				s.Errorf("failed to start bot %d: %s", i+1, err)
+				s.stats.failed()
+			} else {
+				botsMutex.Lock()
+				bots = append(bots, bot)
+				botsMutex.Unlock()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.config.Ramp):
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.config.ReportEvery)
+	defer ticker.Stop()
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-ticker.C:
+			report(s.stats.Report())
+		}
+	}
+
+	wg.Wait()
+
+	for _, bot := range bots {
+		bot.stop()
+	}
+
+	report(s.stats.Report())
+}
+
+func (s *Swarm) startBot(index int) (*Bot, error) {
+	heroType := heroes[index%len(heroes)]
+	name := fmt.Sprintf("Bot%d", index+1)
+
+	hero, err := s.heroes.CreateHeroState(name, heroType,
+		s.heroes.CreateHeroStatsState(heroType, s.asset.Records.Character.Stats[heroType]))
+	if err != nil {
+		return nil, err
+	}
+
+	bot, err := newBot(s, index, hero)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bot.start(s.config.Connection, s.config.Interval); err != nil {
+		return nil, err
+	}
+
+	return bot, nil
+}
+
+// mapEngine returns the map the server told a bot to generate, generating it the first time.
+func (s *Swarm) mapEngine(generate d2netpacket.GenerateMapPacket) *d2mapengine.MapEngine {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+
+	if mapEngine, found := s.maps[generate]; found {
+		return mapEngine
+	}
+
+	mapEngine := d2mapengine.CreateMapEngine(s.logLevel, s.asset)
+	mapEngine.SetSeed(generate.Seed)
+	mapEngine.ResetMap(d2enum.RegionAct1Town, mapWidth, mapHeight)
+
+	mapGen, err := d2mapgen.NewMapGenerator(s.asset, s.logLevel, mapEngine)
+	if err != nil {
+		s.Errorf("failed to create the map generator: %s", err)
+		return nil
+	}
+
+	if err := mapGen.Generate(generate.Act, generate.LevelID, generate.Difficulty); err != nil {
+		s.Errorf("failed to generate the map: %s", err)
+		return nil
+	}
+
+	s.maps[generate] = mapEngine
+
+	return mapEngine
+}
@@ -0,0 +1,91 @@
+package d2client
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+// ChatMessageKind tells what kind of message a ChatMessage is.
+type ChatMessageKind int
+
+// Chat message kinds
+const (
+	ChatPublic          ChatMessageKind = iota // Sent by a player to every player
+	ChatWhisperReceived                        // Sent to the local player by another player
+	ChatWhisperSent                            // Sent by the local player to another player
+	ChatSystem                                 // Sent by the server
+)
+
+// ChatMessage is a message received from the server, to be shown in the chat box.
+type ChatMessage struct {
+	Kind ChatMessageKind
+	Name string // Name of the sender, or of the recipient of a whisper sent by the local player
+	Text string
+}
+
+// Chat sends the message the local player entered to the server. It may be a slash command.
+func (g *GameClient) Chat(message string) error {
+	packet, err := d2netpacket.CreateChatPacket(g.PlayerID, "", message)
+	if err != nil {
+		return err
+	}
+
+	return g.clientConnection.SendPacketToServer(packet)
+}
+
+// ChatMessages returns the messages received since it was last called.
+func (g *GameClient) ChatMessages() []ChatMessage {
+	g.chatMutex.Lock()
+	defer g.chatMutex.Unlock()
+
+	messages := g.chatMessages
+	g.chatMessages = nil
+
+	return messages
+}
+
+func (g *GameClient) addChatMessage(message ChatMessage) {
+	g.chatMutex.Lock()
+	defer g.chatMutex.Unlock()
+
+	g.chatMessages = append(g.chatMessages, message)
+}
+
+func (g *GameClient) handleChatPacket(packet d2netpacket.NetPacket) error {
+	chat, err := d2netpacket.UnmarshalChat(packet)
+	if err != nil {
+		return err
+	}
+
+	g.addChatMessage(ChatMessage{Kind: ChatPublic, Name: chat.Name, Text: chat.Message})
+
+	return nil
+}
+
+func (g *GameClient) handleWhisperPacket(packet d2netpacket.NetPacket) error {
+	whisper, err := d2netpacket.UnmarshalWhisper(packet)
+	if err != nil {
+		return err
+	}
+
+	message := ChatMessage{Kind: ChatWhisperReceived, Name: whisper.FromName, Text: whisper.Message}
+
+	if whisper.FromID == g.PlayerID {
+		message.Kind = ChatWhisperSent
+		message.Name = whisper.ToName
+	}
+
+	g.addChatMessage(message)
+
+	return nil
+}
+
+func (g *GameClient) handleSystemMessagePacket(packet d2netpacket.NetPacket) error {
+	systemMessage, err := d2netpacket.UnmarshalSystemMessage(packet)
+	if err != nil {
+		return err
+	}
+
+	g.addChatMessage(ChatMessage{Kind: ChatSystem, Text: systemMessage.Message})
+
+	return nil
+}
@@ -9,4 +9,5 @@ const (
 	Local     ClientConnectionType = iota // Local client
 	LANServer                             // Server
 	LANClient                             // Remote client
+	Demo                                  // Playback of a recorded demo
 )
@@ -38,8 +38,14 @@ func (l *LocalClientConnection) GetConnectionType() d2clientconnectiontype.Clien
 	return d2clientconnectiontype.Local
 }
 
-// SendPacketToClient passes a packet to the game client for processing.
+// SendPacketToClient passes a packet to the game client for processing. The packet is
+// detached, so the client shares no state with the server.
 func (l *LocalClientConnection) SendPacketToClient(packet d2netpacket.NetPacket) error {
+	packet, err := packet.Detach()
+	if err != nil {
+		return err
+	}
+
 	return l.clientListener.OnPacketReceived(packet)
 }
 
@@ -80,9 +86,7 @@ func (l *LocalClientConnection) Open(_, saveFilePath string) error {
 		return err
 	}
 
-	l.gameServer.OnClientConnected(l)
-
-	return nil
+	return l.gameServer.OnClientConnected(l)
 }
 
 // Close disconnects from the server and destroys it.
@@ -100,8 +104,14 @@ func (l *LocalClientConnection) Close() error {
 	return nil
 }
 
-// SendPacketToServer calls d2server.OnPacketReceived with the given packet.
+// SendPacketToServer calls d2server.OnPacketReceived with the given packet, detached
+// so the server shares no state with the client.
 func (l *LocalClientConnection) SendPacketToServer(packet d2netpacket.NetPacket) error {
+	packet, err := packet.Detach()
+	if err != nil {
+		return err
+	}
+
 	return l.gameServer.OnPacketReceived(l, packet)
 }
 
@@ -0,0 +1,191 @@
+package d2remoteclient
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2config"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2server"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2tls"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2udpchannel"
+)
+
+// lobbyTimeout is how long a request to the lobby of a server may take.
+const lobbyTimeout = 5 * time.Second
+
+const (
+	tlsScheme          = "tls://"
+	udpScheme          = "udp://"
+	knownHostsFileName = "known_hosts"
+)
+
+// ConnectionString describes the server and the game a client joins.
+type ConnectionString struct {
+	Address        string // host:port of the server
+	GameID         string // Game to join, empty if the lobby should pick one
+	Password       string // Password of the game
+	ServerPassword string // Password of a private server
+	TLS            bool   // Connect over TLS
+	UDP            bool   // Connect over UDP, see d2udpchannel
+}
+
+// ParseConnectionString parses a connection string of the form
+// [tls://|udp://][serverpassword@]host[:port][/game[/password]].
+func ParseConnectionString(connectionString string) ConnectionString {
+	var c ConnectionString
+
+	switch {
+	case strings.HasPrefix(connectionString, tlsScheme):
+		c.TLS = true
+		connectionString = strings.TrimPrefix(connectionString, tlsScheme)
+	case strings.HasPrefix(connectionString, udpScheme):
+		c.UDP = true
+		connectionString = strings.TrimPrefix(connectionString, udpScheme)
+	}
+
+	parts := strings.SplitN(connectionString, "/", 3) // nolint:gomnd // address, game and password
+
+	host := parts[0]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		c.ServerPassword, host = host[:at], host[at+1:]
+	}
+
+	c.Address = serverAddress(host)
+
+	if len(parts) > 1 {
+		c.GameID = parts[1]
+	}
+
+	if len(parts) > 2 { // nolint:gomnd // address, game and password
+		c.Password = parts[2]
+	}
+
+	return c
+}
+
+// serverAddress adds the default port to the address, if it has none.
+func serverAddress(address string) string {
+	if !strings.Contains(address, ":") {
+		address += ":" + strconv.Itoa(d2server.Port)
+	}
+
+	return address
+}
+
+// dial connects to the server. Over TLS, the certificate of the server must be the one
+// pinned for its address, the first certificate it presents is pinned. Over UDP there
+// is no handshake, the connection fails once the server does not answer.
+func dial(server ConnectionString, timeout time.Duration) (net.Conn, error) {
+	if server.UDP {
+		return d2udpchannel.Dial(server.Address)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+
+	if !server.TLS {
+		return dialer.Dial("tcp", server.Address)
+	}
+
+	knownHosts, err := d2tls.LoadKnownHosts(filepath.Join(filepath.Dir(d2config.DefaultConfigPath()), knownHostsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.DialWithDialer(dialer, "tcp", server.Address, d2tls.ClientConfig(server.Address, knownHosts))
+}
+
+// ListGames returns the games hosted by the lobby of the server of the connection string.
+func ListGames(address string) ([]d2netpacket.GameInfo, error) {
+	server := ParseConnectionString(address)
+
+	request, err := d2netpacket.CreateListGamesPacket(server.ServerPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := lobbyRequest(server, request, d2netpackettype.GameList)
+	if err != nil {
+		return nil, err
+	}
+
+	gameList, err := d2netpacket.UnmarshalGameList(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return gameList.Games, nil
+}
+
+// CreateGame asks the lobby of the server of the connection string to create a game,
+// and returns its ID. The game is joined by opening a connection to address/ID.
+func CreateGame(address, name string, difficulty d2enum.DifficultyType, password string,
+	maxPlayers int) (string, error) {
+	server := ParseConnectionString(address)
+
+	request, err := d2netpacket.CreateCreateGamePacket(name, difficulty, password, maxPlayers, server.ServerPassword)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := lobbyRequest(server, request, d2netpackettype.GameCreated)
+	if err != nil {
+		return "", err
+	}
+
+	gameCreated, err := d2netpacket.UnmarshalGameCreated(response)
+	if err != nil {
+		return "", err
+	}
+
+	return gameCreated.GameID, nil
+}
+
+// lobbyRequest sends the packet to the lobby of the server and returns its response.
+// The lobby explains why it refused a request with a system message.
+func lobbyRequest(server ConnectionString, request d2netpacket.NetPacket,
+	expected d2netpackettype.NetPacketType) (d2netpacket.NetPacket, error) {
+	conn, err := dial(server, lobbyTimeout)
+	if err != nil {
+		return d2netpacket.NetPacket{}, err
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(lobbyTimeout)); err != nil {
+		return d2netpacket.NetPacket{}, err
+	}
+
+	if err := d2netpacket.NewEncoder(d2netpacket.CodecJSON, conn).Encode(request); err != nil {
+		return d2netpacket.NetPacket{}, err
+	}
+
+	response, err := d2netpacket.NewDecoder(d2netpacket.CodecJSON, conn).Decode()
+	if err != nil {
+		return d2netpacket.NetPacket{}, err
+	}
+
+	switch response.PacketType {
+	case expected:
+		return response, nil
+	case d2netpackettype.SystemMessage:
+		message, err := d2netpacket.UnmarshalSystemMessage(response)
+		if err != nil {
+			return response, err
+		}
+
+		return response, errors.New(message.Message)
+	}
+
+	return response, fmt.Errorf("unexpected response %s from the lobby", response.PacketType)
+}
@@ -1,11 +1,11 @@
 package d2remoteclient
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"net"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -20,6 +20,15 @@ import (
 
 const logPrefix = "Remote Client"
 
+const (
+	// reconnectTimeout is how long the client tries to reconnect after its connection drops.
+	// The server keeps the player for about as long.
+	reconnectTimeout  = 2 * time.Minute
+	reconnectInterval = 2 * time.Second
+
+	connectTimeout = 10 * time.Second
+)
+
 // RemoteClientConnection is the implementation of ClientConnection
 // for a remote client.
 type RemoteClientConnection struct {
@@ -27,8 +36,13 @@ type RemoteClientConnection struct {
 	heroState      *d2hero.HeroStateFactory
 	clientListener d2networking.ClientListener // The GameClient
 	uniqueID       string                      // Unique ID generated on construction
-	tcpConnection  *net.TCPConn                // UDP connection to the server
+	tcpConnection  net.Conn                    // TCP connection to the server, over TLS if the server asks for it
 	active         bool                        // The connection is currently open
+	encoder        d2netpacket.PacketEncoder   // Encodes packets with the codec negotiated with the server
+	encoderMutex   sync.Mutex
+	gameState      *d2hero.HeroState // Hero state sent when connecting
+	sessionToken   string            // Session issued by the server, used to reconnect
+	server         ConnectionString  // Server and game to join
 
 	*d2util.Logger
 }
@@ -56,30 +70,49 @@ func Create(l d2util.LogLevel, asset *d2asset.AssetManager) (*RemoteClientConnec
 
 // Open runs serverListener() in a goroutine to continuously read UDP packets.
 // It also sends a PlayerConnectionRequestPacket packet to the server (see d2netpacket).
+// The connection string is [tls://|udp://][serverpassword@]host[:port][/game[/password]],
+// see ParseConnectionString.
 func (r *RemoteClientConnection) Open(connectionString, saveFilePath string) error {
-	if !strings.Contains(connectionString, ":") {
-		connectionString += ":6669"
-	}
+	return r.OpenAs(connectionString, r.heroState.LoadHeroState(saveFilePath))
+}
 
-	tcpAddress, err := net.ResolveTCPAddr("tcp", connectionString)
+// OpenAs is Open for a hero which is not saved, such as the hero of a bot.
+func (r *RemoteClientConnection) OpenAs(connectionString string, hero *d2hero.HeroState) error {
+	r.gameState = hero
+	r.server = ParseConnectionString(connectionString)
 
-	if err != nil {
+	if err := r.connect(); err != nil {
 		return err
 	}
 
-	r.tcpConnection, err = net.DialTCP("tcp", nil, tcpAddress)
+	r.active = true
+
+	go r.serverListener()
+
+	return nil
+}
+
+// connect dials the server and sends a PlayerConnectionRequestPacket, with the
+// session token if the client is reconnecting.
+func (r *RemoteClientConnection) connect() error {
+	tcpConnection, err := dial(r.server, connectTimeout)
 	if err != nil {
 		return err
 	}
 
-	r.active = true
-	go r.serverListener()
+	r.encoderMutex.Lock()
+	if r.tcpConnection != nil {
+		_ = r.tcpConnection.Close() // the dropped connection
+	}
 
-	r.Infof("Connected to server at %s", r.tcpConnection.RemoteAddr().String())
+	r.tcpConnection = tcpConnection
+	r.encoder = d2netpacket.NewEncoder(d2netpacket.CodecJSON, tcpConnection)
+	r.encoderMutex.Unlock()
 
-	gameState := r.heroState.LoadHeroState(saveFilePath)
+	r.Infof("Connected to server at %s", tcpConnection.RemoteAddr().String())
 
-	packet, err := d2netpacket.CreatePlayerConnectionRequestPacket(r.GetUniqueID(), gameState)
+	packet, err := d2netpacket.CreatePlayerConnectionRequestPacket(r.GetUniqueID(), r.gameState,
+		r.server.GameID, r.server.Password, r.server.ServerPassword, r.sessionToken)
 	if err != nil {
 		r.Errorf("PlayerConnectionRequestPacket: %v", err)
 	}
@@ -94,6 +127,26 @@ func (r *RemoteClientConnection) Open(connectionString, saveFilePath string) err
 	return nil
 }
 
+// reconnect tries to connect to the server again and resume the session, until
+// it succeeds or reconnectTimeout passes.
+func (r *RemoteClientConnection) reconnect() bool {
+	if r.sessionToken == "" {
+		return false
+	}
+
+	for deadline := time.Now().Add(reconnectTimeout); time.Now().Before(deadline) && r.active; {
+		r.Infof("Connection to the server lost, reconnecting...")
+
+		if err := r.connect(); err == nil {
+			return true
+		}
+
+		time.Sleep(reconnectInterval)
+	}
+
+	return false
+}
+
 // Close informs the server that this client has disconnected and sets
 // RemoteClientConnection.active to false.
 func (r *RemoteClientConnection) Close() error {
@@ -114,13 +167,13 @@ func (r *RemoteClientConnection) Close() error {
 }
 
 // GetUniqueID returns RemoteClientConnection.uniqueID.
-func (r RemoteClientConnection) GetUniqueID() string {
+func (r *RemoteClientConnection) GetUniqueID() string {
 	return r.uniqueID
 }
 
 // GetConnectionType returns an enum representing the connection type.
 // See: d2clientconnectiontype
-func (r RemoteClientConnection) GetConnectionType() d2clientconnectiontype.ClientConnectionType {
+func (r *RemoteClientConnection) GetConnectionType() d2clientconnectiontype.ClientConnectionType {
 	return d2clientconnectiontype.LANClient
 }
 
@@ -129,28 +182,22 @@ func (r *RemoteClientConnection) SetClientListener(listener d2networking.ClientL
 	r.clientListener = listener
 }
 
-// SendPacketToServer compresses the JSON encoding of a NetPacket and
+// SendPacketToServer encodes a NetPacket with the negotiated codec and
 // sends it to the server.
 func (r *RemoteClientConnection) SendPacketToServer(packet d2netpacket.NetPacket) error {
-	encoder := json.NewEncoder(r.tcpConnection)
-
-	err := encoder.Encode(packet)
-	if err != nil {
-		return err
-	}
+	r.encoderMutex.Lock()
+	defer r.encoderMutex.Unlock()
 
-	return nil
+	return r.encoder.Encode(packet)
 }
 
 // serverListener runs a while loop, reading from the GameServer's TCP
 // connection.
 func (r *RemoteClientConnection) serverListener() {
-	decoder := json.NewDecoder(r.tcpConnection)
+	decoder := d2netpacket.NewDecoder(d2netpacket.CodecJSON, r.tcpConnection)
 
 	for {
-		var packet d2netpacket.NetPacket
-
-		err := decoder.Decode(&packet)
+		packet, err := decoder.Decode()
 		if err != nil {
 			switch err {
 			case io.EOF:
@@ -159,14 +206,23 @@ func (r *RemoteClientConnection) serverListener() {
 				r.Errorf("failed to decode the packet, err: %v\n", err)
 			}
 
+			if r.active && r.reconnect() {
+				decoder = d2netpacket.NewDecoder(d2netpacket.CodecJSON, r.tcpConnection)
+				continue
+			}
+
 			return // allow the connection to close
 		}
 
-		p, err := r.decodeToPacket(packet.PacketType, string(packet.PacketData))
+		p, err := r.decodeToPacket(packet)
 		if err != nil {
 			r.Errorf("%v %v", packet.PacketType, err)
 		}
 
+		if packet.PacketType == d2netpackettype.UpdateServerInfo {
+			decoder = r.switchCodec(p, decoder)
+		}
+
 		err = r.clientListener.OnPacketReceived(p)
 		if err != nil {
 			r.Errorf("%v %v", packet.PacketType, err)
@@ -174,6 +230,34 @@ func (r *RemoteClientConnection) serverListener() {
 	}
 }
 
+// switchCodec switches the encoder and decoder over to the codec chosen by the server in the
+// given UpdateServerInfo packet. The server uses the codec for every packet after this one.
+// It also keeps the session token of the packet, to reconnect with.
+func (r *RemoteClientConnection) switchCodec(
+	packet d2netpacket.NetPacket,
+	decoder d2netpacket.PacketDecoder) d2netpacket.PacketDecoder {
+	serverInfo, err := d2netpacket.UnmarshalUpdateServerInfo(packet)
+	if err != nil {
+		return decoder
+	}
+
+	if serverInfo.SessionToken != "" {
+		r.sessionToken = serverInfo.SessionToken
+	}
+
+	if serverInfo.Codec == "" {
+		return decoder
+	}
+
+	r.Infof("Using %s codec", serverInfo.Codec)
+
+	r.encoderMutex.Lock()
+	r.encoder = d2netpacket.NewEncoder(serverInfo.Codec, r.tcpConnection)
+	r.encoderMutex.Unlock()
+
+	return d2netpacket.SwitchDecoder(decoder, serverInfo.Codec, r.tcpConnection)
+}
+
 // bytesToJSON reads the packet type, decompresses the packet and returns a JSON string.
 // nolint:unused // WIP
 func (r *RemoteClientConnection) bytesToJSON(buffer []byte) (string, d2netpackettype.NetPacketType, error) {
@@ -185,35 +269,55 @@ func (r *RemoteClientConnection) bytesToJSON(buffer []byte) (string, d2netpacket
 	return string(packet.PacketData), packet.PacketType, nil
 }
 
-// decodeToPacket unmarshals the JSON string into the correct struct
+// decodeToPacket unmarshals the packet into the correct struct
 // and returns a NetPacket declaring that struct.
 // nolint:gocyclo,funlen // switch statement on packet type makes sense, no need to change
 func (r *RemoteClientConnection) decodeToPacket(
-	t d2netpackettype.NetPacketType,
-	data string) (d2netpacket.NetPacket, error) {
+	packet d2netpacket.NetPacket) (d2netpacket.NetPacket, error) {
 	var (
 		np  = d2netpacket.NetPacket{}
+		t   = packet.PacketType
 		err error
 		p   interface{}
 	)
 
 	switch t {
 	case d2netpackettype.GenerateMap:
-		p, err = d2netpacket.UnmarshalGenerateMap([]byte(data))
+		p, err = d2netpacket.UnmarshalGenerateMap(packet)
 	case d2netpackettype.MovePlayer:
-		p, err = d2netpacket.UnmarshalMovePlayer([]byte(data))
+		p, err = d2netpacket.UnmarshalMovePlayer(packet)
+	case d2netpackettype.MovePlayerCorrection:
+		p, err = d2netpacket.UnmarshalMovePlayerCorrection(packet)
 	case d2netpackettype.UpdateServerInfo:
-		p, err = d2netpacket.UnmarshalUpdateServerInfo([]byte(data))
+		p, err = d2netpacket.UnmarshalUpdateServerInfo(packet)
 	case d2netpackettype.AddPlayer:
-		p, err = d2netpacket.UnmarshalAddPlayer([]byte(data))
+		p, err = d2netpacket.UnmarshalAddPlayer(packet)
 	case d2netpackettype.CastSkill:
-		p, err = d2netpacket.UnmarshalCast([]byte(data))
+		p, err = d2netpacket.UnmarshalCast(packet)
 	case d2netpackettype.Ping:
-		p, err = d2netpacket.UnmarshalPing([]byte(data))
+		p, err = d2netpacket.UnmarshalPing(packet)
 	case d2netpackettype.PlayerDisconnectionNotification:
-		p, err = d2netpacket.UnmarshalPlayerDisconnectionRequest([]byte(data))
+		p, err = d2netpacket.UnmarshalPlayerDisconnectionRequest(packet)
 	case d2netpackettype.ServerClosed:
-		p, err = d2netpacket.UnmarshalServerClosed([]byte(data))
+		p, err = d2netpacket.UnmarshalServerClosed(packet)
+	case d2netpackettype.SystemMessage:
+		p, err = d2netpacket.UnmarshalSystemMessage(packet)
+	case d2netpackettype.Chat:
+		p, err = d2netpacket.UnmarshalChat(packet)
+	case d2netpackettype.Whisper:
+		p, err = d2netpacket.UnmarshalWhisper(packet)
+	case d2netpackettype.SpawnItem:
+		p, err = d2netpacket.UnmarshalSpawnItem(packet)
+	case d2netpackettype.SpawnMissile:
+		p, err = d2netpacket.UnmarshalSpawnMissile(packet)
+	case d2netpackettype.SpawnNPC:
+		p, err = d2netpacket.UnmarshalSpawnNPC(packet)
+	case d2netpackettype.EntityStates:
+		p, err = d2netpacket.UnmarshalEntityStates(packet)
+	case d2netpackettype.ConnectionRejected:
+		p, err = d2netpacket.UnmarshalConnectionRejected(packet)
+	case d2netpackettype.PlayerWarp:
+		p, err = d2netpacket.UnmarshalPlayerWarp(packet)
 	default:
 		err = fmt.Errorf("RemoteClientConnection: unrecognized packet type: %v", t)
 	}
@@ -222,12 +326,7 @@ func (r *RemoteClientConnection) decodeToPacket(
 		return np, err
 	}
 
-	mp, marshalErr := d2netpacket.MarshalPacket(p)
-	if marshalErr != nil {
-		r.Errorf("MarshalPacket: %v", marshalErr)
-	}
-
-	np = d2netpacket.NetPacket{PacketType: t, PacketData: mp}
+	np = d2netpacket.NetPacket{PacketType: t, Packet: p}
 
 	return np, nil
 }
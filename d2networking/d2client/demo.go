@@ -0,0 +1,262 @@
+package d2client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2demo"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// demoTickInterval is how often the playback of a demo delivers the packets which are due.
+const demoTickInterval = 10 * time.Millisecond
+
+var errNotPlaying = errors.New("the demo is not playing")
+
+// RecordDemo records every packet the client receives into the demo file at path.
+// It must be called before Open.
+func (g *GameClient) RecordDemo(path string) error {
+	recorder, err := d2demo.Create(path)
+	if err != nil {
+		return err
+	}
+
+	g.recorder = recorder
+
+	return nil
+}
+
+// Demo returns the connection playing back a demo, or nil if the client is not
+// playing one.
+func (g *GameClient) Demo() *DemoServerConnection {
+	demo, _ := g.clientConnection.(*DemoServerConnection)
+	return demo
+}
+
+// recordPacket writes the packet to the demo being recorded, if any.
+func (g *GameClient) recordPacket(packet d2netpacket.NetPacket) {
+	if g.recorder == nil {
+		return
+	}
+
+	data, err := packet.Data()
+	if err == nil {
+		err = g.recorder.Record(uint32(packet.PacketType), data)
+	}
+
+	if err != nil {
+		g.Errorf("failed to record %s, stopping the recording: %s", packet.PacketType, err)
+		g.stopRecording()
+	}
+}
+
+func (g *GameClient) stopRecording() {
+	if g.recorder == nil {
+		return
+	}
+
+	if err := g.recorder.Close(); err != nil {
+		g.Errorf("failed to close the demo: %s", err)
+	}
+
+	g.recorder = nil
+}
+
+// beginRewind is called before a demo plays again from the start. The players are
+// kept, so the game screen keeps its local player, and packets adding them again
+// update them instead.
+func (g *GameClient) beginRewind() {
+	g.rewoundPlayers = make(map[string]bool)
+
+	g.chatMutex.Lock()
+	g.chatMessages = nil
+	g.chatMutex.Unlock()
+}
+
+// endRewind removes the players which did not exist yet at the time the demo was rewound to.
+func (g *GameClient) endRewind() {
+	for id, player := range g.Players {
+		if g.rewoundPlayers[id] || id == g.PlayerID {
+			continue
+		}
+
+		g.MapEngine.RemoveEntity(player)
+		delete(g.Players, id)
+	}
+
+	g.rewoundPlayers = nil
+}
+
+// DemoServerConnection is a ServerConnection which plays back a recorded demo. The
+// packets the client sends are dropped.
+type DemoServerConnection struct {
+	playback  *d2demo.Playback
+	listener  d2networking.ClientListener
+	client    *GameClient
+	done      chan struct{}
+	closeOnce sync.Once
+	mutex     sync.Mutex
+
+	*d2util.Logger
+}
+
+// NewDemoServerConnection creates a connection which plays back a demo to the client.
+func NewDemoServerConnection(client *GameClient, logger *d2util.Logger) *DemoServerConnection {
+	return &DemoServerConnection{
+		client: client,
+		done:   make(chan struct{}),
+		Logger: logger,
+	}
+}
+
+// Open loads the demo file, whose path is the connection string, and starts playing it.
+func (d *DemoServerConnection) Open(connectionString, _ string) error {
+	records, err := d2demo.Open(connectionString)
+	if err != nil {
+		return err
+	}
+
+	d.playback = d2demo.NewPlayback(records)
+
+	d.Infof("Playing demo %s (%s)", connectionString, d.playback.Duration().Round(time.Second))
+
+	go d.play()
+
+	return nil
+}
+
+func (d *DemoServerConnection) play() {
+	ticker := time.NewTicker(demoTickInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case now := <-ticker.C:
+			d.mutex.Lock()
+			d.deliver(d.playback.Advance(now.Sub(last)))
+			d.mutex.Unlock()
+
+			last = now
+		}
+	}
+}
+
+// deliver passes the records to the client. The caller must hold the mutex, so
+// records are delivered in order.
+func (d *DemoServerConnection) deliver(records []d2demo.Record) {
+	for _, record := range records {
+		packet := d2netpacket.NetPacket{
+			PacketType: d2netpackettype.NetPacketType(record.PacketType),
+			PacketData: record.Data,
+		}
+
+		if err := d.listener.OnPacketReceived(packet); err != nil {
+			d.Errorf("demo: failed to play %s: %s", packet.PacketType, err)
+		}
+	}
+}
+
+// Close stops the playback.
+func (d *DemoServerConnection) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.done)
+	})
+
+	return nil
+}
+
+// SendPacketToServer drops the packet, the demo can not be changed.
+func (d *DemoServerConnection) SendPacketToServer(_ d2netpacket.NetPacket) error {
+	return nil
+}
+
+// SetClientListener sets the listener the demo is played back to.
+func (d *DemoServerConnection) SetClientListener(listener d2networking.ClientListener) {
+	d.listener = listener
+}
+
+// TogglePause pauses or resumes the playback, and returns true if it is now paused.
+func (d *DemoServerConnection) TogglePause() (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.playback == nil {
+		return false, errNotPlaying
+	}
+
+	d.playback.SetPaused(!d.playback.Paused())
+
+	return d.playback.Paused(), nil
+}
+
+// SetSpeed sets how much faster than real time the demo plays, fast-forwarding it
+// when the speed is above 1.
+func (d *DemoServerConnection) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid speed %g", speed)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.playback == nil {
+		return errNotPlaying
+	}
+
+	d.playback.SetSpeed(speed)
+
+	return nil
+}
+
+// Seek moves the playback to the given time in the demo. The packets up to that time
+// are delivered to the client at once.
+func (d *DemoServerConnection) Seek(to time.Duration) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.playback == nil {
+		return errNotPlaying
+	}
+
+	records, rewound := d.playback.Seek(to)
+
+	if rewound {
+		d.client.beginRewind()
+		defer d.client.endRewind()
+	}
+
+	d.deliver(records)
+
+	return nil
+}
+
+// Status describes the state of the playback.
+func (d *DemoServerConnection) Status() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.playback == nil {
+		return "not playing"
+	}
+
+	state := "playing"
+
+	switch {
+	case d.playback.Finished():
+		state = "finished"
+	case d.playback.Paused():
+		state = "paused"
+	}
+
+	return fmt.Sprintf("%s %s / %s at %gx", state, d.playback.Position().Round(time.Second),
+		d.playback.Duration().Round(time.Second), d.playback.Speed())
+}
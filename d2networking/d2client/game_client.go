@@ -3,6 +3,8 @@ package d2client
 import (
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 
@@ -10,9 +12,8 @@ import (
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
 
-	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2interface"
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
@@ -21,8 +22,11 @@ import (
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2localclient"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2remoteclient"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2demo"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
 	"github.com/OpenDiablo2/OpenDiablo2/d2script"
 )
 
@@ -30,6 +34,10 @@ const logPrefix = "Game Client"
 
 const (
 	numSubtilesPerTile = 5
+
+	// snapDistance is how far, in sub tiles, a displayed player may be from where the
+	// server says it is before it is placed there instead of walking there.
+	snapDistance = 5 * numSubtilesPerTile
 )
 
 // GameClient manages a connection to d2server.GameServer
@@ -46,6 +54,16 @@ type GameClient struct {
 	Players          map[string]*d2mapentity.Player // IDs of the other players
 	Seed             int64                          // Map seed
 	RegenMap         bool                           // Regenerate tile cache on render (map has changed)
+	moveSequence     uint32                         // Sequence number of the last move of the local player
+	snapshots        *snapshotBuffer                // Moves of the other players, until they are due
+	chatMessages     []ChatMessage                  // Received chat messages, see ChatMessages
+	chatMutex        sync.Mutex
+	recorder         *d2demo.Recorder                 // Demo the received packets are recorded into, if any
+	rewoundPlayers   map[string]bool                  // Players added again while a demo is rewound
+	replication      *d2replication.Receiver          // Entity states received from the server
+	netEntities      map[uint32]d2interface.MapEntity // Entities spawned by the server, by network ID
+	rejection        string                           // Why the server refused to let the player join, if it did
+	rejectionMutex   sync.Mutex
 
 	*d2util.Logger
 }
@@ -61,6 +79,9 @@ func Create(connectionType d2clientconnectiontype.ClientConnectionType,
 		Players:        make(map[string]*d2mapentity.Player),
 		connectionType: connectionType,
 		scriptEngine:   scriptEngine,
+		replication:    d2replication.NewReceiver(),
+		snapshots:      newSnapshotBuffer(),
+		netEntities:    make(map[uint32]d2interface.MapEntity),
 	}
 
 	result.Logger = d2util.NewLogger()
@@ -69,7 +90,8 @@ func Create(connectionType d2clientconnectiontype.ClientConnectionType,
 
 	// for a remote client connection, set loading to true - wait until we process the GenerateMapPacket
 	// before we start updating map entites
-	result.MapEngine.IsLoading = connectionType == d2clientconnectiontype.LANClient
+	result.MapEngine.IsLoading = connectionType == d2clientconnectiontype.LANClient ||
+		connectionType == d2clientconnectiontype.Demo
 
 	mapGen, err := d2mapgen.NewMapGenerator(asset, l, result.MapEngine)
 	if err != nil {
@@ -85,6 +107,8 @@ func Create(connectionType d2clientconnectiontype.ClientConnectionType,
 		result.clientConnection, err = d2localclient.Create(asset, l, true)
 	case d2clientconnectiontype.Local:
 		result.clientConnection, err = d2localclient.Create(asset, l, false)
+	case d2clientconnectiontype.Demo:
+		result.clientConnection = NewDemoServerConnection(result, result.Logger)
 	default:
 		err = fmt.Errorf("unknown client connection type specified: %d", connectionType)
 	}
@@ -98,6 +122,15 @@ func Create(connectionType d2clientconnectiontype.ClientConnectionType,
 	return result, nil
 }
 
+// SimulateNetwork makes the connection to the server behave like a network
+// with the given conditions. It must be called before Open.
+func (g *GameClient) SimulateNetwork(conditions d2netsim.Conditions) {
+	conn := NewSimulatedServerConnection(g.clientConnection, conditions, g.Logger)
+	conn.SetClientListener(g)
+
+	g.clientConnection = conn
+}
+
 // Open creates the server and connects to it if the client is local.
 // If the client is remote it sends a PlayerConnectionRequestPacket to the
 // server (see d2netpacket).
@@ -118,9 +151,24 @@ func (g *GameClient) Close() error {
 		g.scriptEngine.DisallowEval()
 	}
 
+	g.stopRecording()
+
+	// the server closed the connection after it refused it
+	if _, rejected := g.Rejection(); rejected {
+		return nil
+	}
+
 	return g.clientConnection.Close()
 }
 
+// Rejection returns why the server refused to let the player join, if it did.
+func (g *GameClient) Rejection() (reason string, rejected bool) {
+	g.rejectionMutex.Lock()
+	defer g.rejectionMutex.Unlock()
+
+	return g.rejection, g.rejection != ""
+}
+
 // Destroy does the same thing as Close.
 func (g *GameClient) Destroy() error {
 	return g.Close()
@@ -130,6 +178,8 @@ func (g *GameClient) Destroy() error {
 // packets.
 // nolint:gocyclo // switch statement on packet type makes sense, no need to change
 func (g *GameClient) OnPacketReceived(packet d2netpacket.NetPacket) error {
+	g.recordPacket(packet)
+
 	switch packet.PacketType {
 	case d2netpackettype.GenerateMap:
 		if err := g.handleGenerateMapPacket(packet); err != nil {
@@ -147,6 +197,10 @@ func (g *GameClient) OnPacketReceived(packet d2netpacket.NetPacket) error {
 		if err := g.handleMovePlayerPacket(packet); err != nil {
 			return err
 		}
+	case d2netpackettype.MovePlayerCorrection:
+		if err := g.handleMovePlayerCorrectionPacket(packet); err != nil {
+			return err
+		}
 	case d2netpackettype.CastSkill:
 		if err := g.handleCastSkillPacket(packet); err != nil {
 			return err
@@ -155,6 +209,18 @@ func (g *GameClient) OnPacketReceived(packet d2netpacket.NetPacket) error {
 		if err := g.handleSpawnItemPacket(packet); err != nil {
 			return err
 		}
+	case d2netpackettype.SpawnMissile:
+		if err := g.handleSpawnMissilePacket(packet); err != nil {
+			return err
+		}
+	case d2netpackettype.SpawnNPC:
+		if err := g.handleSpawnNPCPacket(packet); err != nil {
+			return err
+		}
+	case d2netpackettype.EntityStates:
+		if err := g.handleEntityStatesPacket(packet); err != nil {
+			return err
+		}
 	case d2netpackettype.Ping:
 		if err := g.handlePingPacket(); err != nil {
 			g.Errorf("GameClient: error responding to server ping: %s", err)
@@ -163,13 +229,30 @@ func (g *GameClient) OnPacketReceived(packet d2netpacket.NetPacket) error {
 		if err := g.handlePlayerDisconnectionPacket(packet); err != nil {
 			return err
 		}
+	case d2netpackettype.SystemMessage:
+		if err := g.handleSystemMessagePacket(packet); err != nil {
+			return err
+		}
+	case d2netpackettype.Chat:
+		if err := g.handleChatPacket(packet); err != nil {
+			return err
+		}
+	case d2netpackettype.Whisper:
+		if err := g.handleWhisperPacket(packet); err != nil {
+			return err
+		}
 	case d2netpackettype.ServerClosed:
 		// https://github.com/OpenDiablo2/OpenDiablo2/issues/802
 		g.Infof("Server has been closed")
 		os.Exit(0)
-	case d2netpackettype.ServerFull:
-		g.Infof("Server is full") // need to be verified
-		os.Exit(0)
+	case d2netpackettype.ConnectionRejected:
+		if err := g.handleConnectionRejectedPacket(packet); err != nil {
+			return err
+		}
+	case d2netpackettype.PlayerWarp:
+		if err := g.handlePlayerWarpPacket(packet); err != nil {
+			return err
+		}
 	default:
 		g.Fatalf("Invalid packet type: %d", packet.PacketType)
 	}
@@ -184,13 +267,35 @@ func (g *GameClient) SendPacketToServer(packet d2netpacket.NetPacket) error {
 }
 
 func (g *GameClient) handleGenerateMapPacket(packet d2netpacket.NetPacket) error {
-	mapData, err := d2netpacket.UnmarshalGenerateMap(packet.PacketData)
+	mapData, err := d2netpacket.UnmarshalGenerateMap(packet)
 	if err != nil {
 		return err
 	}
 
-	if mapData.RegionType == d2enum.RegionAct1Town {
-		g.mapGen.GenerateAct1Overworld()
+	g.MapEngine.SetSeed(mapData.Seed)
+
+	if err := g.mapGen.Generate(mapData.Act, mapData.LevelID, mapData.Difficulty); err != nil {
+		return err
+	}
+
+	g.resetReplication()
+
+	// the server spawns the NPCs near the player
+	for _, entity := range g.MapEngine.Entities() {
+		if npc, ok := entity.(*d2mapentity.NPC); ok {
+			g.MapEngine.RemoveEntity(npc)
+		}
+	}
+
+	// generating the map removed the entities, the local player is kept and the server
+	// adds the other players of the map
+	for id, player := range g.Players {
+		if id != g.PlayerID {
+			delete(g.Players, id)
+			continue
+		}
+
+		g.MapEngine.AddEntity(player)
 	}
 
 	g.RegenMap = true
@@ -198,12 +303,36 @@ func (g *GameClient) handleGenerateMapPacket(packet d2netpacket.NetPacket) error
 	return nil
 }
 
+func (g *GameClient) handleConnectionRejectedPacket(packet d2netpacket.NetPacket) error {
+	connectionRejected, err := d2netpacket.UnmarshalConnectionRejected(packet)
+	if err != nil {
+		return err
+	}
+
+	g.Warningf("The server refused the connection: %s", connectionRejected.Message())
+
+	g.rejectionMutex.Lock()
+	g.rejection = connectionRejected.Message()
+	g.rejectionMutex.Unlock()
+
+	return nil
+}
+
 func (g *GameClient) handleUpdateServerInfoPacket(packet d2netpacket.NetPacket) error {
-	serverInfo, err := d2netpacket.UnmarshalUpdateServerInfo(packet.PacketData)
+	serverInfo, err := d2netpacket.UnmarshalUpdateServerInfo(packet)
 	if err != nil {
 		return err
 	}
 
+	if serverInfo.Resumed {
+		g.Infof("Resumed the session of player %s", serverInfo.PlayerID)
+
+		// the server spawns the entities near the player again
+		g.resetReplication()
+
+		return nil
+	}
+
 	g.MapEngine.SetSeed(serverInfo.Seed)
 	g.PlayerID = serverInfo.PlayerID
 	g.Seed = serverInfo.Seed
@@ -213,13 +342,31 @@ func (g *GameClient) handleUpdateServerInfoPacket(packet d2netpacket.NetPacket)
 }
 
 func (g *GameClient) handleAddPlayerPacket(packet d2netpacket.NetPacket) error {
-	player, err := d2netpacket.UnmarshalAddPlayer(packet.PacketData)
+	player, err := d2netpacket.UnmarshalAddPlayer(packet)
 	if err != nil {
 		return err
 	}
 
 	d2hero.HydrateSkills(player.Skills, g.asset)
 
+	if g.rewoundPlayers != nil {
+		g.rewoundPlayers[player.ID] = true
+	}
+
+	// after a session is resumed, the server sends the players the client already has
+	if existing, found := g.Players[player.ID]; found {
+		existing.SetPosition(d2vector.NewPosition(float64(player.X), float64(player.Y)))
+		existing.Stats = player.Stats
+		existing.Equipment = &player.Equipment
+		existing.Gold = player.Gold
+
+		if player.ID == g.PlayerID {
+			existing.AcknowledgeMove(g.moveSequence)
+		}
+
+		return nil
+	}
+
 	newPlayer := g.MapEngine.NewPlayer(player.ID, player.Name, player.X, player.Y, 0,
 		player.HeroType, player.Stats, player.Skills, &player.Equipment, player.LeftSkill, player.RightSkill, player.Gold)
 
@@ -229,184 +376,148 @@ func (g *GameClient) handleAddPlayerPacket(packet d2netpacket.NetPacket) error {
 	return nil
 }
 
-func (g *GameClient) handleSpawnItemPacket(packet d2netpacket.NetPacket) error {
-	item, err := d2netpacket.UnmarshalSpawnItem(packet.PacketData)
-	if err != nil {
-		return err
+// MovePlayer moves the local player towards the given tile position and sends the
+// move to the server. The move is applied right away and reconciled once the server
+// acknowledges or corrects it.
+func (g *GameClient) MovePlayer(targetX, targetY float64) error {
+	// a demo is watched, not played
+	if g.connectionType == d2clientconnectiontype.Demo {
+		return nil
+	}
+
+	player, found := g.Players[g.PlayerID]
+	if !found {
+		return fmt.Errorf("local player %s has not been added", g.PlayerID)
 	}
 
-	itemEntity, err := g.MapEngine.NewItem(item.X, item.Y, item.Codes...)
+	g.moveSequence++
 
-	if err == nil {
-		g.MapEngine.AddEntity(itemEntity)
+	start := player.Position.World()
+	dest := d2vector.NewPositionTile(targetX, targetY)
+
+	player.PredictMove(g.moveSequence, dest)
+	g.setPlayerPath(player, player.Position, dest)
+
+	packet, err := d2netpacket.CreateMovePlayerPacket(g.PlayerID, start.X(), start.Y(), targetX, targetY, g.moveSequence)
+	if err != nil {
+		return err
 	}
 
-	return err
+	return g.SendPacketToServer(packet)
 }
 
 func (g *GameClient) handleMovePlayerPacket(packet d2netpacket.NetPacket) error {
-	movePlayer, err := d2netpacket.UnmarshalMovePlayer(packet.PacketData)
+	movePlayer, err := d2netpacket.UnmarshalMovePlayer(packet)
 	if err != nil {
 		return err
 	}
 
-	player := g.Players[movePlayer.PlayerID]
+	player, found := g.Players[movePlayer.PlayerID]
+	if !found {
+		return fmt.Errorf("received a move for unknown player %s", movePlayer.PlayerID)
+	}
+
 	start := d2vector.NewPositionTile(movePlayer.StartX, movePlayer.StartY)
 	dest := d2vector.NewPositionTile(movePlayer.DestX, movePlayer.DestY)
-	path := g.MapEngine.PathFind(start, dest)
-
-	if len(path) > 0 {
-		player.SetPath(path, func() {
-			tilePosition := player.Position.Tile()
-			tile := g.MapEngine.TileAt(int(tilePosition.X()), int(tilePosition.Y()))
-
-			if tile == nil {
-				return
-			}
 
-			player.SetIsInTown(tile.RegionType == d2enum.RegionAct1Town)
+	if movePlayer.PlayerID != g.PlayerID {
+		g.snapshots.push(movePlayer.PlayerID, moveSnapshot{received: time.Now(), start: start, dest: dest})
+		return nil
+	}
 
-			err := player.SetAnimationMode(player.GetAnimationMode())
+	// the local player already walks along its predicted path
+	if player.AcknowledgeMove(movePlayer.Sequence) {
+		return nil
+	}
 
-			if err != nil {
-				fmtStr := "GameClient: error setting animation mode for player %s: %s"
-				g.Errorf(fmtStr, player.ID(), err)
-			}
-		})
+	// walk on from where the player is shown, unless it drifted too far from the server
+	if player.Position.Distance(&start.Vector) > snapDistance {
+		player.SetPosition(start)
 	}
 
+	g.setPlayerPath(player, player.Position, dest)
+
 	return nil
 }
 
-func (g *GameClient) handleCastSkillPacket(packet d2netpacket.NetPacket) error {
-	playerCast, err := d2netpacket.UnmarshalCast(packet.PacketData)
+func (g *GameClient) handleMovePlayerCorrectionPacket(packet d2netpacket.NetPacket) error {
+	correction, err := d2netpacket.UnmarshalMovePlayerCorrection(packet)
 	if err != nil {
 		return err
 	}
 
-	player := g.Players[playerCast.SourceEntityID]
-	player.StopMoving()
-
-	castX := playerCast.TargetX * numSubtilesPerTile
-	castY := playerCast.TargetY * numSubtilesPerTile
-
-	direction := player.Position.DirectionTo(*d2vector.NewVector(castX, castY))
-	player.SetDirection(direction)
-
-	skillRecord := g.asset.Records.Skill.Details[playerCast.SkillID]
-
-	missileEntities, err := g.createMissileEntities(skillRecord, player, castX, castY)
-	if err != nil {
-		return err
+	player, found := g.Players[correction.PlayerID]
+	if !found {
+		return fmt.Errorf("received a move correction for unknown player %s", correction.PlayerID)
 	}
 
-	var summonedNpcEntity *d2mapentity.NPC
-	if skillRecord.Summon != "" {
-		summonedNpcEntity, err = g.createSummonedNpcEntity(skillRecord, int(castX), int(castY))
+	position := d2vector.NewPositionTile(correction.X, correction.Y)
 
-		if err != nil {
-			return err
-		}
+	if correction.PlayerID != g.PlayerID {
+		g.snapshots.push(correction.PlayerID, moveSnapshot{received: time.Now(), start: position, dest: position})
+		return nil
 	}
 
-	player.StartCasting(skillRecord.Anim, func() {
-		if len(missileEntities) > 0 {
-			// shoot the missiles of the skill after the player has finished casting
-			for _, missileEntity := range missileEntities {
-				g.MapEngine.AddEntity(missileEntity)
-			}
-		}
-
-		if summonedNpcEntity != nil {
-			// summon the referenced NPC after the player has finished casting
-			g.MapEngine.AddEntity(summonedNpcEntity)
-		}
-	})
+	// roll back to the server position, then replay the moves the server has not seen yet
+	player.SetPosition(position)
+	player.AcknowledgeMove(correction.Sequence)
 
-	overlayRecord := g.asset.Records.Layout.Overlays[skillRecord.Castoverlay]
-
-	return g.playCastOverlay(overlayRecord, int(player.Position.X()), int(player.Position.Y()))
-}
-
-func (g *GameClient) createSummonedNpcEntity(skillRecord *d2records.SkillRecord, x, y int) (*d2mapentity.NPC, error) {
-	monsterStatsRecord := g.asset.Records.Monster.Stats[skillRecord.Summon]
-
-	if monsterStatsRecord == nil {
-		fmtErr := "cannot cast skill - No monstat entry for \"%s\""
-		return nil, fmt.Errorf(fmtErr, skillRecord.Summon)
+	if pending := player.PendingMoves(); len(pending) > 0 {
+		g.setPlayerPath(player, position, pending[len(pending)-1].Dest)
 	}
 
-	// https://github.com/OpenDiablo2/OpenDiablo2/issues/803
-	summonedNpcEntity, err := g.MapEngine.NewNPC(x, y, monsterStatsRecord, 0)
-	if err != nil {
-		return nil, err
-	}
-
-	return summonedNpcEntity, nil
+	return nil
 }
 
-func (g *GameClient) createMissileEntities(
-	skillRecord *d2records.SkillRecord,
-	player *d2mapentity.Player,
-	castX, castY float64,
-) ([]*d2mapentity.Missile, error) {
-	missileRecords := []*d2records.MissileRecord{
-		g.asset.Records.GetMissileByName(skillRecord.Cltmissile),
-		g.asset.Records.GetMissileByName(skillRecord.Cltmissilea),
-		g.asset.Records.GetMissileByName(skillRecord.Cltmissileb),
-		g.asset.Records.GetMissileByName(skillRecord.Cltmissilec),
-		g.asset.Records.GetMissileByName(skillRecord.Cltmissiled),
+// setPlayerPath makes the player walk from start to dest.
+func (g *GameClient) setPlayerPath(player *d2mapentity.Player, start, dest d2vector.Position) {
+	path := g.MapEngine.PathFind(start, dest)
+	if len(path) == 0 {
+		return
 	}
 
-	missileEntities := make([]*d2mapentity.Missile, 0)
+	player.SetPath(path, func() {
+		tilePosition := player.Position.Tile()
+		tile := g.MapEngine.TileAt(int(tilePosition.X()), int(tilePosition.Y()))
 
-	for _, missileRecord := range missileRecords {
-		if missileRecord == nil {
-			continue
+		if tile == nil {
+			return
 		}
 
-		missileEntity, err := g.createMissileEntity(missileRecord, player, castX, castY)
+		player.SetIsInTown(tile.RegionType == d2enum.RegionAct1Town)
+
+		err := player.SetAnimationMode(player.GetAnimationMode())
+
 		if err != nil {
-			return nil, err
+			fmtStr := "GameClient: error setting animation mode for player %s: %s"
+			g.Errorf(fmtStr, player.ID(), err)
 		}
+	})
+}
 
-		missileEntities = append(missileEntities, missileEntity)
+func (g *GameClient) handleCastSkillPacket(packet d2netpacket.NetPacket) error {
+	playerCast, err := d2netpacket.UnmarshalCast(packet)
+	if err != nil {
+		return err
 	}
 
-	return missileEntities, nil
-}
+	player := g.Players[playerCast.SourceEntityID]
+	player.StopMoving()
 
-func (g *GameClient) createMissileEntity(
-	missileRecord *d2records.MissileRecord,
-	player *d2mapentity.Player,
-	castX, castY float64,
-) (*d2mapentity.Missile, error) {
-	if missileRecord == nil {
-		return nil, nil
-	}
+	castX := playerCast.TargetX * numSubtilesPerTile
+	castY := playerCast.TargetY * numSubtilesPerTile
 
-	radians := d2math.GetRadiansBetween(
-		player.Position.X(),
-		player.Position.Y(),
-		castX,
-		castY,
-	)
+	direction := player.Position.DirectionTo(*d2vector.NewVector(castX, castY))
+	player.SetDirection(direction)
 
-	missileEntity, err := g.MapEngine.NewMissile(
-		int(player.Position.X()),
-		int(player.Position.Y()),
-		g.asset.Records.Missiles[missileRecord.Id],
-	)
+	skillRecord := g.asset.Records.Skill.Details[playerCast.SkillID]
 
-	if err != nil {
-		return nil, err
-	}
+	// the missiles and the summoned NPC of the skill are spawned by the server
+	player.StartCasting(skillRecord.Anim, nil)
 
-	missileEntity.SetRadians(radians, func() {
-		g.MapEngine.RemoveEntity(missileEntity)
-	})
+	overlayRecord := g.asset.Records.Layout.Overlays[skillRecord.Castoverlay]
 
-	return missileEntity, nil
+	return g.playCastOverlay(overlayRecord, int(player.Position.X()), int(player.Position.Y()))
 }
 
 func (g *GameClient) playCastOverlay(overlayRecord *d2records.OverlayRecord, x, y int) error {
@@ -448,18 +559,41 @@ func (g *GameClient) handlePingPacket() error {
 }
 
 func (g *GameClient) handlePlayerDisconnectionPacket(packet d2netpacket.NetPacket) error {
-	disconnectPacket, err := d2netpacket.UnmarshalPlayerDisconnectionRequest(packet.PacketData)
+	disconnectPacket, err := d2netpacket.UnmarshalPlayerDisconnectionRequest(packet)
 	if err != nil {
 		return err
 	}
 
-	player := g.Players[disconnectPacket.ID]
-	g.MapEngine.RemoveEntity(player)
-	delete(g.Players, disconnectPacket.ID)
+	g.removePlayer(disconnectPacket.ID)
 
 	return nil
 }
 
+// handlePlayerWarpPacket removes a player which left the map through a warp.
+func (g *GameClient) handlePlayerWarpPacket(packet d2netpacket.NetPacket) error {
+	playerWarp, err := d2netpacket.UnmarshalPlayerWarp(packet)
+	if err != nil {
+		return err
+	}
+
+	g.Debugf("Player %s went to level %d of act %d", playerWarp.PlayerID, playerWarp.LevelID, playerWarp.Act)
+	g.removePlayer(playerWarp.PlayerID)
+
+	return nil
+}
+
+// removePlayer removes a player from the map, if it is on the map of the client.
+func (g *GameClient) removePlayer(playerID string) {
+	player, found := g.Players[playerID]
+	if !found {
+		return
+	}
+
+	g.MapEngine.RemoveEntity(player)
+	delete(g.Players, playerID)
+	g.snapshots.remove(playerID)
+}
+
 // IsSinglePlayer returns a bool for whether the game is a single-player game
 func (g *GameClient) IsSinglePlayer() bool {
 	return g.connectionType == d2clientconnectiontype.Local
@@ -0,0 +1,95 @@
+package d2client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+)
+
+// interpolationDelay is how far behind the server the other players are shown. Their
+// moves arrive unevenly, so they are buffered and played back this long after they
+// arrived, which shows them walking as evenly as the server sent them.
+const interpolationDelay = 100 * time.Millisecond
+
+// moveSnapshot is where the server had a player, and where it was walking to.
+type moveSnapshot struct {
+	received time.Time
+	start    d2vector.Position
+	dest     d2vector.Position
+}
+
+// snapshotBuffer holds the snapshots of the other players until they are due.
+// Snapshots are pushed by the connection and played back by the game loop.
+type snapshotBuffer struct {
+	mutex     sync.Mutex
+	snapshots map[string][]moveSnapshot // by player ID, oldest first
+}
+
+func newSnapshotBuffer() *snapshotBuffer {
+	return &snapshotBuffer{snapshots: make(map[string][]moveSnapshot)}
+}
+
+// push buffers a snapshot of a player.
+func (b *snapshotBuffer) push(playerID string, snapshot moveSnapshot) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.snapshots[playerID] = append(b.snapshots[playerID], snapshot)
+}
+
+// due removes the snapshots which arrived interpolationDelay before now, and returns
+// the latest of them by player. The older ones are already out of date.
+func (b *snapshotBuffer) due(now time.Time) map[string]moveSnapshot {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	due := make(map[string]moveSnapshot)
+
+	for playerID, snapshots := range b.snapshots {
+		n := 0
+		for n < len(snapshots) && !snapshots[n].received.Add(interpolationDelay).After(now) {
+			n++
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		due[playerID] = snapshots[n-1]
+
+		if n == len(snapshots) {
+			delete(b.snapshots, playerID)
+		} else {
+			b.snapshots[playerID] = snapshots[n:]
+		}
+	}
+
+	return due
+}
+
+// remove drops the snapshots of a player, who left or warped.
+func (b *snapshotBuffer) remove(playerID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.snapshots, playerID)
+}
+
+// Advance plays back the snapshots of the other players which are due, see
+// interpolationDelay. It is called once per frame by the game loop.
+func (g *GameClient) Advance(now time.Time) {
+	for playerID, snapshot := range g.snapshots.due(now) {
+		player, found := g.Players[playerID]
+		if !found {
+			continue
+		}
+
+		// walk on from where the player is shown, unless it drifted too far from the server
+		if player.Position.Distance(&snapshot.start.Vector) > snapDistance {
+			player.SetPosition(snapshot.start)
+		}
+
+		g.setPlayerPath(player, player.Position, snapshot.dest)
+	}
+}
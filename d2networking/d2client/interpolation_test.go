@@ -0,0 +1,73 @@
+package d2client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+)
+
+func snapshotTo(received time.Time, x float64) moveSnapshot {
+	return moveSnapshot{
+		received: received,
+		start:    d2vector.NewPositionTile(0, 0),
+		dest:     d2vector.NewPositionTile(x, 0),
+	}
+}
+
+func TestSnapshotBufferWaitsForTheDelay(t *testing.T) {
+	b := newSnapshotBuffer()
+	now := time.Now()
+
+	b.push("player", snapshotTo(now, 1))
+
+	if due := b.due(now.Add(interpolationDelay / 2)); len(due) != 0 {
+		t.Fatalf("expected no snapshot before the delay, got %v", due)
+	}
+
+	due := b.due(now.Add(interpolationDelay))
+	if snapshot, found := due["player"]; !found || snapshot.dest.X() != 1*numSubtilesPerTile {
+		t.Fatalf("expected the snapshot once the delay passed, got %v", due)
+	}
+
+	if due := b.due(now.Add(2 * interpolationDelay)); len(due) != 0 {
+		t.Fatalf("expected the snapshot to be played back once, got %v", due)
+	}
+}
+
+func TestSnapshotBufferPlaysBackTheLatestDue(t *testing.T) {
+	b := newSnapshotBuffer()
+	now := time.Now()
+
+	b.push("player", snapshotTo(now, 1))
+	b.push("player", snapshotTo(now.Add(10*time.Millisecond), 2))
+	b.push("player", snapshotTo(now.Add(interpolationDelay), 3))
+	b.push("other", snapshotTo(now, 4))
+
+	due := b.due(now.Add(interpolationDelay + 10*time.Millisecond))
+
+	if len(due) != 2 {
+		t.Fatalf("expected a snapshot of both players, got %v", due)
+	}
+
+	if snapshot := due["player"]; snapshot.dest.X() != 2*numSubtilesPerTile {
+		t.Errorf("expected the latest due snapshot, got one to %v", snapshot.dest.X())
+	}
+
+	due = b.due(now.Add(2 * interpolationDelay))
+	if snapshot := due["player"]; len(due) != 1 || snapshot.dest.X() != 3*numSubtilesPerTile {
+		t.Errorf("expected the last snapshot to follow, got %v", due)
+	}
+}
+
+func TestSnapshotBufferRemove(t *testing.T) {
+	b := newSnapshotBuffer()
+	now := time.Now()
+
+	b.push("player", snapshotTo(now, 1))
+	b.remove("player")
+
+	if due := b.due(now.Add(interpolationDelay)); len(due) != 0 {
+		t.Fatalf("expected the snapshots of a removed player to be dropped, got %v", due)
+	}
+}
@@ -0,0 +1,144 @@
+package d2client
+
+import (
+	"fmt"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2interface"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
+)
+
+// entitySnapDistance is how far, in sub tiles, an NPC or a missile may be from where the
+// server has it before it is placed there instead of moving on.
+const entitySnapDistance = numSubtilesPerTile
+
+// resetReplication removes the entities spawned by the server. The server spawns them
+// again, after the map was generated or the session resumed.
+func (g *GameClient) resetReplication() {
+	for _, entity := range g.netEntities {
+		g.MapEngine.RemoveEntity(entity)
+	}
+
+	g.netEntities = make(map[uint32]d2interface.MapEntity)
+	g.replication = d2replication.NewReceiver()
+}
+
+// addNetEntity adds an entity the server spawned, replacing the entity it had with the
+// same network ID, if any.
+func (g *GameClient) addNetEntity(netID uint32, entity d2interface.MapEntity) {
+	g.MapEngine.RemoveEntity(g.netEntities[netID])
+
+	g.netEntities[netID] = entity
+	g.replication.Spawn(netID)
+	g.MapEngine.AddEntity(entity)
+}
+
+func (g *GameClient) handleSpawnItemPacket(packet d2netpacket.NetPacket) error {
+	item, err := d2netpacket.UnmarshalSpawnItem(packet)
+	if err != nil {
+		return err
+	}
+
+	itemEntity, err := g.MapEngine.NewItem(item.X, item.Y, item.Codes...)
+	if err != nil {
+		return err
+	}
+
+	g.addNetEntity(item.NetID, itemEntity)
+
+	return nil
+}
+
+func (g *GameClient) handleSpawnMissilePacket(packet d2netpacket.NetPacket) error {
+	spawn, err := d2netpacket.UnmarshalSpawnMissile(packet)
+	if err != nil {
+		return err
+	}
+
+	missileEntity, err := g.MapEngine.NewMissile(spawn.X, spawn.Y, g.asset.Records.Missiles[spawn.MissileID])
+	if err != nil {
+		return err
+	}
+
+	// the server despawns the missile once it reached its range
+	missileEntity.SetRadians(spawn.Radians, nil)
+
+	g.addNetEntity(spawn.NetID, missileEntity)
+
+	return nil
+}
+
+func (g *GameClient) handleSpawnNPCPacket(packet d2netpacket.NetPacket) error {
+	spawn, err := d2netpacket.UnmarshalSpawnNPC(packet)
+	if err != nil {
+		return err
+	}
+
+	monsterStatsRecord := g.asset.Records.Monster.Stats[spawn.MonStat]
+	if monsterStatsRecord == nil {
+		return fmt.Errorf("cannot spawn NPC - No monstat entry for \"%s\"", spawn.MonStat)
+	}
+
+	// https://github.com/OpenDiablo2/OpenDiablo2/issues/803
+	npcEntity, err := g.MapEngine.NewNPC(spawn.X, spawn.Y, monsterStatsRecord, 0)
+	if err != nil {
+		return err
+	}
+
+	g.addNetEntity(spawn.NetID, npcEntity)
+
+	return nil
+}
+
+// handleEntityStatesPacket moves the NPCs and missiles near the player the way the server
+// simulates them, and acknowledges the snapshot. Entities keep moving towards their target
+// between updates, and are only placed where the server has them when they drifted too far.
+func (g *GameClient) handleEntityStatesPacket(packet d2netpacket.NetPacket) error {
+	entityStates, err := d2netpacket.UnmarshalEntityStates(packet)
+	if err != nil {
+		return err
+	}
+
+	changed, err := g.replication.Apply(entityStates.Sequence, entityStates.Baseline,
+		entityStates.States, entityStates.Despawned)
+	if err != nil {
+		return err
+	}
+
+	for _, netID := range entityStates.Despawned {
+		g.MapEngine.RemoveEntity(g.netEntities[netID])
+		delete(g.netEntities, netID)
+	}
+
+	for netID, state := range changed {
+		switch entity := g.netEntities[netID].(type) {
+		case *d2mapentity.NPC:
+			applyEntityState(&entity.Position, &entity.Target, state, entity.SetPosition, entity.SetPath)
+		case *d2mapentity.Missile:
+			applyEntityState(&entity.Position, &entity.Target, state, entity.SetPosition, entity.SetPath)
+		}
+	}
+
+	ack, err := d2netpacket.CreateEntityStatesAckPacket(entityStates.Sequence)
+	if err != nil {
+		return err
+	}
+
+	return g.clientConnection.SendPacketToServer(ack)
+}
+
+func applyEntityState(position, target *d2vector.Position, state d2replication.State,
+	setPosition func(d2vector.Position), setPath func([]d2vector.Position, func())) {
+	serverPosition := d2vector.NewPosition(state.X, state.Y)
+	serverTarget := d2vector.NewPosition(state.TargetX, state.TargetY)
+
+	if position.Distance(&serverPosition.Vector) > entitySnapDistance {
+		setPosition(serverPosition)
+	}
+
+	if !target.EqualsApprox(&serverTarget.Vector) {
+		setPath([]d2vector.Position{serverTarget}, nil)
+	}
+}
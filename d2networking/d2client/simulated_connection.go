@@ -0,0 +1,79 @@
+package d2client
+
+import (
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
+)
+
+// SimulatedServerConnection is a ServerConnection which sends and receives
+// packets under simulated network conditions.
+type SimulatedServerConnection struct {
+	ServerConnection
+	outgoing *d2netsim.Link
+	incoming *d2netsim.Link
+	logger   *d2util.Logger
+}
+
+// NewSimulatedServerConnection wraps the connection so packets in both
+// directions are delayed, dropped, duplicated and reordered according to the
+// conditions.
+func NewSimulatedServerConnection(conn ServerConnection, conditions d2netsim.Conditions,
+	logger *d2util.Logger) *SimulatedServerConnection {
+	seed := time.Now().UnixNano()
+
+	return &SimulatedServerConnection{
+		ServerConnection: conn,
+		outgoing:         d2netsim.NewLink(conditions, seed),
+		incoming:         d2netsim.NewLink(conditions, seed+1),
+		logger:           logger,
+	}
+}
+
+// SendPacketToServer schedules the packet to be sent to the server.
+func (s *SimulatedServerConnection) SendPacketToServer(packet d2netpacket.NetPacket) error {
+	s.outgoing.Send(func() {
+		if err := s.ServerConnection.SendPacketToServer(packet); err != nil {
+			s.logger.Errorf("simulated network: sending %s: %s", packet.PacketType, err)
+		}
+	})
+
+	return nil
+}
+
+// SetClientListener sets the listener, which receives packets from the server
+// under the simulated conditions.
+func (s *SimulatedServerConnection) SetClientListener(listener d2networking.ClientListener) {
+	s.ServerConnection.SetClientListener(&simulatedListener{
+		ClientListener: listener,
+		link:           s.incoming,
+		logger:         s.logger,
+	})
+}
+
+// Close stops the simulation and closes the connection.
+func (s *SimulatedServerConnection) Close() error {
+	s.outgoing.Close()
+	s.incoming.Close()
+
+	return s.ServerConnection.Close()
+}
+
+type simulatedListener struct {
+	d2networking.ClientListener
+	link   *d2netsim.Link
+	logger *d2util.Logger
+}
+
+func (l *simulatedListener) OnPacketReceived(packet d2netpacket.NetPacket) error {
+	l.link.Send(func() {
+		if err := l.ClientListener.OnPacketReceived(packet); err != nil {
+			l.logger.Errorf("simulated network: receiving %s: %s", packet.PacketType, err)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package d2demo records the packets a game client receives into a demo file,
+// and plays demo files back.
+//
+// A demo file starts with a header, followed by one record per packet. A record
+// holds the time since the recording started, the packet type and the packet data,
+// the first two as unsigned varints and the data prefixed by its length.
+package d2demo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	headerMagic = "OD2DEMO"
+	version     = 1
+
+	// maxRecordSize protects against corrupt files claiming huge records.
+	maxRecordSize = 1 << 24
+)
+
+var (
+	errNotADemo       = errors.New("not a demo file")
+	errRecordTooLarge = errors.New("demo record too large")
+)
+
+// Record is one packet received by the client.
+type Record struct {
+	Time       time.Duration // Time since the recording started
+	PacketType uint32
+	Data       []byte
+}
+
+// Recorder writes the packets received by a client to a demo.
+type Recorder struct {
+	w      *bufio.Writer
+	closer io.Closer
+	start  time.Time
+	mutex  sync.Mutex
+}
+
+// Create creates the demo file at path and records into it.
+func Create(path string) (*Recorder, error) {
+	f, err := os.Create(path) //nolint:gosec // the player chooses the demo file
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewRecorder(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	r.closer = f
+
+	return r, nil
+}
+
+// NewRecorder records into w. Times are measured from now.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	r := &Recorder{w: bufio.NewWriter(w), start: time.Now()}
+
+	if _, err := r.w.WriteString(headerMagic); err != nil {
+		return nil, err
+	}
+
+	if err := r.w.WriteByte(version); err != nil {
+		return nil, err
+	}
+
+	return r, r.w.Flush()
+}
+
+// Record writes a packet to the demo.
+func (r *Recorder) Record(packetType uint32, data []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.write(Record{Time: time.Since(r.start), PacketType: packetType, Data: data})
+}
+
+func (r *Recorder) write(record Record) error {
+	var header [3 * binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(header[:], uint64(record.Time))
+	n += binary.PutUvarint(header[n:], uint64(record.PacketType))
+	n += binary.PutUvarint(header[n:], uint64(len(record.Data)))
+
+	if _, err := r.w.Write(header[:n]); err != nil {
+		return err
+	}
+
+	if _, err := r.w.Write(record.Data); err != nil {
+		return err
+	}
+
+	// a demo of a session which crashed is still useful
+	return r.w.Flush()
+}
+
+// Close finishes the demo.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+
+	return nil
+}
+
+// Open reads the demo file at path.
+func Open(path string) ([]Record, error) {
+	f, err := os.Open(path) //nolint:gosec // the player chooses the demo file
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck // read only
+
+	return Load(f)
+}
+
+// Load reads a demo. A demo cut short, as it is when the game crashed while recording,
+// gives the records before the cut.
+func Load(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(headerMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || !bytes.Equal(header[:len(headerMagic)], []byte(headerMagic)) {
+		return nil, errNotADemo
+	}
+
+	if header[len(headerMagic)] != version {
+		return nil, fmt.Errorf("unsupported demo version %d", header[len(headerMagic)])
+	}
+
+	records := make([]Record, 0)
+
+	for {
+		record, err := readRecord(br)
+
+		switch {
+		case err == io.EOF, errors.Is(err, io.ErrUnexpectedEOF):
+			return records, nil
+		case err != nil:
+			return records, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+func readRecord(r *bufio.Reader) (Record, error) {
+	t, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Record{}, err
+	}
+
+	packetType, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+
+	if size > maxRecordSize {
+		return Record{}, errRecordTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+
+	return Record{Time: time.Duration(t), PacketType: uint32(packetType), Data: data}, nil
+}
+
+// unexpectedEOF reports the end of the file in the middle of a record as a cut short demo.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package d2demo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	var buf bytes.Buffer
+
+	recorder, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.Record(2, []byte(`{"id":"a"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.Record(3, []byte(`{"x":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a demo cut short still gives the records before the cut
+	records, err := Load(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].PacketType != 2 || string(records[0].Data) != `{"id":"a"}` {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	records, err = Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[1].PacketType != 3 || records[1].Time < records[0].Time {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if _, err := Load(bytes.NewReader([]byte("not a demo"))); err == nil {
+		t.Error("expected an error loading something else than a demo")
+	}
+}
+
+func TestPlayback(t *testing.T) {
+	records := []Record{
+		{Time: 0, PacketType: 0},
+		{Time: time.Second, PacketType: 1},
+		{Time: 2 * time.Second, PacketType: 2},
+		{Time: 3 * time.Second, PacketType: 3},
+	}
+
+	p := NewPlayback(records)
+
+	if due := p.Advance(500 * time.Millisecond); len(due) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(due))
+	}
+
+	p.SetPaused(true)
+
+	if due := p.Advance(time.Hour); len(due) != 0 {
+		t.Fatalf("expected no records while paused, got %d", len(due))
+	}
+
+	p.SetPaused(false)
+	p.SetSpeed(4)
+
+	if due := p.Advance(500 * time.Millisecond); len(due) != 2 || due[1].PacketType != 2 {
+		t.Fatalf("expected records 1 and 2 at 4x speed, got %+v", due)
+	}
+
+	due, rewound := p.Seek(time.Second)
+	if !rewound || len(due) != 2 || due[0].PacketType != 0 {
+		t.Fatalf("expected to start over, got %+v rewound %v", due, rewound)
+	}
+
+	due, rewound = p.Seek(10 * time.Second)
+	if rewound || len(due) != 2 || !p.Finished() {
+		t.Fatalf("expected the remaining records, got %+v rewound %v", due, rewound)
+	}
+}
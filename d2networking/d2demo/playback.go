@@ -0,0 +1,99 @@
+package d2demo
+
+import (
+	"sort"
+	"time"
+)
+
+// Playback is the clock of a demo being played back. It tells which records are due
+// as time passes, and supports pausing, changing the speed and seeking.
+type Playback struct {
+	records  []Record
+	next     int // index of the next record to play
+	position time.Duration
+	speed    float64
+	paused   bool
+}
+
+// NewPlayback plays the records from the start, at normal speed.
+func NewPlayback(records []Record) *Playback {
+	return &Playback{records: records, speed: 1}
+}
+
+// Advance moves the playback forward by the elapsed real time, scaled by the speed,
+// and returns the records which became due.
+func (p *Playback) Advance(elapsed time.Duration) []Record {
+	if p.paused {
+		return nil
+	}
+
+	return p.playTo(p.position + time.Duration(float64(elapsed)*p.speed))
+}
+
+// Seek moves the playback to the given time. Moving forward returns the records in
+// between. Moving backward can not undo the records already played, so the playback
+// starts over: rewound is true, and the records from the start are returned.
+func (p *Playback) Seek(to time.Duration) (records []Record, rewound bool) {
+	if to < 0 {
+		to = 0
+	}
+
+	if to < p.position {
+		p.next = 0
+		p.position = 0
+		rewound = true
+	}
+
+	return p.playTo(to), rewound
+}
+
+func (p *Playback) playTo(to time.Duration) []Record {
+	end := p.next + sort.Search(len(p.records)-p.next, func(i int) bool {
+		return p.records[p.next+i].Time > to
+	})
+
+	due := p.records[p.next:end]
+	p.next = end
+	p.position = to
+
+	return due
+}
+
+// SetPaused pauses or resumes the playback.
+func (p *Playback) SetPaused(paused bool) {
+	p.paused = paused
+}
+
+// Paused returns true if the playback is paused.
+func (p *Playback) Paused() bool {
+	return p.paused
+}
+
+// SetSpeed sets how much faster than real time the demo plays.
+func (p *Playback) SetSpeed(speed float64) {
+	p.speed = speed
+}
+
+// Speed returns how much faster than real time the demo plays.
+func (p *Playback) Speed() float64 {
+	return p.speed
+}
+
+// Position returns the time the playback has reached.
+func (p *Playback) Position() time.Duration {
+	return p.position
+}
+
+// Duration returns the time of the last record.
+func (p *Playback) Duration() time.Duration {
+	if len(p.records) == 0 {
+		return 0
+	}
+
+	return p.records[len(p.records)-1].Time
+}
+
+// Finished returns true once every record has been played.
+func (p *Playback) Finished() bool {
+	return p.next == len(p.records)
+}
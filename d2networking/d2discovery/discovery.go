@@ -0,0 +1,183 @@
+// Package d2discovery finds game servers on the local network. Clients
+// broadcast a beacon over UDP, and servers answer it with a description of
+// the game they host.
+package d2discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+)
+
+const (
+	// Port is the UDP port servers listen on for discovery beacons.
+	Port = 6670
+
+	maxDatagramSize = 1024
+)
+
+// nolint:gochecknoglobals // constant byte slices
+var (
+	beaconMagic   = []byte("OD2?")
+	responseMagic = []byte("OD2!")
+)
+
+var (
+	errNotAResponse  = errors.New("datagram is not a discovery response")
+	errBeaconNotSent = errors.New("no discovery beacon could be sent")
+)
+
+// ServerInfo describes a game server found on the network.
+type ServerInfo struct {
+	Name            string                `json:"name"`
+	Address         string                `json:"-"` // host:port to join, filled in by Discover
+	Port            int                   `json:"port"`
+	Players         int                   `json:"players"`
+	MaxPlayers      int                   `json:"maxPlayers"` // Of a game
+	Difficulty      d2enum.DifficultyType `json:"difficulty"` // Of the game joined without a game ID
+	ProtocolVersion int                   `json:"protocolVersion"`
+	TLS             bool                  `json:"tls,omitempty"` // Clients connect over TLS
+}
+
+// Responder answers discovery beacons on behalf of a server.
+type Responder struct {
+	conn     net.PacketConn
+	info     func() ServerInfo
+	closeOne sync.Once
+}
+
+// NewResponder listens for beacons on the given UDP address, usually ":" followed by
+// Port. info is called for every beacon, so the response is always up to date.
+func NewResponder(address string, info func() ServerInfo) (*Responder, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Responder{conn: conn, info: info}, nil
+}
+
+// Addr returns the address the responder listens on.
+func (r *Responder) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Serve answers beacons until the responder is closed.
+func (r *Responder) Serve() error {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(buf[:n], beaconMagic) {
+			continue
+		}
+
+		data, err := json.Marshal(r.info())
+		if err != nil {
+			return err
+		}
+
+		// a beacon which can not be answered is treated like a lost one
+		_, _ = r.conn.WriteTo(append(append([]byte(nil), responseMagic...), data...), addr)
+	}
+}
+
+// Close stops the responder.
+func (r *Responder) Close() error {
+	var err error
+
+	r.closeOne.Do(func() {
+		err = r.conn.Close()
+	})
+
+	return err
+}
+
+// Discover broadcasts a beacon and returns the servers which answer within the
+// timeout. The beacon is also sent to each of the extra addresses, which lets it
+// reach servers broadcasts do not, such as one on the loopback interface.
+func Discover(timeout time.Duration, extra ...string) ([]ServerInfo, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	targets := append([]string{net.JoinHostPort("255.255.255.255", strconv.Itoa(Port))}, extra...)
+	sent := 0
+
+	for _, target := range targets {
+		addr, err := net.ResolveUDPAddr("udp4", target)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := conn.WriteTo(beaconMagic, addr); err == nil {
+			sent++
+		}
+	}
+
+	if sent == 0 {
+		return nil, errBeaconNotSent
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	servers := make([]ServerInfo, 0)
+	seen := make(map[string]bool)
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return servers, nil
+			}
+
+			return servers, err
+		}
+
+		info, err := parseResponse(buf[:n], addr)
+		if err != nil || seen[info.Address] {
+			continue
+		}
+
+		seen[info.Address] = true
+		servers = append(servers, info)
+	}
+}
+
+func parseResponse(datagram []byte, from net.Addr) (ServerInfo, error) {
+	var info ServerInfo
+
+	if !bytes.HasPrefix(datagram, responseMagic) {
+		return info, errNotAResponse
+	}
+
+	if err := json.Unmarshal(datagram[len(responseMagic):], &info); err != nil {
+		return info, err
+	}
+
+	host, _, err := net.SplitHostPort(from.String())
+	if err != nil {
+		return info, err
+	}
+
+	info.Address = net.JoinHostPort(host, strconv.Itoa(info.Port))
+
+	return info, nil
+}
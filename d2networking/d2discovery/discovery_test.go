@@ -0,0 +1,42 @@
+package d2discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+)
+
+func TestDiscoverLoopback(t *testing.T) {
+	want := ServerInfo{
+		Name:            "test server",
+		Port:            6669,
+		Players:         2,
+		MaxPlayers:      8,
+		Difficulty:      d2enum.DifficultyNightmare,
+		ProtocolVersion: 1,
+	}
+
+	responder, err := NewResponder("127.0.0.1:0", func() ServerInfo { return want })
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+
+	defer responder.Close()
+
+	go func() { _ = responder.Serve() }()
+
+	servers, err := Discover(200*time.Millisecond, responder.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(servers) != 1 {
+		t.Fatalf("expected one server, found %d", len(servers))
+	}
+
+	want.Address = "127.0.0.1:6669"
+	if servers[0] != want {
+		t.Errorf("expected %+v, got %+v", want, servers[0])
+	}
+}
@@ -0,0 +1,86 @@
+package d2metrics
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	metricsPath     = "/metrics"
+	healthPath      = "/health"
+	textContentType = "text/plain; version=0.0.4; charset=utf-8"
+	readTimeout     = 10 * time.Second
+)
+
+// Health is the body of the health endpoint.
+type Health struct {
+	Status string            `json:"status"` // ok, or unhealthy if a check failed
+	Uptime float64           `json:"uptimeSeconds"`
+	Checks map[string]string `json:"checks"` // ok, or the error of the check, by check name
+}
+
+// Health runs the liveness checks.
+func (m *Metrics) Health() Health {
+	m.Lock()
+	checks := append([]check(nil), m.checks...)
+	uptime := time.Since(m.started).Seconds()
+	m.Unlock()
+
+	health := Health{Status: "ok", Uptime: uptime, Checks: make(map[string]string, len(checks))}
+
+	for _, c := range checks {
+		if err := c.test(); err != nil {
+			health.Status = "unhealthy"
+			health.Checks[c.name] = err.Error()
+
+			continue
+		}
+
+		health.Checks[c.name] = "ok"
+	}
+
+	return health
+}
+
+// Handler returns the HTTP handler of the metrics and health endpoints.
+func (m *Metrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", textContentType)
+		_, _ = m.WriteTo(w)
+	})
+
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, _ *http.Request) {
+		health := m.Health()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if health.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_ = json.NewEncoder(w).Encode(health)
+	})
+
+	return mux
+}
+
+// Serve serves the metrics on /metrics and the health on /health at the TCP address,
+// until the returned server is closed.
+func (m *Metrics) Serve(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Handler: m.Handler(), ReadHeaderTimeout: readTimeout}
+
+	go func() {
+		_ = server.Serve(listener) // returns once the server is closed
+	}()
+
+	return server, nil
+}
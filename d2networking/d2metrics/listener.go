@@ -0,0 +1,88 @@
+package d2metrics
+
+import (
+	"io"
+	"net"
+)
+
+// Listener returns a listener whose connections count the bytes they read and write.
+// It wraps the listener a TLS listener wraps, so the bytes on the wire are counted.
+// Connections which also send unreliably, like those of d2udpchannel, keep doing so
+// and count those bytes too.
+func (m *Metrics) Listener(l net.Listener) net.Listener {
+	if m == nil {
+		return l
+	}
+
+	return &countingListener{Listener: l, metrics: m}
+}
+
+// unreliableConn is a connection which can also send packets which may be lost, see
+// d2tcpclientconnection.UnreliableConn.
+type unreliableConn interface {
+	net.Conn
+	Unreliable() io.Writer
+}
+
+type countingListener struct {
+	net.Listener
+	metrics *Metrics
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	counting := &countingConn{Conn: conn, metrics: l.metrics}
+
+	if unreliable, ok := conn.(unreliableConn); ok {
+		return &countingUnreliableConn{
+			countingConn: counting,
+			unreliable:   &countingWriter{Writer: unreliable.Unreliable(), metrics: l.metrics},
+		}, nil
+	}
+
+	return counting, nil
+}
+
+type countingConn struct {
+	net.Conn
+	metrics *Metrics
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.metrics.countBytes(n, 0)
+
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.metrics.countBytes(0, n)
+
+	return n, err
+}
+
+type countingUnreliableConn struct {
+	*countingConn
+	unreliable io.Writer
+}
+
+func (c *countingUnreliableConn) Unreliable() io.Writer {
+	return c.unreliable
+}
+
+type countingWriter struct {
+	io.Writer
+	metrics *Metrics
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.metrics.countBytes(0, n)
+
+	return n, err
+}
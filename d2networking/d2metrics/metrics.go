@@ -0,0 +1,288 @@
+// Package d2metrics collects the metrics of a dedicated server, and serves them over
+// HTTP in the Prometheus text format, along with a JSON health endpoint for liveness
+// checks.
+package d2metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+const namespace = "od2"
+
+// tickBuckets are the upper bounds of the buckets of the tick duration histogram, in
+// seconds. The server simulates a tick every 40ms.
+var tickBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.02, 0.04, 0.08, 0.16}
+
+// Metrics counts the packets and bytes a server sends and receives, and the time it
+// takes to simulate its ticks. Other values are read from gauges when the metrics are
+// written. A nil *Metrics ignores everything it is told.
+type Metrics struct {
+	sync.Mutex
+	started     time.Time
+	received    map[d2netpackettype.NetPacketType]uint64
+	sent        map[d2netpackettype.NetPacketType]uint64
+	bytesIn     uint64
+	bytesOut    uint64
+	tickCounts  []uint64 // By bucket of tickBuckets, and one more for larger durations
+	tickSum     float64  // In seconds
+	lastTick    time.Time
+	gauges      []gauge
+	labelGauges []labelGauge
+	checks      []check
+}
+
+type gauge struct {
+	name, help string
+	value      func() float64
+}
+
+// labelGauge is a gauge with a value by label value.
+type labelGauge struct {
+	name, help, label string
+	values            func() map[string]float64
+}
+
+type check struct {
+	name string
+	test func() error
+}
+
+// New creates the metrics of a server.
+func New() *Metrics {
+	return &Metrics{
+		started:    time.Now(),
+		received:   make(map[d2netpackettype.NetPacketType]uint64),
+		sent:       make(map[d2netpackettype.NetPacketType]uint64),
+		tickCounts: make([]uint64, len(tickBuckets)+1),
+	}
+}
+
+// CountReceived counts a packet received from a client.
+func (m *Metrics) CountReceived(packetType d2netpackettype.NetPacketType) {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.received[packetType]++
+}
+
+// CountSent counts a packet sent to a client.
+func (m *Metrics) CountSent(packetType d2netpackettype.NetPacketType) {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.sent[packetType]++
+}
+
+func (m *Metrics) countBytes(in, out int) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.bytesIn += uint64(in)
+	m.bytesOut += uint64(out)
+}
+
+// ObserveTick records the time it took to simulate a tick.
+func (m *Metrics) ObserveTick(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	seconds := d.Seconds()
+	bucket := sort.SearchFloat64s(tickBuckets, seconds)
+
+	m.tickCounts[bucket]++
+	m.tickSum += seconds
+	m.lastTick = time.Now()
+}
+
+// LastTick returns when the last tick was simulated, or the zero time if none was.
+func (m *Metrics) LastTick() time.Time {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.lastTick
+}
+
+// Gauge adds a gauge whose value is read each time the metrics are written. The name
+// is prefixed with the namespace of the metrics.
+func (m *Metrics) Gauge(name, help string, value func() float64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.gauges = append(m.gauges, gauge{name: name, help: help, value: value})
+}
+
+// LabelGauge adds a gauge which has a value for each value of the label.
+func (m *Metrics) LabelGauge(name, help, label string, values func() map[string]float64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.labelGauges = append(m.labelGauges, labelGauge{name: name, help: help, label: label, values: values})
+}
+
+// Check adds a liveness check to the health endpoint. The server is unhealthy while
+// the check returns an error.
+func (m *Metrics) Check(name string, test func() error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.checks = append(m.checks, check{name: name, test: test})
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	m.Lock()
+	gauges := append([]gauge(nil), m.gauges...)
+	labelGauges := append([]labelGauge(nil), m.labelGauges...)
+
+	writePacketCounts(&b, "packets_received_total", "Packets received from the clients.", m.received)
+	writePacketCounts(&b, "packets_sent_total", "Packets sent to the clients.", m.sent)
+	writeMetric(&b, "bytes_received_total", "Bytes received from the clients.", "counter", float64(m.bytesIn))
+	writeMetric(&b, "bytes_sent_total", "Bytes sent to the clients.", "counter", float64(m.bytesOut))
+	m.writeTickHistogram(&b)
+	writeMetric(&b, "uptime_seconds", "Time since the server started.", "gauge", time.Since(m.started).Seconds())
+	m.Unlock()
+
+	// gauges may take locks of the server, which may be counting packets
+	for _, g := range gauges {
+		writeMetric(&b, g.name, g.help, "gauge", g.value())
+	}
+
+	for _, g := range labelGauges {
+		writeLabelGauge(&b, g)
+	}
+
+	writeRuntime(&b)
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+// writeTickHistogram writes the tick durations. The caller must hold the lock.
+func (m *Metrics) writeTickHistogram(b *strings.Builder) {
+	name := namespace + "_tick_duration_seconds"
+
+	fmt.Fprintf(b, "# HELP %s Time it takes to simulate a tick of a game.\n# TYPE %s histogram\n", name, name)
+
+	var count uint64
+
+	for i, bound := range tickBuckets {
+		count += m.tickCounts[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), count)
+	}
+
+	count += m.tickCounts[len(tickBuckets)]
+
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, formatFloat(m.tickSum))
+	fmt.Fprintf(b, "%s_count %d\n", name, count)
+}
+
+func writePacketCounts(b *strings.Builder, name, help string, counts map[d2netpackettype.NetPacketType]uint64) {
+	name = namespace + "_" + name
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	types := make([]d2netpackettype.NetPacketType, 0, len(counts))
+
+	for packetType := range counts {
+		types = append(types, packetType)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	for _, packetType := range types {
+		fmt.Fprintf(b, "%s{type=\"%s\"} %d\n", name, escapeLabel(packetType.String()), counts[packetType])
+	}
+}
+
+func writeLabelGauge(b *strings.Builder, g labelGauge) {
+	name := namespace + "_" + g.name
+	values := g.values()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, g.help, name)
+
+	labels := make([]string, 0, len(values))
+
+	for label := range values {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %s\n", name, g.label, escapeLabel(label), formatFloat(values[label]))
+	}
+}
+
+// writeRuntime writes the goroutines, memory and garbage collector statistics, prefixed
+// with go like the metrics of the official Go client.
+func writeRuntime(b *strings.Builder) {
+	var stats runtime.MemStats
+
+	runtime.ReadMemStats(&stats)
+
+	writeGoMetric(b, "go_goroutines", "Number of goroutines that currently exist.", "gauge",
+		float64(runtime.NumGoroutine()))
+	writeGoMetric(b, "go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", "gauge",
+		float64(stats.HeapAlloc))
+	writeGoMetric(b, "go_memstats_sys_bytes", "Number of bytes obtained from system.", "gauge",
+		float64(stats.Sys))
+	writeGoMetric(b, "go_memstats_heap_objects", "Number of allocated objects.", "gauge",
+		float64(stats.HeapObjects))
+	writeGoMetric(b, "go_gc_cycles_total", "Number of completed GC cycles.", "counter",
+		float64(stats.NumGC))
+	writeGoMetric(b, "go_gc_pause_seconds_total", "Total time the GC stopped the world.", "counter",
+		time.Duration(stats.PauseTotalNs).Seconds())
+	writeGoMetric(b, "go_memstats_next_gc_bytes", "Number of heap bytes when next garbage collection will take place.",
+		"gauge", float64(stats.NextGC))
+}
+
+func writeMetric(b *strings.Builder, name, help, metricType string, value float64) {
+	writeGoMetric(b, namespace+"_"+name, help, metricType, value)
+}
+
+func writeGoMetric(b *strings.Builder, name, help, metricType string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, metricType, name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
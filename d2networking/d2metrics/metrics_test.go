@@ -0,0 +1,141 @@
+package d2metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+
+	m.CountReceived(d2netpackettype.MovePlayer)
+	m.CountReceived(d2netpackettype.MovePlayer)
+	m.CountSent(d2netpackettype.EntityStates)
+	m.ObserveTick(3 * time.Millisecond)
+	m.ObserveTick(time.Second)
+	m.Gauge("players", "Players.", func() float64 { return 4 })
+	m.LabelGauge("asset_cache_weight", "Weight.", "cache", func() map[string]float64 {
+		return map[string]float64{"dt1": 12}
+	})
+
+	var b strings.Builder
+
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`od2_packets_received_total{type="MovePlayer"} 2`,
+		`od2_packets_sent_total{type="EntityStates"} 1`,
+		`od2_tick_duration_seconds_bucket{le="0.0025"} 0`,
+		`od2_tick_duration_seconds_bucket{le="0.005"} 1`,
+		`od2_tick_duration_seconds_bucket{le="+Inf"} 2`,
+		`od2_tick_duration_seconds_count 2`,
+		`od2_players 4`,
+		`od2_asset_cache_weight{cache="dt1"} 12`,
+		`# TYPE go_goroutines gauge`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected the line %q in:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	m := New()
+
+	var failure error
+
+	m.Check("simulation", func() error { return failure })
+
+	request := httptest.NewRequest(http.MethodGet, healthPath, nil)
+	response := httptest.NewRecorder()
+
+	m.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	failure = errors.New("stalled")
+	response = httptest.NewRecorder()
+
+	m.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusServiceUnavailable || !strings.Contains(response.Body.String(), "stalled") {
+		t.Errorf("expected an unhealthy status, got %d %s", response.Code, response.Body.String())
+	}
+}
+
+// testUnreliableConn is one end of a pipe which also sends unreliably to a buffer.
+type testUnreliableConn struct {
+	net.Conn
+	unreliable bytes.Buffer
+}
+
+func (c *testUnreliableConn) Unreliable() io.Writer { return &c.unreliable }
+
+// testListener accepts one connection.
+type testListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *testListener) Accept() (net.Conn, error) { return l.conn, nil }
+
+func TestListenerKeepsUnreliable(t *testing.T) {
+	m := New()
+	server, client := net.Pipe()
+
+	defer client.Close()
+
+	conn, err := m.Listener(&testListener{conn: &testUnreliableConn{Conn: server}}).Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreliable, ok := conn.(interface{ Unreliable() io.Writer })
+	if !ok {
+		t.Fatal("expected the connection to keep sending unreliably")
+	}
+
+	go func() { _, _ = client.Write([]byte("ping")) }()
+
+	if _, err := io.ReadFull(conn, make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := unreliable.Unreliable().Write([]byte("state")); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"od2_bytes_received_total 4", "od2_bytes_sent_total 5"} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected the line %q in:\n%s", line, b.String())
+		}
+	}
+
+	plain, err := m.Listener(&testListener{conn: server}).Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := plain.(interface{ Unreliable() io.Writer }); ok {
+		t.Error("expected only connections which send unreliably to do so")
+	}
+}
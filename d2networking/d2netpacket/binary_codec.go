@@ -0,0 +1,277 @@
+package d2netpacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2datautils"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// A binary frame is laid out as follows, all values little endian:
+//
+//	uint32  length of the rest of the frame
+//	byte    packet type
+//	...     packet fields, see binaryLayouts
+//
+// Strings are a uint16 length followed by the bytes, floats are IEEE 754 doubles,
+// times are int64 unix nanoseconds and bools are a single byte.
+const (
+	frameHeaderSize = 4
+	maxPacketType   = math.MaxUint8
+	maxStringLength = math.MaxUint16
+)
+
+var (
+	errUnknownLayout  = errors.New("no binary layout for packet type")
+	errStringTooLong  = errors.New("string is too long for a binary packet")
+	errTrailingFields = errors.New("binary packet has trailing data")
+)
+
+// MarshalBinaryPacket encodes the NetPacket as a binary frame.
+func MarshalBinaryPacket(packet NetPacket) ([]byte, error) {
+	if packet.PacketType > maxPacketType {
+		return nil, fmt.Errorf("%w: %d", errUnknownLayout, packet.PacketType)
+	}
+
+	layout, ok := binaryLayouts[packet.PacketType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownLayout, packet.PacketType)
+	}
+
+	w := &packetWriter{StreamWriter: d2datautils.CreateStreamWriter()}
+	w.PushUint32(0) // length, filled in below
+	w.PushBytes(byte(packet.PacketType))
+
+	if err := layout.encode(packet, w); err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", packet.PacketType, err)
+	}
+
+	if w.err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", packet.PacketType, w.err)
+	}
+
+	frame := w.GetBytes()
+	binary.LittleEndian.PutUint32(frame, uint32(len(frame)-frameHeaderSize))
+
+	return frame, nil
+}
+
+// UnmarshalBinaryPacket decodes a binary frame into a NetPacket. The result carries the
+// decoded struct, which the Unmarshal function of its packet type returns as is.
+func UnmarshalBinaryPacket(frame []byte) (NetPacket, error) {
+	if len(frame) < frameHeaderSize {
+		return NetPacket{}, io.ErrUnexpectedEOF
+	}
+
+	return unmarshalBinaryBody(frame[frameHeaderSize:])
+}
+
+func unmarshalBinaryBody(body []byte) (NetPacket, error) {
+	r := &packetReader{StreamReader: d2datautils.CreateStreamReader(body)}
+
+	packetType := d2netpackettype.NetPacketType(r.byte())
+	if r.err != nil {
+		return NetPacket{}, r.err
+	}
+
+	layout, ok := binaryLayouts[packetType]
+	if !ok {
+		return NetPacket{PacketType: packetType}, fmt.Errorf("%w: %d", errUnknownLayout, packetType)
+	}
+
+	p := layout.decode(r)
+	if r.err != nil {
+		return NetPacket{PacketType: packetType}, fmt.Errorf("decoding %s: %w", packetType, r.err)
+	}
+
+	if !r.EOF() {
+		return NetPacket{PacketType: packetType}, fmt.Errorf("decoding %s: %w", packetType, errTrailingFields)
+	}
+
+	return NetPacket{PacketType: packetType, Packet: p}, nil
+}
+
+type binaryEncoder struct {
+	w io.Writer
+}
+
+func (e *binaryEncoder) Encode(packet NetPacket) error {
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(frame)
+
+	return err
+}
+
+type binaryDecoder struct {
+	r io.Reader
+}
+
+func (d *binaryDecoder) Decode() (NetPacket, error) {
+	var header [frameHeaderSize]byte
+
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return NetPacket{}, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[:])
+	if length > MaxFrameSize {
+		return NetPacket{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length)
+	}
+
+	body := make([]byte, length)
+
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return NetPacket{}, err
+	}
+
+	return unmarshalBinaryBody(body)
+}
+
+// Buffered always returns an empty reader, binary frames are read exactly.
+func (d *binaryDecoder) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+// packetWriter extends the StreamWriter with the value types used by packet layouts.
+// The first error encountered is kept in err.
+type packetWriter struct {
+	*d2datautils.StreamWriter
+	err error
+}
+
+func (w *packetWriter) pushString(s string) {
+	if len(s) > maxStringLength {
+		w.err = errStringTooLong
+		return
+	}
+
+	w.PushUint16(uint16(len(s)))
+	w.PushBytes([]byte(s)...)
+}
+
+func (w *packetWriter) pushInt(v int) {
+	w.PushInt32(int32(v))
+}
+
+func (w *packetWriter) pushFloat(v float64) {
+	w.PushUint64(math.Float64bits(v))
+}
+
+func (w *packetWriter) pushBool(v bool) {
+	if v {
+		w.PushBytes(1)
+		return
+	}
+
+	w.PushBytes(0)
+}
+
+func (w *packetWriter) pushTime(t time.Time) {
+	w.PushInt64(t.UnixNano())
+}
+
+// packetReader extends the StreamReader with the value types used by packet layouts.
+// After the first error, err is set and every read returns a zero value.
+type packetReader struct {
+	*d2datautils.StreamReader
+	err error
+}
+
+func (r *packetReader) fail(err error) {
+	if r.err == nil && err != nil {
+		r.err = io.ErrUnexpectedEOF
+	}
+}
+
+func (r *packetReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.ReadByte()
+	r.fail(err)
+
+	return v
+}
+
+func (r *packetReader) uint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.ReadUInt16()
+	r.fail(err)
+
+	return v
+}
+
+func (r *packetReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.ReadUInt32()
+	r.fail(err)
+
+	return v
+}
+
+func (r *packetReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.ReadInt32()
+	r.fail(err)
+
+	return int(v)
+}
+
+func (r *packetReader) int64() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.ReadInt64()
+	r.fail(err)
+
+	return v
+}
+
+func (r *packetReader) float() float64 {
+	return math.Float64frombits(uint64(r.int64()))
+}
+
+func (r *packetReader) bool() bool {
+	return r.byte() != 0
+}
+
+func (r *packetReader) time() time.Time {
+	return time.Unix(0, r.int64())
+}
+
+func (r *packetReader) string() string {
+	length := int(r.uint16())
+	if r.err != nil || length == 0 {
+		return ""
+	}
+
+	v, err := r.ReadBytes(length)
+	r.fail(err)
+
+	return string(v)
+}
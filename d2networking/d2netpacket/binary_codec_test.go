@@ -0,0 +1,352 @@
+package d2netpacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2inventory"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
+)
+
+func TestBinaryMovePlayerRoundTrip(t *testing.T) {
+	packet, err := CreateMovePlayerPacket("player", 1.5, 2.25, 10.75, -3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := packet.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frame) >= len(data) {
+		t.Errorf("binary frame (%d bytes) should be smaller than the JSON data (%d bytes)", len(frame), len(data))
+	}
+
+	decoded, err := UnmarshalBinaryPacket(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.PacketType != d2netpackettype.MovePlayer {
+		t.Fatalf("expected packet type %s, got %s", d2netpackettype.MovePlayer, decoded.PacketType)
+	}
+
+	move, err := UnmarshalMovePlayer(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := MovePlayerPacket{PlayerID: "player", StartX: 1.5, StartY: 2.25, DestX: 10.75, DestY: -3, Sequence: 7}
+	if move != want {
+		t.Errorf("expected %+v, got %+v", want, move)
+	}
+}
+
+func TestBinaryGenerateMapRoundTrip(t *testing.T) {
+	packet, err := CreateGenerateMapPacket(2, 50, d2enum.DifficultyHell, -1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalBinaryPacket(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generate, err := UnmarshalGenerateMap(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := GenerateMapPacket{Act: 2, LevelID: 50, Difficulty: d2enum.DifficultyHell, Seed: -1 << 40}
+	if generate != want {
+		t.Errorf("expected %+v, got %+v", want, generate)
+	}
+}
+
+func TestBinaryAddPlayerRoundTrip(t *testing.T) {
+	stats := &d2hero.HeroStatsState{Level: 3, Strength: 20, Health: 50, MaxHealth: 55}
+	skills := map[int]*d2hero.HeroSkill{
+		0: d2hero.NewShallowHeroSkill(0, 1),
+		6: d2hero.NewShallowHeroSkill(6, 4),
+	}
+	equipment := d2inventory.CharacterEquipment{
+		RightHand: &d2inventory.InventoryItemWeapon{ItemCode: "ssd", WeaponClass: "1hs"},
+		Torso:     &d2inventory.InventoryItemArmor{ItemCode: "qui", ArmorClass: "lit"},
+	}
+
+	packet, err := CreateAddPlayerPacket("id", "name", 10, 20, d2enum.HeroBarbarian,
+		stats, skills, equipment, 0, 6, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalBinaryPacket(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := UnmarshalAddPlayer(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID != "id" || p.Name != "name" || p.X != 10 || p.Y != 20 || p.HeroType != d2enum.HeroBarbarian {
+		t.Errorf("player fields were not decoded: %+v", p)
+	}
+
+	if p.Gold != 100 || p.LeftSkill != 0 || p.RightSkill != 6 {
+		t.Errorf("gold or skills were not decoded: %+v", p)
+	}
+
+	if p.Stats == nil || *p.Stats != *stats {
+		t.Errorf("expected stats %+v, got %+v", stats, p.Stats)
+	}
+
+	if len(p.Skills) != 2 || p.Skills[6] == nil || p.Skills[6].Shallow.SkillPoints != 4 {
+		t.Errorf("skills were not decoded: %+v", p.Skills)
+	}
+
+	if p.Equipment.RightHand == nil || p.Equipment.RightHand.ItemCode != "ssd" || p.Equipment.Head != nil {
+		t.Errorf("equipment was not decoded: %+v", p.Equipment)
+	}
+}
+
+func TestBinaryGameListRoundTrip(t *testing.T) {
+	games := []GameInfo{
+		{ID: "1", Name: "Cows", Players: 3, MaxPlayers: 8, Difficulty: d2enum.DifficultyHell, HasPassword: true},
+		{ID: "2", Name: "Game 2", Players: 0, MaxPlayers: 4, Difficulty: d2enum.DifficultyNormal},
+	}
+
+	packet, err := CreateGameListPacket(games)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalBinaryPacket(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gameList, err := UnmarshalGameList(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gameList.Games) != len(games) {
+		t.Fatalf("expected %d games, got %d", len(games), len(gameList.Games))
+	}
+
+	for i := range games {
+		if gameList.Games[i] != games[i] {
+			t.Errorf("expected %+v, got %+v", games[i], gameList.Games[i])
+		}
+	}
+}
+
+func TestBinaryLobbyRequestsRoundTrip(t *testing.T) {
+	listGames, err := CreateListGamesPacket("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createGame, err := CreateCreateGamePacket("Cows", d2enum.DifficultyHell, "moo", 4, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, packet := range []NetPacket{listGames, createGame} {
+		frame, err := MarshalBinaryPacket(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := UnmarshalBinaryPacket(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.Packet != packet.Packet {
+			t.Errorf("expected %+v, got %+v", packet.Packet, decoded.Packet)
+		}
+	}
+}
+
+func TestBinaryEntityStatesRoundTrip(t *testing.T) {
+	update := d2replication.Update{
+		Sequence: 12,
+		Baseline: 9,
+		Deltas: []d2replication.Delta{
+			{NetID: 3, Fields: d2replication.AllFields, X: 101.5, Y: 240, TargetX: 110, TargetY: 240},
+			{NetID: 4, Fields: d2replication.FieldTarget, TargetX: 12, TargetY: 13},
+		},
+		Despawned: []uint32{5},
+	}
+
+	packet, err := CreateEntityStatesPacket(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalBinaryPacket(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entityStates, err := UnmarshalEntityStates(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entityStates.Sequence != update.Sequence || entityStates.Baseline != update.Baseline {
+		t.Errorf("expected snapshot %d against %d, got %d against %d",
+			update.Sequence, update.Baseline, entityStates.Sequence, entityStates.Baseline)
+	}
+
+	if len(entityStates.States) != len(update.Deltas) {
+		t.Fatalf("expected %d states, got %d", len(update.Deltas), len(entityStates.States))
+	}
+
+	for i := range update.Deltas {
+		if entityStates.States[i] != update.Deltas[i] {
+			t.Errorf("expected %+v, got %+v", update.Deltas[i], entityStates.States[i])
+		}
+	}
+
+	if len(entityStates.Despawned) != 1 || entityStates.Despawned[0] != 5 {
+		t.Errorf("expected despawned %v, got %v", update.Despawned, entityStates.Despawned)
+	}
+}
+
+func TestBinaryFrameTruncated(t *testing.T) {
+	packet, err := CreateCastPacket("player", 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := MarshalBinaryPacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UnmarshalBinaryPacket(frame[:len(frame)-1]); err == nil {
+		t.Error("expected an error decoding a truncated frame")
+	}
+}
+
+func TestDecoderFrameTooLarge(t *testing.T) {
+	var header [frameHeaderSize]byte
+
+	binary.LittleEndian.PutUint32(header[:], MaxFrameSize+1)
+
+	if _, err := NewDecoder(CodecBinary, bytes.NewReader(header[:])).Decode(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("expected %v decoding a binary frame, got %v", ErrFrameTooLarge, err)
+	}
+
+	chat, err := CreateChatPacket("id", "name", strings.Repeat("a", MaxFrameSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stream bytes.Buffer
+
+	if err := NewEncoder(CodecJSON, &stream).Encode(chat); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewDecoder(CodecJSON, &stream).Decode(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("expected %v decoding a JSON packet, got %v", ErrFrameTooLarge, err)
+	}
+}
+
+func TestSwitchDecoder(t *testing.T) {
+	var stream bytes.Buffer
+
+	request, err := CreatePlayerConnectionRequestPacket("id", nil, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	move, err := CreateMovePlayerPacket("id", 1, 2, 3, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewEncoder(CodecJSON, &stream).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewEncoder(CodecBinary, &stream).Encode(move); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := NewDecoder(CodecJSON, &stream)
+
+	first, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := UnmarshalPlayerConnectionRequest(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ProtocolVersion != ProtocolVersion {
+		t.Errorf("expected protocol version %d, got %d", ProtocolVersion, r.ProtocolVersion)
+	}
+
+	codec := NegotiateCodec(r.Codecs)
+	if codec != CodecBinary {
+		t.Fatalf("expected the binary codec to be negotiated, got %s", codec)
+	}
+
+	decoder = SwitchDecoder(decoder, codec, &stream)
+
+	second, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.PacketType != d2netpackettype.MovePlayer {
+		t.Errorf("expected packet type %s, got %s", d2netpackettype.MovePlayer, second.PacketType)
+	}
+}
+
+func TestNegotiateCodecLegacyPeer(t *testing.T) {
+	if codec := NegotiateCodec(nil); codec != CodecJSON {
+		t.Errorf("expected peers without codecs to use %s, got %s", CodecJSON, codec)
+	}
+}
@@ -0,0 +1,944 @@
+package d2netpacket
+
+import (
+	"sort"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2inventory"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
+)
+
+// binaryLayout describes the binary field layout of one packet type. encode writes the
+// fields of the packet struct, decode reads the fields back into the packet struct.
+// Fields are written and read in the same order.
+type binaryLayout struct {
+	encode func(packet NetPacket, w *packetWriter) error
+	decode func(r *packetReader) interface{}
+}
+
+// binaryLayouts holds the layout of every packet type. A packet type without a layout
+// can only be sent using the JSON codec.
+// nolint:gochecknoglobals // constant lookup table
+var binaryLayouts = map[d2netpackettype.NetPacketType]binaryLayout{
+	d2netpackettype.UpdateServerInfo: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalUpdateServerInfo(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushInt64(p.Seed)
+			w.pushString(p.PlayerID)
+			w.pushString(string(p.Codec))
+			w.pushString(p.SessionToken)
+			w.pushBool(p.Resumed)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return UpdateServerInfoPacket{
+				Seed:         r.int64(),
+				PlayerID:     r.string(),
+				Codec:        CodecType(r.string()),
+				SessionToken: r.string(),
+				Resumed:      r.bool(),
+			}
+		},
+	},
+	d2netpackettype.GenerateMap: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalGenerateMap(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushInt(p.Act)
+			w.pushInt(p.LevelID)
+			w.pushInt(int(p.Difficulty))
+			w.PushInt64(p.Seed)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return GenerateMapPacket{
+				Act:        r.int(),
+				LevelID:    r.int(),
+				Difficulty: d2enum.DifficultyType(r.int()),
+				Seed:       r.int64(),
+			}
+		},
+	},
+	d2netpackettype.AddPlayer: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalAddPlayer(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.ID)
+			w.pushString(p.Name)
+			w.pushInt(p.X)
+			w.pushInt(p.Y)
+			w.pushInt(int(p.HeroType))
+			w.pushEquipment(&p.Equipment)
+			w.pushStats(p.Stats)
+			w.pushSkills(p.Skills)
+			w.pushInt(p.LeftSkill)
+			w.pushInt(p.RightSkill)
+			w.pushInt(p.Gold)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return AddPlayerPacket{
+				ID:         r.string(),
+				Name:       r.string(),
+				X:          r.int(),
+				Y:          r.int(),
+				HeroType:   d2enum.Hero(r.int()),
+				Equipment:  r.equipment(),
+				Stats:      r.stats(),
+				Skills:     r.skills(),
+				LeftSkill:  r.int(),
+				RightSkill: r.int(),
+				Gold:       r.int(),
+			}
+		},
+	},
+	d2netpackettype.MovePlayer: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalMovePlayer(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.PlayerID)
+			w.pushFloat(p.StartX)
+			w.pushFloat(p.StartY)
+			w.pushFloat(p.DestX)
+			w.pushFloat(p.DestY)
+			w.PushUint32(p.Sequence)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return MovePlayerPacket{
+				PlayerID: r.string(),
+				StartX:   r.float(),
+				StartY:   r.float(),
+				DestX:    r.float(),
+				DestY:    r.float(),
+				Sequence: r.uint32(),
+			}
+		},
+	},
+	d2netpackettype.PlayerConnectionRequest: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalPlayerConnectionRequest(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushInt(p.ProtocolVersion)
+			w.pushString(p.ID)
+			w.pushHeroState(p.PlayerState)
+			w.pushCodecs(p.Codecs)
+			w.pushString(p.SessionToken)
+			w.pushString(p.GameID)
+			w.pushString(p.Password)
+			w.pushString(p.ServerPassword)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return PlayerConnectionRequestPacket{
+				ProtocolVersion: r.int(),
+				ID:              r.string(),
+				PlayerState:     r.heroState(),
+				Codecs:          r.codecs(),
+				SessionToken:    r.string(),
+				GameID:          r.string(),
+				Password:        r.string(),
+				ServerPassword:  r.string(),
+			}
+		},
+	},
+	d2netpackettype.PlayerDisconnectionNotification: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalPlayerDisconnectionRequest(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.ID)
+			w.pushHeroState(p.PlayerState)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return PlayerDisconnectRequestPacket{
+				ID:          r.string(),
+				PlayerState: r.heroState(),
+			}
+		},
+	},
+	d2netpackettype.Ping: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalPing(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushTime(p.TS)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return PingPacket{TS: r.time()}
+		},
+	},
+	d2netpackettype.Pong: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalPong(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.ID)
+			w.pushTime(p.TS)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return PongPacket{ID: r.string(), TS: r.time()}
+		},
+	},
+	d2netpackettype.ServerClosed: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalServerClosed(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushTime(p.TS)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return ServerClosedPacket{TS: r.time()}
+		},
+	},
+	d2netpackettype.CastSkill: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalCast(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.SourceEntityID)
+			w.pushInt(p.SkillID)
+			w.pushFloat(p.TargetX)
+			w.pushFloat(p.TargetY)
+			w.pushString(p.TargetEntityID)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return CastPacket{
+				SourceEntityID: r.string(),
+				SkillID:        r.int(),
+				TargetX:        r.float(),
+				TargetY:        r.float(),
+				TargetEntityID: r.string(),
+			}
+		},
+	},
+	d2netpackettype.SpawnItem: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalSpawnItem(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushUint32(p.NetID)
+			w.pushInt(p.X)
+			w.pushInt(p.Y)
+			w.pushStrings(p.Codes)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return SpawnItemPacket{
+				NetID: r.uint32(),
+				X:     r.int(),
+				Y:     r.int(),
+				Codes: r.strings(),
+			}
+		},
+	},
+	d2netpackettype.SavePlayer: {
+		// Only the fields of the player which the server reads are sent
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalSavePlayer(packet)
+			if err != nil {
+				return err
+			}
+
+			if p.Player == nil {
+				p.Player = &d2mapentity.Player{}
+			}
+
+			w.pushSkillID(p.Player.LeftSkill)
+			w.pushSkillID(p.Player.RightSkill)
+			w.pushStats(p.Player.Stats)
+			w.pushInt(p.Player.Act)
+			w.pushInt(int(p.Difficulty))
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			leftSkill := d2hero.NewShallowHeroSkill(r.int(), 0)
+			rightSkill := d2hero.NewShallowHeroSkill(r.int(), 0)
+
+			return SavePlayerPacket{
+				Player: &d2mapentity.Player{
+					LeftSkill:  leftSkill,
+					RightSkill: rightSkill,
+					Stats:      r.stats(),
+					Act:        r.int(),
+				},
+				Difficulty: d2enum.DifficultyType(r.int()),
+			}
+		},
+	},
+	d2netpackettype.MovePlayerCorrection: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalMovePlayerCorrection(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.PlayerID)
+			w.pushFloat(p.X)
+			w.pushFloat(p.Y)
+			w.PushUint32(p.Sequence)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return MovePlayerCorrectionPacket{
+				PlayerID: r.string(),
+				X:        r.float(),
+				Y:        r.float(),
+				Sequence: r.uint32(),
+			}
+		},
+	},
+	d2netpackettype.SystemMessage: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalSystemMessage(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.Message)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return SystemMessagePacket{Message: r.string()}
+		},
+	},
+	d2netpackettype.Chat: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalChat(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.PlayerID)
+			w.pushString(p.Name)
+			w.pushString(p.Message)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return ChatPacket{
+				PlayerID: r.string(),
+				Name:     r.string(),
+				Message:  r.string(),
+			}
+		},
+	},
+	d2netpackettype.Whisper: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalWhisper(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.FromID)
+			w.pushString(p.FromName)
+			w.pushString(p.ToID)
+			w.pushString(p.ToName)
+			w.pushString(p.Message)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return WhisperPacket{
+				FromID:   r.string(),
+				FromName: r.string(),
+				ToID:     r.string(),
+				ToName:   r.string(),
+				Message:  r.string(),
+			}
+		},
+	},
+	d2netpackettype.ListGames: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalListGames(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.ServerPassword)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return ListGamesPacket{ServerPassword: r.string()}
+		},
+	},
+	d2netpackettype.GameList: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalGameList(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushGameInfos(p.Games)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return GameListPacket{Games: r.gameInfos()}
+		},
+	},
+	d2netpackettype.CreateGame: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalCreateGame(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.Name)
+			w.pushInt(int(p.Difficulty))
+			w.pushString(p.Password)
+			w.pushInt(p.MaxPlayers)
+			w.pushString(p.ServerPassword)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return CreateGamePacket{
+				Name:           r.string(),
+				Difficulty:     d2enum.DifficultyType(r.int()),
+				Password:       r.string(),
+				MaxPlayers:     r.int(),
+				ServerPassword: r.string(),
+			}
+		},
+	},
+	d2netpackettype.GameCreated: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalGameCreated(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.GameID)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return GameCreatedPacket{GameID: r.string()}
+		},
+	},
+	d2netpackettype.SpawnMissile: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalSpawnMissile(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushUint32(p.NetID)
+			w.pushInt(p.MissileID)
+			w.pushInt(p.X)
+			w.pushInt(p.Y)
+			w.pushFloat(p.Radians)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return SpawnMissilePacket{
+				NetID:     r.uint32(),
+				MissileID: r.int(),
+				X:         r.int(),
+				Y:         r.int(),
+				Radians:   r.float(),
+			}
+		},
+	},
+	d2netpackettype.SpawnNPC: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalSpawnNPC(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushUint32(p.NetID)
+			w.pushString(p.MonStat)
+			w.pushInt(p.X)
+			w.pushInt(p.Y)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return SpawnNPCPacket{
+				NetID:   r.uint32(),
+				MonStat: r.string(),
+				X:       r.int(),
+				Y:       r.int(),
+			}
+		},
+	},
+	d2netpackettype.EntityStates: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalEntityStates(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushUint32(p.Sequence)
+			w.PushUint32(p.Baseline)
+			w.pushDeltas(p.States)
+			w.pushNetIDs(p.Despawned)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return EntityStatesPacket{
+				Sequence:  r.uint32(),
+				Baseline:  r.uint32(),
+				States:    r.deltas(),
+				Despawned: r.netIDs(),
+			}
+		},
+	},
+	d2netpackettype.EntityStatesAck: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalEntityStatesAck(packet)
+			if err != nil {
+				return err
+			}
+
+			w.PushUint32(p.Sequence)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return EntityStatesAckPacket{Sequence: r.uint32()}
+		},
+	},
+	d2netpackettype.PlayerWarp: {
+		encode: func(packet NetPacket, w *packetWriter) error {
+			p, err := UnmarshalPlayerWarp(packet)
+			if err != nil {
+				return err
+			}
+
+			w.pushString(p.PlayerID)
+			w.pushInt(p.Act)
+			w.pushInt(p.LevelID)
+
+			return nil
+		},
+		decode: func(r *packetReader) interface{} {
+			return PlayerWarpPacket{
+				PlayerID: r.string(),
+				Act:      r.int(),
+				LevelID:  r.int(),
+			}
+		},
+	},
+}
+
+// pushDeltas writes only the fields each delta carries.
+func (w *packetWriter) pushDeltas(deltas []d2replication.Delta) {
+	w.PushUint16(uint16(len(deltas)))
+
+	for i := range deltas {
+		w.PushUint32(deltas[i].NetID)
+		w.PushBytes(byte(deltas[i].Fields))
+
+		if deltas[i].Fields&d2replication.FieldPosition != 0 {
+			w.pushFloat(deltas[i].X)
+			w.pushFloat(deltas[i].Y)
+		}
+
+		if deltas[i].Fields&d2replication.FieldTarget != 0 {
+			w.pushFloat(deltas[i].TargetX)
+			w.pushFloat(deltas[i].TargetY)
+		}
+	}
+}
+
+func (r *packetReader) deltas() []d2replication.Delta {
+	count := int(r.uint16())
+	result := make([]d2replication.Delta, 0, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		delta := d2replication.Delta{
+			NetID:  r.uint32(),
+			Fields: d2replication.Fields(r.byte()),
+		}
+
+		if delta.Fields&d2replication.FieldPosition != 0 {
+			delta.X, delta.Y = r.float(), r.float()
+		}
+
+		if delta.Fields&d2replication.FieldTarget != 0 {
+			delta.TargetX, delta.TargetY = r.float(), r.float()
+		}
+
+		result = append(result, delta)
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushNetIDs(ids []uint32) {
+	w.PushUint16(uint16(len(ids)))
+
+	for _, id := range ids {
+		w.PushUint32(id)
+	}
+}
+
+func (r *packetReader) netIDs() []uint32 {
+	count := int(r.uint16())
+	result := make([]uint32, 0, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		result = append(result, r.uint32())
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushGameInfos(games []GameInfo) {
+	w.PushUint16(uint16(len(games)))
+
+	for i := range games {
+		w.pushString(games[i].ID)
+		w.pushString(games[i].Name)
+		w.pushInt(games[i].Players)
+		w.pushInt(games[i].MaxPlayers)
+		w.pushInt(int(games[i].Difficulty))
+		w.pushBool(games[i].HasPassword)
+	}
+}
+
+func (r *packetReader) gameInfos() []GameInfo {
+	count := int(r.uint16())
+	result := make([]GameInfo, 0, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		result = append(result, GameInfo{
+			ID:          r.string(),
+			Name:        r.string(),
+			Players:     r.int(),
+			MaxPlayers:  r.int(),
+			Difficulty:  d2enum.DifficultyType(r.int()),
+			HasPassword: r.bool(),
+		})
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushStrings(s []string) {
+	w.PushUint16(uint16(len(s)))
+
+	for _, v := range s {
+		w.pushString(v)
+	}
+}
+
+func (r *packetReader) strings() []string {
+	count := int(r.uint16())
+	result := make([]string, 0, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		result = append(result, r.string())
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushCodecs(codecs []CodecType) {
+	s := make([]string, len(codecs))
+	for i := range codecs {
+		s[i] = string(codecs[i])
+	}
+
+	w.pushStrings(s)
+}
+
+func (r *packetReader) codecs() []CodecType {
+	s := r.strings()
+	result := make([]CodecType, len(s))
+
+	for i := range s {
+		result[i] = CodecType(s[i])
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushHeroState(s *d2hero.HeroState) {
+	w.pushBool(s != nil)
+
+	if s == nil {
+		return
+	}
+
+	w.pushString(s.HeroName)
+	w.pushInt(int(s.HeroType))
+	w.pushInt(s.Act)
+	w.pushEquipment(&s.Equipment)
+	w.pushStats(s.Stats)
+	w.pushSkills(s.Skills)
+	w.pushFloat(s.X)
+	w.pushFloat(s.Y)
+	w.pushInt(s.LeftSkill)
+	w.pushInt(s.RightSkill)
+	w.pushInt(s.Gold)
+	w.pushInt(int(s.Difficulty))
+}
+
+func (r *packetReader) heroState() *d2hero.HeroState {
+	if !r.bool() {
+		return nil
+	}
+
+	return &d2hero.HeroState{
+		HeroName:   r.string(),
+		HeroType:   d2enum.Hero(r.int()),
+		Act:        r.int(),
+		Equipment:  r.equipment(),
+		Stats:      r.stats(),
+		Skills:     r.skills(),
+		X:          r.float(),
+		Y:          r.float(),
+		LeftSkill:  r.int(),
+		RightSkill: r.int(),
+		Gold:       r.int(),
+		Difficulty: d2enum.DifficultyType(r.int()),
+	}
+}
+
+func (w *packetWriter) pushStats(s *d2hero.HeroStatsState) {
+	w.pushBool(s != nil)
+
+	if s == nil {
+		return
+	}
+
+	w.pushInt(s.Level)
+	w.pushInt(s.Experience)
+	w.pushInt(s.Strength)
+	w.pushInt(s.Energy)
+	w.pushInt(s.Dexterity)
+	w.pushInt(s.Vitality)
+	w.pushInt(s.StatsPoints)
+	w.pushInt(s.SkillPoints)
+	w.pushInt(s.Health)
+	w.pushInt(s.MaxHealth)
+	w.pushInt(s.Mana)
+	w.pushInt(s.MaxMana)
+	w.pushInt(s.MaxStamina)
+}
+
+func (r *packetReader) stats() *d2hero.HeroStatsState {
+	if !r.bool() {
+		return nil
+	}
+
+	return &d2hero.HeroStatsState{
+		Level:       r.int(),
+		Experience:  r.int(),
+		Strength:    r.int(),
+		Energy:      r.int(),
+		Dexterity:   r.int(),
+		Vitality:    r.int(),
+		StatsPoints: r.int(),
+		SkillPoints: r.int(),
+		Health:      r.int(),
+		MaxHealth:   r.int(),
+		Mana:        r.int(),
+		MaxMana:     r.int(),
+		MaxStamina:  r.int(),
+	}
+}
+
+// pushSkills writes the skill IDs and points of the skills, sorted by skill ID.
+func (w *packetWriter) pushSkills(skills map[int]*d2hero.HeroSkill) {
+	ids := make([]int, 0, len(skills))
+	for id := range skills {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	w.PushUint16(uint16(len(ids)))
+
+	for _, id := range ids {
+		w.pushInt(id)
+		w.pushSkill(skills[id])
+	}
+}
+
+func (r *packetReader) skills() map[int]*d2hero.HeroSkill {
+	count := int(r.uint16())
+	result := make(map[int]*d2hero.HeroSkill, count)
+
+	for i := 0; i < count && r.err == nil; i++ {
+		id := r.int()
+		result[id] = r.skill()
+	}
+
+	return result
+}
+
+func (w *packetWriter) pushSkill(s *d2hero.HeroSkill) {
+	w.pushSkillID(s)
+
+	if s == nil || s.Shallow == nil {
+		w.pushInt(0)
+		return
+	}
+
+	w.pushInt(s.Shallow.SkillPoints)
+}
+
+func (r *packetReader) skill() *d2hero.HeroSkill {
+	return d2hero.NewShallowHeroSkill(r.int(), r.int())
+}
+
+func (w *packetWriter) pushSkillID(s *d2hero.HeroSkill) {
+	switch {
+	case s == nil:
+		w.pushInt(0)
+	case s.Shallow != nil:
+		w.pushInt(s.Shallow.SkillID)
+	case s.SkillRecord != nil:
+		w.pushInt(s.SkillRecord.ID)
+	default:
+		w.pushInt(0)
+	}
+}
+
+func (w *packetWriter) pushEquipment(e *d2inventory.CharacterEquipment) {
+	w.pushArmor(e.Head)
+	w.pushArmor(e.Torso)
+	w.pushArmor(e.Legs)
+	w.pushArmor(e.RightArm)
+	w.pushArmor(e.LeftArm)
+	w.pushWeapon(e.LeftHand)
+	w.pushWeapon(e.RightHand)
+	w.pushArmor(e.Shield)
+}
+
+func (r *packetReader) equipment() d2inventory.CharacterEquipment {
+	return d2inventory.CharacterEquipment{
+		Head:      r.armor(),
+		Torso:     r.armor(),
+		Legs:      r.armor(),
+		RightArm:  r.armor(),
+		LeftArm:   r.armor(),
+		LeftHand:  r.weapon(),
+		RightHand: r.weapon(),
+		Shield:    r.armor(),
+	}
+}
+
+func (w *packetWriter) pushArmor(a *d2inventory.InventoryItemArmor) {
+	w.pushBool(a != nil)
+
+	if a == nil {
+		return
+	}
+
+	w.pushInt(a.InventorySizeX)
+	w.pushInt(a.InventorySizeY)
+	w.pushInt(a.InventorySlotX)
+	w.pushInt(a.InventorySlotY)
+	w.pushString(a.ItemName)
+	w.pushString(a.ItemCode)
+	w.pushString(a.ArmorClass)
+}
+
+func (r *packetReader) armor() *d2inventory.InventoryItemArmor {
+	if !r.bool() {
+		return nil
+	}
+
+	return &d2inventory.InventoryItemArmor{
+		InventorySizeX: r.int(),
+		InventorySizeY: r.int(),
+		InventorySlotX: r.int(),
+		InventorySlotY: r.int(),
+		ItemName:       r.string(),
+		ItemCode:       r.string(),
+		ArmorClass:     r.string(),
+	}
+}
+
+func (w *packetWriter) pushWeapon(v *d2inventory.InventoryItemWeapon) {
+	w.pushBool(v != nil)
+
+	if v == nil {
+		return
+	}
+
+	w.pushInt(v.InventorySizeX)
+	w.pushInt(v.InventorySizeY)
+	w.pushInt(v.InventorySlotX)
+	w.pushInt(v.InventorySlotY)
+	w.pushString(v.ItemName)
+	w.pushString(v.ItemCode)
+	w.pushString(v.WeaponClass)
+	w.pushString(v.WeaponClassOffHand)
+}
+
+func (r *packetReader) weapon() *d2inventory.InventoryItemWeapon {
+	if !r.bool() {
+		return nil
+	}
+
+	return &d2inventory.InventoryItemWeapon{
+		InventorySizeX:     r.int(),
+		InventorySizeY:     r.int(),
+		InventorySlotX:     r.int(),
+		InventorySlotY:     r.int(),
+		ItemName:           r.string(),
+		ItemCode:           r.string(),
+		WeaponClass:        r.string(),
+		WeaponClassOffHand: r.string(),
+	}
+}
@@ -0,0 +1,171 @@
+package d2netpacket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxFrameSize is the size of the largest NetPacket a PacketDecoder reads, in bytes of
+// its encoding. A peer which sends a larger one is misbehaving.
+const MaxFrameSize = 1 << 20
+
+// ErrFrameTooLarge is returned by a PacketDecoder which reads a NetPacket larger than
+// MaxFrameSize.
+var ErrFrameTooLarge = errors.New("packet is too large")
+
+// CodecType names the wire encoding used to transport NetPackets on a connection.
+type CodecType string
+
+// Codec types
+const (
+	CodecJSON   CodecType = "json"   // Every NetPacket is a JSON object
+	CodecBinary CodecType = "binary" // Every NetPacket is a length-prefixed binary frame
+)
+
+// SupportedCodecs returns the codecs this build can speak, in order of preference.
+// A remote client offers these in its PlayerConnectionRequestPacket.
+func SupportedCodecs() []CodecType {
+	return []CodecType{CodecBinary, CodecJSON}
+}
+
+// NegotiateCodec returns the first of the offered codecs which is supported. Peers which do
+// not offer any codecs predate codec negotiation and only speak JSON.
+func NegotiateCodec(offered []CodecType) CodecType {
+	for _, o := range offered {
+		for _, s := range SupportedCodecs() {
+			if o == s {
+				return o
+			}
+		}
+	}
+
+	return CodecJSON
+}
+
+// PacketEncoder writes NetPackets to a stream.
+type PacketEncoder interface {
+	Encode(packet NetPacket) error
+}
+
+// PacketDecoder reads NetPackets from a stream.
+type PacketDecoder interface {
+	Decode() (NetPacket, error)
+	// Buffered returns the data which has been read from the stream but not decoded yet.
+	// It is used to hand the rest of the stream over to a decoder for another codec.
+	Buffered() io.Reader
+}
+
+// NewEncoder returns a PacketEncoder writing to w using the given codec.
+func NewEncoder(codec CodecType, w io.Writer) PacketEncoder {
+	if codec == CodecBinary {
+		return &binaryEncoder{w: w}
+	}
+
+	return &jsonEncoder{json.NewEncoder(w)}
+}
+
+// NewDecoder returns a PacketDecoder reading from r using the given codec.
+func NewDecoder(codec CodecType, r io.Reader) PacketDecoder {
+	if codec == CodecBinary {
+		return &binaryDecoder{r: r}
+	}
+
+	limiter := &frameLimiter{r: r}
+
+	return &jsonDecoder{Decoder: json.NewDecoder(limiter), limiter: limiter}
+}
+
+// SwitchDecoder returns a decoder for the given codec which carries on reading r after the
+// last packet decoded by d.
+func SwitchDecoder(d PacketDecoder, codec CodecType, r io.Reader) PacketDecoder {
+	if _, ok := d.(*jsonDecoder); ok {
+		if codec == CodecJSON {
+			return d
+		}
+
+		// json.Encoder terminates every packet with a newline, which is not part of the next packet
+		return NewDecoder(codec, &newlineSkipper{r: io.MultiReader(d.Buffered(), r)})
+	}
+
+	return NewDecoder(codec, io.MultiReader(d.Buffered(), r))
+}
+
+// newlineSkipper drops the first byte read from r if it is a newline.
+type newlineSkipper struct {
+	r       io.Reader
+	checked bool
+}
+
+func (s *newlineSkipper) Read(p []byte) (int, error) {
+	if s.checked || len(p) == 0 {
+		return s.r.Read(p)
+	}
+
+	s.checked = true
+
+	if _, err := io.ReadFull(s.r, p[:1]); err != nil {
+		return 0, err
+	}
+
+	if p[0] != '\n' {
+		return 1, nil
+	}
+
+	return s.r.Read(p)
+}
+
+type jsonEncoder struct {
+	*json.Encoder
+}
+
+func (e *jsonEncoder) Encode(packet NetPacket) error {
+	data, err := packet.Data()
+	if err != nil {
+		return err
+	}
+
+	packet.PacketData = data
+
+	return e.Encoder.Encode(packet)
+}
+
+type jsonDecoder struct {
+	*json.Decoder
+	limiter *frameLimiter
+}
+
+func (d *jsonDecoder) Decode() (NetPacket, error) {
+	var packet NetPacket
+
+	// the packet starts at the input offset, the decoder may have read past it already
+	d.limiter.limit = d.InputOffset() + MaxFrameSize
+
+	err := d.Decoder.Decode(&packet)
+
+	return packet, err
+}
+
+// frameLimiter fails reads past limit, so a JSON decoder does not buffer a packet of
+// any size.
+type frameLimiter struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (f *frameLimiter) Read(p []byte) (int, error) {
+	if f.read >= f.limit {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrFrameTooLarge, MaxFrameSize)
+	}
+
+	if remaining := f.limit - f.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := f.r.Read(p)
+	f.read += int64(n)
+
+	return n, err
+}
@@ -5,7 +5,9 @@
 //
 // A struct is defined for each packet type. Each struct comes with a function which
 // returns a NetPacket declaring the type enum (header) followed by the associated
-// struct (body). The NetPacket is marshaled to JSON for transport. On receipt of
-// the packet, the enum is read as a single byte then the remaining data (the struct)
-// is unmarshalled to the type associated with the type enum.
+// struct (body). The NetPacket is marshaled to JSON for transport, or to a
+// length-prefixed binary frame when both sides have negotiated the binary codec
+// (see d2netpacket.CodecType). On receipt of the packet, the enum is read as a
+// single byte then the remaining data (the struct) is unmarshalled to the type
+// associated with the type enum.
 package d2netpackettype
@@ -30,7 +30,21 @@ const (
 	CastSkill                                            // Sent by client or server, indicates entity casting skill
 	SpawnItem                                            // Sent by server
 	SavePlayer                                           // Sent by the client, saves the player
-	ServerFull                                           // Sent by server when server has reached max connections
+	ServerFull                                           // No longer sent, see ConnectionRejected
+	MovePlayerCorrection                                 // Sent by server when it rejected a MovePlayer packet
+	SystemMessage                                        // Sent by server, a message from the server to the players
+	Chat                                                 // Sent by client or server, a chat message or command
+	Whisper                                              // Sent by server, a private message between two players
+	ListGames                                            // Sent by client, asks the lobby for its games
+	GameList                                             // Sent by server, the games of the lobby
+	CreateGame                                           // Sent by client, asks the lobby to create a game
+	GameCreated                                          // Sent by server, the ID of the game the lobby created
+	SpawnMissile                                         // Sent by server, a missile starts flying
+	SpawnNPC                                             // Sent by server, an NPC is added to the world
+	EntityStates                                         // Sent by server, the entities near the player whose state changed
+	EntityStatesAck                                      // Sent by client, the last entity states it received
+	ConnectionRejected                                   // Sent by server, why it refused a connection request
+	PlayerWarp                                           // Sent by server, a player left the map through a warp
 
 	UnknownPacketType = 666
 )
@@ -50,6 +64,20 @@ func (n NetPacketType) String() string {
 		SpawnItem:                       "SpawnItem",
 		SavePlayer:                      "SavePlayer",
 		ServerFull:                      "ServerFull",
+		MovePlayerCorrection:            "MovePlayerCorrection",
+		SystemMessage:                   "SystemMessage",
+		Chat:                            "Chat",
+		Whisper:                         "Whisper",
+		ListGames:                       "ListGames",
+		GameList:                        "GameList",
+		CreateGame:                      "CreateGame",
+		GameCreated:                     "GameCreated",
+		SpawnMissile:                    "SpawnMissile",
+		SpawnNPC:                        "SpawnNPC",
+		EntityStates:                    "EntityStates",
+		EntityStatesAck:                 "EntityStatesAck",
+		ConnectionRejected:              "ConnectionRejected",
+		PlayerWarp:                      "PlayerWarp",
 	}
 
 	return strings[n]
@@ -2,17 +2,65 @@ package d2netpacket
 
 import (
 	"encoding/json"
+	"reflect"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
+// ProtocolVersion is the version of the packet protocol. It changes whenever
+// packets change in a way older clients or servers can not handle.
+const ProtocolVersion = 4
+
 // NetPacket is used to wrap and send all packet types under d2netpacket.
 // When decoding a packet: First the PacketType byte is read, then the
 // PacketData is unmarshalled to a struct of the type associated with
 // PacketType.
+//
+// Packets which are created, or decoded by the binary codec, carry the struct
+// of their packet type in Packet instead, and have no PacketData until it is
+// needed, see Data.
 type NetPacket struct {
 	PacketType d2netpackettype.NetPacketType `json:"packetType"`
 	PacketData json.RawMessage               `json:"packetData"`
+	Packet     interface{}                   `json:"-"`
+}
+
+// Data returns the JSON data of the packet, marshalling its struct if the
+// packet was not received as JSON.
+func (p NetPacket) Data() (json.RawMessage, error) {
+	if p.PacketData != nil || p.Packet == nil {
+		return p.PacketData, nil
+	}
+
+	return json.Marshal(p.Packet)
+}
+
+// Detach returns a copy of the packet which shares no memory with the state it
+// was created from, like the HeroState of a player. Packets must be detached
+// before they are handed over to another goroutine or peer in process.
+func (p NetPacket) Detach() (NetPacket, error) {
+	data, err := p.Data()
+	if err != nil {
+		return NetPacket{PacketType: p.PacketType}, err
+	}
+
+	return NetPacket{PacketType: p.PacketType, PacketData: data}, nil
+}
+
+// unmarshalPacket stores the struct of the packet in v, which points to a
+// struct of its packet type. The struct the packet carries is copied, the
+// JSON data is only unmarshalled for packets received as JSON.
+func unmarshalPacket(packet NetPacket, v interface{}) error {
+	if packet.Packet != nil {
+		target := reflect.ValueOf(v).Elem()
+
+		if value := reflect.ValueOf(packet.Packet); value.Type() == target.Type() {
+			target.Set(value)
+			return nil
+		}
+	}
+
+	return json.Unmarshal(packet.PacketData, v)
 }
 
 // InspectPacketType determines the packet type from the given data
@@ -1,8 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2inventory"
@@ -50,23 +48,14 @@ func CreateAddPlayerPacket(
 		Gold:       gold,
 	}
 
-	b, err := json.Marshal(addPlayerPacket)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.AddPlayer}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.AddPlayer,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.AddPlayer, Packet: addPlayerPacket}, nil
 }
 
 // UnmarshalAddPlayer unmarshals the packet data into an AddPlayerPacket struct
-func UnmarshalAddPlayer(packet []byte) (AddPlayerPacket, error) {
+func UnmarshalAddPlayer(packet NetPacket) (AddPlayerPacket, error) {
 	var p AddPlayerPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
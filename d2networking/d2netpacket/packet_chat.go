@@ -0,0 +1,38 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// MaxChatMessageLength is the maximum number of characters in a chat message.
+const MaxChatMessageLength = 200
+
+// ChatPacket contains a chat message. Clients send it with the text they
+// entered, which may be a slash command. The server relays messages to every
+// player, with the ID and name of the sender filled in.
+type ChatPacket struct {
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+}
+
+// CreateChatPacket returns a NetPacket which declares a ChatPacket with the
+// given sender and message.
+func CreateChatPacket(playerID, name, message string) (NetPacket, error) {
+	chat := ChatPacket{
+		PlayerID: playerID,
+		Name:     name,
+		Message:  message,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.Chat, Packet: chat}, nil
+}
+
+// UnmarshalChat unmarshals the given packet to a ChatPacket struct
+func UnmarshalChat(packet NetPacket) (ChatPacket, error) {
+	var resp ChatPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -0,0 +1,71 @@
+package d2netpacket
+
+import (
+	"fmt"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// RejectionReason is why a server refused a connection request.
+type RejectionReason int
+
+// Rejection reasons
+const (
+	RejectedOther    RejectionReason = iota // The detail of the packet explains why
+	RejectedVersion                         // The client speaks another protocol version
+	RejectedFull                            // The game has as many players as it allows
+	RejectedBanned                          // The address of the client is banned
+	RejectedPassword                        // The password of the game is wrong
+)
+
+// ConnectionRejectedPacket is sent by the server instead of UpdateServerInfoPacket
+// when it refuses a PlayerConnectionRequestPacket. The server closes the connection
+// after it. It is always sent as JSON, and its layout must not change, so a client
+// of any version can tell why it was refused.
+type ConnectionRejectedPacket struct {
+	Reason          RejectionReason `json:"reason"`
+	Detail          string          `json:"detail,omitempty"`
+	ProtocolVersion int             `json:"protocolVersion"` // Version the server speaks
+}
+
+// CreateConnectionRejectedPacket returns a NetPacket which declares a
+// ConnectionRejectedPacket with the given reason and detail.
+func CreateConnectionRejectedPacket(reason RejectionReason, detail string) (NetPacket, error) {
+	connectionRejected := ConnectionRejectedPacket{
+		Reason:          reason,
+		Detail:          detail,
+		ProtocolVersion: ProtocolVersion,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.ConnectionRejected, Packet: connectionRejected}, nil
+}
+
+// UnmarshalConnectionRejected unmarshals the given packet to a ConnectionRejectedPacket struct
+func UnmarshalConnectionRejected(packet NetPacket) (ConnectionRejectedPacket, error) {
+	var resp ConnectionRejectedPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
+
+// Message describes the reason of the rejection to the player.
+func (p ConnectionRejectedPacket) Message() string {
+	switch p.Reason {
+	case RejectedVersion:
+		return fmt.Sprintf("the server speaks protocol version %d, this client version %d",
+			p.ProtocolVersion, ProtocolVersion)
+	case RejectedFull:
+		return "the game is full"
+	case RejectedBanned:
+		return "you are banned from this server"
+	case RejectedPassword:
+		return "wrong password"
+	}
+
+	if p.Detail == "" {
+		return "the server refused the connection"
+	}
+
+	return p.Detail
+}
@@ -0,0 +1,43 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// CreateGamePacket is sent by a client to the lobby of a server to create a
+// game. The lobby answers with a GameCreatedPacket, and the client joins the
+// game with a PlayerConnectionRequestPacket on a new connection.
+// A game without a password can be joined by anyone. ServerPassword is the
+// password of a private server, on which only its players create games.
+type CreateGamePacket struct {
+	Name           string                `json:"name"`
+	Difficulty     d2enum.DifficultyType `json:"difficulty"`
+	Password       string                `json:"password,omitempty"`
+	MaxPlayers     int                   `json:"maxPlayers"`
+	ServerPassword string                `json:"serverPassword,omitempty"`
+}
+
+// CreateCreateGamePacket returns a NetPacket which declares a
+// CreateGamePacket with the given settings of the game and server password.
+func CreateCreateGamePacket(name string, difficulty d2enum.DifficultyType, password string,
+	maxPlayers int, serverPassword string) (NetPacket, error) {
+	createGame := CreateGamePacket{
+		Name:           name,
+		Difficulty:     difficulty,
+		Password:       password,
+		MaxPlayers:     maxPlayers,
+		ServerPassword: serverPassword,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.CreateGame, Packet: createGame}, nil
+}
+
+// UnmarshalCreateGame unmarshals the given packet to a CreateGamePacket struct
+func UnmarshalCreateGame(packet NetPacket) (CreateGamePacket, error) {
+	var resp CreateGamePacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
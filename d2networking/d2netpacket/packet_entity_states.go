@@ -0,0 +1,60 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
+)
+
+// EntityStatesPacket is sent by the server as it simulates its world. It
+// holds the entities near the player whose state differs from the baseline,
+// the last snapshot the client acknowledged, and the entities the client
+// must remove. See d2replication.
+type EntityStatesPacket struct {
+	Sequence  uint32                `json:"sequence"`
+	Baseline  uint32                `json:"baseline"`
+	States    []d2replication.Delta `json:"states"`
+	Despawned []uint32              `json:"despawned,omitempty"`
+}
+
+// CreateEntityStatesPacket returns a NetPacket which declares an
+// EntityStatesPacket with the given update.
+func CreateEntityStatesPacket(update d2replication.Update) (NetPacket, error) {
+	entityStates := EntityStatesPacket{
+		Sequence:  update.Sequence,
+		Baseline:  update.Baseline,
+		States:    update.Deltas,
+		Despawned: update.Despawned,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.EntityStates, Packet: entityStates}, nil
+}
+
+// UnmarshalEntityStates unmarshals the given packet to an EntityStatesPacket struct
+func UnmarshalEntityStates(packet NetPacket) (EntityStatesPacket, error) {
+	var resp EntityStatesPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
+
+// EntityStatesAckPacket is sent by the client for every EntityStatesPacket
+// it applied. The server sends the next states against that snapshot.
+type EntityStatesAckPacket struct {
+	Sequence uint32 `json:"sequence"`
+}
+
+// CreateEntityStatesAckPacket returns a NetPacket which declares an
+// EntityStatesAckPacket for the given snapshot.
+func CreateEntityStatesAckPacket(sequence uint32) (NetPacket, error) {
+	return NetPacket{PacketType: d2netpackettype.EntityStatesAck, Packet: EntityStatesAckPacket{Sequence: sequence}}, nil
+}
+
+// UnmarshalEntityStatesAck unmarshals the given packet to an EntityStatesAckPacket struct
+func UnmarshalEntityStatesAck(packet NetPacket) (EntityStatesAckPacket, error) {
+	var resp EntityStatesAckPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
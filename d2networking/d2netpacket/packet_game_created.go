@@ -0,0 +1,30 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// GameCreatedPacket is sent by the lobby of a server in response to a
+// CreateGamePacket. It contains the ID clients join the new game with.
+type GameCreatedPacket struct {
+	GameID string `json:"gameId"`
+}
+
+// CreateGameCreatedPacket returns a NetPacket which declares a
+// GameCreatedPacket with the given game ID.
+func CreateGameCreatedPacket(gameID string) (NetPacket, error) {
+	gameCreated := GameCreatedPacket{
+		GameID: gameID,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.GameCreated, Packet: gameCreated}, nil
+}
+
+// UnmarshalGameCreated unmarshals the given packet to a GameCreatedPacket struct
+func UnmarshalGameCreated(packet NetPacket) (GameCreatedPacket, error) {
+	var resp GameCreatedPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -0,0 +1,41 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// GameInfo describes a game hosted by the lobby of a server.
+type GameInfo struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Players     int                   `json:"players"`
+	MaxPlayers  int                   `json:"maxPlayers"`
+	Difficulty  d2enum.DifficultyType `json:"difficulty"`
+	HasPassword bool                  `json:"hasPassword"`
+}
+
+// GameListPacket is sent by the lobby of a server in response to a
+// ListGamesPacket. It lists the games a client can join.
+type GameListPacket struct {
+	Games []GameInfo `json:"games"`
+}
+
+// CreateGameListPacket returns a NetPacket which declares a GameListPacket
+// with the given games.
+func CreateGameListPacket(games []GameInfo) (NetPacket, error) {
+	gameList := GameListPacket{
+		Games: games,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.GameList, Packet: gameList}, nil
+}
+
+// UnmarshalGameList unmarshals the given packet to a GameListPacket struct
+func UnmarshalGameList(packet NetPacket) (GameListPacket, error) {
+	var resp GameListPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
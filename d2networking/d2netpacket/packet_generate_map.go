@@ -1,43 +1,39 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
-// GenerateMapPacket contains an enumerable representing a region. It
-// is sent by the server to generate the map for the given region on
-// a client.
+// GenerateMapPacket names the map a client generates: the overworld of an
+// act when LevelID is 0, or else the level. Each map has its own seed, and
+// the difficulty changes the size of some levels. It is sent again when the
+// player warps to another map.
 type GenerateMapPacket struct {
-	RegionType d2enum.RegionIdType `json:"regionType"`
+	Act        int                   `json:"act"`
+	LevelID    int                   `json:"levelId"`
+	Difficulty d2enum.DifficultyType `json:"difficulty"`
+	Seed       int64                 `json:"seed"`
 }
 
 // CreateGenerateMapPacket returns a NetPacket which declares a
-// GenerateMapPacket with the given regionType.
-func CreateGenerateMapPacket(regionType d2enum.RegionIdType) (NetPacket, error) {
+// GenerateMapPacket with the given act, level, difficulty and seed.
+func CreateGenerateMapPacket(act, levelID int, difficulty d2enum.DifficultyType, seed int64) (NetPacket, error) {
 	generateMapPacket := GenerateMapPacket{
-		RegionType: regionType,
-	}
-
-	b, err := json.Marshal(generateMapPacket)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.GenerateMap}, err
+		Act:        act,
+		LevelID:    levelID,
+		Difficulty: difficulty,
+		Seed:       seed,
 	}
 
-	return NetPacket{
-		PacketType: d2netpackettype.GenerateMap,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.GenerateMap, Packet: generateMapPacket}, nil
 }
 
 // UnmarshalGenerateMap unmarshals the given packet data into a GenerateMapPacket struct
-func UnmarshalGenerateMap(packet []byte) (GenerateMapPacket, error) {
+func UnmarshalGenerateMap(packet NetPacket) (GenerateMapPacket, error) {
 	var p GenerateMapPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
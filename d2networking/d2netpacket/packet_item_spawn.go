@@ -1,13 +1,14 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
-// SpawnItemPacket contains the data required to create a Item entity
+// SpawnItemPacket contains the data required to create a Item entity.
+// The network ID is zero when a client asks the server to spawn an item,
+// the server sets it when the item comes close to the player.
 type SpawnItemPacket struct {
+	NetID uint32   `json:"netId,omitempty"`
 	X     int      `json:"x"`
 	Y     int      `json:"y"`
 	Codes []string `json:"codes"`
@@ -15,30 +16,22 @@ type SpawnItemPacket struct {
 
 // CreateSpawnItemPacket returns a NetPacket which declares a
 // SpawnItemPacket with the data in given parameters.
-func CreateSpawnItemPacket(x, y int, codes ...string) (NetPacket, error) {
+func CreateSpawnItemPacket(netID uint32, x, y int, codes ...string) (NetPacket, error) {
 	spawnItemPacket := SpawnItemPacket{
+		NetID: netID,
 		X:     x,
 		Y:     y,
 		Codes: codes,
 	}
 
-	b, err := json.Marshal(spawnItemPacket)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.SpawnItem}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.SpawnItem,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.SpawnItem, Packet: spawnItemPacket}, nil
 }
 
-// UnmarshalSpawnItem unmarshals the given data to a SpawnItemPacket struct
-func UnmarshalSpawnItem(packet []byte) (SpawnItemPacket, error) {
+// UnmarshalSpawnItem unmarshals the given packet to a SpawnItemPacket struct
+func UnmarshalSpawnItem(packet NetPacket) (SpawnItemPacket, error) {
 	var p SpawnItemPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
@@ -0,0 +1,29 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// ListGamesPacket is sent by a client to the lobby of a server, which answers
+// with a GameListPacket and closes the connection. ServerPassword is the
+// password of a private server, which lists its games to its players only.
+type ListGamesPacket struct {
+	ServerPassword string `json:"serverPassword,omitempty"`
+}
+
+// CreateListGamesPacket returns a NetPacket which declares a ListGamesPacket
+// with the given server password.
+func CreateListGamesPacket(serverPassword string) (NetPacket, error) {
+	listGames := ListGamesPacket{ServerPassword: serverPassword}
+
+	return NetPacket{PacketType: d2netpackettype.ListGames, Packet: listGames}, nil
+}
+
+// UnmarshalListGames unmarshals the given packet to a ListGamesPacket struct
+func UnmarshalListGames(packet NetPacket) (ListGamesPacket, error) {
+	var resp ListGamesPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
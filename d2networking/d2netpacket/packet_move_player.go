@@ -1,13 +1,13 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
 // MovePlayerPacket contains a movement command for a specific player entity.
 // It is sent by the server to move a player entity on a client.
+// Sequence numbers the moves of a client. The server echoes accepted moves
+// with the same sequence, which acknowledges them to the moving client.
 // https://github.com/OpenDiablo2/OpenDiablo2/issues/825
 type MovePlayerPacket struct {
 	PlayerID string  `json:"playerId"`
@@ -15,36 +15,29 @@ type MovePlayerPacket struct {
 	StartY   float64 `json:"startY"`
 	DestX    float64 `json:"destX"`
 	DestY    float64 `json:"destY"`
+	Sequence uint32  `json:"sequence,omitempty"`
 }
 
 // CreateMovePlayerPacket returns a NetPacket which declares a MovePlayerPacket
-// with the given ID and movement command.
-func CreateMovePlayerPacket(playerID string, startX, startY, destX, destY float64) (NetPacket, error) {
+// with the given ID, movement command and sequence number.
+func CreateMovePlayerPacket(playerID string, startX, startY, destX, destY float64, sequence uint32) (NetPacket, error) {
 	movePlayerPacket := MovePlayerPacket{
 		PlayerID: playerID,
 		StartX:   startX,
 		StartY:   startY,
 		DestX:    destX,
 		DestY:    destY,
+		Sequence: sequence,
 	}
 
-	b, err := json.Marshal(movePlayerPacket)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.MovePlayer}, nil
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.MovePlayer,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.MovePlayer, Packet: movePlayerPacket}, nil
 }
 
-// UnmarshalMovePlayer unmarshals the given data to a MovePlayerPacket struct
-func UnmarshalMovePlayer(packet []byte) (MovePlayerPacket, error) {
+// UnmarshalMovePlayer unmarshals the given packet to a MovePlayerPacket struct
+func UnmarshalMovePlayer(packet NetPacket) (MovePlayerPacket, error) {
 	var p MovePlayerPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
@@ -0,0 +1,40 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// MovePlayerCorrectionPacket contains the position of a player entity as
+// known by the server. It is sent by the server when it rejects a
+// MovePlayerPacket, and instructs clients to place the player entity at the
+// given position. Sequence is the sequence number of the rejected move.
+type MovePlayerCorrectionPacket struct {
+	PlayerID string  `json:"playerId"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Sequence uint32  `json:"sequence,omitempty"`
+}
+
+// CreateMovePlayerCorrectionPacket returns a NetPacket which declares a
+// MovePlayerCorrectionPacket with the given ID, position in tiles and
+// sequence number of the rejected move.
+func CreateMovePlayerCorrectionPacket(playerID string, x, y float64, sequence uint32) (NetPacket, error) {
+	correction := MovePlayerCorrectionPacket{
+		PlayerID: playerID,
+		X:        x,
+		Y:        y,
+		Sequence: sequence,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.MovePlayerCorrection, Packet: correction}, nil
+}
+
+// UnmarshalMovePlayerCorrection unmarshals the given packet to a
+// MovePlayerCorrectionPacket struct
+func UnmarshalMovePlayerCorrection(packet NetPacket) (MovePlayerCorrectionPacket, error) {
+	var p MovePlayerCorrectionPacket
+
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
+}
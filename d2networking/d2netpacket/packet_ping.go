@@ -1,7 +1,6 @@
 package d2netpacket //nolint:dupl // ServerClosed and Ping just happen to be very similar packets
 
 import (
-	"encoding/json"
 	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
@@ -20,23 +19,14 @@ func CreatePingPacket() (NetPacket, error) {
 		TS: time.Now(),
 	}
 
-	b, err := json.Marshal(ping)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.Ping}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.Ping,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.Ping, Packet: ping}, nil
 }
 
-// UnmarshalPing unmarshals the given data to a PingPacket struct
-func UnmarshalPing(packet []byte) (PingPacket, error) {
+// UnmarshalPing unmarshals the given packet to a PingPacket struct
+func UnmarshalPing(packet NetPacket) (PingPacket, error) {
 	var p PingPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
@@ -1,8 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
@@ -28,23 +26,14 @@ func CreateCastPacket(entityID string, skillID int, targetX, targetY float64) (N
 		TargetEntityID: "", // https://github.com/OpenDiablo2/OpenDiablo2/issues/826
 	}
 
-	b, err := json.Marshal(castPacket)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.CastSkill}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.CastSkill,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.CastSkill, Packet: castPacket}, nil
 }
 
-// UnmarshalCast unmarshals the given data to a CastPacket struct
-func UnmarshalCast(packet []byte) (CastPacket, error) {
+// UnmarshalCast unmarshals the given packet to a CastPacket struct
+func UnmarshalCast(packet NetPacket) (CastPacket, error) {
 	var p CastPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
@@ -1,8 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
@@ -10,36 +8,52 @@ import (
 
 // PlayerConnectionRequestPacket contains a player ID and game state.
 // It is sent by a remote client to initiate a connection (join a game).
+// Codecs lists the wire codecs the client supports, see NegotiateCodec.
+// The request itself is always sent as JSON.
+// SessionToken is set when a client reconnects, to resume the session the
+// server issued in UpdateServerInfoPacket.
+// ProtocolVersion is the version of the protocol the client speaks, the server
+// rejects the request if it speaks another one.
+// GameID selects the game of a lobby the client joins, the lobby picks one
+// when it is empty. Password is the password of the game, if it has one.
+// ServerPassword is the password of a private server, which every player
+// needs to join any of its games.
 type PlayerConnectionRequestPacket struct {
-	ID          string            `json:"id"`
-	PlayerState *d2hero.HeroState `json:"gameState"`
+	ProtocolVersion int               `json:"protocolVersion"`
+	ID              string            `json:"id"`
+	PlayerState     *d2hero.HeroState `json:"gameState"`
+	Codecs          []CodecType       `json:"codecs,omitempty"`
+	SessionToken    string            `json:"sessionToken,omitempty"`
+	GameID          string            `json:"gameId,omitempty"`
+	Password        string            `json:"password,omitempty"`
+	ServerPassword  string            `json:"serverPassword,omitempty"`
 }
 
 // CreatePlayerConnectionRequestPacket returns a NetPacket which defines a
-// PlayerConnectionRequestPacket with the given ID and game state.
-func CreatePlayerConnectionRequestPacket(id string, playerState *d2hero.HeroState) (NetPacket, error) {
+// PlayerConnectionRequestPacket with the given ID, game state, game, passwords
+// and session token. The token is empty when joining a game for the first time.
+func CreatePlayerConnectionRequestPacket(id string, playerState *d2hero.HeroState,
+	gameID, password, serverPassword, sessionToken string) (NetPacket, error) {
 	playerConnectionRequest := PlayerConnectionRequestPacket{
-		ID:          id,
-		PlayerState: playerState,
-	}
-
-	b, err := json.Marshal(playerConnectionRequest)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.PlayerConnectionRequest}, err
+		ProtocolVersion: ProtocolVersion,
+		ID:              id,
+		PlayerState:     playerState,
+		Codecs:          SupportedCodecs(),
+		SessionToken:    sessionToken,
+		GameID:          gameID,
+		Password:        password,
+		ServerPassword:  serverPassword,
 	}
 
-	return NetPacket{
-		PacketType: d2netpackettype.PlayerConnectionRequest,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.PlayerConnectionRequest, Packet: playerConnectionRequest}, nil
 }
 
-// UnmarshalPlayerConnectionRequest unmarshals the given data to a
+// UnmarshalPlayerConnectionRequest unmarshals the given packet to a
 // PlayerConnectionRequestPacket struct
-func UnmarshalPlayerConnectionRequest(packet []byte) (PlayerConnectionRequestPacket, error) {
+func UnmarshalPlayerConnectionRequest(packet NetPacket) (PlayerConnectionRequestPacket, error) {
 	var resp PlayerConnectionRequestPacket
 
-	if err := json.Unmarshal(packet, &resp); err != nil {
+	if err := unmarshalPacket(packet, &resp); err != nil {
 		return PlayerConnectionRequestPacket{}, err
 	}
 
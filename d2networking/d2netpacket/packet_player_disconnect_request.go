@@ -1,8 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
@@ -22,25 +20,15 @@ func CreatePlayerDisconnectRequestPacket(id string) (NetPacket, error) {
 		ID: id,
 	}
 
-	b, err := json.Marshal(playerDisconnectRequest)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.PlayerDisconnectionNotification}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.PlayerDisconnectionNotification,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.PlayerDisconnectionNotification, Packet: playerDisconnectRequest}, nil
 }
 
-// UnmarshalPlayerDisconnectionRequest unmarshals the given data to a
+// UnmarshalPlayerDisconnectionRequest unmarshals the given packet to a
 // PlayerDisconnectRequestPacket struct
-func UnmarshalPlayerDisconnectionRequest(packet []byte) (PlayerDisconnectRequestPacket, error) {
+func UnmarshalPlayerDisconnectionRequest(packet NetPacket) (PlayerDisconnectRequestPacket, error) {
 	var resp PlayerDisconnectRequestPacket
 
-	if err := json.Unmarshal(packet, &resp); err != nil {
-		return resp, err
-	}
+	err := unmarshalPacket(packet, &resp)
 
-	return resp, nil
+	return resp, err
 }
@@ -0,0 +1,35 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// PlayerWarpPacket is sent by the server to the clients on the map a player
+// left through a warp, to remove the player. It names the map the player
+// went to: the overworld of an act when LevelID is 0, or else the level.
+type PlayerWarpPacket struct {
+	PlayerID string `json:"playerId"`
+	Act      int    `json:"act"`
+	LevelID  int    `json:"levelId"`
+}
+
+// CreatePlayerWarpPacket returns a NetPacket which declares a
+// PlayerWarpPacket with the given player, act and level.
+func CreatePlayerWarpPacket(playerID string, act, levelID int) (NetPacket, error) {
+	playerWarp := PlayerWarpPacket{
+		PlayerID: playerID,
+		Act:      act,
+		LevelID:  levelID,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.PlayerWarp, Packet: playerWarp}, nil
+}
+
+// UnmarshalPlayerWarp unmarshals the given packet to a PlayerWarpPacket struct
+func UnmarshalPlayerWarp(packet NetPacket) (PlayerWarpPacket, error) {
+	var p PlayerWarpPacket
+
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
+}
@@ -1,7 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
 	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
@@ -22,24 +21,14 @@ func CreatePongPacket(id string) (NetPacket, error) {
 		TS: time.Now(),
 	}
 
-	b, err := json.Marshal(pong)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.Pong}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.Pong,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.Pong, Packet: pong}, nil
 }
 
-// UnmarshalPong unmarshals the given data to a PongPacket struct
-func UnmarshalPong(packet []byte) (PongPacket, error) {
+// UnmarshalPong unmarshals the given packet to a PongPacket struct
+func UnmarshalPong(packet NetPacket) (PongPacket, error) {
 	var resp PongPacket
 
-	if err := json.Unmarshal(packet, &resp); err != nil {
-		return resp, err
-	}
+	err := unmarshalPacket(packet, &resp)
 
-	return resp, nil
+	return resp, err
 }
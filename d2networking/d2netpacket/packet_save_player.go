@@ -1,8 +1,6 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
@@ -23,23 +21,14 @@ func CreateSavePlayerPacket(playerState *d2mapentity.Player, difficulty d2enum.D
 		Difficulty: difficulty,
 	}
 
-	b, err := json.Marshal(savePlayerData)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.SavePlayer}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.SavePlayer,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.SavePlayer, Packet: savePlayerData}, nil
 }
 
-// UnmarshalSavePlayer unmarshalls the given data to a SavePlayerPacket struct
-func UnmarshalSavePlayer(packet []byte) (SavePlayerPacket, error) {
+// UnmarshalSavePlayer unmarshals the given packet to a SavePlayerPacket struct
+func UnmarshalSavePlayer(packet NetPacket) (SavePlayerPacket, error) {
 	var p SavePlayerPacket
-	if err := json.Unmarshal(packet, &p); err != nil {
-		return p, err
-	}
 
-	return p, nil
+	err := unmarshalPacket(packet, &p)
+
+	return p, err
 }
@@ -1,7 +1,6 @@
 package d2netpacket //nolint:dupl // ServerClosed and Ping just happen to be very similar packets
 
 import (
-	"encoding/json"
 	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
@@ -20,24 +19,14 @@ func CreateServerClosedPacket() (NetPacket, error) {
 		TS: time.Now(),
 	}
 
-	b, err := json.Marshal(serverClosed)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.ServerClosed}, err
-	}
-
-	return NetPacket{
-		PacketType: d2netpackettype.ServerClosed,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.ServerClosed, Packet: serverClosed}, nil
 }
 
-// UnmarshalServerClosed unmarshals the given data to a ServerClosedPacket struct
-func UnmarshalServerClosed(packet []byte) (ServerClosedPacket, error) {
+// UnmarshalServerClosed unmarshals the given packet to a ServerClosedPacket struct
+func UnmarshalServerClosed(packet NetPacket) (ServerClosedPacket, error) {
 	var resp ServerClosedPacket
 
-	if err := json.Unmarshal(packet, &resp); err != nil {
-		return resp, err
-	}
+	err := unmarshalPacket(packet, &resp)
 
-	return resp, nil
+	return resp, err
 }
@@ -0,0 +1,39 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// SpawnMissilePacket is sent by the server when a missile comes close to
+// the player. The missile flies in the direction given in radians, until
+// it reaches its range. X and Y are in sub tiles.
+type SpawnMissilePacket struct {
+	NetID     uint32  `json:"netId"`
+	MissileID int     `json:"missileId"`
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Radians   float64 `json:"radians"`
+}
+
+// CreateSpawnMissilePacket returns a NetPacket which declares a
+// SpawnMissilePacket with the given missile.
+func CreateSpawnMissilePacket(netID uint32, missileID, x, y int, radians float64) (NetPacket, error) {
+	spawnMissile := SpawnMissilePacket{
+		NetID:     netID,
+		MissileID: missileID,
+		X:         x,
+		Y:         y,
+		Radians:   radians,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.SpawnMissile, Packet: spawnMissile}, nil
+}
+
+// UnmarshalSpawnMissile unmarshals the given packet to a SpawnMissilePacket struct
+func UnmarshalSpawnMissile(packet NetPacket) (SpawnMissilePacket, error) {
+	var resp SpawnMissilePacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -0,0 +1,37 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// SpawnNPCPacket is sent by the server when an NPC, such as a summoned
+// monster, comes close to the player. MonStat is the key of the monster
+// in monstats.txt. X and Y are in sub tiles.
+type SpawnNPCPacket struct {
+	NetID   uint32 `json:"netId"`
+	MonStat string `json:"monStat"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+}
+
+// CreateSpawnNPCPacket returns a NetPacket which declares a SpawnNPCPacket
+// with the given NPC.
+func CreateSpawnNPCPacket(netID uint32, monStat string, x, y int) (NetPacket, error) {
+	spawnNPC := SpawnNPCPacket{
+		NetID:   netID,
+		MonStat: monStat,
+		X:       x,
+		Y:       y,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.SpawnNPC, Packet: spawnNPC}, nil
+}
+
+// UnmarshalSpawnNPC unmarshals the given packet to a SpawnNPCPacket struct
+func UnmarshalSpawnNPC(packet NetPacket) (SpawnNPCPacket, error) {
+	var resp SpawnNPCPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -0,0 +1,30 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// SystemMessagePacket is sent by the server to show a message, such as an
+// announcement of the server operator, to the players.
+type SystemMessagePacket struct {
+	Message string `json:"message"`
+}
+
+// CreateSystemMessagePacket returns a NetPacket which declares a
+// SystemMessagePacket with the given message.
+func CreateSystemMessagePacket(message string) (NetPacket, error) {
+	systemMessage := SystemMessagePacket{
+		Message: message,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.SystemMessage, Packet: systemMessage}, nil
+}
+
+// UnmarshalSystemMessage unmarshals the given packet to a SystemMessagePacket struct
+func UnmarshalSystemMessage(packet NetPacket) (SystemMessagePacket, error) {
+	var resp SystemMessagePacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -1,44 +1,44 @@
 package d2netpacket
 
 import (
-	"encoding/json"
-
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
 // UpdateServerInfoPacket contains the ID for a player and the map seed.
 // It is sent by the server to synchronize these values on the client.
+// Codec is the wire codec the server has chosen for the connection. This
+// packet is always sent as JSON, both sides use the codec for every
+// packet after it.
+// SessionToken lets the client resume its session after the connection drops.
+// Resumed is set if the server has resumed an existing session.
 type UpdateServerInfoPacket struct {
-	Seed     int64  `json:"seed"`
-	PlayerID string `json:"playerId"`
+	Seed         int64     `json:"seed"`
+	PlayerID     string    `json:"playerId"`
+	Codec        CodecType `json:"codec,omitempty"`
+	SessionToken string    `json:"sessionToken,omitempty"`
+	Resumed      bool      `json:"resumed,omitempty"`
 }
 
 // CreateUpdateServerInfoPacket returns a NetPacket which declares an
-// UpdateServerInfoPacket with the given player ID and map seed.
-func CreateUpdateServerInfoPacket(seed int64, playerID string) (NetPacket, error) {
+// UpdateServerInfoPacket with the given player ID, map seed, codec and session.
+func CreateUpdateServerInfoPacket(seed int64, playerID string, codec CodecType, sessionToken string,
+	resumed bool) (NetPacket, error) {
 	updateServerInfo := UpdateServerInfoPacket{
-		Seed:     seed,
-		PlayerID: playerID,
-	}
-
-	b, err := json.Marshal(updateServerInfo)
-	if err != nil {
-		return NetPacket{PacketType: d2netpackettype.UpdateServerInfo}, err
+		Seed:         seed,
+		PlayerID:     playerID,
+		Codec:        codec,
+		SessionToken: sessionToken,
+		Resumed:      resumed,
 	}
 
-	return NetPacket{
-		PacketType: d2netpackettype.UpdateServerInfo,
-		PacketData: b,
-	}, nil
+	return NetPacket{PacketType: d2netpackettype.UpdateServerInfo, Packet: updateServerInfo}, nil
 }
 
-// UnmarshalUpdateServerInfo unmarshals the data to a UpdateServerInfoPacket struct
-func UnmarshalUpdateServerInfo(packet []byte) (UpdateServerInfoPacket, error) {
+// UnmarshalUpdateServerInfo unmarshals the given packet to a UpdateServerInfoPacket struct
+func UnmarshalUpdateServerInfo(packet NetPacket) (UpdateServerInfoPacket, error) {
 	var resp UpdateServerInfoPacket
 
-	if err := json.Unmarshal(packet, &resp); err != nil {
-		return resp, err
-	}
+	err := unmarshalPacket(packet, &resp)
 
-	return resp, nil
+	return resp, err
 }
@@ -0,0 +1,38 @@
+package d2netpacket
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// WhisperPacket contains a private message. The server sends it to both
+// the sender and the recipient.
+type WhisperPacket struct {
+	FromID   string `json:"fromId"`
+	FromName string `json:"fromName"`
+	ToID     string `json:"toId"`
+	ToName   string `json:"toName"`
+	Message  string `json:"message"`
+}
+
+// CreateWhisperPacket returns a NetPacket which declares a WhisperPacket
+// from one player to another.
+func CreateWhisperPacket(fromID, fromName, toID, toName, message string) (NetPacket, error) {
+	whisper := WhisperPacket{
+		FromID:   fromID,
+		FromName: fromName,
+		ToID:     toID,
+		ToName:   toName,
+		Message:  message,
+	}
+
+	return NetPacket{PacketType: d2netpackettype.Whisper, Packet: whisper}, nil
+}
+
+// UnmarshalWhisper unmarshals the given packet to a WhisperPacket struct
+func UnmarshalWhisper(packet NetPacket) (WhisperPacket, error) {
+	var resp WhisperPacket
+
+	err := unmarshalPacket(packet, &resp)
+
+	return resp, err
+}
@@ -0,0 +1,101 @@
+// Package d2netsim simulates bad network conditions, such as latency and
+// packet loss, between game clients and servers.
+package d2netsim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const percent = 100
+
+var errBadCondition = errors.New("bad network condition")
+
+// Conditions describe the network to simulate. Probabilities are between 0 and 1.
+type Conditions struct {
+	Latency   time.Duration // Delay added to every packet
+	Jitter    time.Duration // Random variation of the latency, in both directions
+	Loss      float64       // Probability of a packet being dropped
+	Duplicate float64       // Probability of a packet being delivered twice
+	Reorder   float64       // Probability of a packet being held back so later packets overtake it
+}
+
+// Enabled returns true if the conditions differ from a perfect network.
+func (c Conditions) Enabled() bool {
+	return c != Conditions{}
+}
+
+// String returns the conditions in the format read by ParseConditions.
+func (c Conditions) String() string {
+	return fmt.Sprintf("latency=%s,jitter=%s,loss=%g,dup=%g,reorder=%g",
+		c.Latency, c.Jitter, c.Loss, c.Duplicate, c.Reorder)
+}
+
+// ParseConditions reads conditions from a comma separated list of key=value
+// pairs, for example "latency=100ms,jitter=20ms,loss=5%,dup=0.01,reorder=2%".
+// Durations use the time.ParseDuration format and probabilities are either a
+// fraction or a percentage. Keys which are left out are zero.
+func ParseConditions(s string) (Conditions, error) {
+	var c Conditions
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 { //nolint:gomnd // key and value
+			return c, fmt.Errorf("%w: %q is not key=value", errBadCondition, pair)
+		}
+
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		var err error
+
+		switch key {
+		case "latency":
+			c.Latency, err = time.ParseDuration(value)
+		case "jitter":
+			c.Jitter, err = time.ParseDuration(value)
+		case "loss":
+			c.Loss, err = parseProbability(value)
+		case "dup", "duplicate":
+			c.Duplicate, err = parseProbability(value)
+		case "reorder":
+			c.Reorder, err = parseProbability(value)
+		default:
+			return c, fmt.Errorf("%w: unknown key %q", errBadCondition, key)
+		}
+
+		if err != nil {
+			return c, fmt.Errorf("%w: %s: %v", errBadCondition, key, err)
+		}
+	}
+
+	return c, nil
+}
+
+func parseProbability(s string) (float64, error) {
+	divisor := 1.0
+
+	if strings.HasSuffix(s, "%") {
+		s, divisor = strings.TrimSuffix(s, "%"), percent
+	}
+
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	p /= divisor
+
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("%g is not between 0 and 1", p)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,187 @@
+package d2netsim
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Link delivers packets in one direction under the simulated conditions.
+// Deliveries run one at a time, on the goroutine of the link.
+type Link struct {
+	conditions Conditions
+	rand       *rand.Rand
+
+	mutex    sync.Mutex
+	queue    deliveryQueue
+	lastAt   time.Time
+	count    uint64
+	wake     chan struct{}
+	done     chan struct{}
+	closeOne sync.Once
+}
+
+type delivery struct {
+	at      time.Time
+	order   uint64
+	deliver func()
+}
+
+// NewLink creates a Link with the given conditions. The seed makes the
+// simulated loss, duplication and reordering repeatable.
+func NewLink(conditions Conditions, seed int64) *Link {
+	l := &Link{
+		conditions: conditions,
+		rand:       rand.New(rand.NewSource(seed)), //nolint:gosec // simulation, not security
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	go l.run()
+
+	return l
+}
+
+// Send schedules deliver to be called once the packet arrives. It is not
+// called if the packet is lost, and called twice if it is duplicated.
+func (l *Link) Send(deliver func()) {
+	l.mutex.Lock()
+
+	if l.rand.Float64() < l.conditions.Loss {
+		l.mutex.Unlock()
+		return
+	}
+
+	copies := 1
+	if l.rand.Float64() < l.conditions.Duplicate {
+		copies++
+	}
+
+	now := time.Now()
+
+	for i := 0; i < copies; i++ {
+		at := now.Add(l.delay())
+
+		if l.rand.Float64() < l.conditions.Reorder {
+			// held back, later packets overtake it
+			at = at.Add(l.conditions.Latency + l.conditions.Jitter)
+		} else {
+			// jitter alone does not reorder packets
+			if at.Before(l.lastAt) {
+				at = l.lastAt
+			}
+
+			l.lastAt = at
+		}
+
+		l.count++
+		heap.Push(&l.queue, &delivery{at: at, order: l.count, deliver: deliver})
+	}
+
+	l.mutex.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the link. Packets which have not arrived yet are dropped.
+func (l *Link) Close() {
+	l.closeOne.Do(func() {
+		close(l.done)
+	})
+}
+
+// delay returns the latency of a packet, varied by the jitter.
+func (l *Link) delay() time.Duration {
+	d := l.conditions.Latency
+
+	if l.conditions.Jitter > 0 {
+		d += time.Duration(l.rand.Int63n(2*int64(l.conditions.Jitter)+1)) - l.conditions.Jitter
+	}
+
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+func (l *Link) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		l.mutex.Lock()
+
+		var next *delivery
+
+		wait := time.Hour
+
+		if len(l.queue) > 0 {
+			if first := l.queue[0]; !time.Now().Before(first.at) {
+				next = heap.Pop(&l.queue).(*delivery)
+			} else {
+				wait = time.Until(first.at)
+			}
+		}
+
+		l.mutex.Unlock()
+
+		if next != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+			}
+
+			next.deliver()
+
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-l.done:
+			return
+		case <-l.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// deliveryQueue orders deliveries by arrival time, then by the order they were sent.
+type deliveryQueue []*delivery
+
+func (q deliveryQueue) Len() int { return len(q) }
+
+func (q deliveryQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].order < q[j].order
+	}
+
+	return q[i].at.Before(q[j].at)
+}
+
+func (q deliveryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *deliveryQueue) Push(x interface{}) { *q = append(*q, x.(*delivery)) }
+
+func (q *deliveryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+
+	return item
+}
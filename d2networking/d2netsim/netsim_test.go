@@ -0,0 +1,162 @@
+package d2netsim
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseConditions(t *testing.T) {
+	c, err := ParseConditions("latency=100ms, jitter=20ms,loss=5%,dup=0.01,reorder=2%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Conditions{
+		Latency:   100 * time.Millisecond,
+		Jitter:    20 * time.Millisecond,
+		Loss:      0.05,
+		Duplicate: 0.01,
+		Reorder:   0.02,
+	}
+
+	if c != want {
+		t.Errorf("expected %v, got %v", want, c)
+	}
+
+	parsed, err := ParseConditions(c.String())
+	if err != nil || parsed != c {
+		t.Errorf("expected %v to parse back, got %v (%v)", c, parsed, err)
+	}
+
+	for _, bad := range []string{"latency", "loss=2", "speed=1", "jitter=fast"} {
+		if _, err := ParseConditions(bad); err == nil {
+			t.Errorf("expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestLinkKeepsOrderWithJitter(t *testing.T) {
+	link := NewLink(Conditions{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond}, 1)
+	defer link.Close()
+
+	const count = 50
+
+	received := make(chan int, count)
+
+	for i := 0; i < count; i++ {
+		i := i
+		link.Send(func() { received <- i })
+	}
+
+	for want := 0; want < count; want++ {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("expected packet %d, got %d", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("packet %d did not arrive", want)
+		}
+	}
+}
+
+// loopback returns two UDP sockets on the loopback interface.
+func loopback(t *testing.T) (sender, receiver net.PacketConn) {
+	sender, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+
+	receiver, err = net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		sender.Close()
+		t.Skipf("no loopback interface: %v", err)
+	}
+
+	return sender, receiver
+}
+
+func countDatagrams(t *testing.T, conn net.PacketConn, wait time.Duration) int {
+	buf := make([]byte, 64)
+	count := 0
+
+	if err := conn.SetReadDeadline(time.Now().Add(wait)); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		if _, _, err := conn.ReadFrom(buf); err != nil {
+			return count
+		}
+
+		count++
+	}
+}
+
+func TestPacketConnLatency(t *testing.T) {
+	sender, receiver := loopback(t)
+	defer receiver.Close()
+
+	const latency = 50 * time.Millisecond
+
+	conn := NewPacketConn(sender, Conditions{Latency: latency}, 1)
+	defer conn.Close()
+
+	start := time.Now()
+
+	if _, err := conn.WriteTo([]byte("hello"), receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := receiver.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+
+	n, _, err := receiver.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf[:n]) != "hello" {
+		t.Errorf("expected hello, got %q", buf[:n])
+	}
+
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("expected the datagram to take at least %s, it took %s", latency, elapsed)
+	}
+}
+
+func TestPacketConnLossAndDuplication(t *testing.T) {
+	sender, receiver := loopback(t)
+	defer receiver.Close()
+
+	lossy := NewPacketConn(sender, Conditions{Loss: 1}, 1)
+
+	for i := 0; i < 10; i++ {
+		if _, err := lossy.WriteTo([]byte{byte(i)}, receiver.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countDatagrams(t, receiver, 100*time.Millisecond); n != 0 {
+		t.Errorf("expected every datagram to be lost, %d arrived", n)
+	}
+
+	lossy.link.Close()
+
+	duplicating := &PacketConn{PacketConn: sender, link: NewLink(Conditions{Duplicate: 1}, 1)}
+	defer duplicating.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := duplicating.WriteTo([]byte{byte(i)}, receiver.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countDatagrams(t, receiver, 100*time.Millisecond); n != 20 {
+		t.Errorf("expected every datagram to arrive twice, %d arrived", n)
+	}
+}
@@ -0,0 +1,40 @@
+package d2netsim
+
+import (
+	"net"
+)
+
+// PacketConn is a net.PacketConn whose outgoing datagrams are sent under the
+// simulated conditions.
+type PacketConn struct {
+	net.PacketConn
+	link *Link
+}
+
+// NewPacketConn wraps the connection so datagrams written to it are delayed,
+// dropped, duplicated and reordered according to the conditions.
+func NewPacketConn(conn net.PacketConn, conditions Conditions, seed int64) *PacketConn {
+	return &PacketConn{
+		PacketConn: conn,
+		link:       NewLink(conditions, seed),
+	}
+}
+
+// WriteTo schedules the datagram to be written to addr. Errors of the delayed
+// write are not reported, as with a datagram lost on the network.
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	datagram := append([]byte(nil), p...)
+
+	c.link.Send(func() {
+		_, _ = c.PacketConn.WriteTo(datagram, addr)
+	})
+
+	return len(p), nil
+}
+
+// Close stops the simulation and closes the connection.
+func (c *PacketConn) Close() error {
+	c.link.Close()
+
+	return c.PacketConn.Close()
+}
@@ -0,0 +1,66 @@
+package d2replication
+
+import "fmt"
+
+// Receiver rebuilds, on a client, the snapshots the server sends as updates against
+// a baseline.
+type Receiver struct {
+	snapshots map[uint32]Snapshot // by sequence number, from the latest baseline on
+	spawned   map[uint32]bool
+}
+
+// NewReceiver creates a Receiver for a client which has no entities yet.
+func NewReceiver() *Receiver {
+	return &Receiver{
+		snapshots: map[uint32]Snapshot{0: {}},
+		spawned:   make(map[uint32]bool),
+	}
+}
+
+// Spawn adds an entity the server spawned on the client. Its state follows in the next update.
+func (r *Receiver) Spawn(netID uint32) {
+	r.spawned[netID] = true
+}
+
+// Apply rebuilds the snapshot of an update from its baseline, and returns the states of the
+// entities which changed. The entities the update despawns are removed.
+func (r *Receiver) Apply(sequence, baseline uint32, deltas []Delta, despawned []uint32) (Snapshot, error) {
+	base, found := r.snapshots[baseline]
+	if !found {
+		return nil, fmt.Errorf("unknown baseline snapshot %d", baseline)
+	}
+
+	for _, netID := range despawned {
+		delete(r.spawned, netID)
+	}
+
+	snapshot := make(Snapshot, len(r.spawned))
+
+	for netID := range r.spawned {
+		if state, found := base[netID]; found {
+			snapshot[netID] = state
+		}
+	}
+
+	changed := make(Snapshot, len(deltas))
+
+	for _, delta := range deltas {
+		if !r.spawned[delta.NetID] {
+			continue
+		}
+
+		state := delta.Apply(snapshot[delta.NetID])
+		snapshot[delta.NetID] = state
+		changed[delta.NetID] = state
+	}
+
+	r.snapshots[sequence] = snapshot
+
+	for seq := range r.snapshots {
+		if seq < baseline {
+			delete(r.snapshots, seq)
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,114 @@
+// Package d2replication decides which entities of the world the client of a player
+// is sent, and compresses their states against the last snapshot the client
+// acknowledged.
+//
+// The server gives every entity it replicates a network ID, which is the same on
+// every client. A client is only sent the entities close to its player: they are
+// spawned on the client as they come close and despawned as they leave. Each update
+// carries the fields of the entity states which differ from the baseline, the last
+// snapshot the client acknowledged, so entities which did not change cost nothing.
+package d2replication
+
+import "math"
+
+const subtilesPerTile = 5
+
+const (
+	// SpawnRadius is how close, in sub tiles, an entity must come to a player before
+	// it is spawned on the client of the player.
+	SpawnRadius = 30 * subtilesPerTile
+
+	// DespawnRadius is how far, in sub tiles, a spawned entity must go before it is
+	// despawned. It is larger than SpawnRadius, so an entity on the edge does not
+	// spawn and despawn over and over.
+	DespawnRadius = 36 * subtilesPerTile
+)
+
+// Interested returns true if the client of a player should have an entity at the given
+// distance, in sub tiles, from the player. Spawned tells if the client has it already.
+func Interested(spawned bool, distance float64) bool {
+	if spawned {
+		return distance <= DespawnRadius
+	}
+
+	return distance <= SpawnRadius
+}
+
+// State is the state of an entity. The entity moves from its position towards its
+// target. Positions are in sub tiles.
+type State struct {
+	X, Y             float64
+	TargetX, TargetY float64
+}
+
+// Distance returns the distance between the position of the entity and the given point.
+func (s State) Distance(x, y float64) float64 {
+	return math.Hypot(s.X-x, s.Y-y)
+}
+
+// Fields is a set of the parts of a State.
+type Fields uint8
+
+// The parts of a State, which are sent when they changed.
+const (
+	FieldPosition Fields = 1 << iota
+	FieldTarget
+
+	AllFields = FieldPosition | FieldTarget
+)
+
+// Delta carries the fields of the state of an entity which differ from the baseline.
+// The other fields are zero.
+type Delta struct {
+	NetID   uint32  `json:"id"`
+	Fields  Fields  `json:"fields"`
+	X       float64 `json:"x,omitempty"`
+	Y       float64 `json:"y,omitempty"`
+	TargetX float64 `json:"targetX,omitempty"`
+	TargetY float64 `json:"targetY,omitempty"`
+}
+
+// diff returns the fields of current which differ from base.
+func diff(base, current State) Fields {
+	var fields Fields
+
+	if base.X != current.X || base.Y != current.Y {
+		fields |= FieldPosition
+	}
+
+	if base.TargetX != current.TargetX || base.TargetY != current.TargetY {
+		fields |= FieldTarget
+	}
+
+	return fields
+}
+
+func newDelta(netID uint32, fields Fields, state State) Delta {
+	delta := Delta{NetID: netID, Fields: fields}
+
+	if fields&FieldPosition != 0 {
+		delta.X, delta.Y = state.X, state.Y
+	}
+
+	if fields&FieldTarget != 0 {
+		delta.TargetX, delta.TargetY = state.TargetX, state.TargetY
+	}
+
+	return delta
+}
+
+// Apply returns the state with the fields of the delta applied to it.
+func (d Delta) Apply(base State) State {
+	if d.Fields&FieldPosition != 0 {
+		base.X, base.Y = d.X, d.Y
+	}
+
+	if d.Fields&FieldTarget != 0 {
+		base.TargetX, base.TargetY = d.TargetX, d.TargetY
+	}
+
+	return base
+}
+
+// Snapshot holds the states of the entities a client has, by network ID.
+type Snapshot map[uint32]State
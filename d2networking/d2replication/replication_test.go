@@ -0,0 +1,116 @@
+package d2replication
+
+import (
+	"testing"
+)
+
+// deliver applies an update to the receiver the way a client does.
+func deliver(t *testing.T, r *Receiver, update Update) Snapshot {
+	t.Helper()
+
+	for _, netID := range update.Spawned {
+		r.Spawn(netID)
+	}
+
+	changed, err := r.Apply(update.Sequence, update.Baseline, update.Deltas, update.Despawned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return changed
+}
+
+func TestInterestedHysteresis(t *testing.T) {
+	between := float64(SpawnRadius+DespawnRadius) / 2
+
+	if Interested(false, between) {
+		t.Error("expected an entity between the radiuses not to spawn")
+	}
+
+	if !Interested(true, between) {
+		t.Error("expected a spawned entity between the radiuses not to despawn")
+	}
+
+	if Interested(true, DespawnRadius+1) {
+		t.Error("expected an entity beyond the despawn radius to despawn")
+	}
+}
+
+func TestSenderSendsOnlyChangesAgainstAcknowledged(t *testing.T) {
+	sender, receiver := NewSender(), NewReceiver()
+
+	world := Snapshot{
+		1: {X: 10, Y: 10, TargetX: 20, TargetY: 10},
+		2: {X: 50, Y: 50, TargetX: 50, TargetY: 50},
+	}
+
+	first, ok := sender.Next(world)
+	if !ok || len(first.Spawned) != 2 || len(first.Deltas) != 2 {
+		t.Fatalf("expected both entities to be spawned with their states, got %+v", first)
+	}
+
+	deliver(t, receiver, first)
+
+	// not acknowledged yet, so the states are sent again in full
+	if update, ok := sender.Next(world); !ok || len(update.Deltas) != 2 || update.Baseline != 0 {
+		t.Fatalf("expected the full states against no baseline, got %+v", update)
+	} else {
+		deliver(t, receiver, update)
+		sender.Acknowledge(update.Sequence)
+	}
+
+	if update, ok := sender.Next(world); ok {
+		t.Fatalf("expected no update for an acknowledged world, got %+v", update)
+	}
+
+	world[1] = State{X: 10, Y: 10, TargetX: 10, TargetY: 30}
+
+	update, ok := sender.Next(world)
+	if !ok || len(update.Deltas) != 1 {
+		t.Fatalf("expected one delta, got %+v", update)
+	}
+
+	if delta := update.Deltas[0]; delta.NetID != 1 || delta.Fields != FieldTarget {
+		t.Fatalf("expected only the target of entity 1, got %+v", delta)
+	}
+
+	changed := deliver(t, receiver, update)
+	if changed[1] != world[1] {
+		t.Errorf("expected %+v, got %+v", world[1], changed[1])
+	}
+}
+
+func TestSenderDespawnAndRespawn(t *testing.T) {
+	sender, receiver := NewSender(), NewReceiver()
+
+	world := Snapshot{7: {X: 1, Y: 2, TargetX: 1, TargetY: 2}}
+
+	update, _ := sender.Next(world)
+	deliver(t, receiver, update)
+	sender.Acknowledge(update.Sequence)
+
+	update, ok := sender.Next(Snapshot{})
+	if !ok || len(update.Despawned) != 1 || update.Despawned[0] != 7 {
+		t.Fatalf("expected entity 7 to be despawned, got %+v", update)
+	}
+
+	deliver(t, receiver, update)
+
+	// respawned unchanged before the despawn was acknowledged, the state must still be sent
+	update, ok = sender.Next(world)
+	if !ok || len(update.Spawned) != 1 || len(update.Deltas) != 1 || update.Deltas[0].Fields != AllFields {
+		t.Fatalf("expected entity 7 to be spawned with its full state, got %+v", update)
+	}
+
+	if changed := deliver(t, receiver, update); changed[7] != world[7] {
+		t.Errorf("expected %+v, got %+v", world[7], changed[7])
+	}
+}
+
+func TestReceiverUnknownBaseline(t *testing.T) {
+	receiver := NewReceiver()
+
+	if _, err := receiver.Apply(5, 4, nil, nil); err == nil {
+		t.Error("expected an error for an unknown baseline")
+	}
+}
@@ -0,0 +1,139 @@
+package d2replication
+
+import "sort"
+
+// maxPendingSnapshots is how many snapshots the client has not acknowledged are kept.
+// A client which falls further behind acknowledges one it was sent later.
+const maxPendingSnapshots = 64
+
+// Update is a snapshot of the entities for a client, compressed against its baseline.
+type Update struct {
+	Sequence  uint32   // Sequence number of the snapshot, which the client acknowledges
+	Baseline  uint32   // Sequence number of the snapshot the deltas are against, 0 if none
+	Spawned   []uint32 // Entities the client must be sent before the update
+	Deltas    []Delta  // Entities whose state differs from the baseline
+	Despawned []uint32 // Entities the client must remove
+}
+
+// Sender keeps track of what the client of one player has: the entities spawned on it,
+// the snapshots it was sent, and the last snapshot it acknowledged.
+type Sender struct {
+	sequence  uint32
+	acked     uint32
+	snapshots map[uint32]Snapshot // by sequence number, from the acknowledged one on
+	spawned   map[uint32]uint32   // sequence number of the update each entity was spawned in
+}
+
+// NewSender creates a Sender for a client which has no entities yet.
+func NewSender() *Sender {
+	return &Sender{
+		snapshots: map[uint32]Snapshot{0: {}},
+		spawned:   make(map[uint32]uint32),
+	}
+}
+
+// Spawned returns true if the entity is spawned on the client.
+func (s *Sender) Spawned(netID uint32) bool {
+	_, found := s.spawned[netID]
+	return found
+}
+
+// Next returns the update which brings the client to the given states of the entities
+// it should have. It returns false if the client is up to date.
+func (s *Sender) Next(visible Snapshot) (Update, bool) {
+	update := Update{Sequence: s.sequence + 1, Baseline: s.acked}
+
+	for _, netID := range sortedIDs(visible) {
+		if !s.Spawned(netID) {
+			s.spawned[netID] = update.Sequence
+			update.Spawned = append(update.Spawned, netID)
+		}
+	}
+
+	for netID := range s.spawned {
+		if _, found := visible[netID]; !found {
+			delete(s.spawned, netID)
+			update.Despawned = append(update.Despawned, netID)
+		}
+	}
+
+	sort.Slice(update.Despawned, func(i, j int) bool { return update.Despawned[i] < update.Despawned[j] })
+
+	baseline := s.snapshots[s.acked]
+
+	for _, netID := range sortedIDs(visible) {
+		state := visible[netID]
+		fields := AllFields
+
+		// an entity spawned after the baseline may have had another life in it
+		if base, found := baseline[netID]; found && s.spawned[netID] <= s.acked {
+			fields = diff(base, state)
+		}
+
+		if fields != 0 {
+			update.Deltas = append(update.Deltas, newDelta(netID, fields, state))
+		}
+	}
+
+	if len(update.Spawned) == 0 && len(update.Deltas) == 0 && len(update.Despawned) == 0 {
+		return Update{}, false
+	}
+
+	s.sequence = update.Sequence
+	s.snapshots[s.sequence] = copySnapshot(visible)
+	s.dropPending()
+
+	return update, true
+}
+
+// Acknowledge makes the snapshot the client acknowledged the baseline of the next updates.
+func (s *Sender) Acknowledge(sequence uint32) {
+	if _, found := s.snapshots[sequence]; !found || sequence <= s.acked {
+		return
+	}
+
+	s.acked = sequence
+
+	for seq := range s.snapshots {
+		if seq < sequence {
+			delete(s.snapshots, seq)
+		}
+	}
+}
+
+// dropPending drops the oldest snapshots the client has not acknowledged, if too many are kept.
+func (s *Sender) dropPending() {
+	for len(s.snapshots) > maxPendingSnapshots+1 {
+		oldest := s.sequence
+
+		for seq := range s.snapshots {
+			if seq != s.acked && seq < oldest {
+				oldest = seq
+			}
+		}
+
+		delete(s.snapshots, oldest)
+	}
+}
+
+func sortedIDs(snapshot Snapshot) []uint32 {
+	ids := make([]uint32, 0, len(snapshot))
+
+	for netID := range snapshot {
+		ids = append(ids, netID)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func copySnapshot(snapshot Snapshot) Snapshot {
+	result := make(Snapshot, len(snapshot))
+
+	for netID, state := range snapshot {
+		result[netID] = state
+	}
+
+	return result
+}
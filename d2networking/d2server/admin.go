@@ -0,0 +1,262 @@
+package d2server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2admin"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+var (
+	errNoSuchPlayer = errors.New("no such player")
+	errKickHost     = errors.New("the host can not be kicked")
+)
+
+// LoadBanList loads the banned IP addresses from the file at path. New bans are saved to it.
+func (g *GameServer) LoadBanList(path string) error {
+	bans, err := d2admin.LoadBanList(path)
+	if err != nil {
+		return err
+	}
+
+	g.bans = bans
+
+	return nil
+}
+
+// suspendedCount returns the number of players whose connection dropped.
+func (g *GameServer) suspendedCount() int {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	suspended := 0
+
+	for _, s := range g.sessions {
+		if s.expiry != nil {
+			suspended++
+		}
+	}
+
+	return suspended
+}
+
+// Game describes the game to the admin console.
+func (g *GameServer) Game() d2admin.Game {
+	g.RLock()
+	defer g.RUnlock()
+
+	return d2admin.Game{
+		ID:         g.id,
+		Name:       g.options.Name,
+		Players:    len(g.connections),
+		MaxPlayers: g.maxConnections,
+		Difficulty: g.options.Difficulty.String(),
+		Password:   g.options.Password != "",
+		Seed:       g.seed,
+	}
+}
+
+// Players returns the connected players, ordered by ID.
+func (g *GameServer) Players() []d2admin.Player {
+	g.RLock()
+	defer g.RUnlock()
+
+	players := make([]d2admin.Player, 0, len(g.connections))
+
+	for id, client := range g.connections {
+		state := client.GetPlayerState()
+		player := d2admin.Player{
+			ID:      id,
+			Name:    state.HeroName,
+			Class:   state.HeroType.String(),
+			Game:    g.id,
+			Address: "local",
+		}
+
+		if state.Stats != nil {
+			player.Level = state.Stats.Level
+		}
+
+		if remote, ok := remoteConnection(client); ok {
+			player.Address = remote.RemoteAddr().String()
+		}
+
+		players = append(players, player)
+	}
+
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+
+	return players
+}
+
+// Kick disconnects the player, telling the client the reason.
+func (g *GameServer) Kick(id, reason string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	client, found := g.connections[id]
+	if !found {
+		return fmt.Errorf("%w: %s", errNoSuchPlayer, id)
+	}
+
+	return g.kick(client, reason)
+}
+
+// Ban bans the IP address and kicks the players connected from it.
+func (g *GameServer) Ban(ip string) (int, error) {
+	if err := g.bans.Add(ip); err != nil {
+		return 0, err
+	}
+
+	return g.kickAddress(net.ParseIP(ip))
+}
+
+// kickAddress kicks the players connected from the IP address.
+func (g *GameServer) kickAddress(ip net.IP) (int, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	kicked := 0
+
+	for _, client := range g.connections {
+		remote, ok := remoteConnection(client)
+		if !ok {
+			continue
+		}
+
+		addr, ok := remoteIP(remote.RemoteAddr())
+		if !ok || !addr.Equal(ip) {
+			continue
+		}
+
+		if err := g.kick(client, "banned"); err != nil {
+			return kicked, err
+		}
+
+		kicked++
+	}
+
+	return kicked, nil
+}
+
+// kick disconnects the client. The caller must hold the lock of the server.
+func (g *GameServer) kick(client ClientConnection, reason string) error {
+	if client.GetConnectionType() == d2clientconnectiontype.Local {
+		return errKickHost
+	}
+
+	message := "You have been kicked"
+	if reason != "" {
+		message += ": " + reason
+	}
+
+	g.Infof("Kicking %s: %s", client.GetUniqueID(), message)
+
+	g.sendSystemMessage(client, message)
+
+	// the client gives up on the game instead of trying to reconnect
+	if closed, err := d2netpacket.CreateServerClosedPacket(); err == nil {
+		if err := client.SendPacketToClient(closed); err != nil {
+			g.Warningf("failed to send ServerClosed to %s: %s", client.GetUniqueID(), err)
+		}
+	}
+
+	g.OnClientDisconnected(client)
+
+	if disconnected, err := d2netpacket.CreatePlayerDisconnectRequestPacket(client.GetUniqueID()); err == nil {
+		g.sendPacketToClients(disconnected)
+	}
+
+	if remote, ok := remoteConnection(client); ok {
+		return remote.Close()
+	}
+
+	return nil
+}
+
+// Say sends a message to every player.
+func (g *GameServer) Say(message string) error {
+	packet, err := d2netpacket.CreateSystemMessagePacket(message)
+	if err != nil {
+		return err
+	}
+
+	g.RLock()
+	defer g.RUnlock()
+
+	g.sendPacketToClients(packet)
+
+	return nil
+}
+
+func (g *GameServer) sendSystemMessage(client ClientConnection, message string) {
+	packet, err := d2netpacket.CreateSystemMessagePacket(message)
+	if err != nil {
+		g.Errorf("SystemMessagePacket: %v", err)
+		return
+	}
+
+	if err := client.SendPacketToClient(packet); err != nil {
+		g.Errorf("GameServer: error sending SystemMessagePacket to client %s: %s", client.GetUniqueID(), err)
+	}
+}
+
+// SaveAll saves every player, including the players whose connection dropped.
+func (g *GameServer) SaveAll() (int, error) {
+	g.RLock()
+	states := make(map[*d2hero.HeroState]bool)
+
+	for _, client := range g.connections {
+		states[client.GetPlayerState()] = true
+	}
+	g.RUnlock()
+
+	g.sessionMutex.Lock()
+	for _, s := range g.sessions {
+		states[s.playerState] = true
+	}
+	g.sessionMutex.Unlock()
+
+	saved := 0
+
+	for state := range states {
+		if err := g.heroStateFactory.Save(state); err != nil {
+			return saved, err
+		}
+
+		saved++
+	}
+
+	return saved, nil
+}
+
+// Shutdown saves every player, tells the clients the server closed, and stops the server.
+func (g *GameServer) Shutdown() {
+	if _, err := g.SaveAll(); err != nil {
+		g.Errorf("failed to save the players: %s", err)
+	}
+
+	if closed, err := d2netpacket.CreateServerClosedPacket(); err == nil {
+		g.RLock()
+		g.sendPacketToClients(closed)
+		g.RUnlock()
+	}
+
+	g.Stop()
+}
+
+// remoteConnection returns the connection of a remote client.
+func remoteConnection(client ClientConnection) (RemoteConnection, bool) {
+	if simulated, ok := client.(*SimulatedClientConnection); ok {
+		client = simulated.ClientConnection
+	}
+
+	remote, ok := client.(RemoteConnection)
+
+	return remote, ok
+}
@@ -0,0 +1,174 @@
+package d2server
+
+import (
+	"math"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapworld"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+// playerArea returns the area the player is in. The caller must hold the lock of the
+// server.
+func (g *GameServer) playerArea(playerID string) d2mapworld.Area {
+	if area, found := g.areas[playerID]; found {
+		return area
+	}
+
+	return g.start
+}
+
+// sendPacketToArea sends a packet to the clients whose player is in the area. The
+// caller must hold the lock of the server.
+func (g *GameServer) sendPacketToArea(area d2mapworld.Area, packet d2netpacket.NetPacket) {
+	for id, c := range g.connections {
+		if g.playerArea(id) != area {
+			continue
+		}
+
+		if err := c.SendPacketToClient(packet); err != nil {
+			g.Errorf("GameServer: error sending packet: %s to client %s: %s", packet.PacketType, c.GetUniqueID(), err)
+		}
+	}
+}
+
+// sendGenerateMap tells the client to generate the map of an area.
+func (g *GameServer) sendGenerateMap(client ClientConnection, area d2mapworld.Area) {
+	gmp, err := d2netpacket.CreateGenerateMapPacket(area.Act, area.LevelID, g.world.Difficulty(), g.world.Seed(area))
+	if err != nil {
+		g.Errorf("GenerateMapPacket: %v", err)
+	}
+
+	err = client.SendPacketToClient(gmp)
+	if err != nil {
+		g.Errorf("GameServer: error sending GenerateMapPacket to client %s: %s", client.GetUniqueID(), err)
+	}
+}
+
+// enterWarps starts moving the players which reached the warp they walked to into the
+// area it leads to, see enterWarp. The caller must hold the lock of the server.
+func (g *GameServer) enterWarps() {
+	for id, client := range g.connections {
+		if g.warping[id] {
+			continue
+		}
+
+		warp := g.reachedWarp(id)
+		if warp == nil {
+			continue
+		}
+
+		g.warping[id] = true
+
+		go g.enterWarp(client, warp)
+	}
+}
+
+// enterWarp finds where a warp leads to, then moves the player through it. The map the
+// warp leads to is generated the first time a player goes there, which takes long, so
+// it is generated without the lock of the server and the game goes on meanwhile. Where
+// the player comes out is looked up under the lock, as the game changes the entities of
+// the maps which were generated.
+func (g *GameServer) enterWarp(client ClientConnection, warp *d2mapentity.Warp) {
+	area, engine, err := g.world.Destination(warp)
+
+	g.Lock()
+	defer g.Unlock()
+
+	id := client.GetUniqueID()
+	delete(g.warping, id)
+
+	// the game may have ended or the player left while the map was generated
+	if g.ctx.Err() != nil || g.connections[id] != client {
+		return
+	}
+
+	if err == nil {
+		x, y := d2mapworld.Arrival(engine, warp)
+		err = g.warpPlayer(client, warp, area, x, y)
+	}
+
+	if err != nil {
+		g.Errorf("Player %s could not enter the warp to level %d: %s", id, warp.Destination, err)
+	}
+}
+
+// warpPlayer moves a player through a warp to the given tile of the area it leads to.
+// The clients of the area it leaves remove the player, the client of the player
+// generates the map it goes to, and the clients of that map add the player. The caller
+// must hold the lock of the server.
+func (g *GameServer) warpPlayer(client ClientConnection, warp *d2mapentity.Warp, area d2mapworld.Area,
+	x, y float64) error {
+	id := client.GetUniqueID()
+	from := g.playerArea(id)
+
+	g.areas[id] = area
+
+	playerState := client.GetPlayerState()
+	playerState.X, playerState.Y = x, y
+
+	g.resetPlayerMovement(id, x, y)
+
+	// the client spawns the entities of the new map as they come close
+	delete(g.replication, id)
+
+	left, err := d2netpacket.CreatePlayerWarpPacket(id, area.Act, area.LevelID)
+	if err != nil {
+		return err
+	}
+
+	g.sendPacketToArea(from, left)
+	g.sendGenerateMap(client, area)
+
+	for otherID, other := range g.connections {
+		if g.playerArea(otherID) != area {
+			continue
+		}
+
+		addPlayer, err := g.addPlayerPacket(client)
+		if err != nil {
+			return err
+		}
+
+		if err := other.SendPacketToClient(addPlayer); err != nil {
+			g.Errorf("GameServer: error sending AddPlayerPacket to client %s: %s", otherID, err)
+		}
+
+		if otherID == id {
+			continue
+		}
+
+		addOther, err := g.addPlayerPacket(other)
+		if err != nil {
+			return err
+		}
+
+		if err := client.SendPacketToClient(addOther); err != nil {
+			g.Errorf("GameServer: error sending AddPlayerPacket to client %s: %s", id, err)
+		}
+	}
+
+	g.Infof("Player %s went from level %d to level %d of act %d", id, warp.Level, warp.Destination, area.Act)
+
+	return nil
+}
+
+// addPlayerPacket returns the packet which adds the player of a client where the
+// server has it.
+func (g *GameServer) addPlayerPacket(client ClientConnection) (d2netpacket.NetPacket, error) {
+	state := client.GetPlayerState()
+
+	return d2netpacket.CreateAddPlayerPacket(
+		client.GetUniqueID(),
+		state.HeroName,
+		int(math.Round(state.X*subtilesPerTile)),
+		int(math.Round(state.Y*subtilesPerTile)),
+		state.HeroType,
+		state.Stats,
+		state.Skills,
+		state.Equipment,
+		state.LeftSkill,
+		state.RightSkill,
+		state.Gold,
+	)
+}
@@ -0,0 +1,252 @@
+package d2server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	// characterSaveInterval is how often the characters in a game are saved, besides
+	// when their player leaves.
+	characterSaveInterval = time.Minute
+
+	minHeroNameLength = 2
+	maxHeroNameLength = 15 // As long as the name text box of the client allows
+	saveDirMode       = 0o750
+	characterFileExt  = ".od2"
+)
+
+var (
+	errInvalidHeroName  = errors.New("invalid hero name")
+	errInvalidHeroClass = errors.New("invalid hero class")
+	errCharacterPlaying = errors.New("the character is already playing")
+	errCharacterClass   = errors.New("a character of another class has this name")
+	errCharacterLoad    = errors.New("the character can not be loaded")
+	errNoCharacter      = errors.New("the client chose no character")
+	errSkillNotLearned  = errors.New("skill not learned")
+)
+
+// characterStore keeps the characters of the players of a dedicated server in a
+// directory of the server. The clients only choose the name and class of their
+// character: its state is created and updated by the server, and a client can not
+// change it. A character is identified by its hero name, and plays one game at once.
+type characterStore struct {
+	sync.Mutex
+	asset   *d2asset.AssetManager
+	factory *d2hero.HeroStateFactory
+	dir     string
+	playing map[string]bool // File paths of the characters in a game
+}
+
+func newCharacterStore(asset *d2asset.AssetManager, dir string) (*characterStore, error) {
+	factory, err := d2hero.NewHeroStateFactory(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, saveDirMode); err != nil {
+		return nil, err
+	}
+
+	return &characterStore{
+		asset:   asset,
+		factory: factory,
+		dir:     dir,
+		playing: make(map[string]bool),
+	}, nil
+}
+
+// path returns the file of the character with the given hero name. Names are case
+// insensitive, and may only have the characters a file name can.
+func (c *characterStore) path(name string) (string, error) {
+	if len(name) < minHeroNameLength || len(name) > maxHeroNameLength {
+		return "", fmt.Errorf("%w: %q", errInvalidHeroName, name)
+	}
+
+	for _, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isDigit := r >= '0' && r <= '9'
+
+		if !isLetter && !isDigit && r != '-' && r != '_' {
+			return "", fmt.Errorf("%w: %q", errInvalidHeroName, name)
+		}
+	}
+
+	return filepath.Join(c.dir, strings.ToLower(name)+characterFileExt), nil
+}
+
+// checkOut returns the character with the given name, creating a new character of the
+// class if there is none. The character can not be checked out again until it is
+// checked in.
+func (c *characterStore) checkOut(name string, heroType d2enum.Hero) (*d2hero.HeroState, error) {
+	path, err := c.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.playing[path] {
+		return nil, fmt.Errorf("%w: %s", errCharacterPlaying, name)
+	}
+
+	var state *d2hero.HeroState
+
+	_, err = os.Stat(path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if state, err = c.create(name, heroType); err != nil {
+			return nil, err
+		}
+
+		state.FilePath = path
+
+		if err = c.save(state); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if state = c.factory.LoadHeroState(path); state == nil {
+			return nil, fmt.Errorf("%w: %s", errCharacterLoad, path)
+		}
+
+		if state.HeroType != heroType {
+			return nil, fmt.Errorf("%w: %s is a %s", errCharacterClass, name, state.HeroType)
+		}
+	}
+
+	c.playing[path] = true
+
+	return state, nil
+}
+
+// create returns a new character of the class, as the character creation screen of
+// the client does.
+func (c *characterStore) create(name string, heroType d2enum.Hero) (*d2hero.HeroState, error) {
+	classStats, found := c.asset.Records.Character.Stats[heroType]
+	if !found {
+		return nil, fmt.Errorf("%w: %d", errInvalidHeroClass, heroType)
+	}
+
+	return c.factory.CreateHeroState(name, heroType, c.factory.CreateHeroStatsState(heroType, classStats))
+}
+
+// save writes the character to its file.
+func (c *characterStore) save(state *d2hero.HeroState) error {
+	return c.factory.Save(state)
+}
+
+// checkIn saves the character, which can then be checked out again.
+func (c *characterStore) checkIn(state *d2hero.HeroState) error {
+	err := c.save(state)
+
+	c.Lock()
+	delete(c.playing, state.FilePath)
+	c.Unlock()
+
+	return err
+}
+
+// checkOutCharacter returns the character the server owns with the name and class of
+// the character the client chose.
+func (g *GameServer) checkOutCharacter(chosen *d2hero.HeroState) (*d2hero.HeroState, error) {
+	if chosen == nil {
+		return nil, errNoCharacter
+	}
+
+	return g.characters.checkOut(chosen.HeroName, chosen.HeroType)
+}
+
+// releaseCharacter saves the character of a client which left the game, so it can
+// join another game.
+func (g *GameServer) releaseCharacter(client ClientConnection) {
+	if g.characters == nil {
+		return
+	}
+
+	state := client.GetPlayerState()
+	if state == nil || state.FilePath == "" {
+		return
+	}
+
+	if err := g.characters.checkIn(state); err != nil {
+		g.Errorf("failed to save the character of %s: %s", client.GetUniqueID(), err)
+	}
+}
+
+// hasSkill returns true if the character of the client can use the skill. Only the
+// characters the server owns are checked. The caller must hold the lock of the server.
+func (g *GameServer) hasSkill(client ClientConnection, skillID int) bool {
+	if g.characters == nil {
+		return true
+	}
+
+	state := client.GetPlayerState()
+	if state == nil {
+		return false
+	}
+
+	_, found := state.Skills[skillID]
+
+	return found
+}
+
+// saveCharacter saves the character of the client when the client asks for it. Only
+// the skills the player chose for the mouse buttons are taken from the packet, if the
+// character has them.
+func (g *GameServer) saveCharacter(client ClientConnection, packet *d2netpacket.SavePlayerPacket) error {
+	g.Lock()
+	defer g.Unlock()
+
+	state := client.GetPlayerState()
+	if state == nil {
+		return errNoCharacter
+	}
+
+	if packet.Player != nil {
+		if left := packet.Player.LeftSkill; left != nil && g.hasSkill(client, left.Shallow.SkillID) {
+			state.LeftSkill = left.Shallow.SkillID
+		}
+
+		if right := packet.Player.RightSkill; right != nil && g.hasSkill(client, right.Shallow.SkillID) {
+			state.RightSkill = right.Shallow.SkillID
+		}
+	}
+
+	return g.characters.save(state)
+}
+
+// saveCharacters saves the characters in the game periodically until the game stops,
+// so the players lose little if the server crashes.
+func (g *GameServer) saveCharacters() {
+	ticker := time.NewTicker(characterSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+			saved, err := g.SaveAll()
+			if err != nil {
+				g.Errorf("failed to save the characters: %s", err)
+				continue
+			}
+
+			g.Debugf("Saved %d characters", saved)
+		}
+	}
+}
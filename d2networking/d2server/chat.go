@@ -0,0 +1,124 @@
+package d2server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const chatCommandHelp = "Commands: /w <name> <message>, /players, /help"
+
+// handleChat relays a chat message of the client to every player, or runs it if it
+// is a slash command.
+func (g *GameServer) handleChat(client ClientConnection, packet d2netpacket.NetPacket) error {
+	chat, err := d2netpacket.UnmarshalChat(packet)
+	if err != nil {
+		return err
+	}
+
+	message := strings.TrimSpace(chat.Message)
+	if message == "" {
+		return nil
+	}
+
+	if utf8.RuneCountInString(message) > d2netpacket.MaxChatMessageLength {
+		g.sendSystemMessage(client, fmt.Sprintf("Messages can not be longer than %d characters",
+			d2netpacket.MaxChatMessageLength))
+
+		return nil
+	}
+
+	g.RLock()
+	defer g.RUnlock()
+
+	if strings.HasPrefix(message, "/") {
+		return g.handleChatCommand(client, message)
+	}
+
+	// the sender is the connection, whatever the client put in the packet
+	relayed, err := d2netpacket.CreateChatPacket(client.GetUniqueID(), client.GetPlayerState().HeroName, message)
+	if err != nil {
+		return err
+	}
+
+	g.sendPacketToClients(relayed)
+
+	return nil
+}
+
+func (g *GameServer) handleChatCommand(client ClientConnection, message string) error {
+	fields := strings.Fields(message)
+
+	switch fields[0] {
+	case "/w", "/whisper", "/m", "/msg":
+		if len(fields) < 3 { // nolint:gomnd // command, name and message
+			g.sendSystemMessage(client, "Usage: /w <name> <message>")
+			return nil
+		}
+
+		return g.whisper(client, fields[1], strings.Join(fields[2:], " "))
+	case "/players", "/who":
+		g.sendSystemMessage(client, g.playerList())
+	case "/help", "/?":
+		g.sendSystemMessage(client, chatCommandHelp)
+	default:
+		g.sendSystemMessage(client, fmt.Sprintf("Unknown command %s. %s", fields[0], chatCommandHelp))
+	}
+
+	return nil
+}
+
+// whisper sends a private message to the player with the given name, and echoes it to the sender.
+func (g *GameServer) whisper(from ClientConnection, name, message string) error {
+	var to ClientConnection
+
+	for _, connection := range g.connections {
+		if strings.EqualFold(connection.GetPlayerState().HeroName, name) {
+			to = connection
+			break
+		}
+	}
+
+	if to == nil {
+		g.sendSystemMessage(from, fmt.Sprintf("There is no player named %s", name))
+		return nil
+	}
+
+	whisper, err := d2netpacket.CreateWhisperPacket(from.GetUniqueID(), from.GetPlayerState().HeroName,
+		to.GetUniqueID(), to.GetPlayerState().HeroName, message)
+	if err != nil {
+		return err
+	}
+
+	if err := to.SendPacketToClient(whisper); err != nil {
+		g.Errorf("GameServer: error sending WhisperPacket to client %s: %s", to.GetUniqueID(), err)
+	}
+
+	if to == from {
+		return nil
+	}
+
+	return from.SendPacketToClient(whisper)
+}
+
+func (g *GameServer) playerList() string {
+	players := make([]string, 0, len(g.connections))
+
+	for _, connection := range g.connections {
+		state := connection.GetPlayerState()
+
+		level := 1
+		if state.Stats != nil {
+			level = state.Stats.Level
+		}
+
+		players = append(players, fmt.Sprintf("%s (level %d %s)", state.HeroName, level, state.HeroType))
+	}
+
+	sort.Strings(players)
+
+	return fmt.Sprintf("%d players: %s", len(players), strings.Join(players, ", "))
+}
@@ -0,0 +1,130 @@
+package d2server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// testChatServer returns a game server with the client and another player.
+func testChatServer() (g *GameServer, client, other *testClient) {
+	client = &testClient{id: testPlayerID, playerState: &d2hero.HeroState{HeroName: "Hero"}}
+	other = &testClient{id: "other", playerState: &d2hero.HeroState{HeroName: "Other"}}
+
+	return testSessionServer(2, client, other), client, other
+}
+
+func sendTestChat(t *testing.T, g *GameServer, client *testClient, playerID, name, message string) {
+	t.Helper()
+
+	packet, err := d2netpacket.CreateChatPacket(playerID, name, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.handleChat(client, packet); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChatSenderIsTheConnection(t *testing.T) {
+	g, client, other := testChatServer()
+
+	sendTestChat(t, g, client, "other", "Other", "hello")
+
+	for _, receiver := range []*testClient{client, other} {
+		if len(receiver.sent) != 1 || receiver.sent[0].PacketType != d2netpackettype.Chat {
+			t.Fatalf("%s: expected the message to be relayed, got %v", receiver.id, receiver.sent)
+		}
+
+		chat, err := d2netpacket.UnmarshalChat(receiver.sent[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if chat.PlayerID != testPlayerID || chat.Name != "Hero" || chat.Message != "hello" {
+			t.Errorf("%s: expected the message from the connection, got %+v", receiver.id, chat)
+		}
+	}
+}
+
+func TestChatOverLengthIsRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		relayed  bool
+		rejected bool
+	}{
+		{"longest", strings.Repeat("a", d2netpacket.MaxChatMessageLength), true, false},
+		{"too long", strings.Repeat("a", d2netpacket.MaxChatMessageLength+1), false, true},
+		{"longest in runes", strings.Repeat("é", d2netpacket.MaxChatMessageLength), true, false},
+		{"too long in runes", strings.Repeat("é", d2netpacket.MaxChatMessageLength+1), false, true},
+		{"blank", "   ", false, false},
+	}
+
+	for _, test := range tests {
+		g, client, other := testChatServer()
+
+		sendTestChat(t, g, client, testPlayerID, "Hero", test.message)
+
+		if relayed := len(other.sent) == 1 && other.sent[0].PacketType == d2netpackettype.Chat; relayed != test.relayed {
+			t.Errorf("%s: expected relayed to be %v, got %v", test.name, test.relayed, other.sent)
+		}
+
+		rejected := len(client.sent) == 1 && client.sent[0].PacketType == d2netpackettype.SystemMessage
+		if rejected != test.rejected {
+			t.Errorf("%s: expected the sender to be told %v, got %v", test.name, test.rejected, client.sent)
+		}
+
+		if test.rejected && len(other.sent) != 0 {
+			t.Errorf("%s: expected nothing to be sent to the other players, got %v", test.name, other.sent)
+		}
+	}
+}
+
+func TestWhisper(t *testing.T) {
+	g, client, other := testChatServer()
+
+	sendTestChat(t, g, client, testPlayerID, "Hero", "/w other psst")
+
+	for _, receiver := range []*testClient{client, other} {
+		if len(receiver.sent) != 1 || receiver.sent[0].PacketType != d2netpackettype.Whisper {
+			t.Fatalf("%s: expected the whisper, got %v", receiver.id, receiver.sent)
+		}
+
+		whisper, err := d2netpacket.UnmarshalWhisper(receiver.sent[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if whisper.FromID != testPlayerID || whisper.ToID != "other" || whisper.Message != "psst" {
+			t.Errorf("%s: expected the whisper from the connection to the other player, got %+v", receiver.id, whisper)
+		}
+	}
+}
+
+func TestWhisperToUnknownName(t *testing.T) {
+	g, client, other := testChatServer()
+
+	sendTestChat(t, g, client, testPlayerID, "Hero", "/w nobody psst")
+
+	if len(other.sent) != 0 {
+		t.Errorf("expected nothing to be sent to the other players, got %v", other.sent)
+	}
+
+	if len(client.sent) != 1 || client.sent[0].PacketType != d2netpackettype.SystemMessage {
+		t.Fatalf("expected the sender to be told, got %v", client.sent)
+	}
+
+	message, err := d2netpacket.UnmarshalSystemMessage(client.sent[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(message.Message, "nobody") {
+		t.Errorf("expected the sender to be told there is no such player, got %q", message.Message)
+	}
+}
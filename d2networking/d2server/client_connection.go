@@ -1,6 +1,8 @@
 package d2server
 
 import (
+	"net"
+
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
@@ -15,3 +17,28 @@ type ClientConnection interface {
 	GetPlayerState() *d2hero.HeroState
 	SetPlayerState(playerState *d2hero.HeroState)
 }
+
+// CodecConnection is implemented by client connections which negotiate the
+// wire codec used for NetPackets during the connection handshake.
+type CodecConnection interface {
+	GetCodec() d2netpacket.CodecType
+}
+
+// RemoteConnection is implemented by client connections of remote clients, which
+// the server can close.
+type RemoteConnection interface {
+	RemoteAddr() net.Addr
+	Close() error
+}
+
+// remoteIP returns the IP address of a remote client, which connects over TCP or UDP.
+func remoteIP(addr net.Addr) (net.IP, bool) {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP, true
+	case *net.UDPAddr:
+		return addr.IP, true
+	default:
+		return nil, false
+	}
+}
@@ -2,44 +2,104 @@
 package d2tcpclientconnection
 
 import (
-	"encoding/json"
+	"io"
 	"net"
+	"sync"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
 )
 
-// TCPClientConnection represents a client connection over TCP
+// TCPClientConnection represents a client connection over TCP, or over the channels of
+// d2udpchannel, which read and write like a TCP connection.
 type TCPClientConnection struct {
-	id            string
-	tcpConnection net.Conn
-	playerState   *d2hero.HeroState
+	id                string
+	tcpConnection     net.Conn
+	playerState       *d2hero.HeroState
+	codec             d2netpacket.CodecType // Codec negotiated with the client
+	encoder           d2netpacket.PacketEncoder
+	unreliable        io.Writer                 // Sends packets which may be lost, if the connection can
+	unreliableEncoder d2netpacket.PacketEncoder // Encodes the packets sent on unreliable
+	encoderMutex      sync.Mutex
+	counter           PacketCounter
 }
 
-// CreateTCPClientConnection creates a new tcp client connection instance
-func CreateTCPClientConnection(tcpConnection net.Conn, id string) *TCPClientConnection {
-	return &TCPClientConnection{
+// UnreliableConn is implemented by connections which can also send packets which may be
+// lost, like the connections of d2udpchannel. Each write to the writer is one packet.
+type UnreliableConn interface {
+	Unreliable() io.Writer
+}
+
+// PacketCounter counts the packets sent to clients.
+type PacketCounter interface {
+	CountSent(packetType d2netpackettype.NetPacketType)
+}
+
+// CreateTCPClientConnection creates a new tcp client connection instance. Packets are sent as
+// JSON until the UpdateServerInfo packet has been sent, then the given codec is used.
+func CreateTCPClientConnection(tcpConnection net.Conn, id string, codec d2netpacket.CodecType) *TCPClientConnection {
+	t := &TCPClientConnection{
 		tcpConnection: tcpConnection,
 		id:            id,
+		codec:         codec,
+		encoder:       d2netpacket.NewEncoder(d2netpacket.CodecJSON, tcpConnection),
+	}
+
+	if conn, ok := tcpConnection.(UnreliableConn); ok {
+		t.unreliable = conn.Unreliable()
+		t.unreliableEncoder = d2netpacket.NewEncoder(d2netpacket.CodecJSON, t.unreliable)
 	}
+
+	return t
 }
 
 // GetUniqueID returns the unique ID for the tcp client connection
-func (t TCPClientConnection) GetUniqueID() string {
+func (t *TCPClientConnection) GetUniqueID() string {
 	return t.id
 }
 
-// SendPacketToClient marshals and sends (writes) NetPackets
+// GetCodec returns the codec negotiated with the client
+func (t *TCPClientConnection) GetCodec() d2netpacket.CodecType {
+	return t.codec
+}
+
+// CountPackets makes the connection count the packets it sends. It must be called
+// before any packet is sent.
+func (t *TCPClientConnection) CountPackets(counter PacketCounter) {
+	t.counter = counter
+}
+
+// SendPacketToClient marshals and sends (writes) NetPackets. Movement is sent on the
+// unreliable channel if the connection has one, as every move replaces the one before it.
 func (t *TCPClientConnection) SendPacketToClient(p d2netpacket.NetPacket) error {
-	encoder := json.NewEncoder(t.tcpConnection)
+	t.encoderMutex.Lock()
+	defer t.encoderMutex.Unlock()
 
-	err := encoder.Encode(p)
-	if err != nil {
+	encoder := t.encoder
+	if t.unreliableEncoder != nil && p.PacketType == d2netpackettype.MovePlayer {
+		encoder = t.unreliableEncoder
+	}
+
+	if err := encoder.Encode(p); err != nil {
 		return err
 	}
 
+	if t.counter != nil {
+		t.counter.CountSent(p.PacketType)
+	}
+
+	// The client switches to the negotiated codec once it has read UpdateServerInfo
+	if p.PacketType == d2netpackettype.UpdateServerInfo {
+		t.encoder = d2netpacket.NewEncoder(t.codec, t.tcpConnection)
+
+		if t.unreliable != nil {
+			t.unreliableEncoder = d2netpacket.NewEncoder(t.codec, t.unreliable)
+		}
+	}
+
 	return nil
 }
 
@@ -55,6 +115,16 @@ func (t *TCPClientConnection) GetPlayerState() *d2hero.HeroState {
 
 // GetConnectionType returns an enum representing the connection type.
 // See: d2clientconnectiontype.
-func (t TCPClientConnection) GetConnectionType() d2clientconnectiontype.ClientConnectionType {
+func (t *TCPClientConnection) GetConnectionType() d2clientconnectiontype.ClientConnectionType {
 	return d2clientconnectiontype.LANClient
 }
+
+// RemoteAddr returns the address of the client.
+func (t *TCPClientConnection) RemoteAddr() net.Addr {
+	return t.tcpConnection.RemoteAddr()
+}
+
+// Close closes the connection to the client.
+func (t *TCPClientConnection) Close() error {
+	return t.tcpConnection.Close()
+}
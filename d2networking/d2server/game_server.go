@@ -2,32 +2,38 @@ package d2server
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/robertkrimen/otto"
 
-	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
-	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
-	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapgen"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapworld"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2admin"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2metrics"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2server/d2tcpclientconnection"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2udpchannel"
 	"github.com/OpenDiablo2/OpenDiablo2/d2script"
 )
 
 const logPrefix = "Game Server"
 
+// Port is the TCP port the game server listens on.
+const Port = 6669
+
 const (
-	port                   = "6669"
 	chunkSize          int = 4096 // nolint:deadcode,unused,varcheck // WIP
 	subtilesPerTile        = 5
 	middleOfTileOffset     = 3
@@ -36,6 +42,9 @@ const (
 var (
 	errPlayerAlreadyExists = errors.New("player already exists")
 	errServerFull          = errors.New("server full") // Server currently at maximum TCP connections
+	errGameClosed          = errors.New("game closed")
+	errProtocolVersion     = errors.New("client speaks another protocol version")
+	errFlooding            = errors.New("too many packets")
 )
 
 // GameServer manages a copy of the map and entities as well as manages packet routing and connections.
@@ -44,16 +53,38 @@ type GameServer struct {
 	sync.RWMutex
 	connections       map[string]ClientConnection
 	listener          net.Listener
+	udpListener       net.Listener // Takes the clients connecting over UDP, see d2udpchannel
 	networkServer     bool
 	ctx               context.Context
 	cancel            context.CancelFunc
 	asset             *d2asset.AssetManager
-	mapEngines        []*d2mapengine.MapEngine
+	world             *d2mapworld.World // Maps of the game, generated as players go to them
 	scriptEngine      *d2script.ScriptEngine
 	seed              int64
 	maxConnections    int
 	packetManagerChan chan ReceivedPacket
 	heroStateFactory  *d2hero.HeroStateFactory
+	movement          map[string]*playerMovement // Last accepted position of each player
+	movementMutex     sync.Mutex
+	netConditions     d2netsim.Conditions // Simulated network conditions of remote clients
+	sessions          map[string]*session // Sessions by player ID, see session.go
+	sessionMutex      sync.Mutex
+	bans              *d2admin.BanList
+	started           time.Time
+	id                string                           // ID of the game in the lobby hosting it, see lobby.go
+	options           GameOptions                      // Settings of the game in the lobby hosting it
+	onEmpty           func(game *GameServer)           // Called once the last player left the game
+	tick              uint64                           // Ticks the world has been simulated for, see world.go
+	entities          map[string]*replicatedEntity     // Entities sent to the clients, see replication.go
+	nextNetID         uint32                           // Network ID of the last entity
+	itemCodes         map[string][]string              // Codes of the items spawned by the clients
+	replication       map[string]*d2replication.Sender // What each client has of the entities
+	limits            *limitCounters                   // Packets dropped by the rate limits, see rate_limit.go
+	characters        *characterStore                  // Owns the characters of the players if set, see characters.go
+	metrics           *d2metrics.Metrics               // Counts the packets of the clients if set
+	start             d2mapworld.Area                  // Area the players join the game in, see SetStartArea
+	areas             map[string]d2mapworld.Area       // Area of each player, see areas.go
+	warping           map[string]bool                  // Players waiting for the map of a warp, see areas.go
 
 	*d2util.Logger
 }
@@ -94,31 +125,36 @@ func NewGameServer(asset *d2asset.AssetManager,
 		networkServer:     networkServer,
 		maxConnections:    maxConnections[0],
 		packetManagerChan: make(chan ReceivedPacket),
-		mapEngines:        make([]*d2mapengine.MapEngine, 0),
 		scriptEngine:      d2script.CreateScriptEngine(),
 		seed:              time.Now().UnixNano(),
 		heroStateFactory:  heroStateFactory,
+		movement:          make(map[string]*playerMovement),
+		sessions:          make(map[string]*session),
+		entities:          make(map[string]*replicatedEntity),
+		itemCodes:         make(map[string][]string),
+		replication:       make(map[string]*d2replication.Sender),
+		limits:            &limitCounters{},
+		areas:             make(map[string]d2mapworld.Area),
+		warping:           make(map[string]bool),
+	}
+
+	gameServer.bans, err = d2admin.LoadBanList("")
+	if err != nil {
+		return nil, err
 	}
 
 	gameServer.Logger = d2util.NewLogger()
 	gameServer.Logger.SetPrefix(logPrefix)
 	gameServer.Logger.SetLevel(l)
 
-	mapEngine := d2mapengine.CreateMapEngine(l, asset)
-	mapEngine.SetSeed(gameServer.seed)
-	mapEngine.ResetMap(d2enum.RegionAct1Town, 100, 100)
+	gameServer.world = d2mapworld.NewWorld(asset, l, gameServer.seed)
 
-	mapGen, err := d2mapgen.NewMapGenerator(asset, l, mapEngine)
-	if err != nil {
+	if err := gameServer.SetStartArea(1, 0); err != nil {
 		return nil, err
 	}
 
-	mapGen.GenerateAct1Overworld()
-
-	gameServer.mapEngines = append(gameServer.mapEngines, mapEngine)
-
 	gameServer.scriptEngine.AddFunction("getMapEngines", func(call otto.FunctionCall) otto.Value {
-		val, err := gameServer.scriptEngine.ToValue(gameServer.mapEngines)
+		val, err := gameServer.scriptEngine.ToValue(gameServer.world.Maps())
 		if err != nil {
 			gameServer.Error(err.Error())
 		}
@@ -128,9 +164,47 @@ func NewGameServer(asset *d2asset.AssetManager,
 	return gameServer, nil
 }
 
+// SetStartArea generates the map the players join the game in: the overworld of an
+// act if levelID is 0, or else the level. Other maps are generated when a player
+// first warps to them. It must be called before the game is run.
+func (g *GameServer) SetStartArea(act, levelID int) error {
+	area := d2mapworld.Area{Act: act, LevelID: levelID}
+
+	if _, err := g.world.Map(area); err != nil {
+		return err
+	}
+
+	g.start = area
+
+	return nil
+}
+
+// PlayerCount returns the number of players in the game.
+func (g *GameServer) PlayerCount() int {
+	g.RLock()
+	defer g.RUnlock()
+
+	return len(g.connections)
+}
+
+// hasRoom returns true if another player can join the game, see isFull.
+func (g *GameServer) hasRoom() bool {
+	g.RLock()
+	defer g.RUnlock()
+
+	return !g.isFull()
+}
+
+// SimulateNetwork makes the connections of remote clients behave like a
+// network with the given conditions. It must be called before Start.
+func (g *GameServer) SimulateNetwork(conditions d2netsim.Conditions) {
+	g.netConditions = conditions
+}
+
 // Start essentially starts all of the game server go routines as well as begins listening for connection. This will
 // return an error if it is unable to bind to a socket.
 func (g *GameServer) Start() error {
+	port := strconv.Itoa(Port)
 	listenerAddress := "127.0.0.1:" + port
 	if g.networkServer {
 		listenerAddress = "0.0.0.0:" + port
@@ -143,52 +217,96 @@ func (g *GameServer) Start() error {
 		return err
 	}
 
+	// clients may connect over UDP as well, on the same port
+	udpListener, err := d2udpchannel.Listen(listenerAddress)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+
 	g.listener = l
+	g.udpListener = udpListener
 
-	go g.packetManager()
+	g.run()
 
-	go func() {
-		for {
-			c, err := g.listener.Accept()
-			if err != nil {
-				select {
-				case <-g.ctx.Done():
-					// this error was just a result of the server closing, don't worry about it
-				default:
-					g.Errorf("Unable to accept connection: %s", err)
-				}
+	go acceptConnections(g.ctx, g.listener, g.Logger, g.handleConnection)
+	go acceptConnections(g.ctx, g.udpListener, g.Logger, g.handleConnection)
 
-				return
+	return nil
+}
+
+// acceptConnections passes every connection of the listener to handle, in a goroutine of
+// its own, until the listener is closed.
+func acceptConnections(ctx context.Context, listener net.Listener, logger *d2util.Logger, handle func(net.Conn)) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				// this error was just a result of the server closing, don't worry about it
+			default:
+				logger.Errorf("Unable to accept connection: %s", err)
 			}
 
-			go g.handleConnection(c)
+			return
 		}
-	}()
 
-	return nil
+		go handle(c)
+	}
+}
+
+// run starts the game, which then takes the packets of the clients and simulates its world.
+func (g *GameServer) run() {
+	g.started = time.Now()
+
+	go g.packetManager()
+	go g.simulate()
+
+	if g.characters != nil {
+		go g.saveCharacters()
+	}
 }
 
 // Stop stops the game server
 func (g *GameServer) Stop() {
 	g.Lock()
+	defer g.Unlock()
+
+	g.stop()
+}
+
+// stop stops the game server. The caller must hold the lock of the server.
+func (g *GameServer) stop() {
 	g.cancel()
 	g.connections = make(map[string]ClientConnection)
 
-	if err := g.listener.Close(); err != nil {
-		g.Errorf("failed to close the listener %s, err: %v\n", g.listener.Addr(), err)
+	// the games of a lobby do not listen themselves
+	for _, listener := range []net.Listener{g.listener, g.udpListener} {
+		if listener == nil {
+			continue
+		}
+
+		if err := listener.Close(); err != nil {
+			g.Errorf("failed to close the listener %s, err: %v\n", listener.Addr(), err)
+		}
 	}
 }
 
 // packetManager is meant to be started as a Goroutine and is used to manage routing of packets to clients.
+// The channel is never closed, as connections may still be sending to it; they stop when the server stops.
 func (g *GameServer) packetManager() {
-	defer close(g.packetManagerChan)
-
 	for {
 		select {
 		// If the server is stopped we need to clean up the packet manager goroutine
 		case <-g.ctx.Done():
 			return
 		case p := <-g.packetManagerChan:
+			// the client may have resumed its session on another connection meanwhile
+			if !g.isConnected(p.Client) {
+				g.Debugf("Dropped %s from a replaced connection of %s", p.Packet.PacketType, p.Client.GetUniqueID())
+				continue
+			}
+
 			err := g.OnPacketReceived(p.Client, p.Packet)
 			if err != nil {
 				g.Errorf("failed to handle packet received from client %s: %v", p.Client.GetUniqueID(), err)
@@ -208,125 +326,281 @@ func (g *GameServer) sendPacketToClients(packet d2netpacket.NetPacket) {
 // handleConnection accepts an individual connection and starts pooling for new packets. It is recommended this is called
 // via Go Routine. Context should be a property of the GameServer Struct.
 func (g *GameServer) handleConnection(conn net.Conn) {
-	var (
-		connected int
-		client    ClientConnection
-	)
-
 	g.Infof("Accepting connection: %s\n", conn.RemoteAddr().String())
 
 	defer func() {
-		if err := conn.Close(); err != nil {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			g.Errorf("failed to close the connection: %s\n", conn.RemoteAddr())
 		}
 	}()
 
-	decoder := json.NewDecoder(conn)
+	decoder := d2netpacket.NewDecoder(d2netpacket.CodecJSON, conn)
 
-	for {
-		var packet d2netpacket.NetPacket
-
-		err := decoder.Decode(&packet)
-		if err != nil {
-			switch err {
-			case io.EOF:
-				break // the other side closed the connection
-			default:
-				g.Error(err.Error())
-			}
+	request, err := decoder.Decode()
+	if err != nil {
+		if errors.Is(err, d2netpacket.ErrFrameTooLarge) {
+			g.limits.addOversized()
+		}
 
-			return // allow the connection to close
+		if err != io.EOF {
+			g.Error(err.Error())
 		}
 
-		// If this is the first packet we are seeing from this specific connection we first need to see if the client
-		// is sending a valid request. If this is a valid request, we will register it and flip the connected switch
-		// to.
-		if connected == 0 {
-			if packet.PacketType != d2netpackettype.PlayerConnectionRequest {
-				g.Infof("Closing connection with %s: did not receive new player connection request...", conn.RemoteAddr().String())
-			}
+		return
+	}
 
-			if client, err = g.registerConnection(packet.PacketData, conn); err != nil {
-				return
-			}
+	g.metrics.CountReceived(request.PacketType)
 
-			connected = 1
-		}
+	// the request is read first, so the client receives the rejection before the connection closes
+	if ip, ok := remoteIP(conn.RemoteAddr()); ok && g.bans.Contains(ip) {
+		g.Infof("Refusing connection from banned address %s", ip)
+		reject(conn, g.Logger, d2netpacket.RejectedBanned, "")
+
+		return
+	}
+
+	g.serveConnection(conn, decoder, request)
+}
+
+// serveConnection registers the client of a connection, whose first packet has been
+// read by the decoder, and passes the packets of the client to the packet manager
+// until the connection closes. The first packet must be a connection request.
+func (g *GameServer) serveConnection(conn net.Conn, decoder d2netpacket.PacketDecoder, request d2netpacket.NetPacket) {
+	if request.PacketType != d2netpackettype.PlayerConnectionRequest {
+		g.Infof("Closing connection with %s: did not receive new player connection request...", conn.RemoteAddr().String())
+		return
+	}
 
+	client, err := g.registerConnection(request, conn)
+	if err != nil {
+		return
+	}
+
+	// The client waits for UpdateServerInfo before it sends anything else, so every
+	// packet after the connection request uses the negotiated codec.
+	if c, ok := client.(CodecConnection); ok {
+		decoder = d2netpacket.SwitchDecoder(decoder, c.GetCodec(), conn)
+	}
+
+	limiter := newRateLimiter(time.Now())
+
+	for packet := request; ; {
 		select {
 		case <-g.ctx.Done():
 			return
 		default:
-			g.packetManagerChan <- ReceivedPacket{
-				Client: client,
-				Packet: packet,
+			allowed, floodErr := g.throttle(client, limiter, packet)
+			if floodErr != nil {
+				g.disconnectFlooder(client, floodErr)
+				return
+			}
+
+			if allowed {
+				select {
+				case g.packetManagerChan <- ReceivedPacket{Client: client, Packet: packet}:
+				case <-g.ctx.Done():
+					return
+				}
 			}
 		}
+
+		if packet, err = decoder.Decode(); err != nil {
+			switch {
+			case err == io.EOF:
+				break // the other side closed the connection
+			case errors.Is(err, net.ErrClosed):
+				break // the client was kicked
+			case errors.Is(err, d2netpacket.ErrFrameTooLarge):
+				g.limits.addOversized()
+				g.disconnectFlooder(client, err)
+			default:
+				g.Error(err.Error())
+			}
+
+			g.suspendClient(client)
+
+			return // allow the connection to close
+		}
+
+		g.metrics.CountReceived(packet.PacketType)
+	}
+}
+
+// throttle returns false if the client sent too many packets of the class of the packet
+// lately, in which case the packet is dropped. It returns an error if the client keeps
+// sending too many packets, and must be disconnected.
+func (g *GameServer) throttle(client ClientConnection, limiter *rateLimiter,
+	packet d2netpacket.NetPacket) (bool, error) {
+	class := classOf(packet.PacketType)
+
+	allowed, flooding := limiter.allow(class, time.Now())
+	if allowed {
+		return true, nil
+	}
+
+	g.limits.drop(class)
+	g.Debugf("Dropped %s from %s: over the %s rate limit", packet.PacketType, client.GetUniqueID(), class)
+
+	if flooding {
+		return false, fmt.Errorf("%w of %s packets", errFlooding, class)
+	}
+
+	return false, nil
+}
+
+// disconnectFlooder kicks a client which sent too many packets, or too large ones.
+func (g *GameServer) disconnectFlooder(client ClientConnection, reason error) {
+	g.limits.addFlooder()
+	g.Warningf("Disconnecting %s: %s", client.GetUniqueID(), reason)
+
+	if err := g.Kick(client.GetUniqueID(), "flooding the server"); err != nil && !errors.Is(err, errNoSuchPlayer) {
+		g.Errorf("failed to kick %s: %s", client.GetUniqueID(), err)
 	}
 }
 
-// registerConnection accepts a PlayerConnectionRequestPacket and thread safely updates the connection pool
+// registerConnection accepts a PlayerConnectionRequestPacket and thread safely updates the connection pool.
+// The client is sent a ConnectionRejectedPacket if it can not join.
 //
 // Errors:
+// - errGameClosed
+// - errProtocolVersion
 // - errServerFull
 // - errPlayerAlreadyExists
-func (g *GameServer) registerConnection(b []byte, conn net.Conn) (ClientConnection, error) {
+func (g *GameServer) registerConnection(request d2netpacket.NetPacket, conn net.Conn) (ClientConnection, error) {
 	var client ClientConnection
 
 	g.Lock()
 	defer g.Unlock()
 
-	// check to see if the server is full
-	if len(g.connections) >= g.maxConnections {
-		sf, serverFullErr := d2netpacket.CreateServerFullPacket()
-		if serverFullErr != nil {
-			g.Errorf("ServerFullPacket: %v", serverFullErr)
-		}
+	// a game of a lobby may have closed while the client was joining it
+	if g.ctx.Err() != nil {
+		reject(conn, g.Logger, d2netpacket.RejectedOther, errGameClosed.Error())
+		return client, errGameClosed
+	}
 
-		msf, marshalServerFullErr := d2netpacket.MarshalPacket(sf)
-		if marshalServerFullErr != nil {
-			g.Errorf("MarshalPacket: %v", marshalServerFullErr)
-		}
+	packet, err := d2netpacket.UnmarshalPlayerConnectionRequest(request)
+	if err != nil {
+		g.Errorf("Failed to unmarshal PlayerConnectionRequest: %s\n", err)
+	}
 
-		_, errServerFullPacket := conn.Write(msf)
-		g.Warningf("%v", errServerFullPacket)
+	if packet.ProtocolVersion != d2netpacket.ProtocolVersion {
+		g.Infof("Refusing %s: it speaks protocol version %d", conn.RemoteAddr(), packet.ProtocolVersion)
+		reject(conn, g.Logger, d2netpacket.RejectedVersion, "")
 
-		return client, errServerFull
+		return client, errProtocolVersion
 	}
 
-	// if it is not full, unmarshal the playerConnectionRequest
-	packet, err := d2netpacket.UnmarshalPlayerConnectionRequest(b)
-	if err != nil {
-		g.Errorf("Failed to unmarshal PlayerConnectionRequest: %s\n", err)
+	// a client which lost its connection takes its player back, its slot was kept for it
+	if packet.SessionToken != "" {
+		if playerState, ok := g.resumeSession(packet.ID, packet.SessionToken); ok {
+			client = g.createClientConnection(conn, &packet)
+			client.SetPlayerState(playerState)
+
+			g.OnClientResumed(client)
+
+			return client, nil
+		}
+
+		g.Warningf("Session of %s can not be resumed, joining as a new player", packet.ID)
+	}
+
+	// check to see if the server is full
+	if g.isFull() {
+		g.Infof("Refusing %s: %s", conn.RemoteAddr(), errServerFull)
+		reject(conn, g.Logger, d2netpacket.RejectedFull, "")
+
+		return client, errServerFull
 	}
 
 	// check to see if the player is already registered
 	if _, ok := g.connections[packet.ID]; ok {
 		g.Errorf("%v", errPlayerAlreadyExists)
+		reject(conn, g.Logger, d2netpacket.RejectedOther, errPlayerAlreadyExists.Error())
+
 		return client, errPlayerAlreadyExists
 	}
 
+	playerState := packet.PlayerState
+
+	// the client only chooses the name and class of a character the server owns
+	if g.characters != nil {
+		if playerState, err = g.checkOutCharacter(playerState); err != nil {
+			g.Infof("Refusing %s: %s", conn.RemoteAddr(), err)
+			reject(conn, g.Logger, d2netpacket.RejectedOther, err.Error())
+
+			return client, err
+		}
+	}
+
 	// Client a new TCP Client Connection and add it to the connections map
-	client = d2tcpclientconnection.CreateTCPClientConnection(conn, packet.ID)
-	client.SetPlayerState(packet.PlayerState)
+	client = g.createClientConnection(conn, &packet)
+	client.SetPlayerState(playerState)
 
-	g.OnClientConnected(client)
+	if err := g.OnClientConnected(client); err != nil {
+		reject(conn, g.Logger, d2netpacket.RejectedOther, err.Error())
+		return nil, err
+	}
 
 	return client, nil
 }
 
+// reject tells the client of a connection why its connection request was refused. The
+// connection is closed after it.
+func reject(conn net.Conn, logger *d2util.Logger, reason d2netpacket.RejectionReason, detail string) {
+	packet, err := d2netpacket.CreateConnectionRejectedPacket(reason, detail)
+	if err != nil {
+		logger.Errorf("ConnectionRejectedPacket: %v", err)
+		return
+	}
+
+	if err := d2netpacket.NewEncoder(d2netpacket.CodecJSON, conn).Encode(packet); err != nil {
+		logger.Errorf("error sending ConnectionRejectedPacket to %s: %s", conn.RemoteAddr(), err)
+	}
+}
+
+// createClientConnection creates the ClientConnection of a remote client from its connection request.
+func (g *GameServer) createClientConnection(conn net.Conn, request *d2netpacket.PlayerConnectionRequestPacket) ClientConnection {
+	tcpClient := d2tcpclientconnection.CreateTCPClientConnection(conn, request.ID,
+		d2netpacket.NegotiateCodec(request.Codecs))
+
+	if g.metrics != nil {
+		tcpClient.CountPackets(g.metrics)
+	}
+
+	var client ClientConnection = tcpClient
+
+	if g.netConditions.Enabled() {
+		client = NewSimulatedClientConnection(client, g.netConditions, g.Logger)
+	}
+
+	return client
+}
+
 // OnClientConnected initializes the given ClientConnection. It sends the
 // following packets to the newly connected client: UpdateServerInfoPacket,
 // GenerateMapPacket, AddPlayerPacket.
 //
-// It also sends AddPlayerPackets for each other player entity to the new
-// player and vice versa, so all player entities exist on all clients.
+// It also sends AddPlayerPackets for each other player entity in the start area
+// to the new player and vice versa, so the clients have the players of their map.
+//
+// If the start area can not be generated, the client is not added and the character it
+// checked out is checked back in.
 //
 // For more information, see d2networking.d2netpacket.
-func (g *GameServer) OnClientConnected(client ClientConnection) {
+func (g *GameServer) OnClientConnected(client ClientConnection) error {
+	startMap, err := g.world.Map(g.start)
+	if err != nil {
+		g.Errorf("failed to generate the start area: %s", err)
+		g.releaseCharacter(client)
+
+		return err
+	}
+
+	g.areas[client.GetUniqueID()] = g.start
+
 	// Temporary position hack --------------------------------------------
 	// https://github.com/OpenDiablo2/OpenDiablo2/issues/829
-	sx, sy := g.mapEngines[0].GetStartPosition()
+	sx, sy := startMap.GetStartPosition()
 	clientPlayerState := client.GetPlayerState()
 	clientPlayerState.X = sx
 	clientPlayerState.Y = sy
@@ -334,12 +608,22 @@ func (g *GameServer) OnClientConnected(client ClientConnection) {
 
 	g.Infof("Client connected with an id of %s", client.GetUniqueID())
 	g.connections[client.GetUniqueID()] = client
+	g.openSession(client)
 
 	g.handleClientConnection(client, sx, sy)
+
+	return nil
 }
 
-func (g *GameServer) handleClientConnection(client ClientConnection, x, y float64) {
-	usi, err := d2netpacket.CreateUpdateServerInfoPacket(g.seed, client.GetUniqueID())
+// sendServerInfo sends the client an UpdateServerInfoPacket with its codec and session.
+func (g *GameServer) sendServerInfo(client ClientConnection, resumed bool) {
+	codec := d2netpacket.CodecJSON
+	if c, ok := client.(CodecConnection); ok {
+		codec = c.GetCodec()
+	}
+
+	usi, err := d2netpacket.CreateUpdateServerInfoPacket(g.seed, client.GetUniqueID(), codec,
+		g.sessionToken(client.GetUniqueID()), resumed)
 	if err != nil {
 		g.Errorf("UpdateServerInfoPacket: %v", err)
 	}
@@ -348,16 +632,12 @@ func (g *GameServer) handleClientConnection(client ClientConnection, x, y float6
 	if err != nil {
 		g.Errorf("GameServer: error sending UpdateServerInfoPacket to client %s: %s", client.GetUniqueID(), err)
 	}
+}
 
-	gmp, err := d2netpacket.CreateGenerateMapPacket(d2enum.RegionAct1Town)
-	if err != nil {
-		g.Errorf("GenerateMapPacket: %v", err)
-	}
+func (g *GameServer) handleClientConnection(client ClientConnection, x, y float64) {
+	g.sendServerInfo(client, false)
 
-	err = client.SendPacketToClient(gmp)
-	if err != nil {
-		g.Errorf("GameServer: error sending GenerateMapPacket to client %s: %s", client.GetUniqueID(), err)
-	}
+	g.sendGenerateMap(client, g.start)
 
 	playerState := client.GetPlayerState()
 
@@ -365,6 +645,8 @@ func (g *GameServer) handleClientConnection(client ClientConnection, x, y float6
 	playerX := int(x*subtilesPerTile) + middleOfTileOffset
 	playerY := int(y*subtilesPerTile) + middleOfTileOffset
 
+	g.resetPlayerMovement(client.GetUniqueID(), float64(playerX)/subtilesPerTile, float64(playerY)/subtilesPerTile)
+
 	d2hero.HydrateSkills(playerState.Skills, g.asset)
 
 	createPlayerPacket, err := d2netpacket.CreateAddPlayerPacket(
@@ -385,6 +667,10 @@ func (g *GameServer) handleClientConnection(client ClientConnection, x, y float6
 	}
 
 	for _, connection := range g.connections {
+		if g.playerArea(connection.GetUniqueID()) != g.start {
+			continue
+		}
+
 		err := connection.SendPacketToClient(createPlayerPacket)
 		if err != nil {
 			g.Errorf("GameServer: error sending %T to client %s: %s", createPlayerPacket, connection.GetUniqueID(), err)
@@ -426,9 +712,18 @@ func (g *GameServer) handleClientConnection(client ClientConnection, x, y float6
 // OnClientDisconnected removes the given client from the list
 // of client connections.
 // If this client was the host, disconnects all clients and kills GameServer.
+// The caller must hold the lock of the server.
 func (g *GameServer) OnClientDisconnected(client ClientConnection) {
 	g.Infof("Client disconnected with an id of %s", client.GetUniqueID())
 	delete(g.connections, client.GetUniqueID())
+	delete(g.areas, client.GetUniqueID())
+	g.removePlayerMovement(client.GetUniqueID())
+	g.closeSession(client.GetUniqueID())
+	g.releaseCharacter(client)
+
+	if simulated, ok := client.(*SimulatedClientConnection); ok {
+		simulated.Close()
+	}
 
 	if client.GetConnectionType() == d2clientconnectiontype.Local {
 		g.Info("Host disconnected, game server shuting down")
@@ -440,10 +735,86 @@ func (g *GameServer) OnClientDisconnected(client ClientConnection) {
 			g.sendPacketToClients(serverClosed)
 		}
 
-		g.Stop()
+		g.stop()
+
+		return
+	}
+
+	if g.onEmpty != nil && g.isEmpty() {
+		g.onEmpty(g)
 	}
 }
 
+// savePlayer saves the player of the client as the client sent it, when the server does
+// not own the characters.
+func (g *GameServer) savePlayer(client ClientConnection, packet *d2netpacket.SavePlayerPacket) {
+	g.Lock()
+	defer g.Unlock()
+
+	playerState := client.GetPlayerState()
+	if playerState == nil || packet.Player == nil {
+		return
+	}
+
+	if left := packet.Player.LeftSkill; left != nil {
+		playerState.LeftSkill = left.Shallow.SkillID
+	}
+
+	if right := packet.Player.RightSkill; right != nil {
+		playerState.RightSkill = right.Shallow.SkillID
+	}
+
+	playerState.Stats = packet.Player.Stats
+	playerState.Act = packet.Player.Act
+	playerState.Difficulty = packet.Difficulty
+
+	if err := g.heroStateFactory.Save(playerState); err != nil {
+		g.Errorf("GameServer: error saving saving Player: %s", err)
+	}
+}
+
+// disconnectClient removes a client which told the server it quits, and relays the
+// notification to the other clients.
+func (g *GameServer) disconnectClient(client ClientConnection, notification d2netpacket.NetPacket) {
+	g.Lock()
+	defer g.Unlock()
+
+	// the client was kicked, or its connection dropped, while the notification was queued
+	if current, found := g.connections[client.GetUniqueID()]; !found || current != client {
+		return
+	}
+
+	g.sendPacketToClients(notification)
+	g.OnClientDisconnected(client)
+}
+
+// isEmpty returns true if the game has no players, including players whose connection
+// dropped. The caller must hold the lock of the server.
+func (g *GameServer) isEmpty() bool {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	return len(g.connections) == 0 && len(g.sessions) == 0
+}
+
+// isFull returns true if the game has no room for another player. Players whose
+// connection dropped keep their slot until their session expires. The caller must
+// hold the lock of the server.
+func (g *GameServer) isFull() bool {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	players := len(g.connections)
+
+	for _, s := range g.sessions {
+		if s.expiry != nil {
+			players++
+		}
+	}
+
+	return players >= g.maxConnections
+}
+
 // OnPacketReceived is called when a packet has been received from a remote client,
 // and by the local client to 'send' a packet to the server,
 // nolint:gocyclo // switch statement on packet type makes sense, no need to change
@@ -454,40 +825,44 @@ func (g *GameServer) OnPacketReceived(client ClientConnection, packet d2netpacke
 
 	switch packet.PacketType {
 	case d2netpackettype.MovePlayer:
-		movePacket, err := d2netpacket.UnmarshalMovePlayer(packet.PacketData)
+		movePacket, err := d2netpacket.UnmarshalMovePlayer(packet)
 		if err != nil {
 			return err
 		}
 
-		playerState := g.connections[client.GetUniqueID()].GetPlayerState()
-		playerState.X = movePacket.DestX
-		playerState.Y = movePacket.DestY
+		// the player state follows the movement, see updatePlayerPositions
+		position, err := g.validateMove(client, &movePacket)
+		if err != nil {
+			g.rejectMove(client, &movePacket, position, err)
+			break
+		}
 
-		g.sendPacketToClients(packet)
-	case d2netpackettype.CastSkill, d2netpackettype.SpawnItem:
-		g.sendPacketToClients(packet)
+		g.RLock()
+		g.sendPacketToArea(g.playerArea(client.GetUniqueID()), packet)
+		g.RUnlock()
+	case d2netpackettype.CastSkill:
+		return g.castSkill(client, packet)
+	case d2netpackettype.SpawnItem:
+		return g.spawnItem(client, packet)
+	case d2netpackettype.EntityStatesAck:
+		return g.acknowledgeEntityStates(client, packet)
 	case d2netpackettype.SavePlayer:
-		savePacket, err := d2netpacket.UnmarshalSavePlayer(packet.PacketData)
+		savePacket, err := d2netpacket.UnmarshalSavePlayer(packet)
 		if err != nil {
 			return err
 		}
 
-		playerState := g.connections[client.GetUniqueID()].GetPlayerState()
-		playerState.LeftSkill = savePacket.Player.LeftSkill.Shallow.SkillID
-		playerState.RightSkill = savePacket.Player.RightSkill.Shallow.SkillID
-		playerState.Stats = savePacket.Player.Stats
-		playerState.Act = savePacket.Player.Act
-		playerState.Difficulty = savePacket.Difficulty
-
-		err = g.heroStateFactory.Save(playerState)
-		if err != nil {
-			g.Errorf("GameServer: error saving saving Player: %s", err)
+		if g.characters != nil {
+			return g.saveCharacter(client, &savePacket)
 		}
+
+		g.savePlayer(client, &savePacket)
+	case d2netpackettype.Chat:
+		return g.handleChat(client, packet)
 	case d2netpackettype.PlayerConnectionRequest:
 		break // prevent log message. these are handled by handleConnection
 	case d2netpackettype.PlayerDisconnectionNotification:
-		g.sendPacketToClients(packet)
-		g.OnClientDisconnected(client)
+		g.disconnectClient(client, packet)
 	default:
 		g.Warningf("GameServer: received unknown packet %s", packet.PacketType)
 	}
@@ -0,0 +1,674 @@
+package d2server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2enum"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2admin"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2metrics"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2udpchannel"
+)
+
+const lobbyLogPrefix = "Lobby"
+
+const (
+	maxGames            = 32          // Most games a lobby hosts at once
+	maxGameNameLength   = 32          // In characters
+	unjoinedGameTimeout = time.Minute // How long a created game waits for its first player
+
+	defaultDifficulty = d2enum.DifficultyNormal // Of the games created for players joining without a game ID
+)
+
+var (
+	errTooManyGames        = errors.New("there are too many games, try again later")
+	errNoSuchGame          = errors.New("no such game")
+	errWrongPassword       = errors.New("wrong password")
+	errWrongServerPassword = errors.New("wrong server password")
+	errInvalidDifficulty   = errors.New("invalid difficulty")
+)
+
+// GameOptions are the settings of a game hosted by a lobby.
+type GameOptions struct {
+	Name       string
+	Difficulty d2enum.DifficultyType
+	Password   string // Players need it to join the game, if it is set
+	MaxPlayers int
+}
+
+// Lobby hosts several independent games, each with its own map, on the port of the
+// game server. Clients list and create games with the lobby packets, and join one
+// with the game ID of their connection request. A game is removed once its last
+// player left.
+type Lobby struct {
+	sync.Mutex
+	asset         *d2asset.AssetManager
+	logLevel      d2util.LogLevel
+	games         []*GameServer // In the order they were created
+	pendingGames  int           // Games being created, which count against maxGames
+	nextGameID    int
+	maxPlayers    int                 // Most players a game can have
+	netConditions d2netsim.Conditions // Simulated network conditions of remote clients
+	bans          *d2admin.BanList
+	tlsConfig     *tls.Config // Clients connect over TLS, if it is set
+	password      string      // Players need it to join any game, if it is set
+	listener      net.Listener
+	udpListener   net.Listener // Takes the clients connecting over UDP, unless TLS is required
+	ctx           context.Context
+	cancel        context.CancelFunc
+	started       time.Time
+	limits        *limitCounters  // Shared by the games, see rate_limit.go
+	characters    *characterStore // Owns the characters of the players if set, see characters.go
+	metrics       *d2metrics.Metrics
+
+	*d2util.Logger
+}
+
+// NewLobby creates a lobby whose games have at most maxPlayers players.
+func NewLobby(asset *d2asset.AssetManager, l d2util.LogLevel, maxPlayers int) (*Lobby, error) {
+	bans, err := d2admin.LoadBanList("")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lobby := &Lobby{
+		asset:      asset,
+		logLevel:   l,
+		maxPlayers: maxPlayers,
+		bans:       bans,
+		limits:     &limitCounters{},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	lobby.Logger = d2util.NewLogger()
+	lobby.Logger.SetPrefix(lobbyLogPrefix)
+	lobby.Logger.SetLevel(l)
+
+	return lobby, nil
+}
+
+// LoadBanList loads the banned IP addresses from the file at path. New bans are saved to it.
+func (l *Lobby) LoadBanList(path string) error {
+	bans, err := d2admin.LoadBanList(path)
+	if err != nil {
+		return err
+	}
+
+	l.bans = bans
+
+	return nil
+}
+
+// SimulateNetwork makes the connections of remote clients behave like a
+// network with the given conditions. It must be called before Start.
+func (l *Lobby) SimulateNetwork(conditions d2netsim.Conditions) {
+	l.netConditions = conditions
+}
+
+// EnableTLS makes the clients connect over TLS with the given configuration. It must
+// be called before Start.
+func (l *Lobby) EnableTLS(config *tls.Config) {
+	l.tlsConfig = config
+}
+
+// SetPassword makes the server private: players need the password to list, create and
+// join games, besides the password of the game. It must be called before Start.
+func (l *Lobby) SetPassword(password string) {
+	l.password = password
+}
+
+// KeepCharacters makes the server own the characters of the players, and keep them in
+// the directory. The clients then only choose the name and class of their character.
+// It must be called before Start.
+func (l *Lobby) KeepCharacters(dir string) error {
+	characters, err := newCharacterStore(l.asset, dir)
+	if err != nil {
+		return err
+	}
+
+	l.characters = characters
+
+	return nil
+}
+
+// EnableMetrics makes the lobby and its games count the packets and bytes of the
+// clients, and the duration of the ticks. It must be called before Start.
+func (l *Lobby) EnableMetrics(metrics *d2metrics.Metrics) {
+	l.metrics = metrics
+}
+
+// Start begins listening for connections on every network interface. It returns an
+// error if it is unable to bind to the socket.
+func (l *Lobby) Start() error {
+	address := "0.0.0.0:" + strconv.Itoa(Port)
+
+	l.Infof("Starting lobby @ %s", address)
+
+	listener, err := net.Listen("tcp4", address)
+	if err != nil {
+		return err
+	}
+
+	listener = l.metrics.Listener(listener)
+
+	if l.tlsConfig != nil {
+		listener = tls.NewListener(listener, l.tlsConfig)
+	}
+
+	l.listener = listener
+
+	// UDP has no TLS, so clients may only connect over UDP if the lobby does not ask for it
+	if l.tlsConfig == nil {
+		udpListener, err := d2udpchannel.Listen(address)
+		if err != nil {
+			_ = l.listener.Close()
+			return err
+		}
+
+		l.udpListener = l.metrics.Listener(udpListener)
+
+		go acceptConnections(l.ctx, l.udpListener, l.Logger, l.handleConnection)
+	}
+
+	l.started = time.Now()
+
+	go acceptConnections(l.ctx, l.listener, l.Logger, l.handleConnection)
+
+	return nil
+}
+
+// handleConnection answers a lobby request, or hands a connection request over to the
+// game the client joins.
+func (l *Lobby) handleConnection(conn net.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			l.Errorf("failed to close the connection: %s\n", conn.RemoteAddr())
+		}
+	}()
+
+	decoder := d2netpacket.NewDecoder(d2netpacket.CodecJSON, conn)
+
+	packet, err := decoder.Decode()
+	if err != nil {
+		if errors.Is(err, d2netpacket.ErrFrameTooLarge) {
+			l.limits.addOversized()
+		}
+
+		if err != io.EOF {
+			l.Error(err.Error())
+		}
+
+		return
+	}
+
+	l.metrics.CountReceived(packet.PacketType)
+
+	// the request is read first, so the client receives the rejection before the connection closes
+	if ip, ok := remoteIP(conn.RemoteAddr()); ok && l.bans.Contains(ip) {
+		l.Infof("Refusing connection from banned address %s", ip)
+
+		if packet.PacketType == d2netpackettype.PlayerConnectionRequest {
+			reject(conn, l.Logger, d2netpacket.RejectedBanned, "")
+		}
+
+		return
+	}
+
+	switch packet.PacketType {
+	case d2netpackettype.ListGames:
+		request, err := d2netpacket.UnmarshalListGames(packet)
+		if err != nil {
+			l.Errorf("Failed to unmarshal ListGames: %s", err)
+			return
+		}
+
+		if !l.checkServerPassword(request.ServerPassword) {
+			l.refuse(conn, errWrongServerPassword)
+			return
+		}
+
+		gameList, err := d2netpacket.CreateGameListPacket(l.gameInfos())
+		if err != nil {
+			l.Errorf("GameListPacket: %v", err)
+			return
+		}
+
+		l.send(conn, gameList)
+	case d2netpackettype.CreateGame:
+		l.handleCreateGame(conn, packet)
+	case d2netpackettype.PlayerConnectionRequest:
+		l.join(conn, decoder, packet)
+	default:
+		l.Infof("Closing connection with %s: unexpected %s", conn.RemoteAddr(), packet.PacketType)
+	}
+}
+
+func (l *Lobby) handleCreateGame(conn net.Conn, packet d2netpacket.NetPacket) {
+	request, err := d2netpacket.UnmarshalCreateGame(packet)
+	if err != nil {
+		l.Errorf("Failed to unmarshal CreateGame: %s", err)
+		return
+	}
+
+	if !l.checkServerPassword(request.ServerPassword) {
+		l.refuse(conn, errWrongServerPassword)
+		return
+	}
+
+	game, err := l.createGame(GameOptions{
+		Name:       request.Name,
+		Difficulty: request.Difficulty,
+		Password:   request.Password,
+		MaxPlayers: request.MaxPlayers,
+	})
+	if err != nil {
+		l.refuse(conn, err)
+		return
+	}
+
+	gameCreated, err := d2netpacket.CreateGameCreatedPacket(game.id)
+	if err != nil {
+		l.Errorf("GameCreatedPacket: %v", err)
+		return
+	}
+
+	l.send(conn, gameCreated)
+}
+
+// join hands the connection over to the game the client asked for. The game serves it
+// until it closes. A client which can not join is told why.
+func (l *Lobby) join(conn net.Conn, decoder d2netpacket.PacketDecoder, packet d2netpacket.NetPacket) {
+	request, err := d2netpacket.UnmarshalPlayerConnectionRequest(packet)
+	if err != nil {
+		l.Errorf("Failed to unmarshal PlayerConnectionRequest: %s", err)
+		return
+	}
+
+	// checked before a game is created for the client
+	if request.ProtocolVersion != d2netpacket.ProtocolVersion {
+		l.Infof("Refusing %s: it speaks protocol version %d", conn.RemoteAddr(), request.ProtocolVersion)
+		reject(conn, l.Logger, d2netpacket.RejectedVersion, "")
+
+		return
+	}
+
+	if !l.checkServerPassword(request.ServerPassword) {
+		l.Infof("Refusing %s: %s", conn.RemoteAddr(), errWrongServerPassword)
+		reject(conn, l.Logger, d2netpacket.RejectedPassword, "")
+
+		return
+	}
+
+	game, err := l.findGame(request.GameID)
+	if err != nil {
+		l.Infof("Refusing %s: %s", conn.RemoteAddr(), err)
+		reject(conn, l.Logger, d2netpacket.RejectedOther, err.Error())
+
+		return
+	}
+
+	if game.options.Password != "" &&
+		subtle.ConstantTimeCompare([]byte(request.Password), []byte(game.options.Password)) != 1 {
+		l.Infof("Refusing %s: %s", conn.RemoteAddr(), errWrongPassword)
+		reject(conn, l.Logger, d2netpacket.RejectedPassword, "")
+
+		return
+	}
+
+	game.serveConnection(conn, decoder, packet)
+}
+
+// checkServerPassword returns true if the server is not private, or the password is its
+// password. Every lobby request of a private server must carry it.
+func (l *Lobby) checkServerPassword(password string) bool {
+	return l.password == "" || subtle.ConstantTimeCompare([]byte(password), []byte(l.password)) == 1
+}
+
+// findGame returns the game with the given ID. Without an ID, it returns the oldest game
+// anyone can join, creating one if there is none.
+func (l *Lobby) findGame(id string) (*GameServer, error) {
+	if id == "" {
+		if game := l.openGame(); game != nil {
+			return game, nil
+		}
+
+		return l.createGame(GameOptions{Difficulty: defaultDifficulty})
+	}
+
+	for _, game := range l.gameList() {
+		if game.id == id {
+			return game, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errNoSuchGame, id)
+}
+
+// openGame returns the oldest game anyone can join, or nil if there is none.
+func (l *Lobby) openGame() *GameServer {
+	for _, game := range l.gameList() {
+		if game.options.Password == "" && game.hasRoom() {
+			return game
+		}
+	}
+
+	return nil
+}
+
+// Difficulty returns the difficulty of the game players join without a game ID, which
+// is the oldest game anyone can join, or of the game created for them if there is none.
+func (l *Lobby) Difficulty() d2enum.DifficultyType {
+	if game := l.openGame(); game != nil {
+		return game.options.Difficulty
+	}
+
+	return defaultDifficulty
+}
+
+// createGame creates and starts a game with a new map. It is removed if no player
+// joins it in time.
+func (l *Lobby) createGame(options GameOptions) (*GameServer, error) {
+	options.Name = strings.TrimSpace(options.Name)
+	if utf8.RuneCountInString(options.Name) > maxGameNameLength {
+		return nil, fmt.Errorf("game names can not be longer than %d characters", maxGameNameLength)
+	}
+
+	if options.Difficulty < d2enum.DifficultyNormal || options.Difficulty > d2enum.DifficultyHell {
+		return nil, fmt.Errorf("%w: %d", errInvalidDifficulty, options.Difficulty)
+	}
+
+	if options.MaxPlayers <= 0 || options.MaxPlayers > l.maxPlayers {
+		options.MaxPlayers = l.maxPlayers
+	}
+
+	id, err := l.reserveGame()
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Name == "" {
+		options.Name = "Game " + id
+	}
+
+	game, err := NewGameServer(l.asset, true, l.logLevel, options.MaxPlayers)
+	if err != nil {
+		l.addReservedGame(nil)
+		return nil, err
+	}
+
+	game.id = id
+	game.options = options
+	game.world.SetDifficulty(options.Difficulty)
+	game.bans = l.bans
+	game.limits = l.limits
+	game.characters = l.characters
+	game.metrics = l.metrics
+	game.onEmpty = l.removeGame
+	game.SimulateNetwork(l.netConditions)
+	game.SetPrefix(logPrefix + " " + id)
+
+	l.addReservedGame(game)
+
+	game.run()
+
+	l.Infof("Created game %s %q, %s, up to %d players", id, options.Name, options.Difficulty, options.MaxPlayers)
+
+	time.AfterFunc(unjoinedGameTimeout, func() {
+		game.Lock()
+		defer game.Unlock()
+
+		if game.isEmpty() {
+			l.removeGame(game)
+		}
+	})
+
+	return game, nil
+}
+
+// reserveGame takes the slot of a game being created, so games created at the same time
+// do not exceed maxGames, and returns the ID of the game.
+func (l *Lobby) reserveGame() (string, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.games)+l.pendingGames >= maxGames {
+		return "", errTooManyGames
+	}
+
+	l.pendingGames++
+	l.nextGameID++
+
+	return strconv.Itoa(l.nextGameID), nil
+}
+
+// addReservedGame adds a game whose slot was reserved, or frees the slot if the game
+// could not be created.
+func (l *Lobby) addReservedGame(game *GameServer) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.pendingGames--
+
+	if game != nil {
+		l.games = append(l.games, game)
+	}
+}
+
+// removeGame stops a game whose last player left. The caller must hold the lock of the game.
+func (l *Lobby) removeGame(game *GameServer) {
+	l.Lock()
+	defer l.Unlock()
+
+	for i := range l.games {
+		if l.games[i] != game {
+			continue
+		}
+
+		l.games = append(l.games[:i], l.games[i+1:]...)
+		game.cancel()
+
+		l.Infof("Removed game %s %q, it has no players", game.id, game.options.Name)
+
+		return
+	}
+}
+
+// gameList returns the games. The lobby does not hold its lock while it calls a game,
+// because a game calls the lobby with its own lock held once its last player left.
+func (l *Lobby) gameList() []*GameServer {
+	l.Lock()
+	defer l.Unlock()
+
+	games := make([]*GameServer, len(l.games))
+	copy(games, l.games)
+
+	return games
+}
+
+func (l *Lobby) gameInfos() []d2netpacket.GameInfo {
+	games := l.gameList()
+	infos := make([]d2netpacket.GameInfo, len(games))
+
+	for i, game := range games {
+		infos[i] = d2netpacket.GameInfo{
+			ID:          game.id,
+			Name:        game.options.Name,
+			Players:     game.PlayerCount(),
+			MaxPlayers:  game.maxConnections,
+			Difficulty:  game.options.Difficulty,
+			HasPassword: game.options.Password != "",
+		}
+	}
+
+	return infos
+}
+
+// refuse tells the client why the lobby refused its lobby request.
+func (l *Lobby) refuse(conn net.Conn, reason error) {
+	l.Infof("Refusing request of %s: %s", conn.RemoteAddr(), reason)
+
+	message, err := d2netpacket.CreateSystemMessagePacket(reason.Error())
+	if err != nil {
+		l.Errorf("SystemMessagePacket: %v", err)
+		return
+	}
+
+	l.send(conn, message)
+}
+
+func (l *Lobby) send(conn net.Conn, packet d2netpacket.NetPacket) {
+	if err := d2netpacket.NewEncoder(d2netpacket.CodecJSON, conn).Encode(packet); err != nil {
+		l.Errorf("Lobby: error sending %s to %s: %s", packet.PacketType, conn.RemoteAddr(), err)
+		return
+	}
+
+	l.metrics.CountSent(packet.PacketType)
+}
+
+// PlayerCount returns the number of players in all games.
+func (l *Lobby) PlayerCount() int {
+	players := 0
+
+	for _, game := range l.gameList() {
+		players += game.PlayerCount()
+	}
+
+	return players
+}
+
+// Status returns the state of the lobby.
+func (l *Lobby) Status() d2admin.Status {
+	games := l.gameList()
+	status := d2admin.Status{
+		Uptime: time.Since(l.started),
+		Games:  len(games),
+		Bans:   len(l.bans.List()),
+	}
+
+	for _, game := range games {
+		status.Players += game.PlayerCount()
+		status.Suspended += game.suspendedCount()
+	}
+
+	status.Dropped, status.Oversized, status.Flooders = l.limits.snapshot()
+
+	return status
+}
+
+// Games describes the games, in the order they were created.
+func (l *Lobby) Games() []d2admin.Game {
+	games := l.gameList()
+	result := make([]d2admin.Game, len(games))
+
+	for i, game := range games {
+		result[i] = game.Game()
+	}
+
+	return result
+}
+
+// Players returns the connected players of every game.
+func (l *Lobby) Players() []d2admin.Player {
+	var players []d2admin.Player
+
+	for _, game := range l.gameList() {
+		players = append(players, game.Players()...)
+	}
+
+	return players
+}
+
+// Kick disconnects the player, whichever game it is in, telling the client the reason.
+func (l *Lobby) Kick(id, reason string) error {
+	for _, game := range l.gameList() {
+		if err := game.Kick(id, reason); !errors.Is(err, errNoSuchPlayer) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errNoSuchPlayer, id)
+}
+
+// Ban bans the IP address and kicks the players connected from it in every game.
+func (l *Lobby) Ban(ip string) (int, error) {
+	if err := l.bans.Add(ip); err != nil {
+		return 0, err
+	}
+
+	kicked := 0
+
+	for _, game := range l.gameList() {
+		n, err := game.kickAddress(net.ParseIP(ip))
+		kicked += n
+
+		if err != nil {
+			return kicked, err
+		}
+	}
+
+	return kicked, nil
+}
+
+// Say sends a message to every player of every game.
+func (l *Lobby) Say(message string) error {
+	for _, game := range l.gameList() {
+		if err := game.Say(message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveAll saves every player of every game, including the players whose connection dropped.
+func (l *Lobby) SaveAll() (int, error) {
+	saved := 0
+
+	for _, game := range l.gameList() {
+		n, err := game.SaveAll()
+		saved += n
+
+		if err != nil {
+			return saved, err
+		}
+	}
+
+	return saved, nil
+}
+
+// Shutdown stops taking connections, and shuts every game down.
+func (l *Lobby) Shutdown() {
+	l.cancel()
+
+	for _, listener := range []net.Listener{l.listener, l.udpListener} {
+		if listener == nil {
+			continue
+		}
+
+		if err := listener.Close(); err != nil {
+			l.Errorf("failed to close the listener %s, err: %v\n", listener.Addr(), err)
+		}
+	}
+
+	for _, game := range l.gameList() {
+		game.Shutdown()
+	}
+}
@@ -0,0 +1,62 @@
+package d2server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReserveGameAtOnce(t *testing.T) {
+	l := &Lobby{}
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		reserved = make(map[string]bool)
+		refused  int
+	)
+
+	for i := 0; i < 2*maxGames; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			id, err := l.reserveGame()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			switch {
+			case errors.Is(err, errTooManyGames):
+				refused++
+			case err != nil:
+				t.Error(err)
+			case reserved[id]:
+				t.Errorf("expected the games to have their own IDs, got %s twice", id)
+			default:
+				reserved[id] = true
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(reserved) != maxGames || refused != maxGames {
+		t.Fatalf("expected %d games to be reserved and %d refused, got %d and %d",
+			maxGames, maxGames, len(reserved), refused)
+	}
+
+	// a game which could not be created frees its slot
+	l.addReservedGame(nil)
+
+	if _, err := l.reserveGame(); err != nil {
+		t.Fatalf("expected the slot of a game which could not be created to be free, got %v", err)
+	}
+
+	l.addReservedGame(&GameServer{})
+
+	if _, err := l.reserveGame(); !errors.Is(err, errTooManyGames) {
+		t.Fatalf("expected the created games to count against the limit, got %v", err)
+	}
+}
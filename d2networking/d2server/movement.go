@@ -0,0 +1,325 @@
+package d2server
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2path"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapworld"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	// maxMoveTolerance is how far, in sub tiles, the start of a move may be from where the
+	// server estimates the player is. It covers latency and rounding on the client.
+	maxMoveTolerance = 10
+
+	// maxRunDrift is how far, in sub tiles, a running player may be ahead of the estimate of
+	// the server, which has the players walk, besides maxMoveTolerance.
+	maxRunDrift = 30
+
+	// maxMovePathLength is how far, in sub tiles along the path to it, the destination of
+	// a move may be. It is further than a click on the screen leads.
+	maxMovePathLength = 150
+
+	// moveArrival is how close, in sub tiles, the path to a destination must end to it for
+	// the destination to be reachable.
+	moveArrival = 1
+)
+
+var (
+	errMoveTooFar           = errors.New("player moved further than its speed allows")
+	errMoveBlocked          = errors.New("destination blocks walking")
+	errMoveUnreachable      = errors.New("destination can not be reached")
+	errMoveStartBlocked     = errors.New("start blocks walking")
+	errMoveStartUnreachable = errors.New("start can not be reached")
+	errMoveWrongPlayer      = errors.New("player tried to move another player")
+)
+
+// playerMovement is the position of a player at the time of its last accepted MovePlayer packet,
+// and the destination the player walks to.
+type playerMovement struct {
+	position d2vector.Position
+	dest     d2vector.Position
+	path     []d2vector.Position // Points the player walks through to the destination
+	time     time.Time
+	warp     *d2mapentity.Warp // Warp at the destination, entered once the player reaches it
+}
+
+// resetPlayerMovement records the given position, in tiles, as the current position of the player.
+func (g *GameServer) resetPlayerMovement(playerID string, x, y float64) {
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	position := d2vector.NewPositionTile(x, y)
+
+	g.movement[playerID] = &playerMovement{
+		position: position,
+		dest:     position,
+		time:     time.Now(),
+	}
+}
+
+// playerPosition estimates where the player is now, assuming it walks along the path of its
+// last move.
+func (g *GameServer) playerPosition(playerID string) (d2vector.Position, bool) {
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	last, found := g.movement[playerID]
+	if !found {
+		return d2vector.Position{}, false
+	}
+
+	position, _ := last.estimate(time.Now())
+
+	return position, true
+}
+
+// estimate returns where the player is at the given time, and the distance along its path
+// it has left to walk, which is 0 once it reached its destination.
+func (m *playerMovement) estimate(now time.Time) (position d2vector.Position, remaining float64) {
+	walked := d2mapentity.BaseWalkSpeed * now.Sub(m.time).Seconds()
+	length := pathLength(m.position, m.path)
+
+	if walked >= length {
+		return m.dest, 0
+	}
+
+	remaining = length - walked
+	from := m.position
+
+	for _, to := range m.path {
+		distance := from.Distance(&to.Vector)
+		if walked >= distance {
+			walked -= distance
+			from = to
+
+			continue
+		}
+
+		direction := to.Clone()
+		direction.Subtract(&from.Vector)
+		direction.SetLength(walked)
+
+		at := from.Clone()
+		at.Add(direction)
+
+		return d2vector.Position{Vector: *at}, remaining
+	}
+
+	return m.dest, 0
+}
+
+// stop has the player stand at the given position from the given time on.
+func (m *playerMovement) stop(position d2vector.Position, now time.Time) {
+	m.position, m.dest, m.path, m.time, m.warp = position, position, nil, now, nil
+}
+
+// pathLength returns the length of a path from the given position.
+func pathLength(from d2vector.Position, path []d2vector.Position) float64 {
+	length := 0.0
+
+	for idx := range path {
+		length += from.Distance(&path[idx].Vector)
+		from = path[idx]
+	}
+
+	return length
+}
+
+// reachedWarp returns the warp the player walked to, once it reached it. The warp is
+// only returned once.
+func (g *GameServer) reachedWarp(playerID string) *d2mapentity.Warp {
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	last, found := g.movement[playerID]
+	if !found || last.warp == nil {
+		return nil
+	}
+
+	if _, remaining := last.estimate(time.Now()); remaining > 0 {
+		return nil
+	}
+
+	warp := last.warp
+	last.warp = nil
+
+	return warp
+}
+
+func (g *GameServer) removePlayerMovement(playerID string) {
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	delete(g.movement, playerID)
+}
+
+// updatePlayerPositions sets the position of each player to where the server estimates
+// it is, so players are saved and resumed where they are rather than where they walk to.
+// The caller must hold the lock of the server.
+func (g *GameServer) updatePlayerPositions() {
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	now := time.Now()
+
+	for id, client := range g.connections {
+		last, found := g.movement[id]
+		if !found {
+			continue
+		}
+
+		playerState := client.GetPlayerState()
+		if playerState == nil {
+			continue
+		}
+
+		position, _ := last.estimate(now)
+		world := position.World()
+		playerState.X, playerState.Y = world.X(), world.Y()
+	}
+}
+
+// validateMove checks a MovePlayer packet against the map of the player and the movement the
+// server last accepted for the player, see checkMove. The accepted move becomes the movement
+// of the player.
+//
+// If the move is rejected, the returned position is where the player should be placed instead.
+func (g *GameServer) validateMove(client ClientConnection, move *d2netpacket.MovePlayerPacket) (d2vector.Position, error) {
+	now := time.Now()
+	start := d2vector.NewPositionTile(move.StartX, move.StartY)
+
+	g.RLock()
+	mapEngine, mapErr := g.world.Map(g.playerArea(client.GetUniqueID()))
+
+	var warp *d2mapentity.Warp
+	if mapErr == nil {
+		warp = d2mapworld.WarpAt(mapEngine, move.DestX, move.DestY)
+	}
+	g.RUnlock()
+
+	g.movementMutex.Lock()
+	defer g.movementMutex.Unlock()
+
+	last, found := g.movement[client.GetUniqueID()]
+	if !found {
+		last = &playerMovement{position: start, dest: start, time: now}
+		g.movement[client.GetUniqueID()] = last
+	}
+
+	if mapErr != nil {
+		return last.position, mapErr
+	}
+
+	return checkMove(last, client.GetUniqueID(), move, now, mapEngine.IsWalkable, warp)
+}
+
+// checkMove checks a move of a player against its last accepted movement, see checkStart for
+// its start. The destination must be walkable, or the warp, and be reachable along a path no
+// longer than maxMovePathLength.
+//
+// An accepted move becomes the movement of the player, and the destination is returned. A
+// move whose start is wrong is rejected with the position the player should be placed at,
+// and other moves are rejected with the start of the move. Either way the player stops there.
+func checkMove(last *playerMovement, playerID string, move *d2netpacket.MovePlayerPacket, now time.Time,
+	walkable d2path.Walkable, warp *d2mapentity.Warp) (d2vector.Position, error) {
+	start := d2vector.NewPositionTile(move.StartX, move.StartY)
+	dest := d2vector.NewPositionTile(move.DestX, move.DestY)
+	current, remaining := last.estimate(now)
+
+	if move.PlayerID != playerID {
+		return current, errMoveWrongPlayer
+	}
+
+	if err := checkStart(current, remaining, now.Sub(last.time).Seconds(), start, walkable); err != nil {
+		last.stop(current, now)
+		return current, err
+	}
+
+	stop := func(reason error) (d2vector.Position, error) {
+		last.stop(start, now)
+		return start, reason
+	}
+
+	// the tiles of most warps block walking, the player enters them instead
+	if warp == nil && !walkable(int(math.Floor(dest.X())), int(math.Floor(dest.Y()))) {
+		return stop(errMoveBlocked)
+	}
+
+	path := d2path.FindPath(start, dest, walkable, d2path.DefaultMaxNodes)
+	if len(path) == 0 {
+		return stop(errMoveUnreachable)
+	}
+
+	if pathLength(start, path) > maxMovePathLength {
+		return stop(errMoveTooFar)
+	}
+
+	end := path[len(path)-1]
+
+	if warp != nil {
+		// the path ends next to the warp, whose tiles block walking
+		tile := end.World()
+		if !warp.Contains(tile.X(), tile.Y()) {
+			return stop(errMoveUnreachable)
+		}
+
+		dest = end
+	} else if end.Distance(&dest.Vector) > moveArrival {
+		return stop(errMoveUnreachable)
+	}
+
+	last.position, last.dest, last.path, last.time, last.warp = start, dest, path, now, warp
+
+	return dest, nil
+}
+
+// checkStart checks the start of a move is where the player can be: a walkable sub tile
+// which the player reaches from where the server estimates it is, the current position,
+// along a path no longer than the player may be away from it. A running player may be
+// ahead of the estimate, but not past its destination, which is the remaining distance.
+func checkStart(current d2vector.Position, remaining, elapsed float64, start d2vector.Position,
+	walkable d2path.Walkable) error {
+	if !walkable(int(math.Floor(start.X())), int(math.Floor(start.Y()))) {
+		return errMoveStartBlocked
+	}
+
+	ahead := math.Min((d2mapentity.BaseRunSpeed-d2mapentity.BaseWalkSpeed)*elapsed, remaining)
+	tolerance := math.Min(ahead, maxRunDrift) + maxMoveTolerance
+
+	if current.Distance(&start.Vector) > tolerance {
+		return errMoveTooFar
+	}
+
+	path := d2path.FindPath(current, start, walkable, d2path.DefaultMaxNodes)
+	if len(path) == 0 || path[len(path)-1].Distance(&start.Vector) > moveArrival ||
+		pathLength(current, path) > tolerance {
+		return errMoveStartUnreachable
+	}
+
+	return nil
+}
+
+// rejectMove tells the clients in the area of the player to place the player at the given
+// position.
+func (g *GameServer) rejectMove(client ClientConnection, move *d2netpacket.MovePlayerPacket, position d2vector.Position,
+	reason error) {
+	g.Warningf("Rejected move %d of player %s: %s", move.Sequence, client.GetUniqueID(), reason)
+
+	world := position.World()
+
+	correction, err := d2netpacket.CreateMovePlayerCorrectionPacket(client.GetUniqueID(), world.X(), world.Y(), move.Sequence)
+	if err != nil {
+		g.Errorf("MovePlayerCorrectionPacket: %v", err)
+		return
+	}
+
+	g.RLock()
+	g.sendPacketToArea(g.playerArea(client.GetUniqueID()), correction)
+	g.RUnlock()
+}
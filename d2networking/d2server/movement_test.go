@@ -0,0 +1,207 @@
+package d2server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	testFieldSize = 300 // sub tiles
+	testPlayerID  = "player"
+)
+
+// testWalkable is an open field with a walled enclosure around tile 20, 20 and the blocked
+// tile 14, 10 of a warp.
+func testWalkable(x, y int) bool {
+	if x < 0 || y < 0 || x >= testFieldSize || y >= testFieldSize {
+		return false
+	}
+
+	if x >= 70 && x < 75 && y >= 50 && y < 55 {
+		return false
+	}
+
+	dx, dy := x-102, y-102
+	if dx < 0 {
+		dx = -dx
+	}
+
+	if dy < 0 {
+		dy = -dy
+	}
+
+	return dx != 5 && dy != 5 || dx > 5 || dy > 5
+}
+
+func TestCheckMove(t *testing.T) {
+	warp := d2mapentity.NewWarp(14, 10, 1, 2, &d2records.LevelWarpRecord{SelectDX: 1, SelectDY: 1}, "")
+
+	tests := []struct {
+		name         string
+		playerID     string
+		startX       float64
+		destX, destY float64
+		warp         *d2mapentity.Warp
+		err          error
+	}{
+		{name: "walk", playerID: testPlayerID, startX: 10, destX: 12, destY: 12},
+		{name: "wrong player", playerID: "other", startX: 10, destX: 12, destY: 12, err: errMoveWrongPlayer},
+		{name: "start too far", playerID: testPlayerID, startX: 20, destX: 22, destY: 12, err: errMoveTooFar},
+		{name: "destination too far", playerID: testPlayerID, startX: 10, destX: 10, destY: 45, err: errMoveTooFar},
+		{name: "blocked", playerID: testPlayerID, startX: 10, destX: 14.5, destY: 10.5, err: errMoveBlocked},
+		{name: "walled in", playerID: testPlayerID, startX: 10, destX: 20.5, destY: 20.5, err: errMoveUnreachable},
+		{name: "warp", playerID: testPlayerID, startX: 10, destX: 14.5, destY: 10.5, warp: warp},
+	}
+
+	for _, test := range tests {
+		now := time.Now()
+		start := d2vector.NewPositionTile(10, 10)
+		last := &playerMovement{position: start, dest: start, time: now}
+
+		move := &d2netpacket.MovePlayerPacket{
+			PlayerID: test.playerID,
+			StartX:   test.startX,
+			StartY:   10,
+			DestX:    test.destX,
+			DestY:    test.destY,
+		}
+
+		position, err := checkMove(last, testPlayerID, move, now, testWalkable, test.warp)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+
+		if err != nil {
+			if last.warp != nil || last.dest != last.position {
+				t.Errorf("%s: expected the player to stop", test.name)
+			}
+
+			continue
+		}
+
+		if last.dest != position || last.warp != test.warp {
+			t.Errorf("%s: expected the player to walk to %v, it walks to %v", test.name, position, last.dest)
+		}
+
+		if test.warp == nil && position != d2vector.NewPositionTile(test.destX, test.destY) {
+			t.Errorf("%s: expected the destination to be accepted, got %v", test.name, position)
+		}
+	}
+}
+
+func TestCheckMoveStart(t *testing.T) {
+	tests := []struct {
+		name           string
+		position, dest d2vector.Position // Of the last accepted move, in sub tiles
+		elapsed        time.Duration
+		start          d2vector.Position
+		err            error
+	}{
+		{
+			name:     "standing",
+			position: d2vector.NewPosition(50, 50), dest: d2vector.NewPosition(50, 50),
+			elapsed: 10 * time.Second,
+			start:   d2vector.NewPosition(55, 50),
+		},
+		{
+			name:     "teleport after standing",
+			position: d2vector.NewPosition(50, 50), dest: d2vector.NewPosition(50, 50),
+			elapsed: 10 * time.Second,
+			start:   d2vector.NewPosition(100, 50),
+			err:     errMoveTooFar,
+		},
+		{
+			name:     "running ahead",
+			position: d2vector.NewPosition(50, 150), dest: d2vector.NewPosition(150, 150),
+			elapsed: 5 * time.Second,
+			start:   d2vector.NewPosition(115, 150),
+		},
+		{
+			name:     "too far ahead",
+			position: d2vector.NewPosition(50, 150), dest: d2vector.NewPosition(150, 150),
+			elapsed: 5 * time.Second,
+			start:   d2vector.NewPosition(135, 150),
+			err:     errMoveTooFar,
+		},
+		{
+			name:     "start blocked",
+			position: d2vector.NewPosition(65, 52), dest: d2vector.NewPosition(65, 52),
+			start: d2vector.NewPosition(72.5, 52.5),
+			err:   errMoveStartBlocked,
+		},
+		{
+			name:     "start walled in",
+			position: d2vector.NewPosition(112.5, 102.5), dest: d2vector.NewPosition(112.5, 102.5),
+			start: d2vector.NewPosition(104.5, 102.5),
+			err:   errMoveStartUnreachable,
+		},
+	}
+
+	for _, test := range tests {
+		now := time.Now()
+		last := &playerMovement{
+			position: test.position,
+			dest:     test.dest,
+			path:     []d2vector.Position{test.dest},
+			time:     now.Add(-test.elapsed),
+		}
+
+		current, _ := last.estimate(now)
+		start := test.start.World()
+
+		move := &d2netpacket.MovePlayerPacket{
+			PlayerID: testPlayerID,
+			StartX:   start.X(),
+			StartY:   start.Y(),
+			DestX:    start.X(),
+			DestY:    start.Y(),
+		}
+
+		position, err := checkMove(last, testPlayerID, move, now, testWalkable, nil)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+
+		if err != nil && (position != current || last.position != current || last.dest != current) {
+			t.Errorf("%s: expected the player to stop where the server has it, %v, got %v", test.name, current, position)
+		}
+	}
+}
+
+func TestEstimateFollowsPath(t *testing.T) {
+	now := time.Now()
+	last := &playerMovement{
+		position: d2vector.NewPosition(0, 0),
+		dest:     d2vector.NewPosition(10, 10),
+		path:     []d2vector.Position{d2vector.NewPosition(10, 0), d2vector.NewPosition(10, 10)},
+		time:     now.Add(-time.Second),
+	}
+
+	// walked 9 sub tiles along the first leg
+	position, remaining := last.estimate(now)
+	want := d2vector.NewPosition(9, 0)
+
+	if position.Distance(&want.Vector) > 0.01 || remaining < 10.99 || remaining > 11.01 {
+		t.Errorf("expected the player at 9, 0 with 11 left, got %v with %.2f left", position, remaining)
+	}
+
+	// walked 18 sub tiles, around the corner
+	position, remaining = last.estimate(now.Add(time.Second))
+	want = d2vector.NewPosition(10, 8)
+
+	if position.Distance(&want.Vector) > 0.01 || remaining < 1.99 || remaining > 2.01 {
+		t.Errorf("expected the player at 10, 8 with 2 left, got %v with %.2f left", position, remaining)
+	}
+
+	if position, remaining = last.estimate(now.Add(time.Minute)); position != last.dest || remaining != 0 {
+		t.Errorf("expected the player at its destination, got %v with %.2f left", position, remaining)
+	}
+}
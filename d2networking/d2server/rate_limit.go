@@ -0,0 +1,179 @@
+package d2server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// packetClass groups the packet types a client sends which share a rate limit.
+type packetClass int
+
+// Packet classes
+const (
+	classMovement    packetClass = iota // MovePlayer
+	classCombat                         // CastSkill
+	classChat                           // Chat, which includes commands
+	classItems                          // SpawnItem
+	classPersistence                    // SavePlayer
+	classOther                          // Acknowledgements, pongs and the rest
+
+	numPacketClasses
+)
+
+var packetClassNames = [numPacketClasses]string{
+	classMovement:    "movement",
+	classCombat:      "combat",
+	classChat:        "chat",
+	classItems:       "items",
+	classPersistence: "persistence",
+	classOther:       "other",
+}
+
+func (c packetClass) String() string {
+	return packetClassNames[c]
+}
+
+// classOf returns the class of a packet type sent by a client.
+func classOf(packetType d2netpackettype.NetPacketType) packetClass {
+	switch packetType {
+	case d2netpackettype.MovePlayer:
+		return classMovement
+	case d2netpackettype.CastSkill:
+		return classCombat
+	case d2netpackettype.Chat:
+		return classChat
+	case d2netpackettype.SpawnItem:
+		return classItems
+	case d2netpackettype.SavePlayer:
+		return classPersistence
+	}
+
+	return classOther
+}
+
+// rateLimit is the number of packets a client may send per second, and at once.
+type rateLimit struct {
+	rate  float64 // Per second
+	burst float64
+}
+
+// The game controls repeat a held action every 250ms, and the client acknowledges
+// every entity state the world sends at its tick rate.
+var rateLimits = [numPacketClasses]rateLimit{
+	classMovement:    {rate: 10, burst: 20},
+	classCombat:      {rate: 10, burst: 20},
+	classChat:        {rate: 1, burst: 5},
+	classItems:       {rate: 2, burst: 10},
+	classPersistence: {rate: 0.1, burst: 3},
+	classOther:       {rate: 2 * simulationTickRate, burst: 4 * simulationTickRate},
+}
+
+// floodTolerance is how many packets of a client may be dropped, and how many more per
+// second, before it is disconnected for flooding.
+var floodTolerance = rateLimit{rate: 1, burst: 20}
+
+// tokenBucket holds up to burst tokens, and gains rate tokens per second.
+type tokenBucket struct {
+	limit  rateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit rateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: limit.burst, last: now}
+}
+
+// take removes a token from the bucket, and returns false if it is empty.
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.rate
+	if b.tokens > b.limit.burst {
+		b.tokens = b.limit.burst
+	}
+
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// rateLimiter limits the packets of one connection, with a token bucket per packet
+// class. Packets over the limit are dropped, and a client whose packets keep being
+// dropped is flooding. It is only used by the goroutine reading the connection.
+type rateLimiter struct {
+	buckets   [numPacketClasses]*tokenBucket
+	tolerance *tokenBucket
+}
+
+func newRateLimiter(now time.Time) *rateLimiter {
+	r := &rateLimiter{tolerance: newTokenBucket(floodTolerance, now)}
+
+	for class, limit := range rateLimits {
+		r.buckets[class] = newTokenBucket(limit, now)
+	}
+
+	return r
+}
+
+// allow returns true if a packet of the class can be handled. Otherwise, flooding is
+// true if the client must be disconnected.
+func (r *rateLimiter) allow(class packetClass, now time.Time) (allowed, flooding bool) {
+	if r.buckets[class].take(now) {
+		return true, false
+	}
+
+	return false, !r.tolerance.take(now)
+}
+
+// limitCounters counts the packets dropped and the clients disconnected by the limits,
+// for the operators.
+type limitCounters struct {
+	sync.Mutex
+	dropped   [numPacketClasses]uint64
+	oversized uint64 // Packets larger than d2netpacket.MaxFrameSize
+	flooders  uint64 // Clients disconnected for flooding
+}
+
+func (c *limitCounters) drop(class packetClass) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.dropped[class]++
+}
+
+func (c *limitCounters) addOversized() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.oversized++
+}
+
+func (c *limitCounters) addFlooder() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.flooders++
+}
+
+// snapshot returns the dropped packets by class name, the oversized packets and the
+// flooders.
+func (c *limitCounters) snapshot() (dropped map[string]uint64, oversized, flooders uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	dropped = make(map[string]uint64, numPacketClasses)
+
+	for class, count := range c.dropped {
+		if count > 0 {
+			dropped[packetClass(class).String()] = count
+		}
+	}
+
+	return dropped, c.oversized, c.flooders
+}
@@ -0,0 +1,210 @@
+package d2server
+
+import (
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2interface"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math"
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math/d2vector"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapentity"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapworld"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2replication"
+)
+
+// entityResyncTicks is how often the state of a moving entity is published although
+// its target did not change, to correct the clients simulating it.
+const entityResyncTicks = simulationTickRate
+
+// replicatedEntity is an entity of the world which is sent to the clients.
+type replicatedEntity struct {
+	netID  uint32          // Network ID, the same on every client
+	area   d2mapworld.Area // Area of the map of the entity
+	entity d2interface.MapEntity
+	state  d2replication.State // State the clients are sent
+	tick   uint64              // Tick the state was published at
+}
+
+// entityState returns the state of an entity which is sent to the clients. Other
+// entities, such as objects, are the same on every client.
+func entityState(entity d2interface.MapEntity) (d2replication.State, bool) {
+	var position, target d2vector.Position
+
+	switch e := entity.(type) {
+	case *d2mapentity.NPC:
+		position, target = e.Position, e.Target
+	case *d2mapentity.Missile:
+		position, target = e.Position, e.Target
+	case *d2mapentity.Item:
+		position, target = e.Position, e.Position
+	default:
+		return d2replication.State{}, false
+	}
+
+	return d2replication.State{
+		X:       position.X(),
+		Y:       position.Y(),
+		TargetX: target.X(),
+		TargetY: target.Y(),
+	}, true
+}
+
+// publishEntityStates updates the states the clients are sent: of the new entities, the
+// entities whose target changed, and the moving entities which were not published for a
+// while. Entities between those points move on the clients as they do on the server.
+// The caller must hold the lock of the server.
+func (g *GameServer) publishEntityStates() {
+	seen := make(map[string]bool, len(g.entities))
+
+	for _, area := range g.world.Loaded() {
+		mapEngine, err := g.world.Map(area)
+		if err != nil {
+			continue
+		}
+
+		for id, entity := range mapEngine.Entities() {
+			state, ok := entityState(entity)
+			if !ok {
+				continue
+			}
+
+			seen[id] = true
+
+			published, found := g.entities[id]
+			if !found {
+				g.nextNetID++
+				g.entities[id] = &replicatedEntity{netID: g.nextNetID, area: area, entity: entity, state: state,
+					tick: g.tick}
+
+				continue
+			}
+
+			moving := state.X != state.TargetX || state.Y != state.TargetY
+			retargeted := state.TargetX != published.state.TargetX || state.TargetY != published.state.TargetY
+
+			if retargeted || (moving && g.tick-published.tick >= entityResyncTicks) {
+				published.state = state
+				published.tick = g.tick
+			}
+		}
+	}
+
+	for id := range g.entities {
+		if !seen[id] {
+			delete(g.entities, id)
+			delete(g.itemCodes, id)
+		}
+	}
+}
+
+// replicate sends each client the entities near its player, on the map of its area:
+// spawns for the entities which came close, and the states which differ from the last snapshot the client
+// acknowledged. The caller must hold the lock of the server.
+func (g *GameServer) replicate() {
+	byNetID := make(map[uint32]*replicatedEntity, len(g.entities))
+
+	for _, published := range g.entities {
+		byNetID[published.netID] = published
+	}
+
+	for id := range g.replication {
+		if _, found := g.connections[id]; !found {
+			delete(g.replication, id)
+		}
+	}
+
+	for id, client := range g.connections {
+		position, found := g.playerPosition(id)
+		if !found {
+			continue
+		}
+
+		sender, found := g.replication[id]
+		if !found {
+			sender = d2replication.NewSender()
+			g.replication[id] = sender
+		}
+
+		visible := make(d2replication.Snapshot)
+		area := g.playerArea(id)
+
+		for _, published := range g.entities {
+			if published.area != area {
+				continue
+			}
+
+			distance := published.state.Distance(position.X(), position.Y())
+			if d2replication.Interested(sender.Spawned(published.netID), distance) {
+				visible[published.netID] = published.state
+			}
+		}
+
+		update, changed := sender.Next(visible)
+		if !changed {
+			continue
+		}
+
+		g.sendEntityStates(client, update, byNetID)
+	}
+}
+
+// sendEntityStates sends a client the spawns and the entity states of an update.
+func (g *GameServer) sendEntityStates(client ClientConnection, update d2replication.Update,
+	byNetID map[uint32]*replicatedEntity) {
+	for _, netID := range update.Spawned {
+		spawn, err := g.spawnPacket(byNetID[netID])
+		if err != nil {
+			g.Errorf("failed to create the spawn packet of entity %d: %s", netID, err)
+			continue
+		}
+
+		if err := client.SendPacketToClient(spawn); err != nil {
+			g.Errorf("GameServer: error sending %s to client %s: %s", spawn.PacketType, client.GetUniqueID(), err)
+		}
+	}
+
+	packet, err := d2netpacket.CreateEntityStatesPacket(update)
+	if err != nil {
+		g.Errorf("EntityStatesPacket: %v", err)
+		return
+	}
+
+	if err := client.SendPacketToClient(packet); err != nil {
+		g.Errorf("GameServer: error sending EntityStatesPacket to client %s: %s", client.GetUniqueID(), err)
+	}
+}
+
+// spawnPacket returns the packet which creates the entity on a client.
+func (g *GameServer) spawnPacket(published *replicatedEntity) (d2netpacket.NetPacket, error) {
+	x, y := int(published.state.X), int(published.state.Y)
+
+	switch e := published.entity.(type) {
+	case *d2mapentity.NPC:
+		return d2netpacket.CreateSpawnNPCPacket(published.netID, e.MonStat().Key, x, y)
+	case *d2mapentity.Missile:
+		radians := d2math.GetRadiansBetween(published.state.X, published.state.Y,
+			published.state.TargetX, published.state.TargetY)
+
+		return d2netpacket.CreateSpawnMissilePacket(published.netID, e.Record().Id, x, y, radians)
+	default:
+		// items are spawned in tiles
+		return d2netpacket.CreateSpawnItemPacket(published.netID, x/subtilesPerTile, y/subtilesPerTile,
+			g.itemCodes[e.ID()]...)
+	}
+}
+
+// acknowledgeEntityStates makes the snapshot the client acknowledged the baseline of the
+// next entity states it is sent.
+func (g *GameServer) acknowledgeEntityStates(client ClientConnection, packet d2netpacket.NetPacket) error {
+	ack, err := d2netpacket.UnmarshalEntityStatesAck(packet)
+	if err != nil {
+		return err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if sender, found := g.replication[client.GetUniqueID()]; found {
+		sender.Acknowledge(ack.Sequence)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package d2server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	// sessionGracePeriod is how long the server keeps the player of a dropped
+	// connection, waiting for the client to reconnect.
+	sessionGracePeriod = 2 * time.Minute
+	sessionTokenSize   = 16
+)
+
+// session is the state the server keeps for a player, so a client can resume
+// playing after its connection drops.
+type session struct {
+	token       string
+	playerState *d2hero.HeroState
+	expiry      *time.Timer // running while the client is disconnected
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, sessionTokenSize)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// openSession issues a session to the client.
+func (g *GameServer) openSession(client ClientConnection) {
+	token, err := newSessionToken()
+	if err != nil {
+		g.Errorf("failed to create a session token for %s: %s", client.GetUniqueID(), err)
+		return
+	}
+
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	g.sessions[client.GetUniqueID()] = &session{token: token, playerState: client.GetPlayerState()}
+}
+
+// sessionToken returns the token of the session of the player.
+func (g *GameServer) sessionToken(playerID string) string {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	if s, found := g.sessions[playerID]; found {
+		return s.token
+	}
+
+	return ""
+}
+
+// closeSession forgets the session of the player.
+func (g *GameServer) closeSession(playerID string) {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	if s, found := g.sessions[playerID]; found && s.expiry != nil {
+		s.expiry.Stop()
+	}
+
+	delete(g.sessions, playerID)
+}
+
+// suspendClient is called when the connection of a client drops without the client
+// disconnecting. The player is kept for the grace period, after which it is removed
+// as if the client had disconnected.
+func (g *GameServer) suspendClient(client ClientConnection) {
+	g.Lock()
+	defer g.Unlock()
+
+	// the client disconnected, or already reconnected on another connection
+	if current, found := g.connections[client.GetUniqueID()]; !found || current != client {
+		return
+	}
+
+	g.sessionMutex.Lock()
+	s, found := g.sessions[client.GetUniqueID()]
+	g.sessionMutex.Unlock()
+
+	if !found {
+		g.OnClientDisconnected(client)
+		return
+	}
+
+	g.Infof("Connection of %s dropped, keeping the player for %s", client.GetUniqueID(), sessionGracePeriod)
+
+	delete(g.connections, client.GetUniqueID())
+
+	if simulated, ok := client.(*SimulatedClientConnection); ok {
+		simulated.Close()
+	}
+
+	g.sessionMutex.Lock()
+	s.expiry = time.AfterFunc(sessionGracePeriod, func() {
+		g.expireSession(client, s)
+	})
+	g.sessionMutex.Unlock()
+}
+
+// expireSession removes the player of a session which has not been resumed in time.
+func (g *GameServer) expireSession(client ClientConnection, s *session) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.sessionMutex.Lock()
+	current, found := g.sessions[client.GetUniqueID()]
+	resumed := s.expiry == nil
+	g.sessionMutex.Unlock()
+
+	// the client may have resumed the session while the timer fired
+	if !found || current != s || resumed {
+		return
+	}
+
+	g.Infof("Session of %s expired", client.GetUniqueID())
+
+	disconnected, err := d2netpacket.CreatePlayerDisconnectRequestPacket(client.GetUniqueID())
+	if err != nil {
+		g.Errorf("PlayerDisconnectRequestPacket: %v", err)
+	} else {
+		g.sendPacketToClients(disconnected)
+	}
+
+	g.OnClientDisconnected(client)
+}
+
+// resumeSession returns the player state of the session with the given token, and stops
+// its grace period.
+func (g *GameServer) resumeSession(playerID, token string) (*d2hero.HeroState, bool) {
+	g.sessionMutex.Lock()
+	defer g.sessionMutex.Unlock()
+
+	s, found := g.sessions[playerID]
+	if !found || s.token != token {
+		return nil, false
+	}
+
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+
+	return s.playerState, true
+}
+
+// OnClientResumed brings a client which resumed its session back in sync. It sends the
+// client an UpdateServerInfoPacket and an AddPlayerPacket for each player of its area,
+// with the positions the server has for them. The client removed the entities it had, and is sent
+// them again. Other clients kept the player while it was away.
+//
+// The server may not have noticed the old connection of the client dropped yet. The new
+// connection replaces it, see replaceConnection.
+func (g *GameServer) OnClientResumed(client ClientConnection) {
+	g.Infof("Client resumed the session of %s", client.GetUniqueID())
+
+	if old, found := g.connections[client.GetUniqueID()]; found && old != client {
+		g.replaceConnection(old)
+	}
+
+	g.connections[client.GetUniqueID()] = client
+
+	playerState := client.GetPlayerState()
+	g.resetPlayerMovement(client.GetUniqueID(), playerState.X, playerState.Y)
+	delete(g.replication, client.GetUniqueID())
+
+	g.sendServerInfo(client, true)
+
+	area := g.playerArea(client.GetUniqueID())
+
+	for _, connection := range g.connections {
+		if g.playerArea(connection.GetUniqueID()) != area {
+			continue
+		}
+
+		addPlayer, err := g.addPlayerPacket(connection)
+		if err != nil {
+			g.Errorf("AddPlayerPacket: %v", err)
+			continue
+		}
+
+		if err := client.SendPacketToClient(addPlayer); err != nil {
+			g.Errorf("GameServer: error sending AddPlayerPacket to client %s: %s", client.GetUniqueID(), err)
+		}
+	}
+}
+
+// replaceConnection closes the connection of a client which resumed its session on
+// another one. Its reader stops without suspending the player, which is connected, and the
+// packets it still had are dropped, see isConnected.
+func (g *GameServer) replaceConnection(old ClientConnection) {
+	g.Infof("Closing the old connection of %s, the client resumed its session", old.GetUniqueID())
+
+	delete(g.connections, old.GetUniqueID())
+
+	if simulated, ok := old.(*SimulatedClientConnection); ok {
+		simulated.Close()
+	}
+
+	if remote, ok := remoteConnection(old); ok {
+		if err := remote.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			g.Errorf("failed to close the old connection of %s: %s", old.GetUniqueID(), err)
+		}
+	}
+}
+
+// isConnected returns true if the client is the connection of its player.
+func (g *GameServer) isConnected(client ClientConnection) bool {
+	g.RLock()
+	defer g.RUnlock()
+
+	return g.connections[client.GetUniqueID()] == client
+}
@@ -0,0 +1,196 @@
+package d2server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2hero"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapworld"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2client/d2clientconnectiontype"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket/d2netpackettype"
+)
+
+// testClient is a remote client which records the packets it is sent.
+type testClient struct {
+	id          string
+	playerState *d2hero.HeroState
+	sent        []d2netpacket.NetPacket
+	closed      bool
+}
+
+func (c *testClient) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+func (c *testClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *testClient) GetUniqueID() string { return c.id }
+
+func (c *testClient) GetConnectionType() d2clientconnectiontype.ClientConnectionType {
+	return d2clientconnectiontype.LANClient
+}
+
+func (c *testClient) SendPacketToClient(packet d2netpacket.NetPacket) error {
+	c.sent = append(c.sent, packet)
+	return nil
+}
+
+func (c *testClient) GetPlayerState() *d2hero.HeroState { return c.playerState }
+
+func (c *testClient) SetPlayerState(playerState *d2hero.HeroState) { c.playerState = playerState }
+
+func testSessionServer(maxConnections int, clients ...*testClient) *GameServer {
+	g := &GameServer{
+		connections:    make(map[string]ClientConnection),
+		maxConnections: maxConnections,
+		movement:       make(map[string]*playerMovement),
+		sessions:       make(map[string]*session),
+		areas:          make(map[string]d2mapworld.Area),
+		Logger:         d2util.NewLogger(),
+	}
+
+	for _, client := range clients {
+		g.connections[client.id] = client
+		g.openSession(client)
+	}
+
+	return g
+}
+
+func TestResumeSession(t *testing.T) {
+	client := &testClient{id: testPlayerID, playerState: &d2hero.HeroState{HeroName: "hero"}}
+	g := testSessionServer(1, client)
+	token := g.sessionToken(testPlayerID)
+
+	g.suspendClient(client)
+
+	if _, found := g.connections[testPlayerID]; found {
+		t.Fatal("expected the client of a dropped connection to be removed")
+	}
+
+	if _, ok := g.resumeSession(testPlayerID, "not the token"); ok {
+		t.Error("expected a session not to resume with another token")
+	}
+
+	if _, ok := g.resumeSession("other", token); ok {
+		t.Error("expected a session not to resume for another player")
+	}
+
+	playerState, ok := g.resumeSession(testPlayerID, token)
+	if !ok || playerState.HeroName != "hero" {
+		t.Fatalf("expected the session to resume with its player, got %v", playerState)
+	}
+
+	if s := g.sessions[testPlayerID]; s.expiry != nil {
+		t.Error("expected the grace period of a resumed session to stop")
+	}
+}
+
+func TestSuspendedPlayersKeepTheirSlot(t *testing.T) {
+	client := &testClient{id: testPlayerID, playerState: &d2hero.HeroState{}}
+	g := testSessionServer(1, client)
+	token := g.sessionToken(testPlayerID)
+
+	g.suspendClient(client)
+
+	if !g.isFull() {
+		t.Fatal("expected the slot of a suspended player to be kept")
+	}
+
+	if _, ok := g.resumeSession(testPlayerID, token); !ok {
+		t.Fatal("expected the session to resume")
+	}
+
+	g.connections[testPlayerID] = client
+
+	if !g.isFull() {
+		t.Fatal("expected the resumed player to take its slot")
+	}
+
+	g.OnClientDisconnected(client)
+
+	if g.isFull() {
+		t.Fatal("expected the slot of a disconnected player to be free")
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	client := &testClient{id: testPlayerID, playerState: &d2hero.HeroState{}}
+	other := &testClient{id: "other", playerState: &d2hero.HeroState{}}
+	g := testSessionServer(2, client, other)
+
+	g.suspendClient(client)
+
+	s := g.sessions[testPlayerID]
+	g.expireSession(client, s)
+
+	if _, found := g.sessions[testPlayerID]; found {
+		t.Fatal("expected an expired session to be closed")
+	}
+
+	if g.isFull() {
+		t.Error("expected the slot of an expired session to be free")
+	}
+
+	if len(other.sent) != 1 || other.sent[0].PacketType != d2netpackettype.PlayerDisconnectionNotification {
+		t.Errorf("expected the other players to be told the player left, got %v", other.sent)
+	}
+}
+
+func TestResumedSessionDoesNotExpire(t *testing.T) {
+	client := &testClient{id: testPlayerID, playerState: &d2hero.HeroState{}}
+	g := testSessionServer(1, client)
+	token := g.sessionToken(testPlayerID)
+
+	g.suspendClient(client)
+
+	s := g.sessions[testPlayerID]
+
+	if _, ok := g.resumeSession(testPlayerID, token); !ok {
+		t.Fatal("expected the session to resume")
+	}
+
+	// the timer fired while the session was resumed
+	g.expireSession(client, s)
+
+	if _, found := g.sessions[testPlayerID]; !found {
+		t.Fatal("expected a resumed session to be kept")
+	}
+}
+
+func TestResumeReplacesLiveConnection(t *testing.T) {
+	old := &testClient{id: testPlayerID, playerState: &d2hero.HeroState{HeroName: "hero"}}
+	g := testSessionServer(1, old)
+	token := g.sessionToken(testPlayerID)
+
+	// the client reconnects before the server noticed its connection dropped
+	playerState, ok := g.resumeSession(testPlayerID, token)
+	if !ok {
+		t.Fatal("expected the session to resume")
+	}
+
+	client := &testClient{id: testPlayerID, playerState: playerState}
+	g.OnClientResumed(client)
+
+	if !old.closed {
+		t.Error("expected the old connection to be closed")
+	}
+
+	if g.isConnected(old) || !g.isConnected(client) {
+		t.Fatal("expected the new connection to replace the old one")
+	}
+
+	// the reader of the old connection stops
+	g.suspendClient(old)
+
+	if !g.isConnected(client) {
+		t.Fatal("expected the player to stay connected when the old connection closes")
+	}
+
+	if s := g.sessions[testPlayerID]; s.expiry != nil {
+		t.Error("expected the player not to be suspended")
+	}
+}
@@ -0,0 +1,60 @@
+package d2server
+
+import (
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
+)
+
+// SimulatedClientConnection is a ClientConnection which sends packets to the
+// client under simulated network conditions.
+type SimulatedClientConnection struct {
+	ClientConnection
+	link   *d2netsim.Link
+	logger *d2util.Logger
+}
+
+// NewSimulatedClientConnection wraps the connection so packets sent to the
+// client are delayed, dropped, duplicated and reordered according to the
+// conditions.
+func NewSimulatedClientConnection(conn ClientConnection, conditions d2netsim.Conditions,
+	logger *d2util.Logger) *SimulatedClientConnection {
+	return &SimulatedClientConnection{
+		ClientConnection: conn,
+		link:             d2netsim.NewLink(conditions, time.Now().UnixNano()),
+		logger:           logger,
+	}
+}
+
+// SendPacketToClient schedules the packet to be sent to the client. The packet is
+// detached first, as it is sent after the state it was created from has changed.
+func (s *SimulatedClientConnection) SendPacketToClient(packet d2netpacket.NetPacket) error {
+	packet, err := packet.Detach()
+	if err != nil {
+		return err
+	}
+
+	s.link.Send(func() {
+		if err := s.ClientConnection.SendPacketToClient(packet); err != nil {
+			s.logger.Errorf("simulated network: sending %s to %s: %s", packet.PacketType, s.GetUniqueID(), err)
+		}
+	})
+
+	return nil
+}
+
+// GetCodec returns the codec of the wrapped connection.
+func (s *SimulatedClientConnection) GetCodec() d2netpacket.CodecType {
+	if c, ok := s.ClientConnection.(CodecConnection); ok {
+		return c.GetCodec()
+	}
+
+	return d2netpacket.CodecJSON
+}
+
+// Close stops the simulation. Packets which have not been sent yet are dropped.
+func (s *SimulatedClientConnection) Close() {
+	s.link.Close()
+}
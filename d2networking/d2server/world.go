@@ -0,0 +1,204 @@
+package d2server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2math"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2map/d2mapengine"
+	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2records"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+)
+
+const (
+	simulationTickRate = 25 // Ticks per second
+	simulationTick     = time.Second / simulationTickRate
+
+	// maxCatchUpTicks is how many ticks the server simulates at once when it fell
+	// behind. Time beyond that is dropped, so a stalled server does not spiral.
+	maxCatchUpTicks = 5
+)
+
+// simulate advances the world of the game on a fixed timestep until the game stops,
+// and sends each client the entities near its player whose state changed.
+func (g *GameServer) simulate() {
+	ticker := time.NewTicker(simulationTick)
+	defer ticker.Stop()
+
+	last := time.Now()
+
+	var behind time.Duration
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case now := <-ticker.C:
+			behind += now.Sub(last)
+			last = now
+
+			ticks := 0
+			for ; behind >= simulationTick && ticks < maxCatchUpTicks; ticks++ {
+				behind -= simulationTick
+			}
+
+			if ticks == maxCatchUpTicks {
+				behind = 0
+			}
+
+			started := time.Now()
+
+			g.Lock()
+
+			for i := 0; i < ticks; i++ {
+				g.step()
+			}
+
+			g.updatePlayerPositions()
+			g.enterWarps()
+
+			g.publishEntityStates()
+			g.replicate()
+			g.Unlock()
+
+			g.metrics.ObserveTick(time.Since(started))
+		}
+	}
+}
+
+// step advances the world by one tick. The caller must hold the lock of the server.
+func (g *GameServer) step() {
+	for _, mapEngine := range g.world.Maps() {
+		mapEngine.Advance(simulationTick.Seconds())
+	}
+
+	g.tick++
+}
+
+// castSkill adds the missiles and the summoned NPC of a skill to the world. The clients
+// play the casting animation from the relayed packet, and are sent the missiles and the
+// NPC as they come close. A character the server owns can only cast the skills it has.
+func (g *GameServer) castSkill(client ClientConnection, packet d2netpacket.NetPacket) error {
+	cast, err := d2netpacket.UnmarshalCast(packet)
+	if err != nil {
+		return err
+	}
+
+	skillRecord, found := g.asset.Records.Skill.Details[cast.SkillID]
+	if !found {
+		return fmt.Errorf("cannot cast unknown skill %d", cast.SkillID)
+	}
+
+	source, found := g.playerPosition(client.GetUniqueID())
+	if !found {
+		return nil
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if !g.hasSkill(client, cast.SkillID) {
+		return fmt.Errorf("%w: %s can not cast %s", errSkillNotLearned, client.GetUniqueID(), skillRecord.Skill)
+	}
+
+	area := g.playerArea(client.GetUniqueID())
+
+	mapEngine, err := g.world.Map(area)
+	if err != nil {
+		return err
+	}
+
+	g.sendPacketToArea(area, packet)
+
+	targetX, targetY := cast.TargetX*subtilesPerTile, cast.TargetY*subtilesPerTile
+	radians := d2math.GetRadiansBetween(source.X(), source.Y(), targetX, targetY)
+
+	missileNames := []string{
+		skillRecord.Cltmissile,
+		skillRecord.Cltmissilea,
+		skillRecord.Cltmissileb,
+		skillRecord.Cltmissilec,
+		skillRecord.Cltmissiled,
+	}
+
+	for _, name := range missileNames {
+		missileRecord := g.asset.Records.GetMissileByName(name)
+		if missileRecord == nil {
+			continue
+		}
+
+		if err := g.spawnMissile(mapEngine, missileRecord, int(source.X()), int(source.Y()), radians); err != nil {
+			return err
+		}
+	}
+
+	if skillRecord.Summon != "" {
+		return g.spawnNPC(mapEngine, skillRecord.Summon, int(targetX), int(targetY))
+	}
+
+	return nil
+}
+
+// spawnMissile adds a missile to a map, which is removed once it reached its range.
+// The caller must hold the lock of the server.
+func (g *GameServer) spawnMissile(mapEngine *d2mapengine.MapEngine, record *d2records.MissileRecord, x, y int,
+	radians float64) error {
+	missile, err := mapEngine.NewMissile(x, y, record)
+	if err != nil {
+		return err
+	}
+
+	missile.SetRadians(radians, func() {
+		mapEngine.RemoveEntity(missile)
+	})
+
+	mapEngine.AddEntity(missile)
+
+	return nil
+}
+
+// spawnNPC adds the monster with the given monstats.txt key to a map. The caller must
+// hold the lock of the server.
+func (g *GameServer) spawnNPC(mapEngine *d2mapengine.MapEngine, monStat string, x, y int) error {
+	monStatRecord := g.asset.Records.Monster.Stats[monStat]
+	if monStatRecord == nil {
+		return fmt.Errorf("cannot spawn NPC, no monstat entry for %q", monStat)
+	}
+
+	// https://github.com/OpenDiablo2/OpenDiablo2/issues/803
+	npc, err := mapEngine.NewNPC(x, y, monStatRecord, 0)
+	if err != nil {
+		return err
+	}
+
+	mapEngine.AddEntity(npc)
+
+	return nil
+}
+
+// spawnItem adds the item a client asked for to the map of its player. Its codes are
+// kept to spawn it on the clients it comes close to.
+func (g *GameServer) spawnItem(client ClientConnection, packet d2netpacket.NetPacket) error {
+	spawn, err := d2netpacket.UnmarshalSpawnItem(packet)
+	if err != nil {
+		return err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	mapEngine, err := g.world.Map(g.playerArea(client.GetUniqueID()))
+	if err != nil {
+		return err
+	}
+
+	item, err := mapEngine.NewItem(spawn.X, spawn.Y, spawn.Codes...)
+	if err != nil {
+		return err
+	}
+
+	g.itemCodes[item.ID()] = spawn.Codes
+	mapEngine.AddEntity(item)
+
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package d2tls provides the TLS transport of the game server: self-signed
+// certificates for dedicated servers, and trust-on-first-use pinning of the
+// certificates on clients, since they are not signed by a certificate authority.
+package d2tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	certificateValidity = 10 * 365 * 24 * time.Hour
+	serialNumberBits    = 128
+	keyFileMode         = 0o600
+	certificateFileMode = 0o644
+)
+
+// GenerateCertificate creates a self-signed certificate for the given host names and
+// IP addresses, and returns it and its private key PEM encoded.
+func GenerateCertificate(hosts []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialNumberBits))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"OpenDiablo2"}, CommonName: "OpenDiablo2 server"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// LoadOrCreateCertificate loads the certificate and private key from the given PEM files.
+// If the certificate file does not exist, a self-signed certificate for the hosts is
+// generated and saved to the files first, so the server keeps its certificate, and the
+// clients which pinned it keep trusting it.
+func LoadOrCreateCertificate(certFile, keyFile string, hosts []string) (tls.Certificate, error) {
+	if _, err := os.Stat(certFile); errors.Is(err, os.ErrNotExist) {
+		certPEM, keyPEM, err := GenerateCertificate(hosts)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+
+		if err := ioutil.WriteFile(keyFile, keyPEM, keyFileMode); err != nil {
+			return tls.Certificate{}, err
+		}
+
+		if err := ioutil.WriteFile(certFile, certPEM, certificateFileMode); err != nil {
+			return tls.Certificate{}, err
+		}
+	} else if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// ServerConfig returns the TLS configuration of a server with the given certificate.
+func ServerConfig(certificate tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded certificate, as colon
+// separated hexadecimal bytes. Players compare it to the one the server logs.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hexBytes := make([]string, len(sum))
+
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexBytes, ":")
+}
@@ -0,0 +1,89 @@
+package d2tls
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func serverCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	certPEM, keyPEM, err := GenerateCertificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+// handshake connects a client to a server with the given certificate over a pipe.
+func handshake(t *testing.T, certificate tls.Certificate, known *KnownHosts) error {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+
+	defer serverConn.Close() //nolint:errcheck // test
+	defer clientConn.Close() //nolint:errcheck // test
+
+	go func() {
+		_ = tls.Server(serverConn, ServerConfig(certificate)).Handshake()
+	}()
+
+	return tls.Client(clientConn, ClientConfig("server:6669", known)).Handshake()
+}
+
+func TestKnownHostsPinOnFirstUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+
+	known, err := LoadKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate := serverCertificate(t)
+
+	if err := handshake(t, certificate, known); err != nil {
+		t.Fatalf("expected the first certificate to be trusted, got %s", err)
+	}
+
+	// the pin is persisted
+	known, err = LoadKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := handshake(t, certificate, known); err != nil {
+		t.Fatalf("expected the pinned certificate to be trusted, got %s", err)
+	}
+
+	if err := handshake(t, serverCertificate(t), known); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Fatalf("expected another certificate to be rejected, got %v", err)
+	}
+}
+
+func TestLoadOrCreateCertificateKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+
+	first, err := LoadOrCreateCertificate(certFile, keyFile, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := LoadOrCreateCertificate(certFile, keyFile, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Fingerprint(first.Certificate[0]) != Fingerprint(second.Certificate[0]) {
+		t.Error("expected the saved certificate to be loaded again")
+	}
+}
@@ -0,0 +1,141 @@
+package d2tls
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	knownHostsFileMode = 0o600
+	knownHostsDirMode  = 0o750
+)
+
+// ErrFingerprintMismatch is returned when a server presents another certificate than
+// the one pinned for its address. The server may have generated a new certificate,
+// or someone may be intercepting the connection.
+var ErrFingerprintMismatch = errors.New("the certificate of the server changed")
+
+var errNoCertificate = errors.New("the server presented no certificate")
+
+// KnownHosts pins the certificate fingerprint of each server address the first time a
+// client connects to it, and rejects other certificates for the address afterwards. It
+// is persisted to a file with one address and fingerprint per line.
+type KnownHosts struct {
+	path         string
+	fingerprints map[string]string // By address
+	sync.Mutex
+}
+
+// LoadKnownHosts reads the pinned fingerprints from the file at path. A missing file
+// is empty. An empty path gives known hosts which are not persisted.
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	k := &KnownHosts{path: path, fingerprints: make(map[string]string)}
+
+	if path == "" {
+		return k, nil
+	}
+
+	f, err := os.Open(path) //nolint:gosec // the path is chosen by the client
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck // read only
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 { //nolint:gomnd // address and fingerprint
+			return nil, fmt.Errorf("%s: invalid line %q", path, line)
+		}
+
+		k.fingerprints[fields[0]] = fields[1]
+	}
+
+	return k, scanner.Err()
+}
+
+// Check accepts the fingerprint if it is the one pinned for the address. The first
+// fingerprint seen for an address is pinned and saved.
+func (k *KnownHosts) Check(address, fingerprint string) error {
+	k.Lock()
+	defer k.Unlock()
+
+	pinned, found := k.fingerprints[address]
+	if found {
+		if pinned != fingerprint {
+			return fmt.Errorf("%w: %s presented %s, but %s is pinned for it in %s",
+				ErrFingerprintMismatch, address, fingerprint, pinned, k.path)
+		}
+
+		return nil
+	}
+
+	k.fingerprints[address] = fingerprint
+
+	return k.save()
+}
+
+// save writes the known hosts to their file. The caller must hold the lock.
+func (k *KnownHosts) save() error {
+	if k.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.path), knownHostsDirMode); err != nil {
+		return err
+	}
+
+	addresses := make([]string, 0, len(k.fingerprints))
+
+	for address := range k.fingerprints {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	var b strings.Builder
+
+	b.WriteString("# Certificate fingerprints of the OpenDiablo2 servers this client trusts\n")
+
+	for _, address := range addresses {
+		fmt.Fprintf(&b, "%s %s\n", address, k.fingerprints[address])
+	}
+
+	return ioutil.WriteFile(k.path, []byte(b.String()), knownHostsFileMode)
+}
+
+// ClientConfig returns the TLS configuration of a client which connects to the server at
+// address. The certificate of the server is checked against the known hosts instead of
+// certificate authorities, because servers sign their own certificates.
+func ClientConfig(address string, known *KnownHosts) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the certificate is verified against the pinned fingerprint below
+		InsecureSkipVerify: true, //nolint:gosec // trust on first use
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errNoCertificate
+			}
+
+			return known.Check(address, Fingerprint(rawCerts[0]))
+		},
+	}
+}
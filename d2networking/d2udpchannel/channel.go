@@ -0,0 +1,451 @@
+// Package d2udpchannel provides reliable-ordered and unreliable-sequenced
+// message channels on top of a net.PacketConn. Conn and Listener carry the
+// game over them: servers without TLS also listen on UDP, and clients join
+// them with udp://host.
+package d2udpchannel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Channel is the delivery guarantee of a message.
+type Channel byte
+
+const (
+	// Unreliable messages may be lost. Messages older than the newest one
+	// received are dropped, so they arrive in order but with gaps.
+	Unreliable Channel = iota + 1
+
+	// Reliable messages are resent until the peer acknowledges them and are
+	// delivered exactly once, in the order they were sent.
+	Reliable
+)
+
+// Every datagram starts with the following header, all values little endian:
+//
+//	byte    channel, flagAck is set if ack and ackBits are valid
+//	uint16  sequence of the datagram
+//	uint16  ack, latest datagram sequence received from the peer
+//	uint32  ackBits, bit n acknowledges datagram ack-n-1
+//	uint16  message sequence within the channel, absent for ack only datagrams
+//	uint16  unreliable messages only: the last reliable message sent before it
+//	...     message
+const (
+	ackOnly     = 0
+	flagAck     = 0x80
+	headerSize  = 9
+	messageSize = 2
+	barrierSize = 2
+	ackBitCount = 32
+
+	// DefaultResendTimeout is how long a reliable message waits for an
+	// acknowledgement before it is sent again.
+	DefaultResendTimeout = 100 * time.Millisecond
+
+	// DefaultMaxResends is how often a reliable message is resent before the
+	// peer is given up on.
+	DefaultMaxResends = 50
+
+	// DefaultPeerTimeout is how long the peer may send nothing before it is
+	// given up on. Idle endpoints keep each other alive, see keepAliveInterval.
+	DefaultPeerTimeout = 10 * time.Second
+
+	// keepAliveInterval is how long an endpoint sends nothing before it sends
+	// an acknowledgement, so the peer knows it is still there.
+	keepAliveInterval = time.Second
+
+	// reliableWindow is how far ahead of the next expected reliable message
+	// a message may be buffered.
+	reliableWindow = 1024
+)
+
+var (
+	errShortDatagram  = errors.New("datagram is shorter than its header")
+	errUnknownChannel = errors.New("datagram has an unknown channel")
+
+	// ErrPeerUnreachable is returned once the peer stopped acknowledging
+	// reliable messages or sending anything. The endpoint can not be used after.
+	ErrPeerUnreachable = errors.New("peer is unreachable")
+)
+
+type pendingMessage struct {
+	sequence  uint16
+	data      []byte
+	sentAt    time.Time
+	resends   int
+	datagrams []uint16
+}
+
+// Endpoint is one side of the channels to a single peer. The owner reads
+// datagrams from the connection and passes those of the peer to Receive, and
+// calls Update regularly so lost reliable messages are resent and a dead peer
+// is noticed.
+type Endpoint struct {
+	conn net.PacketConn
+	addr net.Addr
+
+	// ResendTimeout is how long a reliable message waits for an
+	// acknowledgement before it is resent.
+	ResendTimeout time.Duration
+
+	// MaxResends is how often a reliable message is resent before Update
+	// gives up on the peer.
+	MaxResends int
+
+	// PeerTimeout is how long the peer may send nothing before Update gives
+	// up on it.
+	PeerTimeout time.Duration
+
+	mutex sync.Mutex
+	err   error // Set once the peer is given up on
+
+	heard      bool      // A datagram was received since the last Update
+	lastHeard  time.Time // Update which last saw a datagram of the peer
+	wrote      bool      // A datagram was sent since the last Update
+	lastWrote  time.Time // Update which last saw a datagram sent
+	hasUpdated bool
+
+	localSequence  uint16
+	remoteSequence uint16
+	receivedBits   uint32
+	hasReceived    bool
+	ackPending     bool
+	unackedCount   int // Datagrams received since the last datagram was sent
+
+	nextReliable uint16
+	unacked      []*pendingMessage
+
+	nextDelivery uint16
+	outOfOrder   map[uint16][]byte
+
+	nextUnreliable uint16
+	lastUnreliable uint16
+	hasUnreliable  bool
+}
+
+// NewEndpoint creates an Endpoint which sends to addr over conn.
+func NewEndpoint(conn net.PacketConn, addr net.Addr) *Endpoint {
+	return &Endpoint{
+		conn:          conn,
+		addr:          addr,
+		ResendTimeout: DefaultResendTimeout,
+		MaxResends:    DefaultMaxResends,
+		PeerTimeout:   DefaultPeerTimeout,
+		outOfOrder:    make(map[uint16][]byte),
+	}
+}
+
+// Addr returns the address of the peer.
+func (e *Endpoint) Addr() net.Addr {
+	return e.addr
+}
+
+// Send sends the message on the given channel. An unreliable message keeps its
+// place among the reliable messages: it is only delivered right after the
+// reliable message sent before it, and dropped if it arrives earlier or later.
+// At most reliableWindow reliable messages may wait for an acknowledgement; if
+// the peer falls that far behind it is given up on.
+func (e *Endpoint) Send(channel Channel, data []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.err != nil {
+		return e.err
+	}
+
+	switch channel {
+	case Unreliable:
+		e.nextUnreliable++
+
+		message := make([]byte, barrierSize+len(data))
+		binary.LittleEndian.PutUint16(message, e.nextReliable)
+		copy(message[barrierSize:], data)
+
+		_, err := e.write(Unreliable, e.nextUnreliable, message)
+
+		return err
+	case Reliable:
+		if len(e.unacked) >= reliableWindow {
+			e.err = fmt.Errorf("%w: %d reliable messages are not acknowledged", ErrPeerUnreachable, len(e.unacked))
+			return e.err
+		}
+
+		e.nextReliable++
+
+		message := &pendingMessage{sequence: e.nextReliable, data: data, sentAt: time.Now()}
+		e.unacked = append(e.unacked, message)
+
+		datagram, err := e.write(Reliable, message.sequence, data)
+		message.datagrams = append(message.datagrams, datagram)
+
+		return err
+	default:
+		return errUnknownChannel
+	}
+}
+
+// Receive processes a datagram from the peer and returns the messages which
+// are ready to be delivered, in order.
+func (e *Endpoint) Receive(datagram []byte) ([][]byte, error) {
+	if len(datagram) < headerSize {
+		return nil, errShortDatagram
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	flags := datagram[0]
+	channel := Channel(flags &^ flagAck)
+	sequence := binary.LittleEndian.Uint16(datagram[1:])
+
+	if flags&flagAck != 0 {
+		e.acknowledge(binary.LittleEndian.Uint16(datagram[3:]), binary.LittleEndian.Uint32(datagram[5:]))
+	}
+
+	e.markReceived(sequence)
+	e.heard = true
+
+	if channel == ackOnly {
+		return nil, nil
+	}
+
+	if len(datagram) < headerSize+messageSize {
+		return nil, errShortDatagram
+	}
+
+	e.ackPending = true
+
+	// acknowledge before the datagrams fall out of the acknowledgement bits
+	if e.unackedCount++; e.unackedCount >= ackBitCount/2 {
+		if _, err := e.write(ackOnly, 0, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	messageSequence := binary.LittleEndian.Uint16(datagram[headerSize:])
+	data := append([]byte(nil), datagram[headerSize+messageSize:]...)
+
+	switch channel {
+	case Unreliable:
+		if len(data) < barrierSize {
+			return nil, errShortDatagram
+		}
+
+		// another reliable message than the one sent before it was delivered last
+		if barrier := binary.LittleEndian.Uint16(data); barrier != e.nextDelivery {
+			return nil, nil
+		}
+
+		if e.hasUnreliable && !sequenceGreater(messageSequence, e.lastUnreliable) {
+			return nil, nil
+		}
+
+		e.lastUnreliable, e.hasUnreliable = messageSequence, true
+
+		return [][]byte{data[barrierSize:]}, nil
+	case Reliable:
+		return e.deliverReliable(messageSequence, data), nil
+	default:
+		return nil, errUnknownChannel
+	}
+}
+
+// Update resends the reliable messages which have not been acknowledged in
+// time, and acknowledges received datagrams if nothing else was sent since. It
+// returns ErrPeerUnreachable once a message was resent MaxResends times, or the
+// peer sent nothing for PeerTimeout.
+func (e *Endpoint) Update(now time.Time) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.err != nil {
+		return e.err
+	}
+
+	if !e.hasUpdated || e.heard {
+		e.lastHeard, e.heard = now, false
+	}
+
+	if !e.hasUpdated || e.wrote {
+		e.lastWrote, e.wrote = now, false
+	}
+
+	e.hasUpdated = true
+
+	if silence := now.Sub(e.lastHeard); silence > e.PeerTimeout {
+		e.err = fmt.Errorf("%w: nothing received for %s", ErrPeerUnreachable, silence)
+		return e.err
+	}
+
+	for _, message := range e.unacked {
+		if now.Sub(message.sentAt) < e.ResendTimeout {
+			continue
+		}
+
+		if message.resends >= e.MaxResends {
+			e.err = fmt.Errorf("%w: message %d was resent %d times", ErrPeerUnreachable, message.sequence,
+				message.resends)
+
+			return e.err
+		}
+
+		datagram, err := e.write(Reliable, message.sequence, message.data)
+		if err != nil {
+			return err
+		}
+
+		message.sentAt = now
+		message.resends++
+		message.datagrams = append(message.datagrams, datagram)
+	}
+
+	if e.ackPending || now.Sub(e.lastWrote) >= keepAliveInterval {
+		if _, err := e.write(ackOnly, 0, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unacknowledged returns the number of reliable messages waiting for an
+// acknowledgement.
+func (e *Endpoint) Unacknowledged() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return len(e.unacked)
+}
+
+// write sends a datagram and returns its sequence.
+func (e *Endpoint) write(channel Channel, messageSequence uint16, data []byte) (uint16, error) {
+	e.localSequence++
+
+	size := headerSize
+	if channel != ackOnly {
+		size += messageSize + len(data)
+	}
+
+	datagram := make([]byte, size)
+	datagram[0] = byte(channel)
+
+	if e.hasReceived {
+		datagram[0] |= flagAck
+	}
+
+	binary.LittleEndian.PutUint16(datagram[1:], e.localSequence)
+	binary.LittleEndian.PutUint16(datagram[3:], e.remoteSequence)
+	binary.LittleEndian.PutUint32(datagram[5:], e.receivedBits)
+
+	if channel != ackOnly {
+		binary.LittleEndian.PutUint16(datagram[headerSize:], messageSequence)
+		copy(datagram[headerSize+messageSize:], data)
+	}
+
+	e.ackPending = false
+	e.unackedCount = 0
+	e.wrote = true
+
+	_, err := e.conn.WriteTo(datagram, e.addr)
+
+	return e.localSequence, err
+}
+
+// markReceived records the datagram sequence so it is acknowledged to the peer.
+func (e *Endpoint) markReceived(sequence uint16) {
+	if !e.hasReceived {
+		e.remoteSequence, e.hasReceived = sequence, true
+		return
+	}
+
+	if sequenceGreater(sequence, e.remoteSequence) {
+		shift := sequence - e.remoteSequence
+		if shift > ackBitCount {
+			e.receivedBits = 0
+		} else {
+			e.receivedBits = e.receivedBits<<shift | 1<<(shift-1)
+		}
+
+		e.remoteSequence = sequence
+
+		return
+	}
+
+	if diff := e.remoteSequence - sequence; diff > 0 && diff <= ackBitCount {
+		e.receivedBits |= 1 << (diff - 1)
+	}
+}
+
+// acknowledge drops the reliable messages the peer has received.
+func (e *Endpoint) acknowledge(ack uint16, ackBits uint32) {
+	acked := func(sequence uint16) bool {
+		if sequence == ack {
+			return true
+		}
+
+		diff := ack - sequence
+
+		return diff > 0 && diff <= ackBitCount && ackBits&(1<<(diff-1)) != 0
+	}
+
+	pending := e.unacked[:0]
+
+	for _, message := range e.unacked {
+		received := false
+
+		for _, datagram := range message.datagrams {
+			if acked(datagram) {
+				received = true
+				break
+			}
+		}
+
+		if !received {
+			pending = append(pending, message)
+		}
+	}
+
+	e.unacked = pending
+}
+
+// deliverReliable buffers the message and returns every message which is
+// next in order.
+func (e *Endpoint) deliverReliable(sequence uint16, data []byte) [][]byte {
+	expected := e.nextDelivery + 1
+
+	if sequence != expected && !sequenceGreater(sequence, expected) {
+		return nil // already delivered
+	}
+
+	if sequence-expected >= reliableWindow {
+		return nil
+	}
+
+	e.outOfOrder[sequence] = data
+
+	var delivered [][]byte
+
+	for {
+		next, found := e.outOfOrder[e.nextDelivery+1]
+		if !found {
+			return delivered
+		}
+
+		delete(e.outOfOrder, e.nextDelivery+1)
+		e.nextDelivery++
+
+		delivered = append(delivered, next)
+	}
+}
+
+// sequenceGreater returns true if a is more recent than b, allowing for wrap around.
+func sequenceGreater(a, b uint16) bool {
+	const half = 1 << 15
+
+	return (a > b && a-b <= half) || (a < b && b-a > half)
+}
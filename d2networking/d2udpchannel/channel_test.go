@@ -0,0 +1,266 @@
+package d2udpchannel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+type testAddr string
+
+func (a testAddr) Network() string { return "test" }
+func (a testAddr) String() string  { return string(a) }
+
+// lossyConn is a net.PacketConn which drops and reorders the datagrams written to it.
+type lossyConn struct {
+	rand     *rand.Rand
+	lossRate float64
+	queue    [][]byte
+}
+
+func (c *lossyConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	if c.rand.Float64() < c.lossRate {
+		return len(p), nil
+	}
+
+	datagram := append([]byte(nil), p...)
+
+	if len(c.queue) > 0 && c.rand.Intn(4) == 0 {
+		i := c.rand.Intn(len(c.queue))
+		c.queue = append(c.queue[:i], append([][]byte{datagram}, c.queue[i:]...)...)
+
+		return len(p), nil
+	}
+
+	c.queue = append(c.queue, datagram)
+
+	return len(p), nil
+}
+
+func (c *lossyConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if len(c.queue) == 0 {
+		return 0, nil, fmt.Errorf("no datagram queued")
+	}
+
+	n := copy(p, c.queue[0])
+	c.queue = c.queue[1:]
+
+	return n, testAddr("peer"), nil
+}
+
+func (c *lossyConn) Close() error                     { return nil }
+func (c *lossyConn) LocalAddr() net.Addr              { return testAddr("local") }
+func (c *lossyConn) SetDeadline(time.Time) error      { return nil }
+func (c *lossyConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *lossyConn) SetWriteDeadline(time.Time) error { return nil }
+
+// drain passes every queued datagram of conn to the endpoint and returns the delivered messages.
+func drain(t *testing.T, conn *lossyConn, to *Endpoint) [][]byte {
+	var delivered [][]byte
+
+	buf := make([]byte, 1024)
+
+	for len(conn.queue) > 0 {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages, err := to.Receive(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		delivered = append(delivered, messages...)
+	}
+
+	return delivered
+}
+
+func newLossyPair(lossRate float64) (aConn, bConn *lossyConn, a, b *Endpoint) {
+	aConn = &lossyConn{rand: rand.New(rand.NewSource(1)), lossRate: lossRate}
+	bConn = &lossyConn{rand: rand.New(rand.NewSource(2)), lossRate: lossRate}
+
+	return aConn, bConn, NewEndpoint(aConn, testAddr("b")), NewEndpoint(bConn, testAddr("a"))
+}
+
+func TestReliableOverLossyConn(t *testing.T) {
+	const count = 200
+
+	aConn, bConn, a, b := newLossyPair(0.3)
+
+	for i := 0; i < count; i++ {
+		if err := a.Send(Reliable, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var received [][]byte
+
+	now := time.Now()
+
+	for tick := 0; tick < 1000 && (len(received) < count || a.Unacknowledged() > 0); tick++ {
+		received = append(received, drain(t, aConn, b)...)
+		drain(t, bConn, a)
+
+		now = now.Add(DefaultResendTimeout)
+
+		if err := a.Update(now); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.Update(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != count {
+		t.Fatalf("expected %d messages, got %d", count, len(received))
+	}
+
+	for i, message := range received {
+		if want := []byte(fmt.Sprint(i)); !bytes.Equal(message, want) {
+			t.Fatalf("expected message %d to be %s, got %s", i, want, message)
+		}
+	}
+
+	if n := a.Unacknowledged(); n != 0 {
+		t.Errorf("expected every message to be acknowledged, %d are pending", n)
+	}
+}
+
+func TestUnreliableIsSequenced(t *testing.T) {
+	aConn, _, a, b := newLossyPair(0.3)
+
+	for i := 0; i < 100; i++ {
+		if err := a.Send(Unreliable, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := drain(t, aConn, b)
+	if len(received) == 0 || len(received) == 100 {
+		t.Fatalf("expected some but not all of the messages, got %d", len(received))
+	}
+
+	for i := 1; i < len(received); i++ {
+		if received[i][0] <= received[i-1][0] {
+			t.Fatalf("message %d was delivered after message %d", received[i][0], received[i-1][0])
+		}
+	}
+
+	if a.Unacknowledged() != 0 {
+		t.Error("unreliable messages should not wait for acknowledgements")
+	}
+}
+
+func TestSequenceGreaterWrapsAround(t *testing.T) {
+	if !sequenceGreater(1, 65535) {
+		t.Error("expected 1 to be more recent than 65535")
+	}
+
+	if sequenceGreater(65535, 1) {
+		t.Error("expected 65535 to be older than 1")
+	}
+}
+
+func TestUnreliableKeepsItsPlace(t *testing.T) {
+	aConn, _, a, b := newLossyPair(0)
+
+	for _, channel := range []Channel{Reliable, Unreliable, Reliable, Unreliable} {
+		if err := a.Send(channel, []byte{byte(channel)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first reliable message is lost, the unreliable message after it is dropped
+	aConn.queue = aConn.queue[1:]
+
+	if received := drain(t, aConn, b); len(received) != 0 {
+		t.Fatalf("expected nothing before the first reliable message, got %d messages", len(received))
+	}
+
+	if err := a.Update(time.Now().Add(DefaultResendTimeout)); err != nil {
+		t.Fatal(err)
+	}
+
+	received := drain(t, aConn, b)
+	if len(received) != 2 || received[0][0] != byte(Reliable) || received[1][0] != byte(Reliable) {
+		t.Fatalf("expected both reliable messages and no unreliable one, got %v", received)
+	}
+}
+
+func TestUpdateGivesUpAfterMaxResends(t *testing.T) {
+	_, _, a, _ := newLossyPair(1)
+	a.MaxResends = 3
+
+	if err := a.Send(Reliable, []byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	for resend := 0; resend < a.MaxResends; resend++ {
+		now = now.Add(DefaultResendTimeout)
+
+		if err := a.Update(now); err != nil {
+			t.Fatalf("resend %d: %v", resend, err)
+		}
+	}
+
+	if err := a.Update(now.Add(DefaultResendTimeout)); !errors.Is(err, ErrPeerUnreachable) {
+		t.Fatalf("expected %v, got %v", ErrPeerUnreachable, err)
+	}
+
+	if err := a.Send(Unreliable, nil); !errors.Is(err, ErrPeerUnreachable) {
+		t.Errorf("expected sending to a dead peer to fail, got %v", err)
+	}
+}
+
+func TestUpdateGivesUpOnSilentPeer(t *testing.T) {
+	aConn, bConn, a, b := newLossyPair(0)
+	now := time.Now()
+
+	// idle endpoints keep each other alive
+	for elapsed := time.Duration(0); elapsed < 2*DefaultPeerTimeout; elapsed += keepAliveInterval {
+		if err := a.Update(now.Add(elapsed)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.Update(now.Add(elapsed)); err != nil {
+			t.Fatal(err)
+		}
+
+		drain(t, aConn, b)
+		drain(t, bConn, a)
+	}
+
+	// b is gone
+	silent := now.Add(2 * DefaultPeerTimeout)
+
+	if err := a.Update(silent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Update(silent.Add(DefaultPeerTimeout + time.Second)); !errors.Is(err, ErrPeerUnreachable) {
+		t.Fatalf("expected %v, got %v", ErrPeerUnreachable, err)
+	}
+}
+
+func TestSendGivesUpOnFullBacklog(t *testing.T) {
+	_, _, a, _ := newLossyPair(1)
+
+	for i := 0; i < reliableWindow; i++ {
+		if err := a.Send(Reliable, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.Send(Reliable, nil); !errors.Is(err, ErrPeerUnreachable) {
+		t.Fatalf("expected %v, got %v", ErrPeerUnreachable, err)
+	}
+}
@@ -0,0 +1,487 @@
+package d2udpchannel
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxMessageSize is the largest message sent in one datagram, small enough
+	// not to be fragmented on the way. Longer reliable writes are split, longer
+	// unreliable writes fail.
+	MaxMessageSize = 1200
+
+	// maxDatagramSize is the largest UDP payload.
+	maxDatagramSize = 65507
+
+	// updateInterval is how often the endpoint of a connection is updated.
+	updateInterval = DefaultResendTimeout / 2
+
+	// acceptBacklog is how many new peers may wait for Accept. Datagrams of
+	// further new peers are dropped.
+	acceptBacklog = 16
+)
+
+var errMessageTooLarge = errors.New("message is larger than MaxMessageSize")
+
+// Conn is a net.Conn over the channels to one peer. Writes are sent on the
+// reliable channel and Read returns the delivered messages in order, so a
+// stream of packets reads back as it does over TCP, as long as each packet is
+// written with one Write. Unreliable returns a writer for packets which may be
+// lost. Once the peer is gone, Read and Write return ErrPeerUnreachable.
+type Conn struct {
+	endpoint *Endpoint
+	local    net.Addr
+	onClose  func() error
+
+	writeMutex sync.Mutex // Keeps the parts of a reliable write together
+
+	mutex        sync.Mutex
+	messages     [][]byte      // Delivered messages which were not read yet
+	ready        chan struct{} // Signalled when a message is queued or the deadline changes
+	done         chan struct{} // Closed once the connection is closed
+	closed       bool          // Closed by Close, the endpoint is updated until it is flushed
+	err          error         // Why the connection was closed
+	readDeadline time.Time
+}
+
+func newConn(endpoint *Endpoint, local net.Addr, onClose func() error) *Conn {
+	c := &Conn{
+		endpoint: endpoint,
+		local:    local,
+		onClose:  onClose,
+		ready:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go c.update()
+
+	return c
+}
+
+// Dial connects to the listener at the given UDP address. There is no
+// handshake: if no listener answers, the connection closes with
+// ErrPeerUnreachable once the first write was resent DefaultMaxResends times.
+func Dial(address string) (*Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	packetConn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConn(NewEndpoint(packetConn, addr), packetConn.LocalAddr(), packetConn.Close)
+
+	go c.readFrom(packetConn)
+
+	return c, nil
+}
+
+// readFrom passes the datagrams of the peer to the connection until the packet
+// connection is closed.
+func (c *Conn) readFrom(packetConn net.PacketConn) {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := packetConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		if addr.String() == c.endpoint.Addr().String() {
+			c.receive(buf[:n])
+		}
+	}
+}
+
+// receive passes a datagram of the peer to the endpoint and queues the
+// delivered messages. Malformed datagrams are dropped.
+func (c *Conn) receive(datagram []byte) {
+	messages, err := c.endpoint.Receive(datagram)
+	if err != nil || len(messages) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	c.messages = append(c.messages, messages...)
+	c.mutex.Unlock()
+
+	c.signal()
+}
+
+// update updates the endpoint until the peer is gone, or the connection was
+// closed and all its reliable messages were acknowledged, like TCP lingers.
+func (c *Conn) update() {
+	ticker := time.NewTicker(updateInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := c.endpoint.Update(now); err != nil {
+			c.fail(err)
+			break
+		}
+
+		c.mutex.Lock()
+		closed := c.closed
+		c.mutex.Unlock()
+
+		if closed && c.endpoint.Unacknowledged() == 0 {
+			break
+		}
+	}
+
+	if c.onClose != nil {
+		_ = c.onClose()
+	}
+}
+
+// Read reads the delivered messages, in order.
+func (c *Conn) Read(p []byte) (int, error) {
+	for {
+		c.mutex.Lock()
+
+		if len(c.messages) > 0 {
+			n := copy(p, c.messages[0])
+
+			if c.messages[0] = c.messages[0][n:]; len(c.messages[0]) == 0 {
+				c.messages = c.messages[1:]
+			}
+
+			c.mutex.Unlock()
+
+			return n, nil
+		}
+
+		err, deadline := c.err, c.readDeadline
+		c.mutex.Unlock()
+
+		if err != nil {
+			return 0, err
+		}
+
+		if err := c.wait(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// wait waits until a message is queued, the connection is closed or the
+// deadline changes, and fails once the deadline passed.
+func (c *Conn) wait(deadline time.Time) error {
+	if deadline.IsZero() {
+		select {
+		case <-c.ready:
+		case <-c.done:
+		}
+
+		return nil
+	}
+
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return errTimeout{}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-c.ready:
+	case <-c.done:
+	case <-timer.C:
+	}
+
+	return nil
+}
+
+// Write sends the data on the reliable channel, split in messages of at most
+// MaxMessageSize.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	for written := 0; written < len(p); {
+		size := len(p) - written
+		if size > MaxMessageSize {
+			size = MaxMessageSize
+		}
+
+		if err := c.send(Reliable, p[written:written+size]); err != nil {
+			return written, err
+		}
+
+		written += size
+	}
+
+	return len(p), nil
+}
+
+// Unreliable returns a writer which sends each write as one message on the
+// unreliable channel. Writes longer than MaxMessageSize fail.
+func (c *Conn) Unreliable() io.Writer {
+	return unreliableWriter{c}
+}
+
+type unreliableWriter struct {
+	c *Conn
+}
+
+func (w unreliableWriter) Write(p []byte) (int, error) {
+	if len(p) > MaxMessageSize {
+		return 0, errMessageTooLarge
+	}
+
+	w.c.writeMutex.Lock()
+	defer w.c.writeMutex.Unlock()
+
+	if err := w.c.send(Unreliable, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// send sends a copy of the message, as the endpoint keeps reliable messages
+// until they are acknowledged.
+func (c *Conn) send(channel Channel, message []byte) error {
+	c.mutex.Lock()
+	err := c.err
+	c.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.endpoint.Send(channel, append([]byte(nil), message...)); err != nil {
+		if errors.Is(err, ErrPeerUnreachable) {
+			c.fail(err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connection. Messages which were not acknowledged yet are
+// still resent in the background, until the peer acknowledges them or is gone.
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+
+	if !c.fail(net.ErrClosed) {
+		return net.ErrClosed
+	}
+
+	return nil
+}
+
+// fail fails the reads and writes of the connection with the given error, and
+// returns false if they failed already.
+func (c *Conn) fail(err error) bool {
+	c.mutex.Lock()
+
+	if c.err != nil {
+		c.mutex.Unlock()
+		return false
+	}
+
+	c.err = err
+	c.mutex.Unlock()
+
+	close(c.done)
+
+	return true
+}
+
+func (c *Conn) signal() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// LocalAddr returns the local address of the connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.endpoint.Addr()
+}
+
+// SetDeadline sets the read deadline, writes do not block.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the time after which Read fails with a timeout error.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.readDeadline = t
+	c.mutex.Unlock()
+
+	c.signal()
+
+	return nil
+}
+
+// SetWriteDeadline does nothing, writes do not block.
+func (c *Conn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// errTimeout is the net.Error of a read past the deadline.
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+// Listener is a net.Listener which accepts a Conn for every address it
+// receives a reliable message from.
+type Listener struct {
+	conn   net.PacketConn
+	accept chan *Conn
+	done   chan struct{}
+
+	mutex  sync.Mutex
+	peers  map[string]*Conn
+	closed bool
+}
+
+// Listen listens on the given UDP address.
+func Listen(address string) (*Listener, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListener(conn), nil
+}
+
+// NewListener accepts the peers sending to conn. The listener closes conn when
+// it is closed.
+func NewListener(conn net.PacketConn) *Listener {
+	l := &Listener{
+		conn:   conn,
+		accept: make(chan *Conn, acceptBacklog),
+		done:   make(chan struct{}),
+		peers:  make(map[string]*Conn),
+	}
+
+	go l.read()
+
+	return l
+}
+
+// read passes the datagrams to the connection of their peer until the listener
+// is closed.
+func (l *Listener) read() {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		if c := l.peer(addr, buf[:n]); c != nil {
+			c.receive(buf[:n])
+		}
+	}
+}
+
+// peer returns the connection of the address. A new peer is accepted if its
+// datagram carries a reliable message, the first message of a peer always
+// does; other datagrams are left over from closed connections.
+func (l *Listener) peer(addr net.Addr, datagram []byte) *Conn {
+	l.mutex.Lock()
+
+	key := addr.String()
+
+	if c, found := l.peers[key]; found || l.closed || len(datagram) == 0 ||
+		Channel(datagram[0]&^flagAck) != Reliable {
+		l.mutex.Unlock()
+		return c
+	}
+
+	if len(l.accept) == cap(l.accept) {
+		l.mutex.Unlock()
+		return nil // too many peers wait for Accept
+	}
+
+	c := newConn(NewEndpoint(l.conn, addr), l.conn.LocalAddr(), func() error {
+		l.remove(key)
+		return nil
+	})
+
+	l.peers[key] = c
+	l.accept <- c
+	l.mutex.Unlock()
+
+	return c
+}
+
+func (l *Listener) remove(key string) {
+	l.mutex.Lock()
+	delete(l.peers, key)
+	l.mutex.Unlock()
+}
+
+// Accept waits for a new peer and returns its connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting peers and closes the connections of the peers.
+func (l *Listener) Close() error {
+	l.mutex.Lock()
+
+	if l.closed {
+		l.mutex.Unlock()
+		return net.ErrClosed
+	}
+
+	l.closed = true
+	close(l.done)
+
+	peers := make([]*Conn, 0, len(l.peers))
+	for _, c := range l.peers {
+		peers = append(peers, c)
+	}
+
+	l.mutex.Unlock()
+
+	for _, c := range peers {
+		_ = c.Close()
+	}
+
+	return l.conn.Close()
+}
+
+// Addr returns the address the listener listens on.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
@@ -0,0 +1,135 @@
+package d2udpchannel
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// setMaxResends changes the resends of the endpoint of a connection, which is
+// being updated.
+func setMaxResends(c *Conn, maxResends int) {
+	c.endpoint.mutex.Lock()
+	c.endpoint.MaxResends = maxResends
+	c.endpoint.mutex.Unlock()
+}
+
+func TestConnOverLoopback(t *testing.T) {
+	listener, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	client, err := Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	// longer than a message, so it is split
+	long := bytes.Repeat([]byte("0123456789"), MaxMessageSize/5)
+	stream := append(append([]byte("first"), long...), "last"...)
+
+	if _, err := client.Write([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Write(long); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Unreliable().Write([]byte("last")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make([]byte, len(stream))
+	if _, err := io.ReadFull(conn, received); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received, stream) {
+		t.Fatalf("expected the stream to read back as written")
+	}
+
+	if _, err := conn.Write([]byte("answer")); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := make([]byte, len("answer"))
+	if _, err := io.ReadFull(client, answer); err != nil || string(answer) != "answer" {
+		t.Fatalf("expected the answer, got %q: %v", answer, err)
+	}
+}
+
+func TestDialNobody(t *testing.T) {
+	// a port nobody listens on
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := packetConn.LocalAddr().String()
+
+	if err := packetConn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setMaxResends(client, 2)
+
+	if _, err := client.Write([]byte("anyone?")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, ErrPeerUnreachable) {
+		t.Fatalf("expected %v, got %v", ErrPeerUnreachable, err)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	listener, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	client, err := Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	if err := client.SetReadDeadline(time.Now().Add(updateInterval)); err != nil {
+		t.Fatal(err)
+	}
+
+	var netErr net.Error
+	if _, err := client.Read(make([]byte, 1)); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
@@ -1,11 +1,22 @@
 package d2networking
 
 import (
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/OpenDiablo2/OpenDiablo2/d2common/d2util"
 	"github.com/OpenDiablo2/OpenDiablo2/d2core/d2asset"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2admin"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2discovery"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2metrics"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netpacket"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2netsim"
 	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2server"
+	"github.com/OpenDiablo2/OpenDiablo2/d2networking/d2tls"
 )
 
 // ServerEventFlag represents a server event
@@ -22,6 +33,24 @@ const (
 	ServerMaxPlayersDefault = 8
 )
 
+// stalledSimulation is how long the games can go without a simulated tick before the
+// server is unhealthy.
+const stalledSimulation = 5 * time.Second
+
+// DedicatedServerConfig configures a dedicated server.
+type DedicatedServerConfig struct {
+	MaxPlayers  int
+	Conditions  d2netsim.Conditions // Simulated network conditions of remote clients
+	BanFile     string              // File the banned IP addresses are kept in
+	AdminSocket string              // Unix socket of the admin console, none if empty
+	TLS         bool                // Clients connect over TLS
+	CertFile    string              // PEM certificate of the server, generated if it does not exist
+	KeyFile     string              // PEM private key of the certificate
+	Password    string              // Players need it to join any game, if it is set
+	SaveDir     string              // Directory the characters of the players are kept in
+	Metrics     string              // HTTP address the metrics are served on, none if empty
+}
+
 func hasFlag(value, flag int) bool {
 	return (value & flag) == flag
 }
@@ -29,30 +58,94 @@ func hasFlag(value, flag int) bool {
 /*
 StartDedicatedServer Checks whether or not we should start a server i.e the -listen parameter has been passed in, and if so launches a
 server hosted to the network, in theory. (this is still WIP)
+The server is a lobby, which hosts the games the clients create.
 */
 func StartDedicatedServer(
 	manager *d2asset.AssetManager,
 	in chan int,
 	log chan string,
 	l d2util.LogLevel,
-	maxPlayers int,
+	config DedicatedServerConfig,
 ) error {
-	server, err := d2server.NewGameServer(manager, true, l, maxPlayers)
+	server, err := d2server.NewLobby(manager, l, config.MaxPlayers)
 	if err != nil {
 		return err
 	}
 
+	server.SimulateNetwork(config.Conditions)
+	server.SetPassword(config.Password)
+
+	if err = server.LoadBanList(config.BanFile); err != nil {
+		return err
+	}
+
+	if err = server.KeepCharacters(config.SaveDir); err != nil {
+		return fmt.Errorf("can not keep the characters in %s: %w", config.SaveDir, err)
+	}
+
+	if config.TLS {
+		if err = enableTLS(server, config.CertFile, config.KeyFile); err != nil {
+			return err
+		}
+	}
+
+	metrics := d2metrics.New()
+	if config.Metrics != "" {
+		server.EnableMetrics(metrics)
+	}
+
 	err = server.Start()
 	if err != nil {
 		return err
 	}
 
+	var metricsServer *http.Server
+
+	if config.Metrics != "" {
+		if metricsServer, err = serveMetrics(metrics, server, manager, config.Metrics); err != nil {
+			return fmt.Errorf("can not serve the metrics on %s: %w", config.Metrics, err)
+		}
+	}
+
+	responder, err := startDiscoveryResponder(server, config.MaxPlayers, config.TLS)
+	if err != nil {
+		server.Warningf("LAN discovery is not available: %s", err)
+	}
+
+	console := d2admin.NewConsole(&adminServer{Lobby: server, events: in})
+
+	go func() {
+		if err := console.Serve(os.Stdin, os.Stdout); err != nil {
+			server.Warningf("admin console stopped: %s", err)
+		}
+	}()
+
+	var adminListener net.Listener
+
+	if config.AdminSocket != "" {
+		if adminListener, err = console.ListenUnix(config.AdminSocket); err != nil {
+			server.Warningf("admin socket is not available: %s", err)
+		}
+	}
+
 	for {
 		msgIn := <-in
 		if hasFlag(msgIn, ServerEventStop) {
 			log <- "Stopping server"
 
-			server.Stop()
+			if responder != nil {
+				_ = responder.Close()
+			}
+
+			if adminListener != nil {
+				_ = adminListener.Close()
+			}
+
+			if metricsServer != nil {
+				_ = metricsServer.Close()
+			}
+
+			server.Shutdown()
 			log <- "Exiting..."
 
 			os.Exit(0)
@@ -60,8 +153,140 @@ func StartDedicatedServer(
 	}
 }
 
+// adminServer is the server the admin console acts on.
+type adminServer struct {
+	*d2server.Lobby
+	events chan int
+}
+
+// ScheduleShutdown stops the server after the delay, warning the players first.
+func (a *adminServer) ScheduleShutdown(delay time.Duration) {
+	if delay > 0 {
+		if err := a.Say(fmt.Sprintf("The server shuts down in %s", delay)); err != nil {
+			a.Warningf("failed to announce the shutdown: %s", err)
+		}
+	}
+
+	time.AfterFunc(delay, func() {
+		a.events <- ServerEventStop
+	})
+}
+
+// enableTLS loads the certificate of the server, or generates a self-signed one, and
+// makes the clients connect over TLS. Players can check the logged fingerprint against
+// the one their client pinned.
+func enableTLS(server *d2server.Lobby, certFile, keyFile string) error {
+	hosts := []string{"localhost", "127.0.0.1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+
+	certificate, err := d2tls.LoadOrCreateCertificate(certFile, keyFile, hosts)
+	if err != nil {
+		return fmt.Errorf("can not load the TLS certificate: %w", err)
+	}
+
+	server.Infof("TLS certificate fingerprint %s", d2tls.Fingerprint(certificate.Certificate[0]))
+	server.EnableTLS(d2tls.ServerConfig(certificate))
+
+	return nil
+}
+
+// serveMetrics serves the metrics of the server, and of the asset caches, at the HTTP address.
+func serveMetrics(metrics *d2metrics.Metrics, server *d2server.Lobby, manager *d2asset.AssetManager,
+	address string) (*http.Server, error) {
+	metrics.Gauge("players", "Players in the games.", func() float64 {
+		return float64(server.PlayerCount())
+	})
+
+	metrics.Gauge("games", "Games hosted by the server.", func() float64 {
+		return float64(len(server.Games()))
+	})
+
+	metrics.LabelGauge("asset_cache_weight", "Weight of the assets held by each cache of the asset manager.",
+		"cache", func() map[string]float64 {
+			weights := make(map[string]float64)
+
+			for name, cache := range manager.Caches() {
+				weights[name] = float64(cache.GetWeight())
+			}
+
+			return weights
+		})
+
+	metrics.LabelGauge("asset_cache_budget", "Weight each cache of the asset manager can hold.",
+		"cache", func() map[string]float64 {
+			budgets := make(map[string]float64)
+
+			for name, cache := range manager.Caches() {
+				budgets[name] = float64(cache.GetBudget())
+			}
+
+			return budgets
+		})
+
+	metrics.Check("simulation", func() error {
+		if len(server.Games()) == 0 {
+			return nil
+		}
+
+		if since := time.Since(metrics.LastTick()); since > stalledSimulation {
+			return fmt.Errorf("no tick was simulated for %s", since.Round(time.Second))
+		}
+
+		return nil
+	})
+
+	httpServer, err := metrics.Serve(address)
+	if err != nil {
+		return nil, err
+	}
+
+	server.Infof("Serving metrics on http://%s/metrics", address)
+
+	return httpServer, nil
+}
+
+// startDiscoveryResponder answers LAN discovery beacons with a description of the server.
+func startDiscoveryResponder(server *d2server.Lobby, maxPlayers int, useTLS bool) (*d2discovery.Responder, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "OpenDiablo2"
+	}
+
+	responder, err := d2discovery.NewResponder(":"+strconv.Itoa(d2discovery.Port), func() d2discovery.ServerInfo {
+		return d2discovery.ServerInfo{
+			Name:            name,
+			Port:            d2server.Port,
+			Players:         server.PlayerCount(),
+			MaxPlayers:      maxPlayers,
+			Difficulty:      server.Difficulty(),
+			ProtocolVersion: d2netpacket.ProtocolVersion,
+			TLS:             useTLS,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_ = responder.Serve() // returns once the responder is closed
+	}()
+
+	return responder, nil
+}
+
 // ServerOptions represents game server options
 type ServerOptions struct {
-	Dedicated  *bool
-	MaxPlayers *int
+	Dedicated   *bool
+	MaxPlayers  *int
+	NetSim      *string // Simulated network conditions, see d2netsim.ParseConditions
+	BanFile     *string
+	AdminSocket *string
+	TLS         *bool
+	CertFile    *string
+	KeyFile     *string
+	Password    *string
+	SaveDir     *string
+	Metrics     *string
 }